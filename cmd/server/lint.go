@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// runLint implements `vault-pilot lint`. It returns the process exit code:
+// 0 when the vault is clean, 1 when issues were found, 2 on errors.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	vaultPath := fs.String("vault", "", "Path to Obsidian Vault")
+	inboxDays := fs.Int("inbox-days", 7, "Report inbox items older than this many days")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON instead of Markdown")
+	write := fs.Bool("write", false, "Write the report to "+vault.HealthReportPath)
+	fs.Parse(args)
+
+	if *vaultPath == "" {
+		fmt.Fprintln(os.Stderr, "Please provide -vault path")
+		return 2
+	}
+
+	index := vault.NewIndex(*vaultPath, nil)
+	if err := index.Scan(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to scan vault: %v\n", err)
+		return 2
+	}
+	report := vault.Lint(index, vault.LintOptions{InboxMaxAge: time.Duration(*inboxDays) * 24 * time.Hour})
+
+	if *write {
+		if err := vault.WriteHealthReport(*vaultPath, report); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+			return 2
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		fmt.Print(report.Markdown())
+	}
+
+	if len(report.Issues) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -8,12 +8,17 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/mklimuk/vault-pilot/pkg/ai"
 	"github.com/mklimuk/vault-pilot/pkg/api"
+	"github.com/mklimuk/vault-pilot/pkg/assistant"
 	"github.com/mklimuk/vault-pilot/pkg/automation"
 	"github.com/mklimuk/vault-pilot/pkg/db"
 	"github.com/mklimuk/vault-pilot/pkg/integration/calendar"
@@ -22,15 +27,25 @@ import (
 	"github.com/mklimuk/vault-pilot/pkg/integration/gmail"
 	googleauth "github.com/mklimuk/vault-pilot/pkg/integration/google"
 	"github.com/mklimuk/vault-pilot/pkg/integration/telegram"
+	"github.com/mklimuk/vault-pilot/pkg/semantic"
 	"github.com/mklimuk/vault-pilot/pkg/sync"
 	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(runLint(os.Args[2:]))
+	}
+
 	vaultPath := flag.String("vault", "", "Path to Obsidian Vault")
 	dbPath := flag.String("db", "vault-pilot.db", "Path to SQLite DB")
 	port := flag.String("port", "8080", "HTTP Port")
-	aiProvider := flag.String("ai-provider", "gemini", "AI provider: gemini, moonshot, openai, or anthropic")
+	aiProvider := flag.String("ai-provider", "gemini", "AI provider: gemini, moonshot, openai, anthropic, openai-compatible, ollama, or rules; a comma-separated list (e.g. anthropic,openai,gemini) falls back in order")
+	aiTimeout := flag.Duration("ai-timeout", 0, "Timeout for each AI request (0 means none)")
+	aiBudget := flag.Float64("ai-monthly-budget", 0, "Monthly AI budget in USD; calls fail once it is spent (0 means no limit)")
+	aiPrices := flag.String("ai-prices", "", "JSON file with AI model prices in USD per million tokens, overriding the defaults")
+	assistantSteps := flag.Int("assistant-max-steps", assistant.DefaultMaxSteps, "Model calls the chat assistant may make to answer one message")
+	embeddingProvider := flag.String("embedding-provider", "", "Embedding provider for semantic search: openai, openai-compatible, ollama, gemini, hash, or none (default: hash, which keeps notes local)")
 	flag.Parse()
 
 	if *vaultPath == "" {
@@ -50,52 +65,89 @@ func main() {
 
 	repo := db.NewRepository(database)
 
-	// Initialize AI Client
-	var aiClient ai.Generator
-	switch *aiProvider {
-	case "moonshot":
-		key := os.Getenv("MOONSHOT_API_KEY")
-		if key == "" {
-			log.Fatal("MOONSHOT_API_KEY environment variable is required when using moonshot provider")
-		}
-		aiClient = ai.NewMoonshotClient(key)
-	case "openai":
-		key := os.Getenv("OPENAI_API_KEY")
-		if key == "" {
-			log.Fatal("OPENAI_API_KEY environment variable is required when using openai provider")
-		}
-		aiClient = ai.NewOpenAIClient(key)
-	case "anthropic":
-		key := os.Getenv("ANTHROPIC_API_KEY")
-		if key == "" {
-			log.Fatal("ANTHROPIC_API_KEY environment variable is required when using anthropic provider")
-		}
-		aiClient = ai.NewAnthropicClient(key)
-	case "gemini":
-		key := os.Getenv("GEMINI_API_KEY")
-		if key == "" {
-			log.Fatal("GEMINI_API_KEY environment variable is required when using gemini provider")
-		}
-		ctx := context.Background()
-		geminiClient, err := ai.NewClient(ctx, key)
-		if err != nil {
-			log.Fatalf("Failed to create AI client: %v", err)
-		}
-		defer geminiClient.Close()
-		aiClient = geminiClient
-	default:
-		log.Fatalf("Unknown AI provider: %s", *aiProvider)
+	// Initialize AI Client. Several providers form an ordered fallback chain.
+	aiClient, closeAI, err := newAIClient(context.Background(), *aiProvider, *aiTimeout)
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer closeAI()
+	prices, err := loadPrices(*aiPrices)
+	if err != nil {
+		log.Fatal(err)
+	}
+	meteredAI := ai.NewMeteredGenerator(aiClient, repo, prices)
+	meteredAI.MonthlyBudget = *aiBudget
+	aiClient = meteredAI
 
 	// Initialize Template Engine
 	templateDir := filepath.Join(*vaultPath, "0. GTD System", "Templates")
 	tmplEngine := vault.NewTemplateEngine(templateDir)
 
+	// Prompt templates live in the vault so they can be edited in Obsidian
+	promptDir := filepath.Join(*vaultPath, ai.PromptDir)
+	prompts := ai.NewPromptLibrary(promptDir)
+
 	// Initialize Git Manager
 	gitManager := sync.NewGitManager(*vaultPath)
 
+	// Copy the default prompts into the vault so they can be edited
+	if n, err := ai.InstallPrompts(promptDir); err != nil {
+		log.Printf("Failed to install default prompts: %v", err)
+	} else if n > 0 {
+		log.Printf("Installed %d default prompt(s) in %s", n, promptDir)
+		go gitManager.Sync("Add default AI prompts")
+	}
+
+	// Initialize Vault Index
+	index := vault.NewIndex(*vaultPath, repo)
+	if err := index.Load(); err != nil {
+		log.Printf("Failed to load vault index: %v", err)
+	}
+	// The watcher keeps the index current; the periodic rescan only catches
+	// anything the watcher missed (e.g. inotify queue overflow).
+	if err := index.Start(30 * time.Minute); err != nil {
+		log.Fatalf("Failed to start vault index: %v", err)
+	}
+	defer index.Stop()
+	log.Printf("Vault index ready (%d notes)", len(index.All()))
+
+	// Initialize Vault Watcher. The index subscribes first so later
+	// subscribers see fresh entries.
+	vaultWatcher := vault.NewWatcher(*vaultPath, 2*time.Second)
+	vaultWatcher.Subscribe(index.HandleChanges)
+
+	// Initialize Semantic Index. Retrieved notes ground the AI prompts.
+	embedder, closeEmbedder, err := newEmbedder(context.Background(), *embeddingProvider, *aiTimeout, meteredAI)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeEmbedder()
+	var search *semantic.Index
+	if embedder != nil {
+		search = semantic.NewIndex(index, embedder, repo)
+		if err := search.Load(); err != nil {
+			log.Printf("Failed to load semantic index: %v", err)
+		}
+		search.Start(30 * time.Minute)
+		defer search.Stop()
+		vaultWatcher.Subscribe(search.HandleChanges)
+		prompts.Retriever = search
+		log.Printf("Semantic index using %s (%d passages)", embedder.EmbeddingModel(), search.Len())
+	}
+
+	// Initialize the vault writers, shared by the API and the assistant
+	writers := api.NewWriters(repo, index, tmplEngine, gitManager)
+
+	// Initialize the chat assistant, shared by the API and the chat bots
+	assistantCommit := func(message string) error {
+		return writers.Commit("Assistant: " + message)
+	}
+	tools := assistant.NewVaultRegistry(index, tmplEngine, assistantCommit, writers.Completer)
+	chat := assistant.New(aiClient, tools, prompts, repo)
+	chat.MaxSteps = *assistantSteps
+
 	// Initialize Router
-	router := api.NewRouter(repo, aiClient, tmplEngine, *vaultPath, gitManager)
+	router := api.NewRouter(repo, aiClient, tmplEngine, *vaultPath, gitManager, index, search, chat, writers, prompts)
 
 	// Google service account key — shared by Calendar, Drive, and Gmail
 	googleKeyFile := os.Getenv("GOOGLE_SERVICE_ACCOUNT_KEY")
@@ -109,12 +161,13 @@ func main() {
 		if err != nil {
 			log.Printf("Failed to create Calendar service: %v", err)
 		} else {
-			calSyncer := calendar.NewSyncer(calSvc, repo, *vaultPath, tmplEngine, gitManager,
+			calSyncer := calendar.NewSyncer(calSvc, repo, *vaultPath, tmplEngine, gitManager, index,
 				15*time.Minute, 14*24*time.Hour)
 			if err := calSyncer.Start(); err != nil {
 				log.Printf("Failed to start Calendar syncer: %v", err)
 			} else {
 				log.Println("Google Calendar sync started")
+				vaultWatcher.Subscribe(calSyncer.HandleChanges)
 				defer calSyncer.Stop()
 			}
 		}
@@ -128,11 +181,12 @@ func main() {
 		if err != nil {
 			log.Printf("Failed to create Drive backup service: %v", err)
 		} else {
-			backup := drive.NewBackup(drvSvc, repo, *vaultPath, 30*time.Minute)
+			backup := drive.NewBackup(drvSvc, repo, *vaultPath, index, 30*time.Minute)
 			if err := backup.Start(); err != nil {
 				log.Printf("Failed to start Drive backup: %v", err)
 			} else {
 				log.Println("Google Drive backup started")
+				vaultWatcher.Subscribe(backup.HandleChanges)
 				defer backup.Stop()
 			}
 		}
@@ -176,6 +230,7 @@ func main() {
 
 	automationService := automation.NewService(repo, 15*time.Second, 10)
 	automationService.RegisterAction("pull_gmail", func(ctx context.Context, def db.AutomationDefinition) (string, error) {
+		ctx = ai.WithFeature(ctx, "email_triage")
 		if gmailSvc == nil {
 			return "", fmt.Errorf("gmail service is not configured")
 		}
@@ -196,17 +251,24 @@ func main() {
 				subject = "Email Item"
 			}
 			body := gmail.GetBody(msg)
-			prompt := ai.AnalyzeInboxPrompt(fmt.Sprintf("Subject: %s\nBody: %s", subject, body))
-			analysisJSON, err := aiClient.GenerateText(ctx, prompt)
+			msgCtx, usage := ai.WithUsageTracker(ctx)
+			analysis, prompt, err := prompts.AnalyzeInbox(msgCtx, aiClient, fmt.Sprintf("Subject: %s\nBody: %s", subject, body))
 			if err != nil {
 				log.Printf("pull_gmail: AI analysis failed for subject=%q: %v", subject, err)
 				continue
 			}
-			content := fmt.Sprintf("AI Analysis:\n%s\n\nOriginal:\n%s", analysisJSON, body)
-			if err := vault.CreateInboxItem(*vaultPath, tmplEngine, subject, content); err != nil {
+			content := fmt.Sprintf("%s\n\n- Type: %s\n- Priority: %s\n- Context: %s\n\nOriginal:\n%s",
+				analysis.Description, analysis.Type, analysis.Priority, analysis.Context, body)
+			path, err := vault.CreateInboxNote(*vaultPath, tmplEngine, subject, content)
+			if err != nil {
 				log.Printf("pull_gmail: failed to create inbox item for subject=%q: %v", subject, err)
 				continue
 			}
+			if err := vault.EditFrontmatter(filepath.Join(*vaultPath, path), func(e *vault.FrontmatterEditor) error {
+				return e.SetGeneratedBy(prompt.Ref(), usage.Model())
+			}); err != nil {
+				log.Printf("pull_gmail: failed to record prompt version on %s: %v", path, err)
+			}
 			created++
 		}
 		if created > 0 && gitManager != nil {
@@ -234,11 +296,12 @@ func main() {
 		}
 
 		now := time.Now()
-		prompt := fmt.Sprintf(
-			"Generate a concise daily vault summary for %s with sections: Wins, Open Loops, Risks, and Top 3 Priorities.",
-			now.Format("2006-01-02"),
-		)
-		summary, err := aiClient.GenerateText(ctx, prompt)
+		ctx, usage := ai.WithUsageTracker(ai.WithFeature(ctx, "daily_summary"))
+		prompt, err := prompts.DailySummaryPrompt(ctx, now.Format("2006-01-02"), recentNotes(index, now.Add(-24*time.Hour), targetFolder))
+		if err != nil {
+			return "", err
+		}
+		summary, err := aiClient.Generate(prompt.Context(ctx), prompt.Request())
 		if err != nil {
 			return "", fmt.Errorf("generate summary: %w", err)
 		}
@@ -248,8 +311,18 @@ func main() {
 		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 			return "", fmt.Errorf("create folder: %w", err)
 		}
-		content := fmt.Sprintf("# %s\n\nDate: %s\n\n%s\n", title, now.Format("2006-01-02"), summary)
-		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		note, err := vault.ParseFrontmatter([]byte(fmt.Sprintf("# %s\n\nDate: %s\n\n%s\n", title, now.Format("2006-01-02"), summary)))
+		if err != nil {
+			return "", fmt.Errorf("build summary: %w", err)
+		}
+		if err := note.SetGeneratedBy(prompt.Ref(), usage.Model()); err != nil {
+			return "", fmt.Errorf("record prompt version: %w", err)
+		}
+		content, err := note.Bytes()
+		if err != nil {
+			return "", fmt.Errorf("build summary: %w", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
 			return "", fmt.Errorf("write summary: %w", err)
 		}
 		if gitManager != nil {
@@ -257,12 +330,33 @@ func main() {
 		}
 		return "wrote " + fileName, nil
 	})
-	if err := ensureDefaultAutomations(repo, gmailSvc != nil, os.Getenv("AUTOMATION_TIMEZONE")); err != nil {
-		log.Printf("Failed to seed default automations: %v", err)
+	automationService.RegisterAction("vault_health", func(ctx context.Context, def db.AutomationDefinition) (string, error) {
+		var payload struct {
+			InboxDays int `json:"inbox_days"`
+		}
+		if strings.TrimSpace(def.PayloadJSON) != "" {
+			if err := json.Unmarshal([]byte(def.PayloadJSON), &payload); err != nil {
+				return "", fmt.Errorf("invalid payload_json: %w", err)
+			}
+		}
+		report := vault.Lint(index, vault.LintOptions{InboxMaxAge: time.Duration(payload.InboxDays) * 24 * time.Hour})
+		if err := vault.WriteHealthReport(*vaultPath, report); err != nil {
+			return "", fmt.Errorf("write report: %w", err)
+		}
+		if gitManager != nil {
+			go gitManager.Sync("Automation: update vault health report")
+		}
+		return fmt.Sprintf("found %d issue(s) in %d notes", len(report.Issues), report.NotesChecked), nil
+	})
+
+	// Waiting-for follow-ups are sent through the chat bots set up below
+	followUps := automation.NewFollowUps(index, repo)
+	followUps.AI = aiClient
+	followUps.Prompts = prompts
+	if gmailSvc != nil {
+		followUps.Drafts = gmailSvc
 	}
-	automationService.Start()
-	defer automationService.Stop()
-	log.Println("Automation scheduler started")
+	automationService.RegisterAction("waiting_followups", followUps.Run)
 
 	// Initialize Discord Bot (Optional)
 	discordToken := os.Getenv("DISCORD_TOKEN")
@@ -271,11 +365,20 @@ func main() {
 		if err != nil {
 			log.Printf("Failed to create Discord bot: %v", err)
 		} else {
+			bot.Assistant = chat
+			bot.AllowedUsers = map[string]bool{}
+			for _, id := range idList(os.Getenv("DISCORD_ALLOWED_USERS")) {
+				bot.AllowedUsers[id] = true
+			}
+			bot.NotifyChannelID = os.Getenv("DISCORD_NOTIFY_CHANNEL")
 			if err := bot.Start(); err != nil {
 				log.Printf("Failed to start Discord bot: %v", err)
 			} else {
 				log.Println("Discord Bot started")
 				defer bot.Stop()
+				if bot.NotifyChannelID != "" {
+					followUps.Notifiers = append(followUps.Notifiers, bot)
+				}
 			}
 		}
 	}
@@ -287,22 +390,98 @@ func main() {
 		if err != nil {
 			log.Printf("Failed to create Telegram bot: %v", err)
 		} else {
+			tgBot.Assistant = chat
+			tgBot.AllowedChats = map[int64]bool{}
+			for _, id := range idList(os.Getenv("TELEGRAM_ALLOWED_CHATS")) {
+				chatID, err := strconv.ParseInt(id, 10, 64)
+				if err != nil {
+					log.Printf("Invalid chat ID %q in TELEGRAM_ALLOWED_CHATS: %v", id, err)
+					continue
+				}
+				tgBot.AllowedChats[chatID] = true
+			}
+			if chatID := os.Getenv("TELEGRAM_NOTIFY_CHAT"); chatID != "" {
+				if tgBot.NotifyChatID, err = strconv.ParseInt(chatID, 10, 64); err != nil {
+					log.Printf("Invalid TELEGRAM_NOTIFY_CHAT %q: %v", chatID, err)
+				}
+			}
 			if err := tgBot.Start(); err != nil {
 				log.Printf("Failed to start Telegram bot: %v", err)
 			} else {
 				log.Println("Telegram Bot started")
 				defer tgBot.Stop()
+				if tgBot.NotifyChatID != 0 {
+					followUps.Notifiers = append(followUps.Notifiers, tgBot)
+				}
 			}
 		}
 	}
 
+	// The scheduler starts once the bots that deliver its nudges are up
+	if err := ensureDefaultAutomations(repo, gmailSvc != nil, len(followUps.Notifiers) > 0, os.Getenv("AUTOMATION_TIMEZONE")); err != nil {
+		log.Printf("Failed to seed default automations: %v", err)
+	}
+	automationService.Start()
+	defer automationService.Stop()
+	log.Println("Automation scheduler started")
+
+	// Commit edits made outside vault-pilot (e.g. in Obsidian)
+	autoCommitter := sync.NewAutoCommitter(gitManager, 30*time.Second)
+	vaultWatcher.Subscribe(autoCommitter.HandleChanges)
+	defer func() {
+		// Runs after the watcher has delivered its last changes
+		autoCommitter.Flush()
+		autoCommitter.Stop()
+	}()
+
+	if err := vaultWatcher.Start(); err != nil {
+		log.Printf("Failed to start vault watcher: %v", err)
+	} else {
+		log.Println("Vault watcher started")
+		defer vaultWatcher.Stop()
+	}
+
+	// Shut down on SIGINT or SIGTERM so the deferred stops run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{Addr: ":" + *port, Handler: router}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server shutdown: %v", err)
+		}
+	}()
+
 	log.Printf("Starting server on :%s", *port)
-	if err := http.ListenAndServe(":"+*port, router); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed: %v", err)
 	}
+	log.Println("Shutting down")
+}
+
+// recentNotes returns the titles of the notes changed since t, newest first,
+// leaving out the system folder and the summaries themselves
+func recentNotes(index *vault.Index, since time.Time, summaryFolder string) []string {
+	var recent []vault.IndexedNote
+	for _, n := range index.All() {
+		if n.ModTime.After(since) && !vault.InFolder(n.Path, "0. GTD System") && !vault.InFolder(n.Path, summaryFolder) {
+			recent = append(recent, n)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool { return recent[i].ModTime.After(recent[j].ModTime) })
+	var titles []string
+	for i, n := range recent {
+		if i == 30 {
+			break
+		}
+		titles = append(titles, n.Title)
+	}
+	return titles
 }
 
-func ensureDefaultAutomations(repo *db.Repository, hasGmail bool, tz string) error {
+func ensureDefaultAutomations(repo *db.Repository, hasGmail, hasNotifier bool, tz string) error {
 	if tz == "" {
 		tz = "UTC"
 	}
@@ -357,5 +536,59 @@ func ensureDefaultAutomations(repo *db.Repository, hasGmail bool, tz string) err
 		log.Println("Seeded default automation: generate_daily_summary")
 	}
 
+	if !hasAction["vault_health"] {
+		nextRun, err := automation.NextRun("cron", "0 7 * * 1", tz, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		_, err = repo.CreateAutomation(&db.AutomationDefinition{
+			Name:         "Weekly Vault Health",
+			ActionType:   "vault_health",
+			ScheduleKind: "cron",
+			ScheduleExpr: "0 7 * * 1",
+			Timezone:     tz,
+			PayloadJSON:  `{"inbox_days":7}`,
+			Enabled:      true,
+			NextRunAt:    nextRun,
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Seeded default automation: vault_health")
+	}
+
+	if hasNotifier && !hasAction["waiting_followups"] {
+		nextRun, err := automation.NextRun("cron", "0 9 * * *", tz, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		_, err = repo.CreateAutomation(&db.AutomationDefinition{
+			Name:         "Waiting-For Follow-ups",
+			ActionType:   "waiting_followups",
+			ScheduleKind: "cron",
+			ScheduleExpr: "0 9 * * *",
+			Timezone:     tz,
+			PayloadJSON:  `{"repeat_days":3}`,
+			Enabled:      true,
+			NextRunAt:    nextRun,
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Seeded default automation: waiting_followups")
+	}
+
 	return nil
 }
+
+// idList splits a comma-separated list of IDs, e.g. from an environment
+// variable
+func idList(s string) []string {
+	var ids []string
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
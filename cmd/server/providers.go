@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+)
+
+// providerEnv maps AI providers to the prefix of their environment
+// variables: <PREFIX>_API_KEY, and for HTTP providers <PREFIX>_BASE_URL and
+// <PREFIX>_MODEL
+var providerEnv = map[string]string{
+	"moonshot":          "MOONSHOT",
+	"openai":            "OPENAI",
+	"anthropic":         "ANTHROPIC",
+	"gemini":            "GEMINI",
+	"openai-compatible": "OPENAI_COMPATIBLE",
+	"ollama":            "OLLAMA",
+	"rules":             "",
+}
+
+// newAIClient builds the generator for a comma-separated, ordered list of
+// providers. More than one provider is wrapped in a fallback chain. The
+// returned close function releases the provider clients.
+func newAIClient(ctx context.Context, list string, timeout time.Duration) (ai.Generator, func(), error) {
+	var providers []ai.Provider
+	var closers []func() error
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		gen, closer, err := newProvider(ctx, name, timeout)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		providers = append(providers, ai.Provider{Name: name, Generator: gen})
+	}
+	switch len(providers) {
+	case 0:
+		return nil, nil, fmt.Errorf("no AI provider configured")
+	case 1:
+		return providers[0].Generator, closeAll, nil
+	}
+	return ai.NewFallbackGenerator(providers...), closeAll, nil
+}
+
+// newProvider builds one provider from its environment variables
+func newProvider(ctx context.Context, name string, timeout time.Duration) (ai.Generator, func() error, error) {
+	prefix, ok := providerEnv[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown AI provider: %s", name)
+	}
+	cfg := ai.ClientConfig{
+		APIKey:         os.Getenv(prefix + "_API_KEY"),
+		BaseURL:        os.Getenv(prefix + "_BASE_URL"),
+		Model:          os.Getenv(prefix + "_MODEL"),
+		Timeout:        timeout,
+		EmbeddingModel: os.Getenv(prefix + "_EMBEDDING_MODEL"),
+	}
+	requireEnv := func(suffix, value string) error {
+		if value == "" {
+			return fmt.Errorf("%s_%s environment variable is required when using %s provider", prefix, suffix, name)
+		}
+		return nil
+	}
+
+	switch name {
+	case "ollama":
+		return ai.NewOllamaClient(cfg), nil, nil
+	case "rules":
+		return ai.NewRuleGenerator(), nil, nil
+	case "openai-compatible":
+		if err := requireEnv("BASE_URL", cfg.BaseURL); err != nil {
+			return nil, nil, err
+		}
+		if err := requireEnv("MODEL", cfg.Model); err != nil {
+			return nil, nil, err
+		}
+		cfg.Provider = name
+		return ai.NewOpenAIClientWithConfig(cfg), nil, nil
+	}
+
+	if err := requireEnv("API_KEY", cfg.APIKey); err != nil {
+		return nil, nil, err
+	}
+	switch name {
+	case "moonshot":
+		return ai.NewMoonshotClientWithConfig(cfg), nil, nil
+	case "openai":
+		return ai.NewOpenAIClientWithConfig(cfg), nil, nil
+	case "anthropic":
+		return ai.NewAnthropicClientWithConfig(cfg), nil, nil
+	default: // gemini
+		geminiClient, err := ai.NewClientWithConfig(ctx, cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create AI client: %w", err)
+		}
+		return geminiClient, geminiClient.Close, nil
+	}
+}
+
+// newEmbedder builds the embedder for semantic search. An empty name or
+// "hash" uses the local hash embedder, so notes leave the machine only when
+// the operator names a provider; "none" disables semantic search and returns
+// a nil embedder. Provider embedders are metered and count toward the AI
+// budget.
+func newEmbedder(ctx context.Context, name string, timeout time.Duration, meter *ai.MeteredGenerator) (ai.Embedder, func(), error) {
+	noop := func() {}
+	switch name {
+	case "none":
+		return nil, noop, nil
+	case "", "hash":
+		return ai.NewHashEmbedder(), noop, nil
+	}
+	e, closer, err := embedderFor(ctx, name, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return meter.Embedder(e), closer, nil
+}
+
+// embedderFor builds a provider and checks that it can embed
+func embedderFor(ctx context.Context, name string, timeout time.Duration) (ai.Embedder, func(), error) {
+	gen, closer, err := newProvider(ctx, name, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeFn := func() {
+		if closer != nil {
+			closer()
+		}
+	}
+	e, ok := gen.(ai.Embedder)
+	if !ok {
+		closeFn()
+		return nil, nil, fmt.Errorf("AI provider %s does not support embeddings", name)
+	}
+	return e, closeFn, nil
+}
+
+// loadPrices returns the default price table with the entries of the JSON
+// file at path, if any, added or replacing defaults. The file maps model
+// names to {"input": USD, "output": USD} per million tokens.
+func loadPrices(path string) (ai.PriceTable, error) {
+	prices := ai.PriceTable{}
+	for model, p := range ai.DefaultPrices {
+		prices[model] = p
+	}
+	if path == "" {
+		return prices, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AI prices: %w", err)
+	}
+	var custom ai.PriceTable
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse AI prices: %w", err)
+	}
+	for model, p := range custom {
+		prices[model] = p
+	}
+	return prices, nil
+}
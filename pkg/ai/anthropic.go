@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 const (
@@ -23,38 +25,76 @@ type AnthropicClient struct {
 	baseURL    string
 }
 
-// Ensure AnthropicClient implements Generator.
-var _ Generator = (*AnthropicClient)(nil)
+// Ensure AnthropicClient implements Generator and ToolCaller.
+var (
+	_ Generator  = (*AnthropicClient)(nil)
+	_ ToolCaller = (*AnthropicClient)(nil)
+)
 
 // NewAnthropicClient creates a new Anthropic API client.
 func NewAnthropicClient(apiKey string) *AnthropicClient {
+	return NewAnthropicClientWithConfig(ClientConfig{APIKey: apiKey})
+}
+
+// NewAnthropicClientWithConfig creates a client with a custom endpoint, model
+// or timeout.
+func NewAnthropicClientWithConfig(cfg ClientConfig) *AnthropicClient {
+	cfg = cfg.withDefaults(anthropicDefaultBaseURL, anthropicDefaultModel)
 	return &AnthropicClient{
-		httpClient: &http.Client{},
-		apiKey:     apiKey,
-		model:      anthropicDefaultModel,
-		baseURL:    anthropicDefaultBaseURL,
+		httpClient: cfg.httpClient(),
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		baseURL:    cfg.BaseURL,
 	}
 }
 
 type anthropicRequest struct {
-	Model     string             `json:"model"`
-	Messages  []anthropicMessage `json:"messages"`
-	MaxTokens int                `json:"max_tokens"`
+	Model         string               `json:"model"`
+	System        string               `json:"system,omitempty"`
+	Messages      []anthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *anthropicToolChoice `json:"tool_choice,omitempty"`
 }
 
-type anthropicMessage struct {
-	Role    string               `json:"role"`
-	Content []anthropicTextBlock `json:"content"`
+type anthropicTool struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	InputSchema *Schema `json:"input_schema"`
 }
 
-type anthropicTextBlock struct {
+type anthropicToolChoice struct {
 	Type string `json:"type"`
-	Text string `json:"text"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+type anthropicContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`          // tool_use
+	Name      string          `json:"name,omitempty"`        // tool_use
+	Input     json.RawMessage `json:"input,omitempty"`       // tool_use
+	ToolUseID string          `json:"tool_use_id,omitempty"` // tool_result
+	Content   string          `json:"content,omitempty"`     // tool_result
 }
 
 type anthropicResponse struct {
-	Content []anthropicTextBlock `json:"content"`
-	Error   *anthropicError      `json:"error,omitempty"`
+	Content []anthropicContentBlock `json:"content"`
+	Usage   anthropicUsage          `json:"usage"`
+	Error   *anthropicError         `json:"error,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
 
 type anthropicError struct {
@@ -64,27 +104,184 @@ type anthropicError struct {
 
 // GenerateText sends a prompt to Anthropic and returns the generated text.
 func (c *AnthropicClient) GenerateText(ctx context.Context, prompt string) (string, error) {
-	reqBody := anthropicRequest{
-		Model: c.model,
-		Messages: []anthropicMessage{
-			{
-				Role: "user",
-				Content: []anthropicTextBlock{
-					{Type: "text", Text: prompt},
-				},
-			},
-		},
-		MaxTokens: 1024,
+	return c.Generate(ctx, NewRequest(prompt))
+}
+
+// Generate sends a conversation to Anthropic and returns the reply.
+func (c *AnthropicClient) Generate(ctx context.Context, r Request) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	result, err := c.send(ctx, c.request(r))
+	if err != nil {
+		return "", err
 	}
+	return result.text(), nil
+}
+
+// text returns the text blocks of the response
+func (r *anthropicResponse) text() string {
+	var text strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
+
+// Chat answers a conversation, letting the model call req.Tools.
+func (c *AnthropicClient) Chat(ctx context.Context, r Request) (Message, error) {
+	if err := r.Validate(); err != nil {
+		return Message{}, err
+	}
+	body := c.request(r)
+	for _, t := range r.Tools {
+		body.Tools = append(body.Tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: t.Parameters})
+	}
+	result, err := c.send(ctx, body)
+	if err != nil {
+		return Message{}, err
+	}
+	reply := Message{Role: RoleAssistant, Content: result.text()}
+	for _, block := range result.Content {
+		if block.Type == "tool_use" {
+			reply.ToolCalls = append(reply.ToolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: block.Input})
+		}
+	}
+	return reply, nil
+}
 
+// GenerateJSON asks for JSON matching schema by forcing the model to call a
+// tool whose input schema is the requested one, and returns the tool input.
+func (c *AnthropicClient) GenerateJSON(ctx context.Context, r Request, schema *Schema) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	reqBody := c.request(r)
+	reqBody.Tools = []anthropicTool{{Name: schema.name(), Description: schema.Description, InputSchema: schema}}
+	reqBody.ToolChoice = &anthropicToolChoice{Type: "tool", Name: schema.name()}
+	result, err := c.send(ctx, reqBody)
+	if err != nil {
+		return "", err
+	}
+	for _, block := range result.Content {
+		if block.Type == "tool_use" && block.Name == schema.name() {
+			return string(block.Input), nil
+		}
+	}
+	return "", fmt.Errorf("anthropic API error: no %s tool call returned", schema.name())
+}
+
+// request converts a Request to the messages API format. Tool calls become
+// tool_use blocks and their results tool_result blocks of a user message.
+// Empty assistant replies are left out: the API rejects empty text blocks.
+func (c *AnthropicClient) request(r Request) anthropicRequest {
+	body := anthropicRequest{
+		Model:         c.model,
+		System:        r.System,
+		MaxTokens:     r.maxTokens(),
+		Temperature:   r.Temperature,
+		StopSequences: r.Stop,
+	}
+	for _, m := range r.Messages {
+		if m.Role == RoleTool {
+			block := anthropicContentBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content}
+			// Results of one turn's calls share a user message
+			if n := len(body.Messages); n > 0 && body.Messages[n-1].Content[0].Type == "tool_result" {
+				body.Messages[n-1].Content = append(body.Messages[n-1].Content, block)
+				continue
+			}
+			body.Messages = append(body.Messages, anthropicMessage{Role: RoleUser, Content: []anthropicContentBlock{block}})
+			continue
+		}
+		if m.Role == RoleAssistant && m.Content == "" && len(m.ToolCalls) == 0 {
+			continue
+		}
+		var blocks []anthropicContentBlock
+		if m.Content != "" || len(m.ToolCalls) == 0 {
+			blocks = append(blocks, anthropicContentBlock{Type: "text", Text: m.Content})
+		}
+		for _, call := range m.ToolCalls {
+			blocks = append(blocks, anthropicContentBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: call.arguments()})
+		}
+		body.Messages = append(body.Messages, anthropicMessage{Role: m.Role, Content: blocks})
+	}
+	return body
+}
+
+// anthropicStreamEvent is an event of a streamed messages response
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"` // message_start
+	Usage anthropicUsage  `json:"usage"` // message_delta
+	Error *anthropicError `json:"error,omitempty"`
+}
+
+// Stream sends a conversation to Anthropic and calls fn as the reply is
+// generated.
+func (c *AnthropicClient) Stream(ctx context.Context, r Request, fn StreamFunc) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	body := c.request(r)
+	body.Stream = true
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var text strings.Builder
+	var usage anthropicUsage
+	err = readSSE(resp.Body, func(_, data string) error {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to parse stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				text.WriteString(event.Delta.Text)
+				return fn(event.Delta.Text)
+			}
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("anthropic API error: %s", event.Error.Message)
+			}
+			return fmt.Errorf("anthropic API error: %s", data)
+		case "message_stop":
+			return errStreamDone
+		}
+		return nil
+	})
+	if errors.Is(err, errStreamDone) {
+		err = nil
+	}
+	c.reportUsage(ctx, usage)
+	return text.String(), err
+}
+
+// post sends a messages request and returns the successful response
+func (c *AnthropicClient) post(ctx context.Context, reqBody anthropicRequest) (*http.Response, error) {
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/messages", bytes.NewReader(bodyBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", c.apiKey)
@@ -92,33 +289,49 @@ func (c *AnthropicClient) GenerateText(ctx context.Context, prompt string) (stri
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError("anthropic", resp)
+	}
+	return resp, nil
+}
 
-	respBytes, err := io.ReadAll(resp.Body)
+// send posts a messages request and returns the response with at least one
+// content block
+func (c *AnthropicClient) send(ctx context.Context, reqBody anthropicRequest) (*anthropicResponse, error) {
+	resp, err := c.post(ctx, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("anthropic API error (status %d): %s", resp.StatusCode, string(respBytes))
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	var result anthropicResponse
 	if err := json.Unmarshal(respBytes, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("anthropic API error: %s", result.Error.Message)
+		return nil, fmt.Errorf("anthropic API error: %s", result.Error.Message)
 	}
 
 	if len(result.Content) == 0 {
-		return "", fmt.Errorf("no content returned")
+		return nil, fmt.Errorf("no content returned")
 	}
 
-	return result.Content[0].Text, nil
+	c.reportUsage(ctx, result.Usage)
+	return &result, nil
+}
+
+// reportUsage reports the token usage of a response.
+func (c *AnthropicClient) reportUsage(ctx context.Context, u anthropicUsage) {
+	reportUsage(ctx, Usage{Provider: "anthropic", Model: c.model, PromptTokens: u.InputTokens, CompletionTokens: u.OutputTokens})
 }
 
 // Close is a no-op for the HTTP-based Anthropic client.
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAnthropicGenerateText(t *testing.T) {
@@ -38,7 +40,7 @@ func TestAnthropicGenerateText(t *testing.T) {
 		}
 
 		resp := anthropicResponse{
-			Content: []anthropicTextBlock{{Type: "text", Text: "world"}},
+			Content: []anthropicContentBlock{{Type: "text", Text: "world"}},
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
@@ -57,6 +59,31 @@ func TestAnthropicGenerateText(t *testing.T) {
 	}
 }
 
+func TestAnthropicClientConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/proxy/v1/messages" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if req.Model != "claude-sonnet-4-0" {
+			t.Errorf("model = %s", req.Model)
+		}
+		json.NewEncoder(w).Encode(anthropicResponse{Content: []anthropicContentBlock{{Type: "text", Text: "proxied"}}})
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClientWithConfig(ClientConfig{APIKey: "test-key", BaseURL: server.URL + "/proxy/v1/", Model: "claude-sonnet-4-0", Timeout: time.Second})
+	if client.httpClient.Timeout != time.Second {
+		t.Errorf("timeout = %v", client.httpClient.Timeout)
+	}
+	if text, err := client.GenerateText(context.Background(), "hi"); err != nil || text != "proxied" {
+		t.Errorf("GenerateText = %q, %v", text, err)
+	}
+}
+
 func TestAnthropicGenerateTextAPIError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -75,7 +102,7 @@ func TestAnthropicGenerateTextAPIError(t *testing.T) {
 
 func TestAnthropicGenerateTextEmptyContent(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		resp := anthropicResponse{Content: []anthropicTextBlock{}}
+		resp := anthropicResponse{Content: []anthropicContentBlock{}}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 	}))
@@ -112,3 +139,172 @@ func TestAnthropicClose(t *testing.T) {
 		t.Fatalf("expected nil error from Close, got %v", err)
 	}
 }
+
+func TestAnthropicGenerateJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if len(req.Tools) != 1 || req.Tools[0].Name != "inbox_analysis" || req.Tools[0].InputSchema.Type != "object" {
+			t.Errorf("unexpected tools: %+v", req.Tools)
+		}
+		if req.ToolChoice == nil || req.ToolChoice.Type != "tool" || req.ToolChoice.Name != "inbox_analysis" {
+			t.Errorf("unexpected tool choice: %+v", req.ToolChoice)
+		}
+		resp := anthropicResponse{Content: []anthropicContentBlock{
+			{Type: "tool_use", Name: "inbox_analysis", Input: json.RawMessage(`{"title":"Call Bob","description":"About the fence"}`)},
+		}}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("test-key")
+	client.baseURL = server.URL
+
+	a, err := AnalyzeInbox(context.Background(), client, "call bob about the fence")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Title != "Call Bob" || a.Description != "About the fence" {
+		t.Errorf("unexpected analysis: %+v", a)
+	}
+}
+
+func TestAnthropicGenerate(t *testing.T) {
+	var got anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(anthropicResponse{Content: []anthropicContentBlock{{Type: "text", Text: "Which "}, {Type: "text", Text: "project?"}}})
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("test-key")
+	client.baseURL = server.URL
+
+	result, err := client.Generate(context.Background(), Request{
+		System:   "Ask clarifying questions.",
+		Messages: []Message{{Role: RoleUser, Content: "Add a task"}},
+		Stop:     []string{"\n\nHuman:"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "Which project?" {
+		t.Errorf("expected the text blocks joined, got %q", result)
+	}
+	if got.System != "Ask clarifying questions." || got.MaxTokens != DefaultMaxTokens || got.Temperature != nil || len(got.StopSequences) != 1 {
+		t.Errorf("unexpected request: %+v", got)
+	}
+
+	if _, err := client.Generate(context.Background(), Request{Messages: []Message{{Role: RoleAssistant, Content: "Hi"}}}); err == nil {
+		t.Error("expected an error for a conversation ending with the assistant")
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":12}}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Good \"}}\n\n" +
+			"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"week\"}}\n\n" +
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":2}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("test-key")
+	client.baseURL = server.URL
+
+	var streamed string
+	ctx, tracker := WithUsageTracker(context.Background())
+	result, err := client.Stream(ctx, NewRequest("hi"), func(d string) error {
+		streamed += d
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "Good week" || streamed != result {
+		t.Errorf("result = %q, streamed = %q", result, streamed)
+	}
+	if u, _ := tracker.Usage(); u.PromptTokens != 12 || u.CompletionTokens != 2 || u.Model != anthropicDefaultModel {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("test-key")
+	client.baseURL = server.URL
+
+	if _, err := client.Stream(context.Background(), NewRequest("hi"), func(string) error { return nil }); err == nil || !strings.Contains(err.Error(), "Overloaded") {
+		t.Errorf("expected overloaded error, got %v", err)
+	}
+}
+
+func TestAnthropicChatTools(t *testing.T) {
+	var got anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(anthropicResponse{Content: []anthropicContentBlock{
+			{Type: "text", Text: "Let me check."},
+			{Type: "tool_use", ID: "toolu_2", Name: "list_next_actions", Input: json.RawMessage(`{"context":"@home"}`)},
+		}})
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("test-key")
+	client.baseURL = server.URL
+
+	first := ToolCall{ID: "toolu_1", Name: "read_project", Arguments: json.RawMessage(`{"name":"Garden"}`)}
+	second := ToolCall{ID: "toolu_1b", Name: "read_project"}
+	reply, err := client.Chat(context.Background(), Request{
+		Messages: []Message{
+			{Role: RoleUser, Content: "What's next for the garden?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{first, second}},
+			NewToolResult(first, "status: active"),
+			NewToolResult(second, "not found"),
+		},
+		Tools: []Tool{{Name: "list_next_actions", Parameters: &Schema{Type: "object"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply.Content != "Let me check." || len(reply.ToolCalls) != 1 || reply.ToolCalls[0].ID != "toolu_2" {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].InputSchema == nil {
+		t.Errorf("unexpected tools: %+v", got.Tools)
+	}
+	// Both results go back in one user message
+	if len(got.Messages) != 3 || got.Messages[1].Content[1].Type != "tool_use" || string(got.Messages[1].Content[1].Input) != "{}" ||
+		got.Messages[2].Role != "user" || len(got.Messages[2].Content) != 2 || got.Messages[2].Content[1].ToolUseID != "toolu_1b" {
+		t.Errorf("unexpected messages: %+v", got.Messages)
+	}
+
+	// An empty assistant reply is not sent
+	got = anthropicRequest{}
+	_, err = client.Chat(context.Background(), Request{
+		Messages: []Message{
+			{Role: RoleUser, Content: "Hello"},
+			{Role: RoleAssistant},
+			{Role: RoleUser, Content: "Are you there?"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Messages) != 2 || got.Messages[1].Content[0].Text != "Are you there?" {
+		t.Errorf("unexpected messages: %+v", got.Messages)
+	}
+}
@@ -0,0 +1,40 @@
+package ai
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ClientConfig configures an HTTP provider. Empty fields keep the provider
+// defaults, so a self-hosted OpenAI-compatible server only needs BaseURL and
+// Model.
+type ClientConfig struct {
+	APIKey  string // sent as a bearer token when set
+	BaseURL string // e.g. http://localhost:11434/v1
+	Model   string
+	Timeout time.Duration // per request, zero means no timeout
+
+	EmbeddingModel string // for providers that implement Embedder
+
+	// Provider names the server in usage records and errors when a client
+	// talks to another provider's API, e.g. "openai-compatible"
+	Provider string
+}
+
+// withDefaults fills the empty fields except EmbeddingModel
+func (c ClientConfig) withDefaults(baseURL, model string) ClientConfig {
+	if c.BaseURL == "" {
+		c.BaseURL = baseURL
+	}
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+	if c.Model == "" {
+		c.Model = model
+	}
+	return c
+}
+
+// httpClient returns a client with the configured timeout
+func (c ClientConfig) httpClient() *http.Client {
+	return &http.Client{Timeout: c.Timeout}
+}
@@ -0,0 +1,115 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns texts into vectors for semantic search
+type Embedder interface {
+	// Embed returns one vector per text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// EmbeddingModel names the model. Vectors of different models cannot be
+	// compared, so an index is rebuilt when it changes.
+	EmbeddingModel() string
+}
+
+// Normalize scales v to unit length in place, so cosine similarity becomes a
+// dot product
+func Normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+// Dot returns the dot product of two vectors of the same length
+func Dot(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// checkEmbeddings verifies a provider returned one vector per text
+func checkEmbeddings(provider string, vectors [][]float32, texts []string) error {
+	if len(vectors) != len(texts) {
+		return fmt.Errorf("%s returned %d embeddings for %d texts", provider, len(vectors), len(texts))
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return fmt.Errorf("%s returned no embedding for text %d", provider, i)
+		}
+	}
+	return nil
+}
+
+// HashEmbedder is a local Embedder that needs no model. It hashes words and
+// word pairs into a fixed number of dimensions, so it finds notes sharing
+// vocabulary with the query rather than meaning. Useful offline and in tests.
+type HashEmbedder struct {
+	Dimensions int
+}
+
+// Ensure HashEmbedder implements Embedder
+var _ Embedder = (*HashEmbedder)(nil)
+
+// NewHashEmbedder creates a hash embedder with 512 dimensions
+func NewHashEmbedder() *HashEmbedder {
+	return &HashEmbedder{Dimensions: 512}
+}
+
+// EmbeddingModel returns "hash-<dimensions>"
+func (e *HashEmbedder) EmbeddingModel() string {
+	return fmt.Sprintf("hash-%d", e.Dimensions)
+}
+
+// Embed hashes each text
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, t := range texts {
+		v := make([]float32, e.Dimensions)
+		words := strings.FieldsFunc(strings.ToLower(t), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for j, w := range words {
+			if len([]rune(w)) < 2 {
+				continue
+			}
+			e.add(v, w, 1)
+			if j > 0 {
+				e.add(v, words[j-1]+" "+w, 0.5)
+			}
+		}
+		vectors[i] = Normalize(v)
+	}
+	return vectors, nil
+}
+
+// add adds weight to the dimension of term, with a hashed sign so
+// collisions tend to cancel out
+func (e *HashEmbedder) add(v []float32, term string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(term))
+	sum := h.Sum64()
+	if sum&1 == 1 {
+		weight = -weight
+	}
+	v[(sum>>1)%uint64(len(v))] += weight
+}
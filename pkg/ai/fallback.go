@@ -0,0 +1,276 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+type featureKey struct{}
+
+// WithFeature labels the AI calls made with ctx, e.g. "inbox" or
+// "weekly_review", for logs and accounting
+func WithFeature(ctx context.Context, feature string) context.Context {
+	return context.WithValue(ctx, featureKey{}, feature)
+}
+
+// FeatureFrom returns the feature label set by WithFeature, or ""
+func FeatureFrom(ctx context.Context) string {
+	f, _ := ctx.Value(featureKey{}).(string)
+	return f
+}
+
+type preferredProviderKey struct{}
+
+// WithPreferredProvider makes a fallback chain try the named provider first
+// for the calls made with ctx
+func WithPreferredProvider(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, preferredProviderKey{}, name)
+}
+
+// Provider is a named generator in a fallback chain
+type Provider struct {
+	Name      string
+	Generator Generator
+}
+
+// FallbackGenerator tries its providers in order. Temporary errors (rate
+// limits, server and network errors) are retried with exponential backoff,
+// honouring Retry-After; after that the next provider is tried. A provider
+// that keeps failing with temporary errors or timeouts is skipped until its
+// circuit breaker cools down.
+type FallbackGenerator struct {
+	MaxRetries       int           // retries per provider for temporary errors
+	BaseDelay        time.Duration // first backoff delay, doubled on each retry
+	MaxDelay         time.Duration // longest wait; a longer Retry-After moves on to the next provider
+	BreakerThreshold int           // consecutive failures that open a provider's breaker
+	BreakerCooldown  time.Duration // how long an open breaker skips the provider
+
+	providers []*circuit
+	mu        sync.Mutex
+	now       func() time.Time
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+// circuit tracks the health of one provider
+type circuit struct {
+	Provider
+	failures  int
+	openUntil time.Time
+	probing   bool // a half-open trial request is in flight
+}
+
+// Ensure FallbackGenerator implements Generator and ToolCaller
+var (
+	_ Generator  = (*FallbackGenerator)(nil)
+	_ ToolCaller = (*FallbackGenerator)(nil)
+)
+
+// NewFallbackGenerator creates a fallback chain over providers, first is
+// preferred.
+func NewFallbackGenerator(providers ...Provider) *FallbackGenerator {
+	g := &FallbackGenerator{
+		MaxRetries:       2,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         10 * time.Second,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Minute,
+		now:              time.Now,
+		sleep:            sleepContext,
+	}
+	for _, p := range providers {
+		g.providers = append(g.providers, &circuit{Provider: p})
+	}
+	return g
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// GenerateText generates text with the first healthy provider
+func (g *FallbackGenerator) GenerateText(ctx context.Context, prompt string) (string, error) {
+	return g.do(ctx, func(gen Generator) (string, error) { return gen.GenerateText(ctx, prompt) })
+}
+
+// Generate answers a conversation with the first healthy provider
+func (g *FallbackGenerator) Generate(ctx context.Context, req Request) (string, error) {
+	return g.do(ctx, func(gen Generator) (string, error) { return gen.Generate(ctx, req) })
+}
+
+// GenerateJSON generates JSON with the first healthy provider
+func (g *FallbackGenerator) GenerateJSON(ctx context.Context, req Request, schema *Schema) (string, error) {
+	return g.do(ctx, func(gen Generator) (string, error) { return gen.GenerateJSON(ctx, req, schema) })
+}
+
+// Stream streams from the first healthy provider. Once text has been passed
+// to fn a failure is returned as is: retrying would repeat the text.
+func (g *FallbackGenerator) Stream(ctx context.Context, req Request, fn StreamFunc) (string, error) {
+	return g.do(ctx, func(gen Generator) (string, error) {
+		started := false
+		text, err := gen.Stream(ctx, req, func(delta string) error {
+			started = true
+			return fn(delta)
+		})
+		if err != nil && started {
+			return text, fmt.Errorf("%w: %w", errStreamInterrupted, err)
+		}
+		return text, err
+	})
+}
+
+// Chat answers a conversation with tools using the first healthy provider
+// that supports tool calling
+func (g *FallbackGenerator) Chat(ctx context.Context, req Request) (Message, error) {
+	var reply Message
+	_, err := g.do(ctx, func(gen Generator) (string, error) {
+		m, err := Chat(ctx, gen, req)
+		reply = m
+		return m.Content, err
+	})
+	return reply, err
+}
+
+// errStreamInterrupted stops the fallback chain after a partial stream
+var errStreamInterrupted = errors.New("stream interrupted")
+
+// logPrefix names the feature making the call in log lines
+func logPrefix(ctx context.Context) string {
+	if f := FeatureFrom(ctx); f != "" {
+		return "AI " + f
+	}
+	return "AI"
+}
+
+func (g *FallbackGenerator) do(ctx context.Context, call func(Generator) (string, error)) (string, error) {
+	prefix := logPrefix(ctx)
+	providers := g.providers
+	if name, _ := ctx.Value(preferredProviderKey{}).(string); name != "" {
+		providers = preferFirst(providers, name)
+	}
+	var errs []error
+	for i, p := range providers {
+		if !g.allow(p) {
+			log.Printf("%s: skipping %s, circuit open", prefix, p.Name)
+			errs = append(errs, fmt.Errorf("%s: circuit open", p.Name))
+			continue
+		}
+		text, err := g.try(ctx, p, call)
+		if err == nil {
+			if i > 0 {
+				log.Printf("%s: answered by %s (fallback)", prefix, p.Name)
+			}
+			return text, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+		if ctx.Err() != nil || errors.Is(err, errStreamInterrupted) {
+			break
+		}
+		if i < len(providers)-1 {
+			log.Printf("%s: %s failed, falling back to %s: %v", prefix, p.Name, providers[i+1].Name, err)
+		}
+	}
+	return "", fmt.Errorf("all AI providers failed: %w", errors.Join(errs...))
+}
+
+// preferFirst moves the named provider to the front, keeping the others in
+// order
+func preferFirst(providers []*circuit, name string) []*circuit {
+	for i, p := range providers {
+		if p.Name == name {
+			out := append([]*circuit{p}, providers[:i]...)
+			return append(out, providers[i+1:]...)
+		}
+	}
+	return providers
+}
+
+// try calls one provider, retrying temporary errors
+func (g *FallbackGenerator) try(ctx context.Context, p *circuit, call func(Generator) (string, error)) (string, error) {
+	delay := g.BaseDelay
+	for attempt := 0; ; attempt++ {
+		text, err := call(p.Generator)
+		if err == nil {
+			g.record(p, nil)
+			return text, nil
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrNoRule) || errors.Is(err, ErrToolsUnsupported) {
+			// Neither the caller giving up nor an unsupported request says
+			// anything about the provider's health
+			g.release(p)
+			return "", err
+		}
+		if !isTemporary(err) || attempt >= g.MaxRetries || errors.Is(err, errStreamInterrupted) {
+			g.record(p, err)
+			return "", err
+		}
+		wait := delay
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		if wait > g.MaxDelay {
+			g.record(p, err)
+			return "", fmt.Errorf("%w (retry after %s)", err, wait)
+		}
+		log.Printf("%s: %s attempt %d failed, retrying in %s: %v", logPrefix(ctx), p.Name, attempt+1, wait, err)
+		if err := g.sleep(ctx, wait); err != nil {
+			g.release(p)
+			return "", err
+		}
+		delay = min(delay*2, g.MaxDelay)
+	}
+}
+
+// allow reports whether p may be called. An open breaker lets one trial
+// request through once the cooldown has passed.
+func (g *FallbackGenerator) allow(p *circuit) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if p.failures < g.BreakerThreshold {
+		return true
+	}
+	if g.now().Before(p.openUntil) || p.probing {
+		return false
+	}
+	p.probing = true
+	return true
+}
+
+// release ends a trial request that neither succeeded nor failed, e.g. when
+// the caller gave up
+func (g *FallbackGenerator) release(p *circuit) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p.probing = false
+}
+
+// record updates p's breaker after a call. Only provider failures (see
+// isProviderFailure) count toward opening it.
+func (g *FallbackGenerator) record(p *circuit, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p.probing = false
+	if err == nil {
+		p.failures = 0
+		return
+	}
+	if !isProviderFailure(err) {
+		return
+	}
+	p.failures++
+	if p.failures >= g.BreakerThreshold {
+		p.openUntil = g.now().Add(g.BreakerCooldown)
+		log.Printf("AI: %s failed %d times in a row, circuit open until %s", p.Name, p.failures, p.openUntil.Format(time.RFC3339))
+	}
+}
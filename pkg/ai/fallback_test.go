@@ -0,0 +1,209 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubGenerator returns the queued errors before answering with text
+type stubGenerator struct {
+	text  string
+	errs  []error
+	calls int
+}
+
+func (s *stubGenerator) next() (string, error) {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		if len(s.errs) > 1 {
+			s.errs = s.errs[1:]
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return s.text, nil
+}
+
+func (s *stubGenerator) GenerateText(ctx context.Context, prompt string) (string, error) {
+	return s.next()
+}
+
+func (s *stubGenerator) Generate(ctx context.Context, req Request) (string, error) {
+	return s.next()
+}
+
+func (s *stubGenerator) GenerateJSON(ctx context.Context, req Request, schema *Schema) (string, error) {
+	return s.next()
+}
+
+func (s *stubGenerator) Stream(ctx context.Context, req Request, fn StreamFunc) (string, error) {
+	text, err := s.next()
+	if err != nil {
+		return "", err
+	}
+	return text, fn(text)
+}
+
+func newTestFallback(providers ...Provider) (*FallbackGenerator, *[]time.Duration, *time.Time) {
+	g := NewFallbackGenerator(providers...)
+	var waits []time.Duration
+	now := time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC)
+	g.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	g.now = func() time.Time { return now }
+	return g, &waits, &now
+}
+
+func TestFallbackRetriesWithBackoff(t *testing.T) {
+	primary := &stubGenerator{text: "primary", errs: []error{
+		&APIError{Provider: "anthropic", StatusCode: 529},
+		&APIError{Provider: "anthropic", StatusCode: 429, RetryAfter: 3 * time.Second},
+		nil,
+	}}
+	g, waits, _ := newTestFallback(Provider{"anthropic", primary}, Provider{"openai", &stubGenerator{text: "backup"}})
+
+	text, err := g.GenerateText(context.Background(), "hi")
+	if err != nil || text != "primary" {
+		t.Fatalf("GenerateText = %q, %v", text, err)
+	}
+	if len(*waits) != 2 || (*waits)[0] != 500*time.Millisecond || (*waits)[1] != 3*time.Second {
+		t.Errorf("waits = %v, want backoff then Retry-After", *waits)
+	}
+}
+
+func TestFallbackFallsThrough(t *testing.T) {
+	primary := &stubGenerator{errs: []error{&APIError{Provider: "anthropic", StatusCode: 401}}}
+	second := &stubGenerator{errs: []error{&APIError{Provider: "openai", StatusCode: 503, RetryAfter: time.Hour}}}
+	third := &stubGenerator{text: "gemini"}
+	g, waits, _ := newTestFallback(Provider{"anthropic", primary}, Provider{"openai", second}, Provider{"gemini", third})
+
+	text, err := g.Generate(WithFeature(context.Background(), "inbox"), NewRequest("hi"))
+	if err != nil || text != "gemini" {
+		t.Fatalf("Generate = %q, %v", text, err)
+	}
+	if primary.calls != 1 || second.calls != 1 || len(*waits) != 0 {
+		t.Errorf("calls = %d, %d, waits = %v; non-temporary errors and long Retry-After should not be retried",
+			primary.calls, second.calls, *waits)
+	}
+
+	third.errs = []error{errors.New("boom")}
+	if _, err := g.GenerateJSON(context.Background(), NewRequest("hi"), &Schema{Type: "object"}); err == nil ||
+		!strings.Contains(err.Error(), "all AI providers failed") || !strings.Contains(err.Error(), "gemini: boom") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestFallbackCircuitBreaker(t *testing.T) {
+	primary := &stubGenerator{text: "primary", errs: []error{&APIError{Provider: "anthropic", StatusCode: 503, RetryAfter: time.Hour}}}
+	backup := &stubGenerator{text: "backup"}
+	g, _, now := newTestFallback(Provider{"anthropic", primary}, Provider{"openai", backup})
+	g.BreakerThreshold = 2
+
+	for i := 0; i < 3; i++ {
+		if text, _ := g.GenerateText(context.Background(), "hi"); text != "backup" {
+			t.Fatalf("call %d = %q", i, text)
+		}
+	}
+	if primary.calls != 2 {
+		t.Errorf("primary called %d times, want the breaker to open after 2", primary.calls)
+	}
+
+	// After the cooldown one trial request goes through and closes the breaker
+	*now = now.Add(g.BreakerCooldown)
+	primary.errs = nil
+	if text, _ := g.GenerateText(context.Background(), "hi"); text != "primary" {
+		t.Errorf("after cooldown = %q", text)
+	}
+	if text, _ := g.GenerateText(context.Background(), "hi"); text != "primary" || primary.calls != 4 {
+		t.Errorf("breaker did not close: %q, %d calls", text, primary.calls)
+	}
+}
+
+func TestFallbackBreakerIgnoresRejectedRequests(t *testing.T) {
+	primary := &stubGenerator{text: "primary", errs: []error{&APIError{Provider: "anthropic", StatusCode: 400}}}
+	backup := &stubGenerator{text: "backup"}
+	g, _, _ := newTestFallback(Provider{"anthropic", primary}, Provider{"openai", backup})
+	g.BreakerThreshold = 2
+
+	for i := 0; i < 3; i++ {
+		if text, _ := g.GenerateText(context.Background(), "hi"); text != "backup" {
+			t.Fatalf("call %d = %q", i, text)
+		}
+	}
+	if primary.calls != 3 {
+		t.Errorf("primary called %d times, a 400 must not open the breaker", primary.calls)
+	}
+}
+
+func TestFallbackStreamDoesNotRepeatText(t *testing.T) {
+	g, _, _ := newTestFallback(Provider{"anthropic", &stubGenerator{text: "partial"}}, Provider{"openai", &stubGenerator{text: "other"}})
+	var got []string
+	_, err := g.Stream(context.Background(), NewRequest("hi"), func(d string) error {
+		got = append(got, d)
+		return errors.New("client gone")
+	})
+	if err == nil || len(got) != 1 {
+		t.Errorf("stream continued after a partial answer: %v, %q", err, got)
+	}
+}
+
+func TestAPIErrorFromResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"slow down"}}`))
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("test-key")
+	client.baseURL = server.URL
+	_, err := client.GenerateText(context.Background(), "hi")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 429 || apiErr.RetryAfter != 7*time.Second || !isTemporary(err) {
+		t.Fatalf("err = %#v", err)
+	}
+
+	now := time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC)
+	if d := parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now); d != 90*time.Second {
+		t.Errorf("HTTP date Retry-After = %s", d)
+	}
+}
+
+// toolStub answers Chat with a fixed message
+type toolStub struct {
+	stubGenerator
+	reply Message
+}
+
+func (s *toolStub) Chat(ctx context.Context, req Request) (Message, error) {
+	if _, err := s.next(); err != nil {
+		return Message{}, err
+	}
+	return s.reply, nil
+}
+
+func TestFallbackChatSkipsProvidersWithoutTools(t *testing.T) {
+	plain := &stubGenerator{text: "plain"}
+	tools := &toolStub{reply: Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "1", Name: "list_next_actions"}}}}
+	g, _, _ := newTestFallback(Provider{"ollama", plain}, Provider{"openai", tools})
+	g.BreakerThreshold = 1
+
+	for i := 0; i < 2; i++ {
+		reply, err := g.Chat(context.Background(), NewRequest("what's next?"))
+		if err != nil || len(reply.ToolCalls) != 1 {
+			t.Fatalf("Chat = %+v, %v", reply, err)
+		}
+	}
+	if text, err := g.Generate(context.Background(), NewRequest("hi")); err != nil || text != "plain" {
+		t.Errorf("a provider without tools should stay healthy, got %q, %v", text, err)
+	}
+}
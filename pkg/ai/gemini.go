@@ -2,43 +2,99 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// Generator defines the interface for text generation
+// Generator defines the interface for text generation. Implementations report
+// their token usage to the UsageTracker in the context, if any.
 type Generator interface {
 	GenerateText(ctx context.Context, prompt string) (string, error)
+	// Generate answers a conversation, see Request
+	Generate(ctx context.Context, req Request) (string, error)
+	// Stream is Generate with fn called as the reply is generated. It
+	// returns the whole reply.
+	Stream(ctx context.Context, req Request, fn StreamFunc) (string, error)
+	// GenerateJSON answers req with JSON meant to match schema, using the
+	// provider's structured output support. Use GenerateStructured to
+	// validate it.
+	GenerateJSON(ctx context.Context, req Request, schema *Schema) (string, error)
 }
 
 // Client wraps the Gemini API client
 type Client struct {
-	genaiClient *genai.Client
-	model       *genai.GenerativeModel
+	genaiClient    *genai.Client
+	model          *genai.GenerativeModel
+	modelName      string
+	embeddingModel string
+	timeout        time.Duration // per request, zero means no timeout
 }
 
-// Ensure Client implements Generator
-var _ Generator = (*Client)(nil)
+// Ensure Client implements Generator, ToolCaller and Embedder
+var (
+	_ Generator  = (*Client)(nil)
+	_ ToolCaller = (*Client)(nil)
+	_ Embedder   = (*Client)(nil)
+)
+
+const (
+	geminiDefaultModel          = "gemini-pro"
+	geminiDefaultEmbeddingModel = "text-embedding-004"
+)
 
 // NewClient creates a new Gemini client
 func NewClient(ctx context.Context, apiKey string) (*Client, error) {
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	return NewClientWithConfig(ctx, ClientConfig{APIKey: apiKey})
+}
+
+// NewClientWithConfig creates a Gemini client with a custom endpoint, model
+// or timeout
+func NewClientWithConfig(ctx context.Context, cfg ClientConfig) (*Client, error) {
+	opts := []option.ClientOption{option.WithAPIKey(cfg.APIKey)}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithEndpoint(cfg.BaseURL))
+	}
+	client, err := genai.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gemini client: %w", err)
 	}
 
-	// Use gemini-pro by default
-	model := client.GenerativeModel("gemini-pro")
-
+	modelName := cfg.Model
+	if modelName == "" {
+		modelName = geminiDefaultModel
+	}
+	embeddingModel := cfg.EmbeddingModel
+	if embeddingModel == "" {
+		embeddingModel = geminiDefaultEmbeddingModel
+	}
 	return &Client{
-		genaiClient: client,
-		model:       model,
+		genaiClient:    client,
+		model:          client.GenerativeModel(modelName),
+		modelName:      modelName,
+		embeddingModel: embeddingModel,
+		timeout:        cfg.Timeout,
 	}, nil
 }
 
+// withTimeout bounds a request by the configured timeout. The genai client
+// takes no HTTP client along with an API key, so the timeout goes on ctx.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
 // Close closes the client
 func (c *Client) Close() error {
 	return c.genaiClient.Close()
@@ -46,21 +102,287 @@ func (c *Client) Close() error {
 
 // GenerateText generates text from a prompt
 func (c *Client) GenerateText(ctx context.Context, prompt string) (string, error) {
-	resp, err := c.model.GenerateContent(ctx, genai.Text(prompt))
+	return c.Generate(ctx, NewRequest(prompt))
+}
+
+// Generate answers a conversation. Earlier messages become the chat history.
+func (c *Client) Generate(ctx context.Context, r Request) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	return c.generate(ctx, c.configure(r), r.Messages)
+}
+
+// Stream answers a conversation and calls fn as the reply is generated.
+func (c *Client) Stream(ctx context.Context, r Request, fn StreamFunc) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	chat, last := c.chat(c.configure(r), r.Messages)
+	iter := chat.SendMessageStream(ctx, last...)
+	var text strings.Builder
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			c.reportUsage(ctx, iter.MergedResponse())
+			return text.String(), nil
+		}
+		if err != nil {
+			return text.String(), fmt.Errorf("failed to stream content: %w", geminiError(err))
+		}
+		delta := candidateText(resp)
+		if delta == "" {
+			continue
+		}
+		text.WriteString(delta)
+		if err := fn(delta); err != nil {
+			return text.String(), err
+		}
+	}
+}
+
+// EmbeddingModel returns the model used by Embed
+func (c *Client) EmbeddingModel() string {
+	return c.embeddingModel
+}
+
+// Embed embeds the texts in one batch request
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	em := c.genaiClient.EmbeddingModel(c.embeddingModel)
+	batch := em.NewBatch()
+	for _, t := range texts {
+		batch.AddContent(genai.Text(t))
+	}
+	resp, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed content: %w", geminiError(err))
+	}
+	vectors := make([][]float32, len(resp.Embeddings))
+	for i, e := range resp.Embeddings {
+		vectors[i] = e.Values
+	}
+	if err := checkEmbeddings("gemini", vectors, texts); err != nil {
+		return nil, err
+	}
+	// The batch API reports no token counts
+	reportUsage(ctx, Usage{Provider: "gemini", Model: c.embeddingModel})
+	return vectors, nil
+}
+
+// configure returns a copy of the model with the request's settings
+func (c *Client) configure(r Request) *genai.GenerativeModel {
+	model := *c.model
+	if r.System != "" {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(r.System))
+	}
+	if r.Temperature != nil {
+		model.SetTemperature(float32(*r.Temperature))
+	}
+	if r.MaxTokens > 0 {
+		model.SetMaxOutputTokens(int32(r.MaxTokens))
+	}
+	if len(r.Stop) > 0 {
+		model.StopSequences = r.Stop
+	}
+	return &model
+}
+
+// GenerateJSON generates JSON constrained by the model's ResponseSchema
+func (c *Client) GenerateJSON(ctx context.Context, r Request, schema *Schema) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	model := c.configure(r)
+	model.ResponseMIMEType = "application/json"
+	model.ResponseSchema = geminiSchema(schema)
+	return c.generate(ctx, model, r.Messages)
+}
+
+// Chat answers a conversation, letting the model call req.Tools. Gemini does
+// not identify calls, so they get IDs from the time and their position.
+func (c *Client) Chat(ctx context.Context, r Request) (Message, error) {
+	if err := r.Validate(); err != nil {
+		return Message{}, err
+	}
+	model := c.configure(r)
+	if len(r.Tools) > 0 {
+		tool := &genai.Tool{}
+		for _, t := range r.Tools {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, &genai.FunctionDeclaration{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  geminiSchema(t.Parameters),
+			})
+		}
+		model.Tools = []*genai.Tool{tool}
+	}
+	resp, err := c.send(ctx, model, r.Messages)
+	if err != nil {
+		return Message{}, err
+	}
+	reply := Message{Role: RoleAssistant, Content: candidateText(resp)}
+	turn := time.Now().UnixNano()
+	if resp.Candidates[0].Content != nil {
+		for _, part := range resp.Candidates[0].Content.Parts {
+			if fc, ok := part.(genai.FunctionCall); ok {
+				args, err := json.Marshal(fc.Args)
+				if err != nil {
+					return Message{}, fmt.Errorf("failed to encode %s arguments: %w", fc.Name, err)
+				}
+				reply.ToolCalls = append(reply.ToolCalls, ToolCall{ID: fmt.Sprintf("call_%x_%d", turn, len(reply.ToolCalls)), Name: fc.Name, Arguments: args})
+			}
+		}
+	}
+	return reply, nil
+}
+
+// chat starts a chat session holding the history, and returns the parts to
+// send: the last message, or the results of the last tool calls
+func (c *Client) chat(model *genai.GenerativeModel, messages []Message) (*genai.ChatSession, []genai.Part) {
+	chat := model.StartChat()
+	last := len(messages) - 1
+	for last > 0 && messages[last].Role == RoleTool && messages[last-1].Role == RoleTool {
+		last--
+	}
+	for _, m := range messages[:last] {
+		role := "user"
+		if m.Role == RoleAssistant {
+			role = "model"
+		}
+		chat.History = append(chat.History, &genai.Content{Role: role, Parts: geminiParts(m)})
+	}
+	var parts []genai.Part
+	for _, m := range messages[last:] {
+		parts = append(parts, geminiParts(m)...)
+	}
+	return chat, parts
+}
+
+// geminiParts converts a message to content parts
+func geminiParts(m Message) []genai.Part {
+	if m.Role == RoleTool {
+		return []genai.Part{genai.FunctionResponse{Name: m.ToolName, Response: map[string]any{"result": m.Content}}}
+	}
+	var parts []genai.Part
+	if m.Content != "" || len(m.ToolCalls) == 0 {
+		parts = append(parts, genai.Text(m.Content))
+	}
+	for _, call := range m.ToolCalls {
+		var args map[string]any
+		json.Unmarshal(call.arguments(), &args)
+		parts = append(parts, genai.FunctionCall{Name: call.Name, Args: args})
+	}
+	return parts
+}
+
+// generate sends the last message with the ones before it as history
+func (c *Client) generate(ctx context.Context, model *genai.GenerativeModel, messages []Message) (string, error) {
+	resp, err := c.send(ctx, model, messages)
+	if err != nil {
+		return "", err
+	}
+	return candidateText(resp), nil
+}
+
+// send sends the last message with the ones before it as history and returns
+// a response with at least one candidate
+func (c *Client) send(ctx context.Context, model *genai.GenerativeModel, messages []Message) (*genai.GenerateContentResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	chat, last := c.chat(model, messages)
+	resp, err := chat.SendMessage(ctx, last...)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %w", err)
+		return nil, fmt.Errorf("failed to generate content: %w", geminiError(err))
 	}
 
 	if len(resp.Candidates) == 0 {
-		return "", fmt.Errorf("no candidates returned")
+		return nil, fmt.Errorf("no candidates returned")
+	}
+
+	c.reportUsage(ctx, resp)
+	return resp, nil
+}
+
+// reportUsage reports the token counts of a response
+func (c *Client) reportUsage(ctx context.Context, resp *genai.GenerateContentResponse) {
+	u := Usage{Provider: "gemini", Model: c.modelName}
+	if resp != nil && resp.UsageMetadata != nil {
+		u.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+		u.CompletionTokens = int(resp.UsageMetadata.CandidatesTokenCount)
 	}
+	reportUsage(ctx, u)
+}
 
+// candidateText returns the text of the first candidate
+func candidateText(resp *genai.GenerateContentResponse) string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
 	var sb strings.Builder
 	for _, part := range resp.Candidates[0].Content.Parts {
 		if txt, ok := part.(genai.Text); ok {
 			sb.WriteString(string(txt))
 		}
 	}
+	return sb.String()
+}
+
+// geminiCodes maps retryable gRPC codes to HTTP statuses
+var geminiCodes = map[codes.Code]int{
+	codes.ResourceExhausted: http.StatusTooManyRequests,
+	codes.Unavailable:       http.StatusServiceUnavailable,
+	codes.Internal:          http.StatusInternalServerError,
+}
+
+// geminiError turns rate limit and server errors into an *APIError so they
+// are retried like the other providers'
+func geminiError(err error) error {
+	var httpErr interface{ HTTPCode() int }
+	if errors.As(err, &httpErr) && httpErr.HTTPCode() > 0 {
+		return &APIError{Provider: "gemini", StatusCode: httpErr.HTTPCode(), Message: err.Error()}
+	}
+	if st, ok := status.FromError(err); ok {
+		if code, ok := geminiCodes[st.Code()]; ok {
+			return &APIError{Provider: "gemini", StatusCode: code, Message: st.Message()}
+		}
+	}
+	return err
+}
+
+// geminiTypes maps JSON Schema types to Gemini's
+var geminiTypes = map[string]genai.Type{
+	"object":  genai.TypeObject,
+	"array":   genai.TypeArray,
+	"string":  genai.TypeString,
+	"number":  genai.TypeNumber,
+	"integer": genai.TypeInteger,
+	"boolean": genai.TypeBoolean,
+}
 
-	return sb.String(), nil
+// geminiSchema converts a schema to Gemini's representation
+func geminiSchema(s *Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	out := &genai.Schema{
+		Type:        geminiTypes[s.Type],
+		Description: s.Description,
+		Required:    s.Required,
+		Items:       geminiSchema(s.Items),
+		Enum:        s.Enum,
+	}
+	if len(s.Enum) > 0 {
+		out.Format = "enum"
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, p := range s.Properties {
+			out.Properties[name] = geminiSchema(p)
+		}
+	}
+	return out
 }
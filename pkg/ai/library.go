@@ -0,0 +1,322 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PromptDir is the vault folder holding the prompt templates
+const PromptDir = "0. GTD System/Prompts"
+
+// Built-in prompt names
+const (
+	PromptInboxAnalysis        = "inbox-analysis"
+	PromptWeeklyReview         = "weekly-review"
+	PromptWeeklyReviewMarkdown = "weekly-review-markdown"
+	PromptDailySummary         = "daily-summary"
+	PromptAssistant            = "assistant"
+	PromptWaitingFollowUp      = "waiting-follow-up"
+)
+
+//go:embed prompts/*.md
+var builtinPrompts embed.FS
+
+// ModelPreferences are the generation settings a prompt asks for
+type ModelPreferences struct {
+	Provider    string   `yaml:"provider"` // tried first in a fallback chain
+	Temperature *float64 `yaml:"temperature"`
+	MaxTokens   int      `yaml:"max_tokens"`
+}
+
+// PromptTemplate is a prompt stored as Markdown. The frontmatter holds the
+// version, the variables, an optional output schema and model preferences;
+// the body is the prompt with {{variable}} placeholders.
+type PromptTemplate struct {
+	Name        string
+	Version     string // frontmatter version, or a hash of the file
+	Description string
+	Variables   []string
+	Schema      *Schema // output_schema, nil for the caller's default
+	Model       ModelPreferences
+	Body        string
+}
+
+// promptFrontmatter is the frontmatter of a prompt file
+type promptFrontmatter struct {
+	Version      string           `yaml:"version"`
+	Description  string           `yaml:"description"`
+	Variables    []string         `yaml:"variables"`
+	OutputSchema map[string]any   `yaml:"output_schema"`
+	Model        ModelPreferences `yaml:"model"`
+}
+
+// ParsePromptTemplate parses a prompt file
+func ParsePromptTemplate(name string, data []byte) (*PromptTemplate, error) {
+	sum := sha256.Sum256(data)
+	p := &PromptTemplate{Name: name, Version: hex.EncodeToString(sum[:4]), Body: string(data)}
+
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(content, "---\n"); ok {
+		end := strings.Index(rest, "\n---\n")
+		if end < 0 {
+			return nil, fmt.Errorf("prompt %s: unterminated frontmatter", name)
+		}
+		var fm promptFrontmatter
+		if err := yaml.Unmarshal([]byte(rest[:end]), &fm); err != nil {
+			return nil, fmt.Errorf("prompt %s: invalid frontmatter: %w", name, err)
+		}
+		if fm.Version != "" {
+			p.Version = fm.Version
+		}
+		p.Description = fm.Description
+		p.Variables = fm.Variables
+		p.Model = fm.Model
+		if fm.OutputSchema != nil {
+			// Round-trip through JSON to reuse the Schema field names
+			data, err := json.Marshal(fm.OutputSchema)
+			if err != nil {
+				return nil, fmt.Errorf("prompt %s: invalid output_schema: %w", name, err)
+			}
+			if err := json.Unmarshal(data, &p.Schema); err != nil {
+				return nil, fmt.Errorf("prompt %s: invalid output_schema: %w", name, err)
+			}
+		}
+		p.Body = rest[end+len("\n---\n"):]
+	}
+	return p, nil
+}
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// Render fills the placeholders. Every declared variable must be given;
+// placeholders without a value are left as they are.
+func (p *PromptTemplate) Render(vars map[string]string) (*Prompt, error) {
+	for _, v := range p.Variables {
+		if _, ok := vars[v]; !ok {
+			return nil, fmt.Errorf("prompt %s: missing variable %q", p.Name, v)
+		}
+	}
+	text := placeholderRe.ReplaceAllStringFunc(p.Body, func(m string) string {
+		if v, ok := vars[placeholderRe.FindStringSubmatch(m)[1]]; ok {
+			return v
+		}
+		return m
+	})
+	return &Prompt{Name: p.Name, Version: p.Version, Text: text, Schema: p.Schema, Model: p.Model}, nil
+}
+
+// Prompt is a rendered prompt template
+type Prompt struct {
+	Name    string
+	Version string
+	Text    string
+	Schema  *Schema
+	Model   ModelPreferences
+}
+
+// Ref identifies the prompt and its version, e.g. "inbox-analysis@3", for
+// recording on generated notes
+func (p *Prompt) Ref() string {
+	return p.Name + "@" + p.Version
+}
+
+// Request returns a request for the prompt with the preferred settings
+func (p *Prompt) Request() Request {
+	r := NewRequest(p.Text)
+	r.Temperature = p.Model.Temperature
+	r.MaxTokens = p.Model.MaxTokens
+	return r
+}
+
+// Context returns ctx carrying the preferred provider, if any
+func (p *Prompt) Context(ctx context.Context) context.Context {
+	if p.Model.Provider == "" {
+		return ctx
+	}
+	return WithPreferredProvider(ctx, p.Model.Provider)
+}
+
+// schemaOr returns the prompt's output schema, or def. A custom schema
+// without a title keeps def's, which names it for the providers.
+func (p *Prompt) schemaOr(def *Schema) *Schema {
+	if p.Schema == nil {
+		return def
+	}
+	if p.Schema.Title == "" {
+		s := *p.Schema
+		s.Title = def.Title
+		return &s
+	}
+	return p.Schema
+}
+
+// Retriever finds the vault notes relevant to a query, formatted for a prompt
+type Retriever interface {
+	Retrieve(ctx context.Context, query string) (string, error)
+}
+
+// PromptLibrary loads prompt templates from a vault folder. A file is parsed
+// again when it changes on disk, so edits made in Obsidian apply to the next
+// call. Prompts without a file fall back to the built-in ones. A nil library
+// uses only the built-in prompts.
+type PromptLibrary struct {
+	// Retriever, if set, fills the notes variable of the built-in prompts
+	// with relevant vault notes
+	Retriever Retriever
+
+	dir string
+
+	mu    sync.Mutex
+	cache map[string]*cachedPrompt
+}
+
+type cachedPrompt struct {
+	modTime time.Time
+	size    int64
+	prompt  *PromptTemplate
+}
+
+// NewPromptLibrary creates a library reading the prompts in dir
+func NewPromptLibrary(dir string) *PromptLibrary {
+	return &PromptLibrary{dir: dir, cache: make(map[string]*cachedPrompt)}
+}
+
+// Get returns the named prompt template. A prompt file that cannot be read
+// or parsed is logged and the built-in prompt is used.
+func (l *PromptLibrary) Get(name string) (*PromptTemplate, error) {
+	if l != nil {
+		p, err := l.load(name)
+		if err == nil {
+			return p, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			// A broken edit must not stop the assistant
+			log.Printf("Prompt %s: %v; using the built-in prompt", name, err)
+		}
+	}
+	return builtinPrompt(name)
+}
+
+// Render renders the named prompt with vars
+func (l *PromptLibrary) Render(name string, vars map[string]string) (*Prompt, error) {
+	p, err := l.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.Render(vars)
+}
+
+// load reads name from the vault folder, reusing the parsed template while
+// the file is unchanged
+func (l *PromptLibrary) load(name string) (*PromptTemplate, error) {
+	path := filepath.Join(l.dir, name+".md")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.cache[name]; ok && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.prompt, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParsePromptTemplate(name, data)
+	if err != nil {
+		return nil, err
+	}
+	l.cache[name] = &cachedPrompt{modTime: info.ModTime(), size: info.Size(), prompt: p}
+	return p, nil
+}
+
+// builtinPrompt returns a prompt shipped with the binary
+func builtinPrompt(name string) (*PromptTemplate, error) {
+	data, err := builtinPrompts.ReadFile("prompts/" + name + ".md")
+	if err != nil {
+		return nil, fmt.Errorf("unknown prompt %q", name)
+	}
+	return ParsePromptTemplate(name, data)
+}
+
+// supersededPrompts are the SHA-256 hashes of built-in prompts replaced by a
+// newer version. A vault copy matching one was never edited.
+var supersededPrompts = map[string]bool{
+	"6db12ca8d5c34f633f069099c752fb3976f07b8aea64a371da58d3863ad8cc9e": true, // daily-summary@1
+	"89af8537ba8d5ed39f1f6da33467edc087890f78e2ee157699688b7468ab7055": true, // inbox-analysis@1
+	"eba91a109fd1c6d264f52bdd36de82a82b40db982ba8be538ba60400a8f1c8cb": true, // weekly-review-markdown@1
+	"6c41b1a4d4f43e55c302cd909be23c1290cca9238c40ff93c7c5be479c564a1a": true, // weekly-review@1
+}
+
+// InstallPrompts copies the built-in prompts that are missing from dir, so
+// they can be edited in the vault, and upgrades unedited copies of older
+// built-in prompts. An edited prompt older than the built-in one is kept and
+// logged. It returns the number of files written.
+func InstallPrompts(dir string) (int, error) {
+	entries, err := builtinPrompts.ReadDir("prompts")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create prompt folder: %w", err)
+	}
+	written := 0
+	for _, e := range entries {
+		data, err := builtinPrompts.ReadFile("prompts/" + e.Name())
+		if err != nil {
+			return written, err
+		}
+		path := filepath.Join(dir, e.Name())
+		if existing, err := os.ReadFile(path); err == nil {
+			sum := sha256.Sum256(existing)
+			if !supersededPrompts[hex.EncodeToString(sum[:])] {
+				warnOutdatedPrompt(e.Name(), existing, data)
+				continue
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return written, fmt.Errorf("failed to read prompt: %w", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return written, fmt.Errorf("failed to write prompt: %w", err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+// warnOutdatedPrompt logs when an edited vault prompt has a lower version
+// than the built-in one
+func warnOutdatedPrompt(file string, vaultData, builtinData []byte) {
+	name := strings.TrimSuffix(file, ".md")
+	mine, err := ParsePromptTemplate(name, vaultData)
+	if err != nil {
+		return
+	}
+	builtin, err := ParsePromptTemplate(name, builtinData)
+	if err != nil {
+		return
+	}
+	v, err1 := strconv.Atoi(mine.Version)
+	b, err2 := strconv.Atoi(builtin.Version)
+	if err1 == nil && err2 == nil && v < b {
+		log.Printf("Prompt %s is version %d but the built-in prompt is version %d; merge its changes to keep them", name, v, b)
+	}
+}
@@ -0,0 +1,161 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePromptTemplate(t *testing.T) {
+	data := []byte("---\nversion: 3\nvariables: [name]\noutput_schema:\n  type: object\n  properties:\n    greeting: {type: string}\nmodel:\n  provider: ollama\n  temperature: 0.5\n  max_tokens: 100\n---\nSay hello to {{name}} and {{ other }}.")
+	tmpl, err := ParsePromptTemplate("hello", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tmpl.Version != "3" || tmpl.Schema == nil || tmpl.Schema.Properties["greeting"].Type != "string" {
+		t.Errorf("unexpected template: %+v", tmpl)
+	}
+	if tmpl.Model.Provider != "ollama" || tmpl.Model.Temperature == nil || *tmpl.Model.Temperature != 0.5 || tmpl.Model.MaxTokens != 100 {
+		t.Errorf("unexpected model preferences: %+v", tmpl.Model)
+	}
+
+	p, err := tmpl.Render(map[string]string{"name": "Bob"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Text != "Say hello to Bob and {{ other }}." || p.Ref() != "hello@3" {
+		t.Errorf("text = %q, ref = %q", p.Text, p.Ref())
+	}
+	if r := p.Request(); r.MaxTokens != 100 || *r.Temperature != 0.5 {
+		t.Errorf("unexpected request: %+v", r)
+	}
+
+	if _, err := tmpl.Render(nil); err == nil {
+		t.Error("expected an error for a missing variable")
+	}
+	if _, err := ParsePromptTemplate("broken", []byte("---\nversion: 1\n")); err == nil {
+		t.Error("expected an error for unterminated frontmatter")
+	}
+}
+
+func TestPromptLibraryReload(t *testing.T) {
+	dir := t.TempDir()
+	lib := NewPromptLibrary(dir)
+
+	// Without a file the built-in prompt is used
+	p, err := lib.DailySummaryPrompt(context.Background(), "2024-05-01", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Version != "2" || !strings.Contains(p.Text, "2024-05-01") {
+		t.Errorf("unexpected built-in prompt: %+v", p)
+	}
+
+	path := filepath.Join(dir, PromptDailySummary+".md")
+	if err := os.WriteFile(path, []byte("---\nversion: 2\nvariables: [date]\n---\nSummary of {{date}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := lib.Render(PromptDailySummary, map[string]string{"date": "today"}); p.Ref() != "daily-summary@2" || p.Text != "Summary of today" {
+		t.Errorf("unexpected prompt after edit: %+v", p)
+	}
+
+	// An edit is picked up without a restart
+	if err := os.WriteFile(path, []byte("---\nversion: 3\nvariables: [date]\n---\nRecap of {{date}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	os.Chtimes(path, later, later)
+	if p, _ := lib.Render(PromptDailySummary, map[string]string{"date": "today"}); p.Ref() != "daily-summary@3" || p.Text != "Recap of today" {
+		t.Errorf("unexpected prompt after second edit: %+v", p)
+	}
+
+	// A broken file falls back to the built-in prompt
+	if err := os.WriteFile(path, []byte("---\nversion: [\n---\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, later.Add(time.Second), later.Add(time.Second))
+	if p, _ := lib.Get(PromptDailySummary); p.Version != "2" {
+		t.Errorf("expected the built-in prompt, got version %s", p.Version)
+	}
+
+	if _, err := lib.Get("missing"); err == nil {
+		t.Error("expected an error for an unknown prompt")
+	}
+}
+
+func TestInstallPrompts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Prompts")
+	n, err := InstallPrompts(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 6 {
+		t.Errorf("expected 6 prompts installed, got %d", n)
+	}
+	if n, _ := InstallPrompts(dir); n != 0 {
+		t.Errorf("expected existing prompts to be kept, got %d written", n)
+	}
+
+	// An unedited older built-in is upgraded, an edited one is kept
+	old := []byte("---\nversion: 1\n---\nAnalyze {{content}}\n")
+	sum := sha256.Sum256(old)
+	supersededPrompts[hex.EncodeToString(sum[:])] = true
+	t.Cleanup(func() { delete(supersededPrompts, hex.EncodeToString(sum[:])) })
+	edited := []byte("---\nversion: 1\n---\nAnalyze {{content}} carefully\n")
+	if err := os.WriteFile(filepath.Join(dir, "inbox-analysis.md"), old, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "daily-summary.md"), edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := InstallPrompts(dir); n != 1 {
+		t.Errorf("expected 1 prompt upgraded, got %d", n)
+	}
+	if p, err := NewPromptLibrary(dir).Get(PromptInboxAnalysis); err != nil || p.Version != "2" {
+		t.Errorf("inbox-analysis = %+v, %v", p, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "daily-summary.md")); string(data) != string(edited) {
+		t.Errorf("edited prompt was overwritten: %q", data)
+	}
+}
+
+type stubRetriever struct {
+	notes string
+	err   error
+	query string
+}
+
+func (r *stubRetriever) Retrieve(ctx context.Context, query string) (string, error) {
+	r.query = query
+	return r.notes, r.err
+}
+
+func TestPromptLibraryRetriever(t *testing.T) {
+	lib := NewPromptLibrary(t.TempDir())
+	r := &stubRetriever{notes: "### Fence repair\nBob quoted 300 EUR.\n"}
+	lib.Retriever = r
+
+	p, err := lib.InboxPrompt(context.Background(), "call bob about the fence")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.query != "call bob about the fence" || !strings.Contains(p.Text, "Bob quoted 300 EUR.") {
+		t.Errorf("query = %q, prompt = %q", r.query, p.Text)
+	}
+
+	// A failing retriever does not fail the prompt
+	r.err = errors.New("index unavailable")
+	p, err = lib.DailySummaryPrompt(context.Background(), "2024-05-01", []string{"Fence repair"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(p.Text, "- Fence repair") || strings.Contains(p.Text, "300 EUR") {
+		t.Errorf("unexpected prompt: %q", p.Text)
+	}
+}
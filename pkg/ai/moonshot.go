@@ -28,17 +28,33 @@ var _ Generator = (*MoonshotClient)(nil)
 
 // NewMoonshotClient creates a new Moonshot API client
 func NewMoonshotClient(apiKey string) *MoonshotClient {
+	return NewMoonshotClientWithConfig(ClientConfig{APIKey: apiKey})
+}
+
+// NewMoonshotClientWithConfig creates a client with a custom endpoint, model or
+// timeout
+func NewMoonshotClientWithConfig(cfg ClientConfig) *MoonshotClient {
+	cfg = cfg.withDefaults(moonshotDefaultBaseURL, moonshotDefaultModel)
 	return &MoonshotClient{
-		httpClient: &http.Client{},
-		apiKey:     apiKey,
-		model:      moonshotDefaultModel,
-		baseURL:    moonshotDefaultBaseURL,
+		httpClient: cfg.httpClient(),
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		baseURL:    cfg.BaseURL,
 	}
 }
 
 type moonshotRequest struct {
-	Model    string            `json:"model"`
-	Messages []moonshotMessage `json:"messages"`
+	Model          string                  `json:"model"`
+	Messages       []moonshotMessage       `json:"messages"`
+	Temperature    *float64                `json:"temperature,omitempty"`
+	MaxTokens      int                     `json:"max_tokens,omitempty"`
+	Stop           []string                `json:"stop,omitempty"`
+	Stream         bool                    `json:"stream,omitempty"`
+	ResponseFormat *moonshotResponseFormat `json:"response_format,omitempty"`
+}
+
+type moonshotResponseFormat struct {
+	Type string `json:"type"`
 }
 
 type moonshotMessage struct {
@@ -48,6 +64,7 @@ type moonshotMessage struct {
 
 type moonshotResponse struct {
 	Choices []moonshotChoice `json:"choices"`
+	Usage   chatUsage        `json:"usage"`
 	Error   *moonshotError   `json:"error,omitempty"`
 }
 
@@ -62,28 +79,95 @@ type moonshotError struct {
 
 // GenerateText sends a prompt to the Moonshot API and returns the generated text
 func (c *MoonshotClient) GenerateText(ctx context.Context, prompt string) (string, error) {
-	reqBody := moonshotRequest{
-		Model: c.model,
-		Messages: []moonshotMessage{
-			{Role: "user", Content: prompt},
-		},
+	return c.Generate(ctx, NewRequest(prompt))
+}
+
+// Generate sends a conversation to the Moonshot API and returns the reply
+func (c *MoonshotClient) Generate(ctx context.Context, r Request) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
 	}
+	return c.complete(ctx, c.request(r))
+}
 
+// request converts a Request to the chat completions format
+func (c *MoonshotClient) request(r Request) moonshotRequest {
+	body := moonshotRequest{
+		Model:       c.model,
+		Temperature: r.Temperature,
+		MaxTokens:   r.MaxTokens,
+		Stop:        r.Stop,
+	}
+	if r.System != "" {
+		body.Messages = append(body.Messages, moonshotMessage{Role: "system", Content: r.System})
+	}
+	for _, m := range r.Messages {
+		body.Messages = append(body.Messages, moonshotMessage{Role: m.Role, Content: m.Content})
+	}
+	return body
+}
+
+// GenerateJSON asks for a JSON object matching schema. Moonshot has a JSON
+// mode but no schema support, so the schema goes into the prompt.
+func (c *MoonshotClient) GenerateJSON(ctx context.Context, r Request, schema *Schema) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	body := c.request(r.withLastMessage(func(prompt string) string { return schemaInstructions(prompt, schema) }))
+	body.ResponseFormat = &moonshotResponseFormat{Type: "json_object"}
+	return c.complete(ctx, body)
+}
+
+// Stream sends a conversation to the Moonshot API and calls fn as the reply is
+// generated
+func (c *MoonshotClient) Stream(ctx context.Context, r Request, fn StreamFunc) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	body := c.request(r)
+	body.Stream = true
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	text, usage, err := readChatStream(resp.Body, fn)
+	c.reportUsage(ctx, usage)
+	return text, err
+}
+
+// post sends a chat completion request and returns the successful response
+func (c *MoonshotClient) post(ctx context.Context, reqBody moonshotRequest) (*http.Response, error) {
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(bodyBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError("moonshot", resp)
+	}
+	return resp, nil
+}
+
+// complete sends a chat completion request and returns the first choice
+func (c *MoonshotClient) complete(ctx context.Context, reqBody moonshotRequest) (string, error) {
+	resp, err := c.post(ctx, reqBody)
+	if err != nil {
+		return "", err
 	}
 	defer resp.Body.Close()
 
@@ -92,10 +176,6 @@ func (c *MoonshotClient) GenerateText(ctx context.Context, prompt string) (strin
 		return "", fmt.Errorf("failed to read response: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("moonshot API error (status %d): %s", resp.StatusCode, string(respBytes))
-	}
-
 	var result moonshotResponse
 	if err := json.Unmarshal(respBytes, &result); err != nil {
 		return "", fmt.Errorf("failed to parse response: %w", err)
@@ -109,9 +189,15 @@ func (c *MoonshotClient) GenerateText(ctx context.Context, prompt string) (strin
 		return "", fmt.Errorf("no choices returned")
 	}
 
+	c.reportUsage(ctx, result.Usage)
 	return result.Choices[0].Message.Content, nil
 }
 
+// reportUsage reports the token usage of a completion
+func (c *MoonshotClient) reportUsage(ctx context.Context, u chatUsage) {
+	reportUsage(ctx, Usage{Provider: "moonshot", Model: c.model, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens})
+}
+
 // Close is a no-op for the HTTP-based Moonshot client
 func (c *MoonshotClient) Close() error {
 	return nil
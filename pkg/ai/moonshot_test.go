@@ -111,3 +111,29 @@ func TestMoonshotClose(t *testing.T) {
 		t.Fatalf("expected nil error from Close, got %v", err)
 	}
 }
+
+func TestMoonshotGenerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		msgs, _ := raw["messages"].([]interface{})
+		if raw["max_tokens"] != 50.0 || len(msgs) != 2 || msgs[0].(map[string]interface{})["role"] != "system" {
+			t.Errorf("unexpected request: %v", raw)
+		}
+		json.NewEncoder(w).Encode(moonshotResponse{Choices: []moonshotChoice{{Message: moonshotMessage{Role: "assistant", Content: "ok"}}}})
+	}))
+	defer server.Close()
+
+	client := NewMoonshotClient("test-key")
+	client.baseURL = server.URL
+
+	result, err := client.Generate(context.Background(), Request{System: "Be brief.", Messages: []Message{{Role: RoleUser, Content: "hi"}}, MaxTokens: 50})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "ok" {
+		t.Errorf("expected 'ok', got %q", result)
+	}
+}
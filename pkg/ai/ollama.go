@@ -0,0 +1,272 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	ollamaDefaultBaseURL = "http://localhost:11434"
+	ollamaDefaultModel   = "llama3.2"
+
+	ollamaDefaultEmbeddingModel = "nomic-embed-text"
+)
+
+// OllamaClient implements the Generator interface using the native Ollama
+// API, for vaults on machines without cloud AI access
+type OllamaClient struct {
+	httpClient     *http.Client
+	model          string
+	embeddingModel string
+	baseURL        string
+}
+
+// Ensure OllamaClient implements Generator and Embedder
+var (
+	_ Generator = (*OllamaClient)(nil)
+	_ Embedder  = (*OllamaClient)(nil)
+)
+
+// NewOllamaClient creates a new Ollama client. The API key is ignored.
+func NewOllamaClient(cfg ClientConfig) *OllamaClient {
+	cfg = cfg.withDefaults(ollamaDefaultBaseURL, ollamaDefaultModel)
+	if cfg.EmbeddingModel == "" {
+		cfg.EmbeddingModel = ollamaDefaultEmbeddingModel
+	}
+	return &OllamaClient{
+		httpClient:     cfg.httpClient(),
+		model:          cfg.Model,
+		embeddingModel: cfg.EmbeddingModel,
+		baseURL:        cfg.BaseURL,
+	}
+}
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type ollamaGenerateRequest struct {
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	Stream  bool           `json:"stream"`
+	Options *ollamaOptions `json:"options,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   any             `json:"format,omitempty"` // "json" or a JSON schema
+	Options  *ollamaOptions  `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ollamaResponse is a response, or a stream chunk, of /api/generate or
+// /api/chat
+type ollamaResponse struct {
+	Response        string        `json:"response"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"` // set when done
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// GenerateText sends a prompt to /api/generate and returns the generated text
+func (c *OllamaClient) GenerateText(ctx context.Context, prompt string) (string, error) {
+	var resp ollamaResponse
+	if err := c.call(ctx, "/api/generate", ollamaGenerateRequest{Model: c.model, Prompt: prompt}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Response, nil
+}
+
+// Generate sends a conversation to /api/chat and returns the reply
+func (c *OllamaClient) Generate(ctx context.Context, r Request) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	var resp ollamaResponse
+	if err := c.call(ctx, "/api/chat", c.chatRequest(r), &resp); err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+// GenerateJSON asks /api/chat for JSON constrained to schema
+func (c *OllamaClient) GenerateJSON(ctx context.Context, r Request, schema *Schema) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	var resp ollamaResponse
+	body := c.chatRequest(r)
+	body.Format = schema
+	if err := c.call(ctx, "/api/chat", body, &resp); err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+// Stream sends a conversation to /api/chat and calls fn as the reply is
+// generated
+func (c *OllamaClient) Stream(ctx context.Context, r Request, fn StreamFunc) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	body := c.chatRequest(r)
+	body.Stream = true
+	resp, err := c.post(ctx, "/api/chat", body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// The stream is one JSON object per line
+	var text strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return text.String(), fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return text.String(), fmt.Errorf("stream error: %s", chunk.Error)
+		}
+		if delta := chunk.Message.Content; delta != "" {
+			text.WriteString(delta)
+			if err := fn(delta); err != nil {
+				return text.String(), err
+			}
+		}
+		if chunk.Done {
+			c.reportUsage(ctx, chunk)
+			return text.String(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return text.String(), fmt.Errorf("failed to read stream: %w", err)
+	}
+	return text.String(), nil
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+// EmbeddingModel returns the model used by Embed
+func (c *OllamaClient) EmbeddingModel() string {
+	return c.embeddingModel
+}
+
+// Embed returns embeddings from /api/embed
+func (c *OllamaClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := c.post(ctx, "/api/embed", ollamaEmbedRequest{Model: c.embeddingModel, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if err := checkEmbeddings("ollama", result.Embeddings, texts); err != nil {
+		return nil, err
+	}
+	reportUsage(ctx, Usage{Provider: "ollama", Model: c.embeddingModel, PromptTokens: result.PromptEvalCount})
+	return result.Embeddings, nil
+}
+
+// chatRequest converts a Request to the /api/chat format
+func (c *OllamaClient) chatRequest(r Request) ollamaChatRequest {
+	body := ollamaChatRequest{Model: c.model}
+	if r.System != "" {
+		body.Messages = append(body.Messages, ollamaMessage{Role: "system", Content: r.System})
+	}
+	for _, m := range r.Messages {
+		body.Messages = append(body.Messages, ollamaMessage{Role: m.Role, Content: m.Content})
+	}
+	if r.Temperature != nil || r.MaxTokens > 0 || len(r.Stop) > 0 {
+		body.Options = &ollamaOptions{Temperature: r.Temperature, NumPredict: r.MaxTokens, Stop: r.Stop}
+	}
+	return body
+}
+
+// call sends a non-streaming request and decodes the response
+func (c *OllamaClient) call(ctx context.Context, path string, reqBody any, out *ollamaResponse) error {
+	resp, err := c.post(ctx, path, reqBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(respBytes, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if out.Error != "" {
+		return fmt.Errorf("ollama API error: %s", out.Error)
+	}
+	c.reportUsage(ctx, *out)
+	return nil
+}
+
+// reportUsage reports the token counts of a final response
+func (c *OllamaClient) reportUsage(ctx context.Context, resp ollamaResponse) {
+	reportUsage(ctx, Usage{Provider: "ollama", Model: c.model, PromptTokens: resp.PromptEvalCount, CompletionTokens: resp.EvalCount})
+}
+
+// post sends a request and returns the successful response
+func (c *OllamaClient) post(ctx context.Context, path string, reqBody any) (*http.Response, error) {
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError("ollama", resp)
+	}
+	return resp, nil
+}
+
+// Close is a no-op for the HTTP-based Ollama client
+func (c *OllamaClient) Close() error {
+	return nil
+}
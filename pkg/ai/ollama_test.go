@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOllamaGenerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if req["model"] != "qwen2.5" || req["stream"] != false {
+			t.Errorf("unexpected request: %v", req)
+		}
+		switch r.URL.Path {
+		case "/api/generate":
+			fmt.Fprintf(w, `{"response":"echo %s","done":true}`, req["prompt"])
+		case "/api/chat":
+			messages := req["messages"].([]any)
+			if len(messages) != 2 || messages[0].(map[string]any)["role"] != "system" {
+				t.Errorf("messages = %v", messages)
+			}
+			if opts := req["options"].(map[string]any); opts["num_predict"] != 50.0 {
+				t.Errorf("options = %v", opts)
+			}
+			if req["format"] != nil {
+				format := req["format"].(map[string]any)
+				if format["type"] != "object" {
+					t.Errorf("format = %v, want the schema", format)
+				}
+				fmt.Fprint(w, `{"message":{"role":"assistant","content":"{\"title\":\"x\"}"},"done":true}`)
+				return
+			}
+			fmt.Fprint(w, `{"message":{"role":"assistant","content":"chat reply"},"done":true}`)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(ClientConfig{BaseURL: server.URL + "/", Model: "qwen2.5", Timeout: time.Second})
+	ctx := context.Background()
+
+	if text, err := client.GenerateText(ctx, "hi"); err != nil || text != "echo hi" {
+		t.Errorf("GenerateText = %q, %v", text, err)
+	}
+	req := NewRequest("hi")
+	req.System = "Be brief."
+	req.MaxTokens = 50
+	if text, err := client.Generate(ctx, req); err != nil || text != "chat reply" {
+		t.Errorf("Generate = %q, %v", text, err)
+	}
+	if text, err := client.GenerateJSON(ctx, req, &Schema{Type: "object"}); err != nil || text != `{"title":"x"}` {
+		t.Errorf("GenerateJSON = %q, %v", text, err)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"content":"Hello"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"content":" there"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"content":""},"done":true}`)
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(ClientConfig{BaseURL: server.URL})
+	var deltas []string
+	text, err := client.Stream(context.Background(), NewRequest("hi"), func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil || text != "Hello there" || len(deltas) != 2 {
+		t.Errorf("Stream = %q, %v, deltas %q", text, err, deltas)
+	}
+}
+
+func TestOpenAICompatibleConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("unexpected Authorization %q without an API key", auth)
+		}
+		var raw map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&raw)
+		if raw["model"] != "llama3.1:8b" {
+			t.Errorf("model = %v", raw["model"])
+		}
+		// Compatible servers such as Ollama read max_tokens only
+		if _, ok := raw["max_completion_tokens"]; ok || raw["max_tokens"] != 50.0 {
+			t.Errorf("token limit = %v", raw)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"local"}}]}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClientWithConfig(ClientConfig{BaseURL: server.URL + "/v1", Model: "llama3.1:8b", Provider: "openai-compatible"})
+	req := NewRequest("hi")
+	req.MaxTokens = 50
+	ctx, usage := WithUsageTracker(context.Background())
+	if text, err := client.Generate(ctx, req); err != nil || !strings.Contains(text, "local") {
+		t.Errorf("Generate = %q, %v", text, err)
+	}
+	if usage.Model() != "openai-compatible/llama3.1:8b" {
+		t.Errorf("usage recorded for %q", usage.Model())
+	}
+}
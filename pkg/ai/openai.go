@@ -7,46 +7,127 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 )
 
 const (
 	openAIDefaultBaseURL = "https://api.openai.com/v1"
 	openAIDefaultModel   = "gpt-4o-mini"
+
+	openAIDefaultEmbeddingModel = "text-embedding-3-small"
 )
 
 // OpenAIClient implements the Generator interface using the OpenAI chat completions API.
+// It also works with OpenAI-compatible servers (vLLM, LM Studio, Ollama) through
+// NewOpenAIClientWithConfig.
 type OpenAIClient struct {
-	httpClient *http.Client
-	apiKey     string
-	model      string
-	baseURL    string
+	httpClient     *http.Client
+	provider       string // recorded with usage, "openai" by default
+	apiKey         string
+	model          string
+	embeddingModel string
+	baseURL        string
+	maxTokensField bool // send max_tokens, which compatible servers read, instead of max_completion_tokens
 }
 
-// Ensure OpenAIClient implements Generator.
-var _ Generator = (*OpenAIClient)(nil)
+// Ensure OpenAIClient implements Generator, ToolCaller and Embedder.
+var (
+	_ Generator  = (*OpenAIClient)(nil)
+	_ ToolCaller = (*OpenAIClient)(nil)
+	_ Embedder   = (*OpenAIClient)(nil)
+)
 
 // NewOpenAIClient creates a new OpenAI API client.
 func NewOpenAIClient(apiKey string) *OpenAIClient {
+	return NewOpenAIClientWithConfig(ClientConfig{APIKey: apiKey})
+}
+
+// NewOpenAIClientWithConfig creates a client with a custom endpoint, model or
+// timeout.
+func NewOpenAIClientWithConfig(cfg ClientConfig) *OpenAIClient {
+	cfg = cfg.withDefaults(openAIDefaultBaseURL, openAIDefaultModel)
+	if cfg.EmbeddingModel == "" {
+		cfg.EmbeddingModel = openAIDefaultEmbeddingModel
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = "openai"
+	}
 	return &OpenAIClient{
-		httpClient: &http.Client{},
-		apiKey:     apiKey,
-		model:      openAIDefaultModel,
-		baseURL:    openAIDefaultBaseURL,
+		httpClient:     cfg.httpClient(),
+		provider:       cfg.Provider,
+		apiKey:         cfg.APIKey,
+		model:          cfg.Model,
+		embeddingModel: cfg.EmbeddingModel,
+		baseURL:        cfg.BaseURL,
+		maxTokensField: !isOpenAIHost(cfg.BaseURL),
 	}
 }
 
+// isOpenAIHost reports whether baseURL is the OpenAI API itself rather than
+// an OpenAI-compatible server
+func isOpenAIHost(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	return err == nil && u.Hostname() == "api.openai.com"
+}
+
 type openAIRequest struct {
-	Model    string          `json:"model"`
-	Messages []openAIMessage `json:"messages"`
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	MaxCompletion  int                   `json:"max_completion_tokens,omitempty"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"` // for OpenAI-compatible servers
+	Stop           []string              `json:"stop,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Tools          []openAITool          `json:"tools,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string  `json:"name"`
+	Schema *Schema `json:"schema"`
+	Strict bool    `json:"strict"`
 }
 
 type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"` // "function"
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Parameters  *Schema `json:"parameters,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // "function"
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON encoded
+	} `json:"function"`
 }
 
 type openAIResponse struct {
 	Choices []openAIChoice `json:"choices"`
+	Usage   chatUsage      `json:"usage"`
 	Error   *openAIError   `json:"error,omitempty"`
 }
 
@@ -61,54 +142,213 @@ type openAIError struct {
 
 // GenerateText sends a prompt to OpenAI and returns the generated text.
 func (c *OpenAIClient) GenerateText(ctx context.Context, prompt string) (string, error) {
-	reqBody := openAIRequest{
-		Model: c.model,
-		Messages: []openAIMessage{
-			{Role: "user", Content: prompt},
-		},
+	return c.Generate(ctx, NewRequest(prompt))
+}
+
+// Generate sends a conversation to OpenAI and returns the reply.
+func (c *OpenAIClient) Generate(ctx context.Context, r Request) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	return c.complete(ctx, c.request(r))
+}
+
+// request converts a Request to the chat completions format
+func (c *OpenAIClient) request(r Request) openAIRequest {
+	body := openAIRequest{
+		Model:       c.model,
+		Temperature: r.Temperature,
+		Stop:        r.Stop,
 	}
+	if c.maxTokensField {
+		body.MaxTokens = r.MaxTokens
+	} else {
+		body.MaxCompletion = r.MaxTokens
+	}
+	if r.System != "" {
+		body.Messages = append(body.Messages, openAIMessage{Role: "system", Content: r.System})
+	}
+	for _, m := range r.Messages {
+		msg := openAIMessage{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			tc := openAIToolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = string(call.arguments())
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		body.Messages = append(body.Messages, msg)
+	}
+	for _, t := range r.Tools {
+		body.Tools = append(body.Tools, openAITool{
+			Type:     "function",
+			Function: openAIFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	return body
+}
 
+// Chat answers a conversation, letting the model call req.Tools.
+func (c *OpenAIClient) Chat(ctx context.Context, r Request) (Message, error) {
+	if err := r.Validate(); err != nil {
+		return Message{}, err
+	}
+	msg, err := c.completion(ctx, c.request(r))
+	if err != nil {
+		return Message{}, err
+	}
+	reply := Message{Role: RoleAssistant, Content: msg.Content}
+	for _, tc := range msg.ToolCalls {
+		reply.ToolCalls = append(reply.ToolCalls, ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: json.RawMessage(tc.Function.Arguments)})
+	}
+	return reply, nil
+}
+
+// GenerateJSON asks for JSON matching schema using structured outputs.
+func (c *OpenAIClient) GenerateJSON(ctx context.Context, r Request, schema *Schema) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	body := c.request(r)
+	body.ResponseFormat = &openAIResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &openAIJSONSchema{Name: schema.name(), Schema: schema},
+	}
+	return c.complete(ctx, body)
+}
+
+// Stream sends a conversation to OpenAI and calls fn as the reply is
+// generated.
+func (c *OpenAIClient) Stream(ctx context.Context, r Request, fn StreamFunc) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	body := c.request(r)
+	body.Stream = true
+	body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	resp, err := c.post(ctx, "/chat/completions", body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	text, usage, err := readChatStream(resp.Body, fn)
+	c.reportUsage(ctx, usage)
+	return text, err
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage chatUsage `json:"usage"`
+}
+
+// EmbeddingModel returns the model used by Embed.
+func (c *OpenAIClient) EmbeddingModel() string {
+	return c.embeddingModel
+}
+
+// Embed returns embeddings from the /embeddings endpoint.
+func (c *OpenAIClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := c.post(ctx, "/embeddings", openAIEmbeddingRequest{Model: c.embeddingModel, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("openai returned embedding index %d for %d texts", d.Index, len(texts))
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	if err := checkEmbeddings(c.provider, vectors, texts); err != nil {
+		return nil, err
+	}
+	reportUsage(ctx, Usage{Provider: c.provider, Model: c.embeddingModel, PromptTokens: result.Usage.PromptTokens})
+	return vectors, nil
+}
+
+// post sends a request to path and returns the successful response.
+func (c *OpenAIClient) post(ctx context.Context, path string, reqBody any) (*http.Response, error) {
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(bodyBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError(c.provider, resp)
+	}
+	return resp, nil
+}
 
-	respBytes, err := io.ReadAll(resp.Body)
+// complete sends a chat completion request and returns the text of the first
+// choice.
+func (c *OpenAIClient) complete(ctx context.Context, reqBody openAIRequest) (string, error) {
+	msg, err := c.completion(ctx, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", err
 	}
+	return msg.Content, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("openai API error (status %d): %s", resp.StatusCode, string(respBytes))
+// completion sends a chat completion request and returns the first choice.
+func (c *OpenAIClient) completion(ctx context.Context, reqBody openAIRequest) (*openAIMessage, error) {
+	resp, err := c.post(ctx, "/chat/completions", reqBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	var result openAIResponse
 	if err := json.Unmarshal(respBytes, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("openai API error: %s", result.Error.Message)
+		return nil, fmt.Errorf("openai API error: %s", result.Error.Message)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned")
+		return nil, fmt.Errorf("no choices returned")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	c.reportUsage(ctx, result.Usage)
+	return &result.Choices[0].Message, nil
+}
+
+// reportUsage reports the token usage of a completion.
+func (c *OpenAIClient) reportUsage(ctx context.Context, u chatUsage) {
+	reportUsage(ctx, Usage{Provider: c.provider, Model: c.model, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens})
 }
 
 // Close is a no-op for the HTTP-based OpenAI client.
@@ -111,3 +111,148 @@ func TestOpenAIClose(t *testing.T) {
 		t.Fatalf("expected nil error from Close, got %v", err)
 	}
 }
+
+func TestOpenAIGenerateJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		f := req.ResponseFormat
+		if f == nil || f.Type != "json_schema" || f.JSONSchema.Name != "weekly_review" || f.JSONSchema.Schema.Properties["priorities"].Type != "array" {
+			t.Errorf("unexpected response format: %+v", f)
+		}
+		resp := openAIResponse{Choices: []openAIChoice{
+			{Message: openAIMessage{Role: "assistant", Content: `{"summary":"Fine","priorities":["A","B"],"reflection":"Why?"}`}},
+		}}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("test-key")
+	client.baseURL = server.URL
+
+	insights, err := GenerateReview(context.Background(), client, []string{"Website"}, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if insights.Summary != "Fine" || len(insights.Priorities) != 2 {
+		t.Errorf("unexpected insights: %+v", insights)
+	}
+}
+
+func TestOpenAIGenerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if raw["max_completion_tokens"] != 200.0 || raw["temperature"] != 0.0 {
+			t.Errorf("unexpected settings: %v", raw)
+		}
+		if stop, _ := raw["stop"].([]interface{}); len(stop) != 1 || stop[0] != "END" {
+			t.Errorf("unexpected stop: %v", raw["stop"])
+		}
+		msgs, _ := raw["messages"].([]interface{})
+		roles := ""
+		for _, m := range msgs {
+			roles += m.(map[string]interface{})["role"].(string) + " "
+		}
+		if roles != "system user assistant user " {
+			t.Errorf("unexpected roles: %s", roles)
+		}
+		json.NewEncoder(w).Encode(openAIResponse{Choices: []openAIChoice{{Message: openAIMessage{Role: "assistant", Content: "Tuesday"}}}})
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("test-key")
+	client.baseURL = server.URL
+
+	req := Request{
+		System: "You are a GTD assistant.",
+		Messages: []Message{
+			{Role: RoleUser, Content: "Add a call to Bob"},
+			{Role: RoleAssistant, Content: "When?"},
+			{Role: RoleUser, Content: "Tuesday"},
+		},
+		MaxTokens: 200,
+		Stop:      []string{"END"},
+	}.WithTemperature(0)
+	result, err := client.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "Tuesday" {
+		t.Errorf("expected 'Tuesday', got %q", result)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("expected stream: true")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": keep-alive\n\n" +
+			`data: {"choices":[{"delta":{"role":"assistant"}}]}` + "\n\n" +
+			`data: {"choices":[{"delta":{"content":"Hel"}}]}` + "\n\n" +
+			`data: {"choices":[{"delta":{"content":"lo"}}]}` + "\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("test-key")
+	client.baseURL = server.URL
+
+	var deltas []string
+	result, err := client.Stream(context.Background(), NewRequest("hi"), func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "Hello" || len(deltas) != 2 {
+		t.Errorf("result = %q, deltas = %q", result, deltas)
+	}
+}
+
+func TestOpenAIChatTools(t *testing.T) {
+	var got openAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[
+			{"id":"call_2","type":"function","function":{"name":"list_next_actions","arguments":"{\"context\":\"@home\"}"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("test-key")
+	client.baseURL = server.URL
+
+	call := ToolCall{ID: "call_1", Name: "read_project", Arguments: json.RawMessage(`{"name":"Garden"}`)}
+	reply, err := client.Chat(context.Background(), Request{
+		Messages: []Message{
+			{Role: RoleUser, Content: "What's next for the garden?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{call}},
+			NewToolResult(call, "status: active"),
+		},
+		Tools: []Tool{{Name: "list_next_actions", Description: "List next actions", Parameters: &Schema{Type: "object"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reply.ToolCalls) != 1 || reply.ToolCalls[0].ID != "call_2" || string(reply.ToolCalls[0].Arguments) != `{"context":"@home"}` {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Type != "function" || got.Tools[0].Function.Name != "list_next_actions" {
+		t.Errorf("unexpected tools: %+v", got.Tools)
+	}
+	if len(got.Messages) != 3 || got.Messages[1].ToolCalls[0].Function.Arguments != `{"name":"Garden"}` ||
+		got.Messages[2].Role != "tool" || got.Messages[2].ToolCallID != "call_1" {
+		t.Errorf("unexpected messages: %+v", got.Messages)
+	}
+}
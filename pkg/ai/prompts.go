@@ -1,52 +1,206 @@
 package ai
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
 
-// AnalyzeInboxPrompt returns a prompt to analyze an inbox item
-func AnalyzeInboxPrompt(content string) string {
-	return fmt.Sprintf(`
-You are a GTD (Getting Things Done) assistant. Analyze the following input and extract structured information.
+// projectList formats project names as a Markdown list
+func projectList(projects []string, empty string) string {
+	list := ""
+	for _, p := range projects {
+		list += fmt.Sprintf("- %s\n", p)
+	}
+	if list == "" {
+		return empty
+	}
+	return list
+}
+
+// noNotes fills the notes variable when nothing was retrieved
+const noNotes = "(none)\n"
+
+// notes returns the vault notes relevant to query, for the notes variable.
+// Retrieval is best effort: a failure is logged and the prompt goes without.
+func (l *PromptLibrary) notes(ctx context.Context, query string) string {
+	if l == nil || l.Retriever == nil || strings.TrimSpace(query) == "" {
+		return noNotes
+	}
+	text, err := l.Retriever.Retrieve(ctx, query)
+	if err != nil {
+		log.Printf("%s: retrieve notes: %v", logPrefix(ctx), err)
+		return noNotes
+	}
+	if strings.TrimSpace(text) == "" {
+		return noNotes
+	}
+	return text
+}
+
+// InboxPrompt renders the prompt to analyze an inbox item and suggest how to
+// process it. activeProjects lets the model link the item to a project.
+func (l *PromptLibrary) InboxPrompt(ctx context.Context, content string, activeProjects ...string) (*Prompt, error) {
+	return l.Render(PromptInboxAnalysis, map[string]string{
+		"content":  content,
+		"projects": projectList(activeProjects, "(none)\n"),
+		"notes":    l.notes(ctx, content),
+	})
+}
+
+// InboxAnalysis is the structured answer to the inbox analysis prompt
+type InboxAnalysis struct {
+	Type        string  `json:"type"`
+	Title       string  `json:"title"`
+	Priority    string  `json:"priority"`
+	Context     string  `json:"context"`
+	Description string  `json:"description"`
+	Action      string  `json:"action"`
+	Project     string  `json:"project"`
+	DueDate     string  `json:"due_date"`
+	WaitingFor  string  `json:"waiting_for"`
+	Confidence  float64 `json:"confidence"`
+	Reason      string  `json:"reason"`
+}
+
+// InboxAnalysisSchema describes InboxAnalysis
+var InboxAnalysisSchema = &Schema{
+	Title:       "inbox_analysis",
+	Type:        "object",
+	Description: "GTD analysis of a captured inbox item",
+	Properties: map[string]*Schema{
+		"type":        {Type: "string", Enum: []string{"task", "idea", "reference", "project"}},
+		"title":       {Type: "string", Description: "A concise title"},
+		"priority":    {Type: "string", Enum: []string{"low", "medium", "high", "urgent"}},
+		"context":     {Type: "string", Description: "GTD context such as @calls, or empty"},
+		"description": {Type: "string", Description: "A brief summary"},
+		"action":      {Type: "string", Enum: []string{"trash", "someday", "reference", "next_action", "project", "waiting_for"}},
+		"project":     {Type: "string", Description: "An active project name, or empty"},
+		"due_date":    {Type: "string", Description: "YYYY-MM-DD, or empty"},
+		"waiting_for": {Type: "string", Description: "Person or organization for waiting_for items"},
+		"confidence":  {Type: "number", Description: "Confidence in the action, from 0 to 1"},
+		"reason":      {Type: "string", Description: "One sentence explaining the suggestion"},
+	},
+	Required: []string{"title", "description"},
+}
+
+// AnalyzeInbox analyzes an inbox item with the built-in prompt and returns
+// the validated analysis
+func AnalyzeInbox(ctx context.Context, g Generator, content string, activeProjects ...string) (*InboxAnalysis, error) {
+	a, _, err := (*PromptLibrary)(nil).AnalyzeInbox(ctx, g, content, activeProjects...)
+	return a, err
+}
 
-Input: "%s"
+// AnalyzeInbox analyzes an inbox item and returns the validated analysis and
+// the prompt used
+func (l *PromptLibrary) AnalyzeInbox(ctx context.Context, g Generator, content string, activeProjects ...string) (*InboxAnalysis, *Prompt, error) {
+	p, err := l.InboxPrompt(ctx, content, activeProjects...)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx = withRuleInput(ctx, ruleInput{Prompt: PromptInboxAnalysis, Content: content, ActiveProjects: activeProjects})
+	var a InboxAnalysis
+	if err := GenerateStructured(p.Context(ctx), g, p.Request(), p.schemaOr(InboxAnalysisSchema), &a); err != nil {
+		return nil, p, err
+	}
+	return &a, p, nil
+}
 
-Determine:
-1. Type: (task, idea, reference, project)
-2. Title: A concise title
-3. Priority: (low, medium, high, urgent)
-4. Suggested Context: (@calls, @computer, @errands, @home, @office, @waiting) if applicable
-5. Description: A brief summary
+// ReviewPrompt renders the prompt for weekly review insights as JSON
+func (l *PromptLibrary) ReviewPrompt(ctx context.Context, activeProjects []string, inboxCount int) (*Prompt, error) {
+	return l.Render(PromptWeeklyReview, l.reviewVars(ctx, activeProjects, inboxCount))
+}
 
-Output as JSON:
-{
-  "type": "...",
-  "title": "...",
-  "priority": "...",
-  "context": "...",
-  "description": "..."
+// ReviewMarkdownPrompt renders the prompt for weekly review insights as
+// Markdown, for streaming
+func (l *PromptLibrary) ReviewMarkdownPrompt(ctx context.Context, activeProjects []string, inboxCount int) (*Prompt, error) {
+	return l.Render(PromptWeeklyReviewMarkdown, l.reviewVars(ctx, activeProjects, inboxCount))
 }
-`, content)
+
+func (l *PromptLibrary) reviewVars(ctx context.Context, activeProjects []string, inboxCount int) map[string]string {
+	return map[string]string{
+		"inbox_count": strconv.Itoa(inboxCount),
+		"projects":    projectList(activeProjects, ""),
+		"notes":       l.notes(ctx, "Weekly review: progress, blockers and next actions of "+strings.Join(activeProjects, ", ")),
+	}
+}
+
+// DailySummaryPrompt renders the prompt for the daily vault summary.
+// recentNotes are the titles of the notes changed in the last day.
+func (l *PromptLibrary) DailySummaryPrompt(ctx context.Context, date string, recentNotes []string) (*Prompt, error) {
+	return l.Render(PromptDailySummary, map[string]string{
+		"date":   date,
+		"recent": projectList(recentNotes, "(none)\n"),
+		"notes":  l.notes(ctx, "Wins, open loops, risks and priorities: "+strings.Join(recentNotes, ", ")),
+	})
 }
 
-// GenerateReviewPrompt returns a prompt to generate a weekly review
-func GenerateReviewPrompt(activeProjects []string, inboxCount int) string {
-	projectsList := ""
-	for _, p := range activeProjects {
-		projectsList += fmt.Sprintf("- %s\n", p)
+// FollowUpEmailPrompt renders the prompt for a follow-up email about an item
+// waiting on someone. details is the body of the waiting-for note.
+func (l *PromptLibrary) FollowUpEmailPrompt(ctx context.Context, title, waitingFor, dateRequested string, daysWaiting int, details string) (*Prompt, error) {
+	if strings.TrimSpace(details) == "" {
+		details = "(none)\n"
+	}
+	if dateRequested == "" {
+		dateRequested = "an unknown date"
 	}
+	return l.Render(PromptWaitingFollowUp, map[string]string{
+		"title":          title,
+		"waiting_for":    waitingFor,
+		"date_requested": dateRequested,
+		"days_waiting":   strconv.Itoa(daysWaiting),
+		"details":        details,
+		"notes":          l.notes(ctx, title+" "+waitingFor),
+	})
+}
+
+// AssistantPrompt renders the system prompt of the chat assistant
+func (l *PromptLibrary) AssistantPrompt(date string, contexts []string) (*Prompt, error) {
+	return l.Render(PromptAssistant, map[string]string{
+		"date":     date,
+		"contexts": strings.Join(contexts, ", "),
+	})
+}
 
-	return fmt.Sprintf(`
-You are a GTD assistant. Help me generate a Weekly Review.
+// ReviewInsights is the structured answer to the weekly review prompt
+type ReviewInsights struct {
+	Summary    string   `json:"summary"`
+	Priorities []string `json:"priorities"`
+	Reflection string   `json:"reflection"`
+}
 
-Context:
-- Inbox Items Remaining: %d
-- Active Projects:
-%s
+// ReviewInsightsSchema describes ReviewInsights
+var ReviewInsightsSchema = &Schema{
+	Title:       "weekly_review",
+	Type:        "object",
+	Description: "Insights for a GTD weekly review",
+	Properties: map[string]*Schema{
+		"summary":    {Type: "string", Description: "Markdown summary of the projects"},
+		"priorities": {Type: "array", Items: &Schema{Type: "string"}, Description: "Key priorities for next week"},
+		"reflection": {Type: "string", Description: "A brief reflection prompt"},
+	},
+	Required: []string{"summary", "priorities", "reflection"},
+}
 
-Instructions:
-1. Summarize the state of the projects.
-2. Suggest 3 key priorities for next week based on the active projects.
-3. Provide a brief reflection prompt.
+// GenerateReview returns validated weekly review insights, using the
+// built-in prompt
+func GenerateReview(ctx context.Context, g Generator, activeProjects []string, inboxCount int) (*ReviewInsights, error) {
+	r, _, err := (*PromptLibrary)(nil).GenerateReview(ctx, g, activeProjects, inboxCount)
+	return r, err
+}
 
-Output as Markdown suitable for the "Reflections" and "Goals & Priorities" sections of the Weekly Review template.
-`, inboxCount, projectsList)
+// GenerateReview returns validated weekly review insights and the prompt used
+func (l *PromptLibrary) GenerateReview(ctx context.Context, g Generator, activeProjects []string, inboxCount int) (*ReviewInsights, *Prompt, error) {
+	p, err := l.ReviewPrompt(ctx, activeProjects, inboxCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx = withRuleInput(ctx, ruleInput{Prompt: PromptWeeklyReview, ActiveProjects: activeProjects, InboxCount: inboxCount})
+	var r ReviewInsights
+	if err := GenerateStructured(p.Context(ctx), g, p.Request(), p.schemaOr(ReviewInsightsSchema), &r); err != nil {
+		return nil, p, err
+	}
+	return &r, p, nil
 }
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Conversation roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool" // the result of a tool call, see ToolCaller
+)
+
+// DefaultMaxTokens is used by providers that require a token limit
+// (Anthropic) when the request sets none
+const DefaultMaxTokens = 1024
+
+// Message is one turn of a conversation
+type Message struct {
+	Role       string     `json:"role"` // RoleUser, RoleAssistant or RoleTool
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // assistant: tools to call
+	ToolCallID string     `json:"tool_call_id,omitempty"` // tool: the call answered
+	ToolName   string     `json:"tool_name,omitempty"`    // tool: the tool called
+}
+
+// Request is a generation request. Messages hold the conversation so far and
+// must end with a user message, or with tool results for Chat. Zero values
+// leave the provider defaults.
+type Request struct {
+	System      string    // system prompt
+	Messages    []Message // conversation history, oldest first
+	Temperature *float64
+	MaxTokens   int
+	Stop        []string // stop sequences
+	Tools       []Tool   // tools offered by Chat
+}
+
+// NewRequest returns a request with a single user message
+func NewRequest(prompt string) Request {
+	return Request{Messages: []Message{{Role: RoleUser, Content: prompt}}}
+}
+
+// WithTemperature returns a copy of the request with the temperature set
+func (r Request) WithTemperature(t float64) Request {
+	r.Temperature = &t
+	return r
+}
+
+// withLastMessage returns a copy of the request with the content of its last
+// message replaced by edit's result
+func (r Request) withLastMessage(edit func(content string) string) Request {
+	r.Messages = slices.Clone(r.Messages)
+	if n := len(r.Messages); n > 0 {
+		r.Messages[n-1].Content = edit(r.Messages[n-1].Content)
+	}
+	return r
+}
+
+// Validate checks the conversation shape all providers accept
+func (r Request) Validate() error {
+	if len(r.Messages) == 0 {
+		return errors.New("request has no messages")
+	}
+	for i, m := range r.Messages {
+		switch m.Role {
+		case RoleUser, RoleAssistant:
+		case RoleTool:
+			if m.ToolCallID == "" {
+				return fmt.Errorf("message %d: tool result without a call ID", i)
+			}
+		default:
+			return fmt.Errorf("message %d: unknown role %q", i, m.Role)
+		}
+	}
+	if last := r.Messages[len(r.Messages)-1].Role; last != RoleUser && last != RoleTool {
+		return errors.New("the last message must come from the user")
+	}
+	if r.MaxTokens < 0 {
+		return errors.New("max tokens must not be negative")
+	}
+	return nil
+}
+
+// maxTokens returns the token limit, falling back to DefaultMaxTokens
+func (r Request) maxTokens() int {
+	if r.MaxTokens > 0 {
+		return r.MaxTokens
+	}
+	return DefaultMaxTokens
+}
@@ -0,0 +1,226 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNoRule is returned by RuleGenerator for prompts it has no rules for
+var ErrNoRule = errors.New("no rule-based answer for this prompt")
+
+type ruleInputKey struct{}
+
+// ruleInput holds the values a structured prompt was rendered from, so
+// RuleGenerator can answer without reading the prompt text
+type ruleInput struct {
+	Prompt         string // PromptInboxAnalysis or PromptWeeklyReview
+	Content        string
+	ActiveProjects []string
+	InboxCount     int
+}
+
+// withRuleInput passes in to a RuleGenerator called with the returned context
+func withRuleInput(ctx context.Context, in ruleInput) context.Context {
+	return context.WithValue(ctx, ruleInputKey{}, in)
+}
+
+// RuleGenerator is a deterministic Generator that needs no model. It answers
+// the inbox analysis and weekly review prompts with keyword rules, so capture
+// keeps working offline, e.g. as the last provider of a fallback chain. Free
+// text generation returns ErrNoRule.
+type RuleGenerator struct {
+	now func() time.Time
+}
+
+// Ensure RuleGenerator implements Generator
+var _ Generator = (*RuleGenerator)(nil)
+
+// NewRuleGenerator creates a rule-based generator
+func NewRuleGenerator() *RuleGenerator {
+	return &RuleGenerator{now: time.Now}
+}
+
+// GenerateText returns ErrNoRule
+func (g *RuleGenerator) GenerateText(ctx context.Context, prompt string) (string, error) {
+	return "", ErrNoRule
+}
+
+// Generate returns ErrNoRule
+func (g *RuleGenerator) Generate(ctx context.Context, r Request) (string, error) {
+	return "", ErrNoRule
+}
+
+// Stream returns ErrNoRule
+func (g *RuleGenerator) Stream(ctx context.Context, r Request, fn StreamFunc) (string, error) {
+	return "", ErrNoRule
+}
+
+// GenerateJSON answers the inbox analysis and weekly review prompts, from
+// the inputs AnalyzeInbox and GenerateReview put in ctx
+func (g *RuleGenerator) GenerateJSON(ctx context.Context, req Request, schema *Schema) (string, error) {
+	in, ok := ctx.Value(ruleInputKey{}).(ruleInput)
+	if !ok {
+		return "", ErrNoRule
+	}
+	var answer any
+	// A vault prompt may bring its own schema title, so go by the prompt
+	switch in.Prompt {
+	case PromptInboxAnalysis:
+		answer = g.analyzeInbox(in.Content, in.ActiveProjects)
+	case PromptWeeklyReview:
+		answer = reviewByRules(in.ActiveProjects, in.InboxCount)
+	default:
+		return "", ErrNoRule
+	}
+	data, err := json.Marshal(answer)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal answer: %w", err)
+	}
+	reportUsage(ctx, Usage{Provider: "rules", Model: "rules"})
+	return string(data), nil
+}
+
+// inboxRule maps keywords to a processing action
+type inboxRule struct {
+	keywords []string
+	typ      string
+	action   string
+}
+
+// inboxRules are checked in order, the first match wins
+var inboxRules = []inboxRule{
+	{[]string{"waiting for", "waiting on", "delegated to", "asked"}, "task", "waiting_for"},
+	{[]string{"someday", "maybe", "idea:", "would be nice"}, "idea", "someday"},
+	{[]string{"project:", "plan ", "organize ", "organise "}, "project", "project"},
+	{[]string{"http://", "https://", "reference", "article", "notes from"}, "reference", "reference"},
+}
+
+// contextRules suggest a GTD context, the first match wins
+var contextRules = []struct {
+	keywords []string
+	context  string
+}{
+	{[]string{"call ", "phone", "ring "}, "@calls"},
+	{[]string{"buy ", "pick up", "shop", "store", "groceries"}, "@errands"},
+	{[]string{"email", "write ", "online", "book ", "order ", "update "}, "@computer"},
+	{[]string{"home", "clean", "fix "}, "@home"},
+	{[]string{"office", "meeting"}, "@office"},
+}
+
+var (
+	isoDatePattern    = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}\b`)
+	waitingForPattern = regexp.MustCompile(`(?i)\b(?:waiting (?:for|on)|delegated to|asked)\s+([A-Z][\w.&-]*(?:\s+[A-Z][\w.&-]*)?)`)
+)
+
+// analyzeInbox applies the keyword rules to an inbox item
+func (g *RuleGenerator) analyzeInbox(content string, activeProjects []string) *InboxAnalysis {
+	lower := " " + strings.ToLower(content) + " "
+	a := &InboxAnalysis{
+		Type:        "task",
+		Title:       FallbackTitle(content),
+		Priority:    "medium",
+		Description: strings.TrimSpace(content),
+		Action:      "next_action",
+		Confidence:  0.3,
+		Reason:      "Rule-based suggestion: no keywords matched, treated as a next action.",
+	}
+	if r := []rune(a.Description); len(r) > 280 {
+		a.Description = string(r[:280]) + "…"
+	}
+	for _, r := range inboxRules {
+		if kw := firstMatch(lower, r.keywords); kw != "" {
+			a.Type, a.Action = r.typ, r.action
+			a.Reason = fmt.Sprintf("Rule-based suggestion: %q suggests %s.", strings.TrimSpace(kw), r.action)
+			break
+		}
+	}
+	if a.Action == "waiting_for" {
+		a.Context = "@waiting"
+		if m := waitingForPattern.FindStringSubmatch(content); m != nil {
+			a.WaitingFor = m[1]
+		}
+	} else {
+		for _, r := range contextRules {
+			if firstMatch(lower, r.keywords) != "" {
+				a.Context = r.context
+				break
+			}
+		}
+	}
+	switch {
+	case firstMatch(lower, []string{"urgent", "asap", "immediately"}) != "":
+		a.Priority = "urgent"
+	case firstMatch(lower, []string{"important", "!!", "deadline"}) != "":
+		a.Priority = "high"
+	}
+	if d := isoDatePattern.FindString(content); d != "" {
+		a.DueDate = d
+	} else if strings.Contains(lower, " today") {
+		a.DueDate = g.now().Format("2006-01-02")
+	} else if strings.Contains(lower, " tomorrow") {
+		a.DueDate = g.now().AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	for _, p := range activeProjects {
+		if strings.Contains(lower, strings.ToLower(p)) {
+			a.Project = p
+			break
+		}
+	}
+	return a
+}
+
+// firstMatch returns the first keyword found in s
+func firstMatch(s string, keywords []string) string {
+	for _, kw := range keywords {
+		if strings.Contains(s, kw) {
+			return kw
+		}
+	}
+	return ""
+}
+
+// maxTitleRunes is the longest title FallbackTitle returns
+const maxTitleRunes = 60
+
+// FallbackTitle titles an inbox item after the first non-empty line of its
+// content, shortened to a word boundary
+func FallbackTitle(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "#-* "))
+		if line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > maxTitleRunes {
+			line = string(r[:maxTitleRunes])
+			if i := strings.LastIndex(line, " "); i > 0 {
+				line = line[:i]
+			}
+		}
+		return line
+	}
+	return "New Inbox Item"
+}
+
+// reviewByRules lists the active projects as the week's priorities
+func reviewByRules(activeProjects []string, inboxCount int) *ReviewInsights {
+	r := &ReviewInsights{
+		Summary:    fmt.Sprintf("%d active project(s), %d item(s) left in the inbox.", len(activeProjects), inboxCount),
+		Priorities: []string{},
+		Reflection: "Which project moved the least this week, and what is its next action?",
+	}
+	if inboxCount > 0 {
+		r.Priorities = append(r.Priorities, "Process the inbox to zero")
+	}
+	for _, p := range activeProjects {
+		if len(r.Priorities) == 3 {
+			break
+		}
+		r.Priorities = append(r.Priorities, "Move "+p+" forward")
+	}
+	return r
+}
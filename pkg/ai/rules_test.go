@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestRuleGeneratorAnalyzeInbox(t *testing.T) {
+	g := NewRuleGenerator()
+	g.now = func() time.Time { return time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	tests := []struct {
+		content string
+		want    InboxAnalysis
+	}{
+		{
+			content: "Call the dentist tomorrow, urgent",
+			want:    InboxAnalysis{Action: "next_action", Context: "@calls", Priority: "urgent", DueDate: "2024-03-02"},
+		},
+		{
+			content: "Waiting for Anna Smith to send the Kitchen Remodel quote",
+			want:    InboxAnalysis{Action: "waiting_for", Context: "@waiting", WaitingFor: "Anna Smith", Project: "Kitchen Remodel", Priority: "medium"},
+		},
+		{
+			content: "Maybe learn to paint someday",
+			want:    InboxAnalysis{Action: "someday", Priority: "medium"},
+		},
+		{
+			content: "https://example.com/article about GTD",
+			want:    InboxAnalysis{Action: "reference", Priority: "medium"},
+		},
+	}
+	for _, tt := range tests {
+		a, err := AnalyzeInbox(ctx, g, tt.content, "Kitchen Remodel", "Taxes")
+		if err != nil {
+			t.Fatalf("%q: %v", tt.content, err)
+		}
+		if a.Action != tt.want.Action || a.Context != tt.want.Context || a.Priority != tt.want.Priority ||
+			a.DueDate != tt.want.DueDate || a.WaitingFor != tt.want.WaitingFor || a.Project != tt.want.Project {
+			t.Errorf("%q: got %+v", tt.content, a)
+		}
+		if a.Title == "" || a.Reason == "" {
+			t.Errorf("%q: missing title or reason: %+v", tt.content, a)
+		}
+	}
+}
+
+func TestRuleGeneratorReview(t *testing.T) {
+	r, err := GenerateReview(context.Background(), NewRuleGenerator(), []string{"Taxes", "Garden"}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Priorities) != 3 || r.Priorities[0] != "Process the inbox to zero" || r.Priorities[1] != "Move Taxes forward" {
+		t.Errorf("priorities = %q", r.Priorities)
+	}
+	if _, err := NewRuleGenerator().GenerateText(context.Background(), "hi"); !errors.Is(err, ErrNoRule) {
+		t.Errorf("GenerateText err = %v", err)
+	}
+}
+
+func TestRuleGeneratorIgnoresPromptWording(t *testing.T) {
+	dir := t.TempDir()
+	custom := "---\nversion: 9\n---\nSort this capture: {{content}}\nProjects: {{projects}}\n"
+	if err := os.WriteFile(filepath.Join(dir, PromptInboxAnalysis+".md"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, _, err := NewPromptLibrary(dir).AnalyzeInbox(context.Background(), NewRuleGenerator(), "Call Bob about the Taxes", "Taxes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Title != "Call Bob about the Taxes" || a.Context != "@calls" || a.Project != "Taxes" {
+		t.Errorf("analysis = %+v", a)
+	}
+	if _, err := NewRuleGenerator().GenerateJSON(context.Background(), NewRequest(custom), InboxAnalysisSchema); !errors.Is(err, ErrNoRule) {
+		t.Errorf("without inputs err = %v", err)
+	}
+}
+
+func TestRuleGeneratorIgnoresSchemaTitle(t *testing.T) {
+	dir := t.TempDir()
+	custom := "---\nversion: 2\noutput_schema:\n  title: my_capture\n  type: object\n  properties:\n    title: {type: string}\n    description: {type: string}\n  required: [title, description]\n---\nSort this capture: {{content}}\nProjects: {{projects}}\n"
+	if err := os.WriteFile(filepath.Join(dir, PromptInboxAnalysis+".md"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, _, err := NewPromptLibrary(dir).AnalyzeInbox(context.Background(), NewRuleGenerator(), "Call Bob about the Taxes", "Taxes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Title != "Call Bob about the Taxes" || a.Context != "@calls" {
+		t.Errorf("analysis = %+v", a)
+	}
+}
+
+func TestFallbackTitle(t *testing.T) {
+	tests := []struct{ content, want string }{
+		{"\n## Buy milk\nand bread", "Buy milk"},
+		{"", "New Inbox Item"},
+		{strings.Repeat("ż", 70), strings.Repeat("ż", 60)},
+		{strings.Repeat("é", 58) + " ąę more words", strings.Repeat("é", 58)},
+	}
+	for _, tt := range tests {
+		got := FallbackTitle(tt.content)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("FallbackTitle(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
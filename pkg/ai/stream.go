@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamFunc receives generated text as it arrives. Returning an error stops
+// the stream and is returned by Stream.
+type StreamFunc func(delta string) error
+
+// errStreamDone stops readSSE at the end marker of a stream
+var errStreamDone = errors.New("stream done")
+
+// readSSE calls fn for each server-sent event in r
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var event string
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		err := fn(event, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment, used as keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return dispatch()
+}
+
+// chatUsage is the token usage of an OpenAI-compatible chat completion
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// chatStreamChunk is a chunk of an OpenAI-compatible chat completions stream
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		Usage *chatUsage `json:"usage,omitempty"` // Moonshot, on the last chunk
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage,omitempty"` // OpenAI, on the last chunk with include_usage
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// readChatStream reads an OpenAI-compatible chat completions stream, calls fn
+// for every content delta and returns the whole text and the usage, if sent
+func readChatStream(r io.Reader, fn StreamFunc) (string, chatUsage, error) {
+	var text strings.Builder
+	var usage chatUsage
+	err := readSSE(r, func(_, data string) error {
+		if data == "[DONE]" {
+			return errStreamDone
+		}
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Usage != nil {
+			usage = *chunk.Choices[0].Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		delta := chunk.Choices[0].Delta.Content
+		text.WriteString(delta)
+		return fn(delta)
+	})
+	if errors.Is(err, errStreamDone) {
+		err = nil
+	}
+	return text.String(), usage, err
+}
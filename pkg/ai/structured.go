@@ -0,0 +1,178 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MaxRepairAttempts is how many times GenerateStructured asks the model to
+// fix a response that does not match the schema
+const MaxRepairAttempts = 2
+
+// ErrInvalidResponse is returned when the model keeps answering with JSON
+// that does not match the schema
+var ErrInvalidResponse = errors.New("ai response does not match the schema")
+
+// Schema is the subset of JSON Schema used for structured output. Title
+// names the schema where a provider needs a name (OpenAI, Anthropic tools).
+type Schema struct {
+	Title       string             `json:"title,omitempty"`
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+}
+
+// name returns a provider-safe name for the schema
+func (s *Schema) name() string {
+	name := strings.Trim(nonNameRe.ReplaceAllString(strings.ToLower(s.Title), "_"), "_")
+	if name == "" {
+		return "response"
+	}
+	return name
+}
+
+var nonNameRe = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// Validate checks that data is JSON matching the schema
+func (s *Schema) Validate(data []byte) error {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return s.validate("$", v)
+}
+
+func (s *Schema) validate(path string, v interface{}) error {
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		for _, key := range s.Required {
+			if _, ok := obj[key]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, key)
+			}
+		}
+		keys := make([]string, 0, len(s.Properties))
+		for key := range s.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if val, ok := obj[key]; ok && val != nil {
+				if err := s.Properties[key].validate(path+"."+key, val); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string", path)
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %s", path, str, strings.Join(s.Enum, ", "))
+		}
+	case "number", "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected a number", path)
+		}
+		if _, err := n.Int64(); s.Type == "integer" && err != nil {
+			return fmt.Errorf("%s: expected an integer", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean", path)
+		}
+	}
+	return nil
+}
+
+// extractJSON returns the JSON value in a model response, dropping Markdown
+// code fences and any prose around it
+func extractJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if m := fenceRe.FindStringSubmatch(s); m != nil {
+		s = strings.TrimSpace(m[1])
+	}
+	if start := strings.IndexAny(s, "{["); start > 0 {
+		s = s[start:]
+	}
+	if end := strings.LastIndexAny(s, "}]"); end >= 0 && end < len(s)-1 {
+		s = s[:end+1]
+	}
+	return s
+}
+
+var fenceRe = regexp.MustCompile("(?s)```(?:json)?\\s*\n?(.*?)```")
+
+// GenerateStructured answers req with JSON matching schema and decodes it into
+// out. Responses that fail validation are sent back to the model with the
+// error, at most MaxRepairAttempts times.
+func GenerateStructured(ctx context.Context, g Generator, req Request, schema *Schema, out interface{}) error {
+	text, err := g.GenerateJSON(ctx, req, schema)
+	for attempt := 0; ; attempt++ {
+		if err != nil {
+			return err
+		}
+		raw := extractJSON(text)
+		verr := schema.Validate([]byte(raw))
+		if verr == nil {
+			if err := json.Unmarshal([]byte(raw), out); err != nil {
+				verr = err
+			} else {
+				return nil
+			}
+		}
+		if attempt == MaxRepairAttempts {
+			return fmt.Errorf("%w: %v", ErrInvalidResponse, verr)
+		}
+		previous := text
+		repair := req.withLastMessage(func(prompt string) string { return repairPrompt(prompt, previous, verr) })
+		text, err = g.GenerateJSON(ctx, repair, schema)
+	}
+}
+
+// repairPrompt asks the model to fix its previous answer
+func repairPrompt(prompt, response string, problem error) string {
+	return fmt.Sprintf(`%s
+
+Your previous answer was:
+%s
+
+It is not valid: %v
+Answer again with only the corrected JSON.`, prompt, response, problem)
+}
+
+// schemaInstructions describes the expected JSON for providers without
+// native schema support
+func schemaInstructions(prompt string, schema *Schema) string {
+	data, _ := json.MarshalIndent(schema, "", "  ")
+	return fmt.Sprintf("%s\n\nRespond with a single JSON object matching this JSON schema:\n%s", prompt, data)
+}
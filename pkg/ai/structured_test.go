@@ -0,0 +1,133 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// scriptedGenerator answers GenerateJSON with one response per call
+type scriptedGenerator struct {
+	responses []string
+	requests  []Request
+}
+
+func (g *scriptedGenerator) GenerateText(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (g *scriptedGenerator) Generate(ctx context.Context, req Request) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (g *scriptedGenerator) Stream(ctx context.Context, req Request, fn StreamFunc) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (g *scriptedGenerator) GenerateJSON(ctx context.Context, req Request, schema *Schema) (string, error) {
+	g.requests = append(g.requests, req)
+	resp := g.responses[0]
+	if len(g.responses) > 1 {
+		g.responses = g.responses[1:]
+	}
+	return resp, nil
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"title": "A", "description": "B", "priority": "high", "confidence": 0.5}`, ""},
+		{"not json", "invalid JSON"},
+		{`["A"]`, "expected an object"},
+		{`{"title": "A"}`, `missing required field "description"`},
+		{`{"title": "A", "description": "B", "priority": "soon"}`, `$.priority: "soon" is not one of`},
+		{`{"title": "A", "description": "B", "confidence": "high"}`, "$.confidence: expected a number"},
+		{`{"title": "A", "description": "B", "context": null}`, ""},
+	}
+	for _, tt := range tests {
+		err := InboxAnalysisSchema.Validate([]byte(tt.data))
+		if tt.err == "" && err != nil {
+			t.Errorf("Validate(%s) = %v", tt.data, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("Validate(%s) = %v, want %q", tt.data, err, tt.err)
+		}
+	}
+	if err := ReviewInsightsSchema.Validate([]byte(`{"summary": "", "priorities": ["a", 2], "reflection": ""}`)); err == nil ||
+		!strings.Contains(err.Error(), "$.priorities[1]") {
+		t.Errorf("array item error = %v", err)
+	}
+}
+
+func TestGenerateStructuredRepairs(t *testing.T) {
+	g := &scriptedGenerator{responses: []string{
+		"Sure! Here it is:\n```json\n{\"title\": \"Fence\"}\n```",
+		`{"title": "Fence", "description": "Fix the fence", "action": "next_action"}`,
+	}}
+	a, err := AnalyzeInbox(context.Background(), g, "fix fence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Description != "Fix the fence" || a.Action != "next_action" {
+		t.Errorf("analysis = %+v", a)
+	}
+	if len(g.requests) != 2 || !strings.Contains(g.requests[1].Messages[0].Content, `missing required field "description"`) {
+		t.Errorf("repair prompt not sent: %+v", g.requests)
+	}
+
+	g = &scriptedGenerator{responses: []string{`{"title": 1}`}}
+	if _, err := AnalyzeInbox(context.Background(), g, "x"); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("err = %v", err)
+	}
+	if len(g.requests) != MaxRepairAttempts+1 {
+		t.Errorf("attempts = %d", len(g.requests))
+	}
+}
+
+func TestGenerateStructuredKeepsSettings(t *testing.T) {
+	g := &scriptedGenerator{responses: []string{`{}`, `{"summary": "Fine", "priorities": [], "reflection": "Why?"}`}}
+	req := NewRequest("review").WithTemperature(0.2)
+	req.MaxTokens = 300
+	var r ReviewInsights
+	if err := GenerateStructured(context.Background(), g, req, ReviewInsightsSchema, &r); err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range g.requests {
+		if got.Temperature == nil || *got.Temperature != 0.2 || got.MaxTokens != 300 {
+			t.Errorf("request settings lost: %+v", got)
+		}
+	}
+	if req.Messages[0].Content != "review" {
+		t.Errorf("repair changed the caller's request: %q", req.Messages[0].Content)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	for in, want := range map[string]string{
+		"```json\n{\"a\": 1}\n```":       `{"a": 1}`,
+		"Here you go: {\"a\": 1} Enjoy!": `{"a": 1}`,
+		`[1, 2]`:                         `[1, 2]`,
+	} {
+		if got := extractJSON(in); got != want {
+			t.Errorf("extractJSON(%q) = %q", in, got)
+		}
+	}
+}
+
+func TestGeminiSchema(t *testing.T) {
+	s := geminiSchema(InboxAnalysisSchema)
+	if s.Type != genai.TypeObject || len(s.Required) != 2 {
+		t.Fatalf("schema = %+v", s)
+	}
+	if p := s.Properties["priority"]; p.Type != genai.TypeString || p.Format != "enum" || len(p.Enum) != 4 {
+		t.Errorf("priority = %+v", p)
+	}
+	if p := geminiSchema(ReviewInsightsSchema).Properties["priorities"]; p.Type != genai.TypeArray || p.Items.Type != genai.TypeString {
+		t.Errorf("priorities = %+v", p)
+	}
+}
@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ErrToolsUnsupported is returned by Chat for generators without tool calling
+var ErrToolsUnsupported = errors.New("AI provider does not support tool calling")
+
+// Tool is a function the model may ask to call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  *Schema // the arguments object
+}
+
+// ToolCall is a call of a Tool requested by the model
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"` // JSON object
+}
+
+// ToolCaller is implemented by generators that support tool calling
+type ToolCaller interface {
+	// Chat answers a conversation offering req.Tools to the model. The reply
+	// is an assistant message holding text, tool calls or both. The results
+	// of the calls are sent back as RoleTool messages in the next request.
+	Chat(ctx context.Context, req Request) (Message, error)
+}
+
+// Chat answers a conversation with tools using g, or returns
+// ErrToolsUnsupported if g cannot call tools
+func Chat(ctx context.Context, g Generator, req Request) (Message, error) {
+	tc, ok := g.(ToolCaller)
+	if !ok {
+		return Message{}, ErrToolsUnsupported
+	}
+	return tc.Chat(ctx, req)
+}
+
+// NewToolResult returns the message answering call with result
+func NewToolResult(call ToolCall, result string) Message {
+	return Message{Role: RoleTool, Content: result, ToolCallID: call.ID, ToolName: call.Name}
+}
+
+// arguments returns the call's arguments, an empty object if there are none
+func (c ToolCall) arguments() json.RawMessage {
+	if len(c.Arguments) == 0 {
+		return json.RawMessage("{}")
+	}
+	return c.Arguments
+}
@@ -0,0 +1,271 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Usage is the token usage a provider reports for a call
+type Usage struct {
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+type usageKey struct{}
+
+// UsageTracker collects the usage providers report during a call
+type UsageTracker struct {
+	mu       sync.Mutex
+	usage    Usage
+	reported bool
+}
+
+// WithUsageTracker returns a context in which every Generator reports its
+// token usage to the returned tracker
+func WithUsageTracker(ctx context.Context) (context.Context, *UsageTracker) {
+	t := &UsageTracker{}
+	return context.WithValue(ctx, usageKey{}, t), t
+}
+
+// Usage returns the usage reported so far. Tokens of several reports, e.g.
+// repair attempts, are added up; provider and model are the last ones.
+func (t *UsageTracker) Usage() (Usage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage, t.reported
+}
+
+// Model names the provider and model that answered, e.g.
+// "openai/gpt-4o-mini", or "" when none was reported
+func (t *UsageTracker) Model() string {
+	u, ok := t.Usage()
+	if !ok || u.Provider == "" {
+		return ""
+	}
+	return u.Provider + "/" + u.Model
+}
+
+// reportUsage adds u to the tracker in ctx, if any
+func reportUsage(ctx context.Context, u Usage) {
+	t, ok := ctx.Value(usageKey{}).(*UsageTracker)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage.Provider = u.Provider
+	t.usage.Model = u.Model
+	t.usage.PromptTokens += u.PromptTokens
+	t.usage.CompletionTokens += u.CompletionTokens
+	t.reported = true
+}
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// PriceTable maps model names, or name prefixes, to prices
+type PriceTable map[string]Price
+
+// DefaultPrices are list prices of the default models at the time of
+// writing. Local models are free and not listed.
+var DefaultPrices = PriceTable{
+	"gpt-4o-mini":            {Input: 0.15, Output: 0.60},
+	"gpt-4o":                 {Input: 2.50, Output: 10.00},
+	"gpt-4.1-mini":           {Input: 0.40, Output: 1.60},
+	"claude-3-5-haiku":       {Input: 0.80, Output: 4.00},
+	"claude-sonnet-4":        {Input: 3.00, Output: 15.00},
+	"gemini-pro":             {Input: 0.50, Output: 1.50},
+	"gemini-1.5-flash":       {Input: 0.075, Output: 0.30},
+	"kimi-k2":                {Input: 0.60, Output: 2.50},
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+}
+
+// Cost estimates the cost of u in USD. The longest matching model prefix
+// wins, so "claude-3-5-haiku" also prices "claude-3-5-haiku-latest".
+// Unknown models cost nothing.
+func (p PriceTable) Cost(u Usage) float64 {
+	var price Price
+	best := -1
+	for model, pr := range p {
+		if strings.HasPrefix(u.Model, model) && len(model) > best {
+			price, best = pr, len(model)
+		}
+	}
+	return (float64(u.PromptTokens)*price.Input + float64(u.CompletionTokens)*price.Output) / 1e6
+}
+
+// ErrBudgetExceeded is returned without calling a provider once the monthly
+// AI budget is spent
+var ErrBudgetExceeded = errors.New("monthly AI budget exceeded")
+
+// UsageRecord is one metered AI call
+type UsageRecord struct {
+	Feature          string // see WithFeature
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Latency          time.Duration
+	CostUSD          float64
+	Error            string // empty on success
+	CreatedAt        time.Time
+}
+
+// UsageStore persists metered calls
+type UsageStore interface {
+	RecordAIUsage(rec *UsageRecord) error
+	// AICostSince returns the total estimated cost of the calls since t
+	AICostSince(t time.Time) (float64, error)
+}
+
+// MeteredGenerator records the usage, latency and estimated cost of every
+// call made through it, and fails fast once MonthlyBudget is spent
+type MeteredGenerator struct {
+	MonthlyBudget float64 // USD, zero means no limit
+	Prices        PriceTable
+
+	inner Generator
+	store UsageStore
+	now   func() time.Time
+}
+
+// Ensure MeteredGenerator implements Generator and ToolCaller
+var (
+	_ Generator  = (*MeteredGenerator)(nil)
+	_ ToolCaller = (*MeteredGenerator)(nil)
+)
+
+// NewMeteredGenerator wraps inner, storing usage records in store
+func NewMeteredGenerator(inner Generator, store UsageStore, prices PriceTable) *MeteredGenerator {
+	return &MeteredGenerator{
+		Prices: prices,
+		inner:  inner,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+// GenerateText generates text and records the usage
+func (g *MeteredGenerator) GenerateText(ctx context.Context, prompt string) (string, error) {
+	return g.meter(ctx, func(ctx context.Context) (string, error) { return g.inner.GenerateText(ctx, prompt) })
+}
+
+// Generate answers a conversation and records the usage
+func (g *MeteredGenerator) Generate(ctx context.Context, req Request) (string, error) {
+	return g.meter(ctx, func(ctx context.Context) (string, error) { return g.inner.Generate(ctx, req) })
+}
+
+// GenerateJSON generates JSON and records the usage
+func (g *MeteredGenerator) GenerateJSON(ctx context.Context, req Request, schema *Schema) (string, error) {
+	return g.meter(ctx, func(ctx context.Context) (string, error) { return g.inner.GenerateJSON(ctx, req, schema) })
+}
+
+// Stream streams a reply and records the usage
+func (g *MeteredGenerator) Stream(ctx context.Context, req Request, fn StreamFunc) (string, error) {
+	return g.meter(ctx, func(ctx context.Context) (string, error) { return g.inner.Stream(ctx, req, fn) })
+}
+
+// Chat answers a conversation with tools and records the usage. It returns
+// ErrToolsUnsupported if the wrapped generator cannot call tools.
+func (g *MeteredGenerator) Chat(ctx context.Context, req Request) (Message, error) {
+	if _, ok := g.inner.(ToolCaller); !ok {
+		return Message{}, ErrToolsUnsupported
+	}
+	var reply Message
+	_, err := g.meter(ctx, func(ctx context.Context) (string, error) {
+		m, err := Chat(ctx, g.inner, req)
+		reply = m
+		return m.Content, err
+	})
+	return reply, err
+}
+
+// MeteredEmbedder records the usage and cost of embedding calls and fails
+// fast once the budget is spent, sharing the store, prices and budget of the
+// MeteredGenerator it came from
+type MeteredEmbedder struct {
+	inner Embedder
+	g     *MeteredGenerator
+}
+
+// Ensure MeteredEmbedder implements Embedder
+var _ Embedder = (*MeteredEmbedder)(nil)
+
+// Embedder returns inner metered like g's generation calls. Calls without a
+// feature are recorded as "embedding".
+func (g *MeteredGenerator) Embedder(inner Embedder) *MeteredEmbedder {
+	return &MeteredEmbedder{inner: inner, g: g}
+}
+
+// Embed embeds texts and records the usage
+func (e *MeteredEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if FeatureFrom(ctx) == "" {
+		ctx = WithFeature(ctx, "embedding")
+	}
+	var vectors [][]float32
+	_, err := e.g.meter(ctx, func(ctx context.Context) (string, error) {
+		v, err := e.inner.Embed(ctx, texts)
+		vectors = v
+		return "", err
+	})
+	return vectors, err
+}
+
+// EmbeddingModel returns the wrapped embedder's model
+func (e *MeteredEmbedder) EmbeddingModel() string {
+	return e.inner.EmbeddingModel()
+}
+
+// MonthStart returns the first instant of t's month
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func (g *MeteredGenerator) meter(ctx context.Context, call func(context.Context) (string, error)) (string, error) {
+	start := g.now()
+	if g.MonthlyBudget > 0 {
+		spent, err := g.store.AICostSince(MonthStart(start))
+		if err != nil {
+			log.Printf("Failed to check AI budget: %v", err)
+		} else if spent >= g.MonthlyBudget {
+			return "", fmt.Errorf("%w: spent $%.2f of $%.2f", ErrBudgetExceeded, spent, g.MonthlyBudget)
+		}
+	}
+
+	callCtx, tracker := WithUsageTracker(ctx)
+	text, err := call(callCtx)
+
+	u, reported := tracker.Usage()
+	if reported {
+		// Pass the usage on to the caller's tracker, if any
+		reportUsage(ctx, u)
+	}
+	rec := &UsageRecord{
+		Feature:          FeatureFrom(ctx),
+		Provider:         u.Provider,
+		Model:            u.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Latency:          g.now().Sub(start),
+		CostUSD:          g.Prices.Cost(u),
+		CreatedAt:        start,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if serr := g.store.RecordAIUsage(rec); serr != nil {
+		log.Printf("Failed to record AI usage: %v", serr)
+	}
+	return text, err
+}
@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// memoryUsageStore keeps usage records in memory
+type memoryUsageStore struct {
+	records []*UsageRecord
+}
+
+func (s *memoryUsageStore) RecordAIUsage(rec *UsageRecord) error {
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *memoryUsageStore) AICostSince(t time.Time) (float64, error) {
+	var cost float64
+	for _, rec := range s.records {
+		if !rec.CreatedAt.Before(t) {
+			cost += rec.CostUSD
+		}
+	}
+	return cost, nil
+}
+
+func TestMeteredGenerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":1000000,"completion_tokens":500000}}`)
+	}))
+	defer server.Close()
+
+	store := &memoryUsageStore{}
+	g := NewMeteredGenerator(NewOpenAIClientWithConfig(ClientConfig{BaseURL: server.URL}), store, DefaultPrices)
+	g.MonthlyBudget = 0.5
+	clock := time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+	g.now = func() time.Time {
+		clock = clock.Add(250 * time.Millisecond)
+		return clock
+	}
+
+	ctx, usage := WithUsageTracker(WithFeature(context.Background(), "daily_summary"))
+	if _, err := g.GenerateText(ctx, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if usage.Model() != "openai/gpt-4o-mini" {
+		t.Errorf("caller's tracker saw %q", usage.Model())
+	}
+	if len(store.records) != 1 {
+		t.Fatalf("records = %d", len(store.records))
+	}
+	rec := store.records[0]
+	if rec.Feature != "daily_summary" || rec.Provider != "openai" || rec.Model != "gpt-4o-mini" ||
+		rec.PromptTokens != 1000000 || rec.CompletionTokens != 500000 || rec.Latency != 250*time.Millisecond {
+		t.Errorf("record = %+v", rec)
+	}
+	if math.Abs(rec.CostUSD-0.45) > 1e-9 {
+		t.Errorf("cost = %v, want 0.45", rec.CostUSD)
+	}
+
+	// The second call spends the budget, the third fails without a request
+	g.GenerateText(context.Background(), "hi")
+	if _, err := g.GenerateText(context.Background(), "hi"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if len(store.records) != 2 {
+		t.Errorf("records = %d, the rejected call should not be recorded", len(store.records))
+	}
+}
+
+func TestMeteredEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"index":0,"embedding":[0.6,0.8]}],"usage":{"prompt_tokens":10000000}}`)
+	}))
+	defer server.Close()
+
+	store := &memoryUsageStore{}
+	g := NewMeteredGenerator(nil, store, DefaultPrices)
+	g.MonthlyBudget = 0.3
+	e := g.Embedder(NewOpenAIClientWithConfig(ClientConfig{BaseURL: server.URL}))
+	if e.EmbeddingModel() != "text-embedding-3-small" {
+		t.Errorf("model = %q", e.EmbeddingModel())
+	}
+
+	vectors, err := e.Embed(context.Background(), []string{"hello"})
+	if err != nil || len(vectors) != 1 {
+		t.Fatalf("Embed = %v, %v", vectors, err)
+	}
+	if len(store.records) != 1 {
+		t.Fatalf("records = %d", len(store.records))
+	}
+	rec := store.records[0]
+	if rec.Feature != "embedding" || rec.Provider != "openai" || rec.Model != "text-embedding-3-small" || rec.PromptTokens != 10000000 || math.Abs(rec.CostUSD-0.2) > 1e-9 {
+		t.Errorf("record = %+v", rec)
+	}
+
+	// Embeddings spend the same budget as text generation
+	e.Embed(context.Background(), []string{"hello"})
+	if _, err := e.Embed(context.Background(), []string{"hello"}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("err = %v, want ErrBudgetExceeded", err)
+	}
+}
+
+func TestPriceTableCost(t *testing.T) {
+	prices := PriceTable{"claude": {Input: 1, Output: 1}, "claude-3-5-haiku": {Input: 0.8, Output: 4}}
+	if c := prices.Cost(Usage{Model: "claude-3-5-haiku-latest", PromptTokens: 1e6, CompletionTokens: 1e6}); math.Abs(c-4.8) > 1e-9 {
+		t.Errorf("cost = %v, want the longest prefix price", c)
+	}
+	if c := prices.Cost(Usage{Model: "llama3.2", PromptTokens: 1e6}); c != 0 {
+		t.Errorf("unknown model cost = %v", c)
+	}
+}
@@ -10,10 +10,14 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/assistant"
 	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/semantic"
 	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
 
@@ -27,6 +31,30 @@ func (m *MockGenerator) GenerateText(ctx context.Context, prompt string) (string
 	return m.Response, m.Err
 }
 
+func (m *MockGenerator) Generate(ctx context.Context, req ai.Request) (string, error) {
+	return m.Response, m.Err
+}
+
+func (m *MockGenerator) Stream(ctx context.Context, req ai.Request, fn ai.StreamFunc) (string, error) {
+	if m.Err != nil {
+		return "", m.Err
+	}
+	for _, word := range strings.SplitAfter(m.Response, " ") {
+		if err := fn(word); err != nil {
+			return "", err
+		}
+	}
+	return m.Response, nil
+}
+
+func (m *MockGenerator) GenerateJSON(ctx context.Context, req ai.Request, schema *ai.Schema) (string, error) {
+	return m.Response, m.Err
+}
+
+func (m *MockGenerator) Chat(ctx context.Context, req ai.Request) (ai.Message, error) {
+	return ai.Message{Role: ai.RoleAssistant, Content: m.Response}, m.Err
+}
+
 func TestHandleCreateInboxItem(t *testing.T) {
 	// Setup Temp Vault
 	tmpVault, err := ioutil.TempDir("", "vault-test-api")
@@ -57,7 +85,7 @@ func TestHandleCreateInboxItem(t *testing.T) {
 	}
 
 	// Setup Router
-	router := NewRouter(repo, mockAI, tmplEngine, tmpVault, nil)
+	router := NewRouter(repo, mockAI, tmplEngine, tmpVault, nil, vault.NewIndex(tmpVault, nil), nil, nil, nil, nil)
 
 	// Create Request
 	reqBody := map[string]string{"content": "Buy milk"}
@@ -103,7 +131,7 @@ func TestAutomationEndpoints(t *testing.T) {
 	ioutil.WriteFile(filepath.Join(tmplDir, "Inbox Item Template.md"), []byte("# {{title}}\n{{description}}"), 0644)
 	tmplEngine := vault.NewTemplateEngine(tmplDir)
 
-	router := NewRouter(repo, &MockGenerator{Response: "{}"}, tmplEngine, tmpVault, nil)
+	router := NewRouter(repo, &MockGenerator{Response: "{}"}, tmplEngine, tmpVault, nil, vault.NewIndex(tmpVault, nil), nil, nil, nil, nil)
 
 	createBody := map[string]interface{}{
 		"name":          "Daily Summary",
@@ -151,3 +179,861 @@ func TestAutomationEndpoints(t *testing.T) {
 		t.Fatalf("run-now status = %d body=%s", runNowResp.Code, runNowResp.Body.String())
 	}
 }
+
+func setupTestRepo(t *testing.T, vaultDir string) *db.Repository {
+	t.Helper()
+	database, err := db.NewDB(filepath.Join(vaultDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+	return db.NewRepository(database)
+}
+
+func writeVaultFile(t *testing.T, vaultDir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(vaultDir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVaultHealthEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "2. Next Actions/@home/Fix sink.md",
+		"---\nstatus: next\npriority: [low/medium/high/urgent]\n---\nSee [[Plumber]]\n")
+
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	router := NewRouter(repo, &MockGenerator{}, nil, tmpVault, nil, index, nil, nil, nil, nil)
+
+	req := httptest.NewRequest("GET", "/vault/health", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpVault, vault.HealthReportPath)); !os.IsNotExist(err) {
+		t.Errorf("GET wrote the report note: %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/vault/health", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var report vault.HealthReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts[vault.CheckBrokenLink] != 1 || report.Counts[vault.CheckPlaceholder] != 1 {
+		t.Errorf("unexpected counts: %v", report.Counts)
+	}
+	if _, err := os.Stat(filepath.Join(tmpVault, vault.HealthReportPath)); err != nil {
+		t.Errorf("report note not written: %v", err)
+	}
+
+	req = httptest.NewRequest("GET", "/vault/health?inbox_days=abc", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad inbox_days, got %d", w.Code)
+	}
+}
+
+func TestBacklinksEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "3. Projects/Website.md", "---\nstatus: active\ntype: project\n---\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@computer/Deploy.md", "---\nstatus: next\nproject: \"[[Website]]\"\n---\n")
+	writeVaultFile(t, tmpVault, "5. Reference/Hosting.md", "---\ntype: reference\n---\nFor [[Website]]\n")
+
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	router := NewRouter(repo, &MockGenerator{}, nil, tmpVault, nil, index, nil, nil, nil, nil)
+
+	req := httptest.NewRequest("GET", "/notes/3.%20Projects/Website.md/backlinks?kind=next-action", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Path      string `json:"path"`
+		Backlinks []struct {
+			Path string `json:"path"`
+			Kind string `json:"kind"`
+		} `json:"backlinks"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Backlinks) != 1 || resp.Backlinks[0].Kind != "next-action" {
+		t.Errorf("unexpected backlinks: %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/notes/Missing/backlinks", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/graph", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var graph struct {
+		Nodes []json.RawMessage `json:"nodes"`
+		Edges []json.RawMessage `json:"edges"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &graph)
+	if len(graph.Nodes) != 3 || len(graph.Edges) != 2 {
+		t.Errorf("unexpected graph: %s", w.Body.String())
+	}
+}
+
+func TestMoveNoteEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "1. Inbox/Idea.md", "# Idea\n")
+	writeVaultFile(t, tmpVault, "Daily.md", "Captured [[Idea]]\n")
+	writeVaultFile(t, tmpVault, "4. Someday Maybe/Taken.md", "taken\n")
+
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	router := NewRouter(repo, &MockGenerator{}, nil, tmpVault, nil, index, nil, nil, nil, nil)
+
+	move := func(from, to string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"from": from, "to": to})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/notes/move", bytes.NewBuffer(body)))
+		return w
+	}
+
+	if w := move("1. Inbox/Idea.md", "4. Someday Maybe/Big Idea.md"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpVault, "Daily.md"))
+	if string(data) != "Captured [[Big Idea]]\n" {
+		t.Errorf("link not rewritten: %q", data)
+	}
+	if w := move("4. Someday Maybe/Big Idea.md", "4. Someday Maybe/Taken.md"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", w.Code)
+	}
+	if w := move("Nope.md", "Other.md"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+	if w := move("Daily.md", "../escape.md"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(tmpVault, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if w := move("Daily.md", "escape/Daily.md"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a symlink out of the vault, got %d", w.Code)
+	}
+}
+
+func TestWeeklyReviewFillsSections(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "0. GTD System/Templates/Weekly Review Template.md",
+		"---\nweek_of: {{date:YYYY-[W]WW}}\n---\n# Weekly Review\n\n## Project Review\n### Active Projects\nReview each project for:\n- [ ] Clear next action identified\n\n### Someday/Maybe Review\n- [ ] Any items ready to activate?\n")
+	writeVaultFile(t, tmpVault, "3. Projects/Website.md", "---\nstatus: active\n---\n# Website\n")
+	writeVaultFile(t, tmpVault, "0. GTD System/Prompts/weekly-review.md", "---\nversion: 7\nvariables: [projects]\n---\nReview {{projects}}\n")
+
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	tmplEngine := vault.NewTemplateEngine(filepath.Join(tmpVault, "0. GTD System", "Templates"))
+	tmplEngine.Clock = func() time.Time { return time.Date(2024, time.March, 6, 9, 0, 0, 0, time.UTC) }
+	router := NewRouter(repo, &MockGenerator{Response: `{"summary": "Keep going.", "priorities": ["Ship the website"], "reflection": "What slowed you down?"}`}, tmplEngine, tmpVault, nil, index, nil, nil, nil, nil)
+
+	req := httptest.NewRequest("POST", "/review/weekly", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpVault, "6. Weekly Reviews", "2024-W10 Weekly Review.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "---\nweek_of: 2024-W10\nprompt_version: weekly-review@7\n---\n# Weekly Review\n\n## Project Review\n### Active Projects\nReview each project for:\n- [ ] [[Website]] - Status: Active - no next action\n- [ ] Clear next action identified\n\n### Someday/Maybe Review\n- [ ] Any items ready to activate?\n\n## AI Insights\nKeep going.\n\n### Priorities\n1. Ship the website\n\n### Reflection\nWhat slowed you down?\n"
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+
+	// Without an index the review cannot list projects
+	router = NewRouter(repo, &MockGenerator{}, tmplEngine, tmpVault, nil, nil, nil, nil, nil, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/review/weekly", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status without index = %d, want 503", w.Code)
+	}
+}
+
+func TestNotesCRUD(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "0. GTD System/Templates/Project Template.md",
+		"---\nstatus: active\ntype: project\ncreated: {{date:YYYY-MM-DD}}\n---\n# {{title}}\n\n## Outcome\nWhat does done look like?\n\n## Next Actions\n- [ ] \n")
+	writeVaultFile(t, tmpVault, "3. Projects/Garden.md", "---\nstatus: someday\narea: home\n---\n# Garden\n")
+
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	tmplEngine := vault.NewTemplateEngine(filepath.Join(tmpVault, "0. GTD System", "Templates"))
+	router := NewRouter(repo, &MockGenerator{}, tmplEngine, tmpVault, nil, index, nil, nil, nil, nil)
+
+	do := func(method, target string, body interface{}, header map[string]string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, target, &buf)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Create from template
+	w := do("POST", "/notes", map[string]interface{}{
+		"path":        "3. Projects/Website",
+		"template":    "Project Template",
+		"frontmatter": map[string]interface{}{"area": "work"},
+		"sections":    map[string]string{"Outcome": "New site is live"},
+	}, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d body=%s", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag on create")
+	}
+	if w := do("POST", "/notes", map[string]interface{}{"path": "3. Projects/Leak", "template": "../../3. Projects/Garden"}, nil); w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "status: someday") {
+		t.Errorf("template traversal status = %d body=%s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/notes", map[string]interface{}{"path": "3. Projects/Website.md", "content": "x"}, nil); w.Code != http.StatusConflict {
+		t.Errorf("duplicate create status = %d", w.Code)
+	}
+
+	// Get
+	w = do("GET", "/notes/3.%20Projects/Website.md", nil, nil)
+	var got struct {
+		Path        string                 `json:"path"`
+		Kind        string                 `json:"kind"`
+		Frontmatter map[string]interface{} `json:"frontmatter"`
+		Content     string                 `json:"content"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if w.Code != http.StatusOK || got.Kind != "project" || got.Frontmatter["area"] != "work" {
+		t.Fatalf("get = %d %+v", w.Code, got)
+	}
+	if !strings.Contains(got.Content, "## Outcome\nNew site is live\n") {
+		t.Errorf("section not filled: %q", got.Content)
+	}
+	if w.Header().Get("ETag") != etag {
+		t.Errorf("ETag changed between create and get")
+	}
+
+	// List with frontmatter filter
+	w = do("GET", "/notes?folder=3.%20Projects&fm.area=work", nil, nil)
+	var list struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Count != 1 {
+		t.Errorf("list count = %d body=%s", list.Count, w.Body.String())
+	}
+
+	// Patch with a stale ETag fails, with the current one succeeds
+	patch := map[string]interface{}{
+		"frontmatter": map[string]interface{}{"status": "completed", "area": nil},
+		"sections":    []map[string]string{{"heading": "Next Actions", "action": "checklist", "content": "Book hosting"}},
+	}
+	if w := do("PATCH", "/notes/3.%20Projects/Website.md", patch, map[string]string{"If-Match": `"stale"`}); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale patch status = %d", w.Code)
+	}
+	w = do("PATCH", "/notes/3.%20Projects/Website.md", patch, map[string]string{"If-Match": etag})
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch status = %d body=%s", w.Code, w.Body.String())
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpVault, "3. Projects", "Website.md"))
+	if !strings.Contains(string(data), "status: completed\n") || strings.Contains(string(data), "area:") || !strings.Contains(string(data), "- [ ] Book hosting") {
+		t.Errorf("patched note:\n%s", data)
+	}
+
+	// Path traversal is rejected
+	if w := do("GET", "/notes/..%2Fsecret.md", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("traversal status = %d", w.Code)
+	}
+	if w := do("POST", "/notes", map[string]interface{}{"path": "../outside.md", "content": "x"}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("traversal create status = %d", w.Code)
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(tmpVault, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if w := do("POST", "/notes", map[string]interface{}{"path": "escape/x.md", "content": "x"}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("symlink create status = %d", w.Code)
+	}
+
+	// Delete
+	if w := do("DELETE", "/notes/3.%20Projects/Garden.md", nil, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", w.Code)
+	}
+	if _, ok := index.Get(filepath.Join("3. Projects", "Garden.md")); ok {
+		t.Error("deleted note still indexed")
+	}
+	if w := do("GET", "/notes/3.%20Projects/Garden.md", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("get deleted status = %d", w.Code)
+	}
+}
+
+func TestProcessInboxEndpoints(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "0. GTD System/Templates/Next Action Template.md",
+		"---\nstatus: [next/waiting]\ncontext: [@calls/@home]\nproject: \"[[Project Name]]\"\n---\n# {{title}}\n\n## Notes\nAdditional context\n")
+	writeVaultFile(t, tmpVault, "1. Inbox/Buy paint.md", "---\ncreated: 2024-03-01\nstatus: inbox\n---\n# Buy paint\n\nWhite, two litres\n")
+	writeVaultFile(t, tmpVault, "1. Inbox/Old flyer.md", "---\ncreated: 2024-03-02\nstatus: inbox\n---\n# Old flyer\n")
+
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	tmplEngine := vault.NewTemplateEngine(filepath.Join(tmpVault, "0. GTD System", "Templates"))
+	router := NewRouter(repo, &MockGenerator{}, tmplEngine, tmpVault, nil, index, nil, nil, nil, nil)
+
+	req := httptest.NewRequest("GET", "/inbox/next", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var next struct {
+		Remaining int `json:"remaining"`
+		Item      struct {
+			Path string `json:"path"`
+		} `json:"item"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &next)
+	if next.Remaining != 2 || next.Item.Path != filepath.Join("1. Inbox", "Buy paint.md") {
+		t.Fatalf("next = %s", w.Body.String())
+	}
+
+	process := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/inbox/process", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	w = process(`{"path": "1. Inbox/Buy paint.md", "action": "next_action", "context": "@errands"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("process status = %d body=%s", w.Code, w.Body.String())
+	}
+	var res struct {
+		NotePath  string `json:"note_path"`
+		Remaining int    `json:"remaining"`
+		Next      string `json:"next"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if res.NotePath != filepath.Join("2. Next Actions", "@errands", "Buy paint.md") || res.Remaining != 1 || res.Next != filepath.Join("1. Inbox", "Old flyer.md") {
+		t.Errorf("process response = %s", w.Body.String())
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpVault, res.NotePath))
+	if !strings.Contains(string(data), "White, two litres") {
+		t.Errorf("original content not carried over:\n%s", data)
+	}
+
+	if w := process(`{"path": "1. Inbox/Old flyer.md", "action": "trash"}`); w.Code != http.StatusOK {
+		t.Fatalf("trash status = %d", w.Code)
+	}
+	if w := process(`{"path": "1. Inbox/Old flyer.md", "action": "trash"}`); w.Code != http.StatusNotFound {
+		t.Errorf("second trash status = %d", w.Code)
+	}
+	if w := process(`{"path": "3. Projects/X.md", "action": "trash"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("non-inbox status = %d", w.Code)
+	}
+	writeVaultFile(t, tmpVault, "1. Inbox/Article.md", "---\nstatus: inbox\n---\n# Article\n")
+	if w := process(`{"path": "1. Inbox/Article.md", "action": "next_action"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing context status = %d", w.Code)
+	}
+	// The vault has no Reference Template: a server problem, not a bad request
+	if w := process(`{"path": "1. Inbox/Article.md", "action": "reference"}`); w.Code != http.StatusInternalServerError {
+		t.Errorf("missing template status = %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/inbox/decisions", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var log struct {
+		Decisions []db.InboxDecisionRecord `json:"decisions"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &log)
+	if len(log.Decisions) != 2 || log.Decisions[0].Action != "trash" || !strings.Contains(log.Decisions[0].OriginalContent, "# Old flyer") {
+		t.Errorf("decisions = %s", w.Body.String())
+	}
+	if log.Decisions[1].Decision.Context != "@errands" {
+		t.Errorf("decision details not stored: %+v", log.Decisions[1])
+	}
+}
+
+func TestInboxSuggestionQueue(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "0. GTD System/Templates/Inbox Item Template.md", "---\nstatus: inbox\n---\n# {{title}}\n\n## Description\n")
+	writeVaultFile(t, tmpVault, "0. GTD System/Templates/Next Action Template.md",
+		"---\nstatus: [next/waiting]\ncontext: [@calls/@home]\nproject: \"[[Project Name]]\"\n---\n# {{title}}\n\n## Notes\nAdditional context\n")
+	writeVaultFile(t, tmpVault, "3. Projects/Garden.md", "---\nstatus: active\ntype: project\n---\n# Garden\n")
+
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	tmplEngine := vault.NewTemplateEngine(filepath.Join(tmpVault, "0. GTD System", "Templates"))
+	mockAI := &MockGenerator{}
+	router := NewRouter(repo, mockAI, tmplEngine, tmpVault, nil, index, nil, nil, nil, nil)
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	mockAI.Response = "```json\n" + `{"type": "task", "title": "Buy seeds", "description": "Tomatoes", "action": "next_action",
+		"context": "errands", "project": "[[garden]]", "due_date": "2024-04-01", "priority": "high", "confidence": 1.4, "reason": "A purchase"}` + "\n```"
+	if w := do("POST", "/inbox", `{"content": "buy tomato seeds for the garden"}`); w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d body=%s", w.Code, w.Body.String())
+	}
+	mockAI.Response = `{"type": "idea", "title": "Learn pottery", "description": "Maybe", "confidence": 0.4}`
+	do("POST", "/inbox", `{"content": "pottery?"}`)
+	mockAI.Response = `{"title": "Unsure", "description": "?", "action": "archive"}`
+	do("POST", "/inbox", `{"content": "??"}`)
+
+	var list struct {
+		Suggestions []db.InboxSuggestion `json:"suggestions"`
+	}
+	json.Unmarshal(do("GET", "/inbox/suggestions", "").Body.Bytes(), &list)
+	if len(list.Suggestions) != 2 {
+		t.Fatalf("suggestions = %+v", list.Suggestions)
+	}
+	seeds, pottery := list.Suggestions[0], list.Suggestions[1]
+	want := vault.InboxDecision{Action: "next_action", Context: "@errands", Project: "Garden", Priority: "high", DueDate: "2024-04-01"}
+	if seeds.Decision != want || seeds.Confidence != 1 || seeds.TargetFolder != filepath.Join("2. Next Actions", "@errands") ||
+		seeds.InboxPath != filepath.Join("1. Inbox", "Buy seeds.md") {
+		t.Errorf("seeds suggestion = %+v", seeds)
+	}
+	if pottery.Decision.Action != "someday" || pottery.Confidence != 0.4 {
+		t.Errorf("pottery suggestion = %+v", pottery)
+	}
+
+	// Accept with an edit
+	w := do("POST", "/inbox/suggestions/"+strconv.FormatInt(seeds.ID, 10)+"/accept", `{"context": "@home"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("accept status = %d body=%s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpVault, "2. Next Actions", "@home", "Buy seeds.md")); err != nil {
+		t.Errorf("accepted suggestion not executed: %v", err)
+	}
+	if w := do("POST", "/inbox/suggestions/"+strconv.FormatInt(seeds.ID, 10)+"/accept", ""); w.Code != http.StatusConflict {
+		t.Errorf("second accept status = %d", w.Code)
+	}
+	s, _ := repo.GetInboxSuggestion(seeds.ID)
+	if s.Status != db.SuggestionEdited || s.FinalDecision == nil || s.FinalDecision.Context != "@home" || s.ResolvedAt == nil {
+		t.Errorf("edited suggestion = %+v", s)
+	}
+
+	// Reject, then process by hand
+	if w := do("POST", "/inbox/suggestions/"+strconv.FormatInt(pottery.ID, 10)+"/reject", `{"reason": "I already do pottery"}`); w.Code != http.StatusOK {
+		t.Fatalf("reject status = %d body=%s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpVault, "1. Inbox", "Learn pottery.md")); err != nil {
+		t.Errorf("rejected item left the inbox: %v", err)
+	}
+	do("POST", "/inbox/process", `{"path": "1. Inbox/Learn pottery.md", "action": "trash"}`)
+	json.Unmarshal(do("GET", "/inbox/suggestions?status=rejected", "").Body.Bytes(), &list)
+	if len(list.Suggestions) != 1 || list.Suggestions[0].Feedback != "I already do pottery" ||
+		list.Suggestions[0].FinalDecision == nil || list.Suggestions[0].FinalDecision.Action != "trash" {
+		t.Errorf("rejected suggestions = %+v", list.Suggestions)
+	}
+	if w := do("GET", "/inbox/suggestions?status=maybe", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("bad status filter = %d", w.Code)
+	}
+	if w := do("POST", "/inbox/suggestions/999/reject", ""); w.Code != http.StatusNotFound {
+		t.Errorf("missing suggestion status = %d", w.Code)
+	}
+}
+
+func TestWeeklyReviewStream(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "0. GTD System/Templates/Weekly Review Template.md", "# Weekly Review\n")
+
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	tmplEngine := vault.NewTemplateEngine(filepath.Join(tmpVault, "0. GTD System", "Templates"))
+	tmplEngine.Clock = func() time.Time { return time.Date(2024, time.March, 6, 9, 0, 0, 0, time.UTC) }
+	router := NewRouter(repo, &MockGenerator{Response: "All projects are moving."}, tmplEngine, tmpVault, nil, index, nil, nil, nil, nil)
+
+	req := httptest.NewRequest("POST", "/review/weekly?stream=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, s := range []string{
+		"event: chunk\ndata: {\"text\":\"All \"}\n\n",
+		"event: chunk\ndata: {\"text\":\"moving.\"}\n\n",
+		"event: done\ndata: {\"path\":\"2024-W10 Weekly Review.md\",\"status\":\"created\"}\n\n",
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("stream is missing %q:\n%s", s, body)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpVault, "6. Weekly Reviews", "2024-W10 Weekly Review.md"))
+	if !strings.Contains(string(data), "## AI Insights\nAll projects are moving.\n") {
+		t.Errorf("review file:\n%s", data)
+	}
+
+	router = NewRouter(repo, &MockGenerator{Err: context.DeadlineExceeded}, tmplEngine, tmpVault, nil, index, nil, nil, nil, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/review/weekly?stream=1", nil))
+	if !strings.Contains(w.Body.String(), "event: error\n") {
+		t.Errorf("expected an error event:\n%s", w.Body.String())
+	}
+}
+
+func TestAIUsageEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	metered := ai.NewMeteredGenerator(&MockGenerator{}, repo, ai.DefaultPrices)
+	metered.MonthlyBudget = 1
+	router := NewRouter(repo, metered, nil, tmpVault, nil, nil, nil, nil, nil, nil)
+
+	if err := repo.RecordAIUsage(&ai.UsageRecord{Feature: "inbox", Provider: "openai", Model: "gpt-4o-mini", PromptTokens: 10, CostUSD: 0.4, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ai/usage?days=7", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Daily     []db.AIUsageAggregate `json:"daily"`
+		Monthly   []db.AIUsageAggregate `json:"monthly"`
+		Spent     float64               `json:"month_to_date_usd"`
+		Budget    float64               `json:"monthly_budget_usd"`
+		Remaining float64               `json:"budget_remaining_usd"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Daily) != 1 || resp.Daily[0].Feature != "inbox" || len(resp.Monthly) != 1 {
+		t.Errorf("aggregates = %+v", resp)
+	}
+	if resp.Spent != 0.4 || resp.Budget != 1 || resp.Remaining != 0.6 {
+		t.Errorf("budget = %+v", resp)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ai/usage?days=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("days=0 status = %d", w.Code)
+	}
+}
+
+func TestSemanticSearchEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "3. Projects/Garden Fence.md", "# Garden Fence\n\nBob quoted 300 EUR for the fence panels.\n")
+	writeVaultFile(t, tmpVault, "5. Reference/Tax Return.md", "# Tax Return\n\nSend the documents to the accountant.\n")
+	index := vault.NewIndex(tmpVault, nil)
+	index.Scan()
+	search := semantic.NewIndex(index, ai.NewHashEmbedder(), repo)
+	if _, err := search.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(repo, &MockGenerator{}, nil, tmpVault, nil, index, search, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/search/semantic?q=fence+quote&limit=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Results []semantic.Result `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Path != "3. Projects/Garden Fence.md" || !strings.Contains(resp.Results[0].Text, "300 EUR") {
+		t.Errorf("results = %+v", resp.Results)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/search/semantic", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("missing q status = %d", w.Code)
+	}
+}
+
+func TestAssistantChatEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	index := vault.NewIndex(tmpVault, nil)
+	gen := &MockGenerator{Response: "Nothing is due today."}
+	chat := assistant.New(gen, assistant.NewVaultRegistry(index, nil, nil, vault.NewCompleter(index, nil)), ai.NewPromptLibrary(t.TempDir()), repo)
+	router := NewRouter(repo, gen, nil, tmpVault, nil, index, nil, chat, nil, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/assistant/chat", strings.NewReader(`{"message":"What's on my plate today?"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var reply assistant.Reply
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Text != "Nothing is due today." || reply.ConversationID == "" || reply.Steps != 1 {
+		t.Errorf("reply = %+v", reply)
+	}
+	if msgs, _ := repo.ConversationMessages(reply.ConversationID, 10); len(msgs) != 2 {
+		t.Errorf("expected the conversation to be stored, got %+v", msgs)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/assistant/chat", strings.NewReader(`{"conversation_id":"x"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("empty message status = %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/assistant/conversations/"+reply.ConversationID, nil))
+	if msgs, _ := repo.ConversationMessages(reply.ConversationID, 10); w.Code != http.StatusNoContent || len(msgs) != 0 {
+		t.Errorf("delete status = %d, messages = %+v", w.Code, msgs)
+	}
+}
+
+func TestNextActionsEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "2. Next Actions/@calls/Call Bob.md", "---\nstatus: next\ncontext: \"@calls\"\nproject: \"[[Website]]\"\npriority: high\ntags: [5min]\n---\n# Call Bob\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@home/Paint fence.md", "---\nstatus: next\ncontext: \"@home\"\nenergy: high\ntime_estimate: 2h\n---\n# Paint fence\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@computer/Pay invoice.md", "---\nstatus: next\ncontext: \"@computer\"\ndue_date: 2020-01-01\ntags: [low-energy, 15min]\n---\n# Pay invoice\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@computer/Old task.md", "---\nstatus: done\ncontext: \"@computer\"\n---\n# Old task\n")
+	index := vault.NewIndex(tmpVault, nil)
+	if err := index.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(repo, &MockGenerator{}, nil, tmpVault, nil, index, nil, nil, nil, nil)
+
+	get := func(url string, v interface{}) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code
+	}
+
+	var list struct {
+		Count   int            `json:"count"`
+		Actions []vault.Action `json:"actions"`
+	}
+	if code := get("/next-actions?context=@computer", &list); code != http.StatusOK || list.Count != 1 || list.Actions[0].Title != "Pay invoice" {
+		t.Errorf("context filter: status = %d, %+v", code, list)
+	}
+	if code := get("/next-actions?tag=5min&project=Website", &list); code != http.StatusOK || list.Count != 1 || list.Actions[0].Title != "Call Bob" {
+		t.Errorf("tag filter: status = %d, %+v", code, list)
+	}
+
+	var grouped struct {
+		Groups []actionGroup `json:"groups"`
+	}
+	if code := get("/next-actions?group_by=time", &grouped); code != http.StatusOK || len(grouped.Groups) != 2 ||
+		grouped.Groups[0].Key != "15min" || len(grouped.Groups[0].Actions) != 2 || grouped.Groups[1].Key != "longer" {
+		t.Errorf("group_by=time: status = %d, %+v", code, grouped)
+	}
+
+	var now struct {
+		Count   int                  `json:"count"`
+		Actions []vault.RankedAction `json:"actions"`
+	}
+	if code := get("/next-actions?mode=now&minutes=30&energy=low&location=office", &now); code != http.StatusOK || now.Count != 2 || now.Actions[0].Title != "Pay invoice" {
+		t.Errorf("mode=now: status = %d, %+v", code, now)
+	}
+
+	if code := get("/next-actions?group_by=colour", &list); code != http.StatusBadRequest {
+		t.Errorf("bad group_by status = %d", code)
+	}
+	if code := get("/next-actions?due_before=tomorrow", &list); code != http.StatusBadRequest {
+		t.Errorf("bad due_before status = %d", code)
+	}
+}
+
+func TestCompleteNextActionEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "3. Projects/Website.md", "---\nstatus: active\n---\n# Website\n\n## Next Actions\n- [ ] [[Deploy]]\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@computer/Deploy.md", "---\nstatus: next\nproject: \"[[Website]]\"\n---\n# Deploy\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@home/Water plants.md", "---\nstatus: next\nrecur: every week when done\n---\n# Water plants\n")
+	index := vault.NewIndex(tmpVault, nil)
+	if err := index.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(repo, &MockGenerator{}, nil, tmpVault, nil, index, nil, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/next-actions/@computer/Deploy/complete?archive=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var result vault.CompletionResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.DonePath != filepath.Join("2. Next Actions", "Archive", "@computer", "Deploy.md") || result.ProjectPath == "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpVault, "3. Projects", "Website.md")); !strings.Contains(string(data), "- [x] [[Deploy]]") {
+		t.Errorf("project not ticked:\n%s", data)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/next-actions/2.%20Next%20Actions/@home/Water%20plants.md/complete", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &result); w.Code != http.StatusOK || err != nil || result.NextDue != time.Now().AddDate(0, 0, 7).Format("2006-01-02") {
+		t.Errorf("recurring: status = %d, %+v", w.Code, result)
+	}
+
+	for url, want := range map[string]int{
+		"/next-actions/@computer/Deploy/complete":                                                 http.StatusNotFound,
+		"/next-actions/@home/Water plants 2/complete":                                             http.StatusNotFound,
+		"/next-actions/Archive/@computer/Deploy/complete":                                         http.StatusBadRequest,
+		"/next-actions/@home/Water plants/undo":                                                   http.StatusNotFound,
+		"/next-actions/@home/Water plants " + result.Completed.Format("2006-01-02") + "/complete": http.StatusConflict,
+	} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", strings.ReplaceAll(url, " ", "%20"), nil))
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d", url, w.Code, want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/next-actions/completions?days=1", nil))
+	var logged struct {
+		Stats       db.CompletionStats    `json:"stats"`
+		Completions []db.CompletionRecord `json:"completions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &logged); w.Code != http.StatusOK || err != nil || logged.Stats.Total != 2 || len(logged.Completions) != 2 || logged.Completions[0].Title != "Water plants" {
+		t.Errorf("completions: status = %d, %+v", w.Code, logged)
+	}
+}
+
+func TestProjectEndpoints(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "3. Projects/Active/Website.md", "---\nstatus: active\ntype: project\n---\n# Website\n")
+	writeVaultFile(t, tmpVault, "3. Projects/Active/Garden.md", "---\nstatus: planning\ntype: project\n---\n# Garden\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@computer/Deploy.md", "---\nstatus: next\nproject: \"[[Website]]\"\n---\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@waiting/Quote.md", "---\nstatus: waiting\nwaiting_for: Bob\nproject: \"[[Website]]\"\n---\n")
+	index := vault.NewIndex(tmpVault, nil)
+	if err := index.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(repo, &MockGenerator{}, nil, tmpVault, nil, index, nil, nil, nil, nil)
+	do := func(method, url, body string, v interface{}) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		if w.Code == http.StatusOK && v != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code
+	}
+
+	var list struct {
+		Count    int                    `json:"count"`
+		Projects []vault.ProjectSummary `json:"projects"`
+	}
+	if code := do("GET", "/projects", "", &list); code != http.StatusOK || list.Count != 2 || list.Projects[1].Title != "Website" {
+		t.Fatalf("list: status = %d, %+v", code, list)
+	}
+	if web := list.Projects[1]; !web.HasNextAction || len(web.NextActions) != 1 || len(web.WaitingFor) != 1 {
+		t.Errorf("website = %+v", web)
+	}
+	if code := do("GET", "/projects?status=planning", "", &list); code != http.StatusOK || list.Count != 1 || list.Projects[0].Title != "Garden" {
+		t.Errorf("status filter: status = %d, %+v", code, list)
+	}
+
+	var resp projectStatusResponse
+	if code := do("POST", "/projects/Website/status", `{"status":"completed","note":"Live."}`, &resp); code != http.StatusOK || resp.Transition.Path != filepath.Join("3. Projects", "Archive", "Website.md") {
+		t.Fatalf("complete: status = %d, %+v", code, resp)
+	}
+	if resp.Project == nil || resp.Project.Status != "completed" || len(resp.Project.History) != 1 || resp.Project.History[0].From != "active" {
+		t.Errorf("completed project = %+v", resp.Project)
+	}
+
+	var detail projectDetail
+	if code := do("GET", "/projects/3.%20Projects/Archive/Website.md", "", &detail); code != http.StatusOK || detail.Title != "Website" || len(detail.History) != 1 {
+		t.Errorf("detail: status = %d, %+v", code, detail)
+	}
+	if code := do("POST", "/projects/Garden/status", `{"status":"completed"}`, nil); code != http.StatusConflict {
+		t.Errorf("planning -> completed status = %d", code)
+	}
+	if code := do("POST", "/projects/Garden/status", `{"status":"done"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("unknown status = %d", code)
+	}
+	if code := do("GET", "/projects/Boat", "", nil); code != http.StatusNotFound {
+		t.Errorf("missing project status = %d", code)
+	}
+}
+
+func TestWaitingEndpoint(t *testing.T) {
+	tmpVault := t.TempDir()
+	repo := setupTestRepo(t, tmpVault)
+	writeVaultFile(t, tmpVault, "2. Next Actions/@waiting/Quote.md", "---\nstatus: waiting\nwaiting_for: Bob\ndate_requested: 2020-01-01\nfollow_up_date: 2020-01-08\nproject: \"[[Garden]]\"\n---\n# Quote\n")
+	writeVaultFile(t, tmpVault, "2. Next Actions/@waiting/Contract.md", "---\nstatus: waiting\nwaiting_for: Legal\nfollow_up_date: 2999-01-01\n---\n# Contract\n")
+	index := vault.NewIndex(tmpVault, nil)
+	if err := index.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(repo, &MockGenerator{}, nil, tmpVault, nil, index, nil, nil, nil, nil)
+
+	get := func(url string) (int, int, []vault.WaitingItem) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		var resp struct {
+			Count   int                 `json:"count"`
+			Overdue int                 `json:"overdue"`
+			Items   []vault.WaitingItem `json:"items"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, resp.Overdue, resp.Items
+	}
+
+	if code, overdue, items := get("/waiting"); code != http.StatusOK || overdue != 1 || len(items) != 2 || items[0].Title != "Quote" || !items[0].Overdue {
+		t.Errorf("GET /waiting: status = %d, overdue = %d, %+v", code, overdue, items)
+	}
+	if code, _, items := get("/waiting?overdue=1"); code != http.StatusOK || len(items) != 1 || items[0].WaitingFor != "Bob" {
+		t.Errorf("overdue filter: status = %d, %+v", code, items)
+	}
+	if code, _, items := get("/waiting?person=legal"); code != http.StatusOK || len(items) != 1 || items[0].Title != "Contract" {
+		t.Errorf("person filter: status = %d, %+v", code, items)
+	}
+	if code, _, items := get("/waiting?project=Garden"); code != http.StatusOK || len(items) != 1 {
+		t.Errorf("project filter: status = %d, %+v", code, items)
+	}
+}
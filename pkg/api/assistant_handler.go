@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/assistant"
+)
+
+// ChatRequest is the payload of POST /assistant/chat
+type ChatRequest struct {
+	ConversationID string `json:"conversation_id"` // empty starts a new conversation
+	Message        string `json:"message"`
+}
+
+// HandleAssistantChat handles POST /assistant/chat. The model answers the
+// message using the vault tools; the reply carries the conversation ID to
+// continue with and the tool calls made.
+func (h *Handler) HandleAssistantChat(w http.ResponseWriter, r *http.Request) {
+	if h.Assistant == nil {
+		http.Error(w, "assistant is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	reply, err := h.Assistant.Chat(r.Context(), req.ConversationID, "api", req.Message)
+	switch {
+	case errors.Is(err, assistant.ErrEmptyMessage):
+		http.Error(w, "message is required", http.StatusBadRequest)
+		return
+	case errors.Is(err, ai.ErrToolsUnsupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// HandleDeleteConversation handles DELETE /assistant/conversations/{id}
+func (h *Handler) HandleDeleteConversation(w http.ResponseWriter, r *http.Request) {
+	if h.Assistant == nil {
+		http.Error(w, "assistant is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.Assistant.Reset(r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
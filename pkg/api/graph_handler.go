@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+type graphNode struct {
+	Path   string `json:"path"`
+	Title  string `json:"title"`
+	Kind   string `json:"kind,omitempty"` // schema name, e.g. next-action
+	Status string `json:"status,omitempty"`
+}
+
+type backlink struct {
+	graphNode
+	Link vault.Link `json:"link"`
+}
+
+// HandleNoteSubresource serves GET /notes/{path...} and
+// GET /notes/{path...}/backlinks.
+func (h *Handler) HandleNoteSubresource(w http.ResponseWriter, r *http.Request) {
+	path := r.PathValue("path")
+	if rest, ok := strings.CutSuffix(path, "/backlinks"); ok {
+		h.handleBacklinks(w, r, rest)
+		return
+	}
+	h.handleGetNote(w, r, path)
+}
+
+// handleBacklinks lists the notes linking to a note. The note may be given
+// by vault path (with or without .md) or by name. ?kind= filters the linking
+// notes by schema, e.g. kind=next-action for a project's actions.
+func (h *Handler) handleBacklinks(w http.ResponseWriter, r *http.Request, ref string) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	graph := h.Index.Graph()
+	target, ok := graph.Resolve(ref)
+	if !ok {
+		http.Error(w, "note not found", http.StatusNotFound)
+		return
+	}
+
+	kind := r.URL.Query().Get("kind")
+	out := []backlink{}
+	for _, e := range graph.Backlinks(target) {
+		node := h.graphNode(e.Source)
+		if kind != "" && node.Kind != kind {
+			continue
+		}
+		out = append(out, backlink{graphNode: node, Link: e.Link})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"path":      target,
+		"backlinks": out,
+	})
+}
+
+// HandleGraph returns the vault link graph. ?folder= limits it to links from
+// notes under a folder.
+func (h *Handler) HandleGraph(w http.ResponseWriter, r *http.Request) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	folder := r.URL.Query().Get("folder")
+
+	nodes := []graphNode{}
+	for _, n := range h.Index.All() {
+		if folder == "" || vault.InFolder(n.Path, folder) {
+			nodes = append(nodes, nodeFor(&n))
+		}
+	}
+	edges := []vault.Edge{}
+	for _, e := range h.Index.Graph().Edges() {
+		if folder == "" || vault.InFolder(e.Source, folder) {
+			edges = append(edges, e)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"nodes": nodes,
+		"edges": edges,
+	})
+}
+
+func (h *Handler) graphNode(path string) graphNode {
+	n, ok := h.Index.Get(path)
+	if !ok {
+		return graphNode{Path: path}
+	}
+	return nodeFor(&n)
+}
+
+func nodeFor(n *vault.IndexedNote) graphNode {
+	node := graphNode{Path: n.Path, Title: n.Title, Status: n.Status()}
+	if s := vault.SchemaFor(n.Path, n.Frontmatter); s != nil {
+		node.Kind = s.Name
+	}
+	return node
+}
@@ -2,15 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
+	gosync "sync"
 
 	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/assistant"
 	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/semantic"
 	"github.com/mklimuk/vault-pilot/pkg/sync"
 	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
@@ -22,6 +26,27 @@ type Handler struct {
 	TmplEngine *vault.TemplateEngine
 	VaultPath  string
 	Git        *sync.GitManager
+	Index      *vault.Index
+	Mover      *vault.Mover
+	Processor  *vault.InboxProcessor
+	Completer  *vault.Completer
+	Projects   *vault.ProjectManager
+	Prompts    *ai.PromptLibrary
+	Search     *semantic.Index
+	Assistant  *assistant.Assistant
+
+	notesMu gosync.Mutex // stands in for the index's note lock without an index
+}
+
+// lockNotes takes the vault's note lock, shared with the vault writers, so
+// If-Match checks hold until the write. It returns the unlock function.
+func (h *Handler) lockNotes() func() {
+	if h.Index == nil {
+		h.notesMu.Lock()
+		return h.notesMu.Unlock
+	}
+	h.Index.LockNotes()
+	return h.Index.UnlockNotes
 }
 
 // CreateInboxRequest represents the payload for creating an inbox item
@@ -38,37 +63,61 @@ func (h *Handler) HandleCreateInboxItem(w http.ResponseWriter, r *http.Request)
 	}
 
 	// 1. Analyze content with AI
-	prompt := ai.AnalyzeInboxPrompt(req.Content)
-	analysisJSON, err := h.AI.GenerateText(r.Context(), prompt)
+	projects := h.activeProjects()
+	resp := map[string]interface{}{"status": "created"}
+	ctx, usage := ai.WithUsageTracker(ai.WithFeature(r.Context(), "inbox"))
+	analysis, prompt, err := h.Prompts.AnalyzeInbox(ctx, h.AI, req.Content, projects...)
+	if errors.Is(err, ai.ErrInvalidResponse) {
+		// Keep the capture even when the analysis is unusable
+		log.Printf("Inbox analysis: %v", err)
+		resp["warning"] = err.Error()
+		analysis, prompt, err = &ai.InboxAnalysis{Title: ai.FallbackTitle(req.Content), Description: req.Content}, nil, nil
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("AI analysis failed: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	// Parse AI response (expecting JSON)
-	// For simplicity, we'll just try to unmarshal it into a map or struct
-	// Note: LLMs might return markdown code blocks, need to strip them
-	analysisJSON = cleanJSON(analysisJSON)
-
-	var analysis struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		// We could use other fields too
-	}
-	if err := json.Unmarshal([]byte(analysisJSON), &analysis); err != nil {
-		// Fallback if JSON is invalid
-		analysis.Title = "New Inbox Item"
-		analysis.Description = req.Content
+	if strings.TrimSpace(analysis.Title) == "" {
+		analysis.Title = ai.FallbackTitle(req.Content)
 	}
 
 	// 2. Create file using Vault Controller
-	err = vault.CreateInboxItem(h.VaultPath, h.TmplEngine, analysis.Title, analysis.Description)
+	path, err := vault.CreateInboxNote(h.VaultPath, h.TmplEngine, analysis.Title, analysis.Description)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create file: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if prompt != nil {
+		if err := vault.EditFrontmatter(filepath.Join(h.VaultPath, path), func(e *vault.FrontmatterEditor) error {
+			return e.SetGeneratedBy(prompt.Ref(), usage.Model())
+		}); err != nil {
+			log.Printf("Record prompt version on %s: %v", path, err)
+		}
+	}
+	if h.Index != nil {
+		if err := h.Index.Update(path); err != nil {
+			log.Printf("Index inbox item %s: %v", path, err)
+		}
+	}
+
+	// 3. Queue the suggested processing decision for review
+	resp["title"], resp["path"] = analysis.Title, path
+	if d, ok := suggestedDecision(analysis, projects); ok && h.Repo != nil {
+		s := &db.InboxSuggestion{
+			InboxPath:  path,
+			Title:      analysis.Title,
+			Decision:   d,
+			Confidence: clampConfidence(analysis.Confidence),
+			Reason:     analysis.Reason,
+		}
+		if err := h.Repo.CreateInboxSuggestion(s); err != nil {
+			log.Printf("Store inbox suggestion for %s: %v", path, err)
+		} else {
+			resp["suggestion"] = s
+		}
+	}
 
-	// 3. Sync with Git
+	// 4. Sync with Git
 	if h.Git != nil {
 		go func() {
 			if err := h.Git.Sync("Add inbox item: " + analysis.Title); err != nil {
@@ -77,155 +126,164 @@ func (h *Handler) HandleCreateInboxItem(w http.ResponseWriter, r *http.Request)
 		}()
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "created", "title": analysis.Title})
+	writeJSON(w, http.StatusCreated, resp)
 }
 
-// HandleListProjects handles GET /projects
-func (h *Handler) HandleListProjects(w http.ResponseWriter, r *http.Request) {
-	// Scan "3. Projects" directory
-	projectsDir := filepath.Join(h.VaultPath, "3. Projects")
+// activeProjects returns the names of projects whose status is active, or
+// none without a vault index
+func (h *Handler) activeProjects() []string {
+	if h.Index == nil {
+		return nil
+	}
 	var activeProjects []string
+	for _, n := range h.Index.Query(vault.Query{Folder: "3. Projects", Status: "active"}) {
+		activeProjects = append(activeProjects, n.Title)
+	}
+	return activeProjects
+}
 
-	err := filepath.Walk(projectsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
-			// Read note to check status
-			note, err := vault.ReadNote(path)
-			if err != nil {
-				return nil // Skip unreadable
-			}
+// HandleGenerateWeeklyReview handles POST /review/weekly. With ?stream=1 the
+// AI insights are sent as server-sent events while they are generated.
+func (h *Handler) HandleGenerateWeeklyReview(w http.ResponseWriter, r *http.Request) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
 
-			// Check status in frontmatter
-			if fm, ok := note.Frontmatter.(map[string]interface{}); ok {
-				if status, ok := fm["status"].(string); ok && status == "active" {
-					activeProjects = append(activeProjects, strings.TrimSuffix(info.Name(), ".md"))
-				}
-			}
-		}
-		return nil
-	})
+	// 1. Gather Context
+	inboxCount := len(h.Index.Query(vault.Query{Folder: "1. Inbox"}))
+	activeProjects := h.activeProjects()
 
+	// Load Weekly Review Template
+	tmpl, err := h.TmplEngine.LoadTemplate("Weekly Review Template")
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to scan projects: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to load template: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{"projects": activeProjects})
-}
-
-// HandleGenerateWeeklyReview handles POST /review/weekly
-func (h *Handler) HandleGenerateWeeklyReview(w http.ResponseWriter, r *http.Request) {
-	// 1. Gather Context
-	// Count Inbox
-	inboxDir := filepath.Join(h.VaultPath, "1. Inbox")
-	inboxCount := 0
-	filepath.Walk(inboxDir, func(path string, info os.FileInfo, err error) error {
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
-			inboxCount++
-		}
-		return nil
-	})
-
-	// Get Active Projects (reuse logic or refactor)
-	// For brevity, let's assume we have a helper or just do it again
-	// Actually, let's just call the internal logic if we extracted it, but for now copy-paste is safer for speed
-	projectsDir := filepath.Join(h.VaultPath, "3. Projects")
-	var activeProjects []string
-	filepath.Walk(projectsDir, func(path string, info os.FileInfo, err error) error {
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
-			note, _ := vault.ReadNote(path)
-			if note != nil {
-				if fm, ok := note.Frontmatter.(map[string]interface{}); ok {
-					if status, ok := fm["status"].(string); ok && status == "active" {
-						activeProjects = append(activeProjects, strings.TrimSuffix(info.Name(), ".md"))
-					}
-				}
-			}
-		}
-		return nil
-	})
+	if r.URL.Query().Get("stream") == "1" {
+		h.streamWeeklyReview(w, r, tmpl, activeProjects, inboxCount)
+		return
+	}
 
 	// 2. Generate Content with AI
-	prompt := ai.GenerateReviewPrompt(activeProjects, inboxCount)
-	aiResponse, err := h.AI.GenerateText(r.Context(), prompt)
+	ctx, usage := ai.WithUsageTracker(ai.WithFeature(r.Context(), "weekly_review"))
+	insights, prompt, err := h.Prompts.GenerateReview(ctx, h.AI, activeProjects, inboxCount)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("AI generation failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// 3. Create Review File
-	// Load Weekly Review Template
-	tmpl, err := h.TmplEngine.LoadTemplate("Weekly Review Template")
+	filename, err := h.writeWeeklyReview(tmpl, activeProjects, prompt, usage, func(doc *vault.Document) string {
+		return reviewInsights(doc, insights)
+	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to load template: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "created", "path": filename})
+}
+
+// streamWeeklyReview streams the AI insights as "chunk" events and ends with
+// a "done" event holding the created file, or an "error" event.
+func (h *Handler) streamWeeklyReview(w http.ResponseWriter, r *http.Request, tmpl string, activeProjects []string, inboxCount int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, payload interface{}) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
 
+	ctx, usage := ai.WithUsageTracker(ai.WithFeature(r.Context(), "weekly_review"))
+	prompt, err := h.Prompts.ReviewMarkdownPrompt(ctx, activeProjects, inboxCount)
+	var insights string
+	if err == nil {
+		ctx := prompt.Context(ctx)
+		insights, err = h.AI.Stream(ctx, prompt.Request(), func(delta string) error {
+			return send("chunk", map[string]string{"text": delta})
+		})
+	}
+	if err == nil {
+		var filename string
+		filename, err = h.writeWeeklyReview(tmpl, activeProjects, prompt, usage, func(*vault.Document) string {
+			return strings.TrimSpace(insights)
+		})
+		if err == nil {
+			send("done", map[string]string{"status": "created", "path": filename})
+			return
+		}
+	}
+	log.Printf("Weekly review stream: %v", err)
+	send("error", map[string]string{"error": err.Error()})
+}
+
+// writeWeeklyReview renders the weekly review template, fills it with the
+// active projects and the AI insights, records the prompt version and model,
+// writes it and logs the review. It returns the file name.
+func (h *Handler) writeWeeklyReview(tmpl string, activeProjects []string, prompt *ai.Prompt, usage *ai.UsageTracker, insights func(doc *vault.Document) string) (string, error) {
 	// Render basic placeholders
-	title := fmt.Sprintf("Weekly Review - %s", time.Now().Format("2006-01-02"))
+	title := fmt.Sprintf("Weekly Review - %s", h.TmplEngine.Now().Format("2006-01-02"))
 	content := h.TmplEngine.Render(tmpl, title)
 
-	// Inject AI content
-	// This is tricky without a robust parser.
-	// We'll append the AI response to the "Reflections" section if possible, or just at the end.
-	// Or better, we replace a placeholder if we added one, but we didn't.
-	// Let's just append it for now.
-	content += "\n\n## AI Insights\n" + aiResponse
-
-	// Populate Active Projects List in the markdown
-	// Find "### Active Projects" and inject list
-	projectListMD := ""
+	// Fill the template sections
+	doc := vault.ParseDocument(content)
+	health := make(map[string]vault.ProjectSummary)
+	for _, p := range vault.ListProjects(h.Index, "active", h.TmplEngine.Now()) {
+		health[p.Title] = p
+	}
+	var projectList []string
 	for _, p := range activeProjects {
-		projectListMD += fmt.Sprintf("- [ ] [[%s]] - Status: Active\n", p)
-	}
-
-	// Simple string replacement for the section
-	if strings.Contains(content, "### Active Projects") {
-		// We want to insert after the header.
-		// Regex or split? Split is easier.
-		parts := strings.Split(content, "### Active Projects")
-		if len(parts) > 1 {
-			// Reconstruct: Part 0 + Header + List + Part 1
-			// But Part 1 starts with the content after header.
-			// We need to be careful not to overwrite existing text if template has some.
-			// The template has:
-			// ### Active Projects
-			// Review each project for:
-			// ...
-
-			// So we should probably append to the list or replace the placeholder lines.
-			// Let's just inject it after "Review each project for:"
-			if strings.Contains(parts[1], "Review each project for:") {
-				subParts := strings.Split(parts[1], "Review each project for:")
-				content = parts[0] + "### Active Projects" + subParts[0] + "Review each project for:\n" + projectListMD + subParts[1]
-			}
+		projectList = append(projectList, "- [ ] "+projectReviewLine(p, health[p]))
+	}
+	if len(projectList) > 0 {
+		// List the projects under the lead-in, above the generic checklist
+		if err := doc.InsertAfter("Project Review > Active Projects", "Review each project for:", strings.Join(projectList, "\n")); err != nil {
+			log.Printf("Weekly review: %v", err)
 		}
 	}
+	doc.AppendSection(2, "AI Insights", insights(doc))
+	fm, err := vault.ParseFrontmatter([]byte(doc.String()))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse review frontmatter: %w", err)
+	}
+	if err := fm.SetGeneratedBy(prompt.Ref(), usage.Model()); err != nil {
+		return "", fmt.Errorf("failed to record prompt version: %w", err)
+	}
+	data, err := fm.Bytes()
+	if err != nil {
+		return "", err
+	}
+	content = string(data)
 
 	// Write File
-	filename := fmt.Sprintf("%s Weekly Review.md", time.Now().Format("2006-W15")) // Using W15 as example format
-	// Actually use correct ISO week
-	y, weekNum := time.Now().ISOWeek()
-	filename = fmt.Sprintf("%d-W%02d Weekly Review.md", y, weekNum)
+	y, weekNum := h.TmplEngine.Now().ISOWeek()
+	filename := fmt.Sprintf("%d-W%02d Weekly Review.md", y, weekNum)
 
 	path := filepath.Join(h.VaultPath, "6. Weekly Reviews", filename)
-
-	// We need to construct a Note object to write
-	// But WriteNote expects parsed frontmatter.
-	// Our TemplateEngine returns a string with FM.
-	// We should probably just write the string directly like in CreateInboxItem.
-	// Refactor Writer to have a WriteFileContent method?
-	// Or just use ioutil.WriteFile here.
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create dir: %v", err), http.StatusInternalServerError)
-		return
+		return "", fmt.Errorf("failed to create dir: %w", err)
 	}
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write file: %v", err), http.StatusInternalServerError)
-		return
+		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
 	// Log to DB
@@ -240,15 +298,41 @@ func (h *Handler) HandleGenerateWeeklyReview(w http.ResponseWriter, r *http.Requ
 			}
 		}()
 	}
+	return filename, nil
+}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "created", "path": filename})
+// staleProjectDays is how long a project may go without activity before the
+// weekly review points it out
+const staleProjectDays = 14
+
+// projectReviewLine is the weekly review checklist item of an active project,
+// flagging a missing next action and a long time without activity
+func projectReviewLine(name string, p vault.ProjectSummary) string {
+	line := fmt.Sprintf("[[%s]] - Status: Active", name)
+	if p.Stalled {
+		line += " - no next action"
+	}
+	if p.DaysSinceActivity >= staleProjectDays {
+		line += fmt.Sprintf(" - no activity for %d days", p.DaysSinceActivity)
+	}
+	return line
 }
 
-func cleanJSON(s string) string {
-	s = strings.TrimSpace(s)
-	s = strings.TrimPrefix(s, "```json")
-	s = strings.TrimPrefix(s, "```")
-	s = strings.TrimSuffix(s, "```")
-	return s
+// reviewInsights puts the priorities into the template's "Goals & Priorities"
+// section and returns the rest as Markdown for the AI Insights section
+func reviewInsights(doc *vault.Document, insights *ai.ReviewInsights) string {
+	var priorities []string
+	for i, p := range insights.Priorities {
+		priorities = append(priorities, fmt.Sprintf("%d. %s", i+1, strings.TrimSpace(p)))
+	}
+	parts := []string{strings.TrimSpace(insights.Summary)}
+	if len(priorities) > 0 {
+		if err := doc.Replace("Goals & Priorities", strings.Join(priorities, "\n")); err != nil {
+			parts = append(parts, "### Priorities\n"+strings.Join(priorities, "\n"))
+		}
+	}
+	if reflection := strings.TrimSpace(insights.Reflection); reflection != "" {
+		parts = append(parts, "### Reflection\n"+reflection)
+	}
+	return strings.Join(parts, "\n\n")
 }
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// HandleVaultHealth handles GET and POST /vault/health. It lints the vault and
+// returns the report as JSON, or as Markdown with ?format=markdown. POST also
+// saves the report note.
+func (h *Handler) HandleVaultHealth(w http.ResponseWriter, r *http.Request) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	opts := vault.LintOptions{}
+	if v := r.URL.Query().Get("inbox_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			http.Error(w, "inbox_days must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		opts.InboxMaxAge = time.Duration(days) * 24 * time.Hour
+	}
+
+	report := vault.Lint(h.Index, opts)
+
+	if r.Method == http.MethodPost {
+		if err := vault.WriteHealthReport(h.VaultPath, report); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to write report: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if h.Git != nil {
+			go func() {
+				if err := h.Git.Sync("Update vault health report"); err != nil {
+					log.Printf("Git sync failed: %v", err)
+				}
+			}()
+		}
+	}
+
+	if r.URL.Query().Get("format") == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(report.Markdown()))
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+type inboxItem struct {
+	Path    string `json:"path"`
+	Title   string `json:"title"`
+	Created string `json:"created,omitempty"`
+}
+
+type processInboxRequest struct {
+	Path string `json:"path"`
+	vault.InboxDecision
+}
+
+type processInboxResponse struct {
+	*vault.ProcessResult
+	Remaining int    `json:"remaining"`
+	Next      string `json:"next,omitempty"` // path of the next item to process
+	Warning   string `json:"warning,omitempty"`
+}
+
+// HandleListInbox handles GET /inbox. Items are listed in processing order.
+func (h *Handler) HandleListInbox(w http.ResponseWriter, r *http.Request) {
+	if h.Processor == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	items := []inboxItem{}
+	for _, n := range h.Processor.Items() {
+		items = append(items, inboxItem{Path: n.Path, Title: n.Title, Created: n.String("created")})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count": len(items),
+		"items": items,
+	})
+}
+
+// HandleNextInboxItem handles GET /inbox/next. It returns the oldest inbox
+// item with its content and the decisions that can be applied to it.
+func (h *Handler) HandleNextInboxItem(w http.ResponseWriter, r *http.Request) {
+	if h.Processor == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	resp := map[string]interface{}{
+		"remaining": len(h.Processor.Items()),
+		"actions":   vault.InboxActions,
+		"item":      nil,
+	}
+	if n, ok := h.Processor.Next(); ok {
+		abs := filepath.Join(h.VaultPath, n.Path)
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp["item"] = newNoteResponse(n.Path, abs, data)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleProcessInboxItem handles POST /inbox/process. It applies one
+// decision (trash, someday, reference, next_action, project or waiting_for)
+// to an inbox item and answers with the next item to look at.
+func (h *Handler) HandleProcessInboxItem(w http.ResponseWriter, r *http.Request) {
+	if h.Processor == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req processInboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" || req.Action == "" {
+		http.Error(w, "path and action are required", http.StatusBadRequest)
+		return
+	}
+	if _, _, err := h.resolveNotePath(req.Path); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Processor.Process(req.Path, req.InboxDecision)
+
+	h.writeProcessResult(w, req.Path, result, err)
+}
+
+// writeProcessResult answers a processing request with the result and the
+// next item to look at, or with the error
+func (h *Handler) writeProcessResult(w http.ResponseWriter, path string, result *vault.ProcessResult, err error) {
+	switch {
+	case result != nil:
+		resp := processInboxResponse{ProcessResult: result}
+		if err != nil {
+			// The item was processed; logging or committing failed.
+			log.Printf("Process inbox %s: %v", path, err)
+			resp.Warning = err.Error()
+		}
+		items := h.Processor.Items()
+		resp.Remaining = len(items)
+		if len(items) > 0 {
+			resp.Next = items[0].Path
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "inbox item not found", http.StatusNotFound)
+	case errors.Is(err, vault.ErrNoteExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, vault.ErrInvalidDecision):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// HandleListInboxDecisions handles GET /inbox/decisions?limit=50
+func (h *Handler) HandleListInboxDecisions(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	decisions, err := h.Repo.ListInboxDecisions(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if decisions == nil {
+		decisions = []db.InboxDecisionRecord{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"decisions": decisions})
+}
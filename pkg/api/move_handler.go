@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+type moveNoteRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type moveNoteResponse struct {
+	*vault.MoveResult
+	Warning string `json:"warning,omitempty"`
+}
+
+// HandleMoveNote handles POST /notes/move. It renames a note and updates every
+// link, project reference and sync record pointing at it. If-Match applies to
+// the source note.
+func (h *Handler) HandleMoveNote(w http.ResponseWriter, r *http.Request) {
+	if h.Mover == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req moveNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.From == "" || req.To == "" {
+		http.Error(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+	// Both ends must stay inside the vault, also through symlinks.
+	for _, ref := range []string{req.From, req.To} {
+		if _, _, err := h.resolveNotePath(ref); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var current []byte
+	result, err := h.Mover.MoveIf(req.From, req.To, func(data []byte) error {
+		current = data
+		return checkIfMatch(r, data)
+	})
+	switch {
+	case errors.Is(err, errPreconditionFailed):
+		w.Header().Set("ETag", etagFor(current))
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case result != nil && err != nil:
+		// The note was moved; some follow-up step failed.
+		log.Printf("Move %s: %v", req.From, err)
+		writeJSON(w, http.StatusOK, moveNoteResponse{MoveResult: result, Warning: err.Error()})
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "note not found", http.StatusNotFound)
+	case errors.Is(err, vault.ErrNoteExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, moveNoteResponse{MoveResult: result})
+	}
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// actionGroup is a group of next actions sharing a context, energy or time
+// bucket
+type actionGroup struct {
+	Key     string         `json:"key"`
+	Actions []vault.Action `json:"actions"`
+}
+
+// actionGroupers name the group of an action for ?group_by=
+var actionGroupers = map[string]func(vault.Action) string{
+	"context": func(a vault.Action) string { return orNone(a.Context) },
+	"energy":  func(a vault.Action) string { return orNone(a.Energy) },
+	"time":    timeBucket,
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// timeBucket groups actions by their estimate
+func timeBucket(a vault.Action) string {
+	switch {
+	case a.Minutes == 0:
+		return "none"
+	case a.Minutes <= 15:
+		return "15min"
+	case a.Minutes <= 60:
+		return "1h"
+	default:
+		return "longer"
+	}
+}
+
+// HandleListNextActions handles GET /next-actions. Filters: context,
+// project, priority, status, due_before (YYYY-MM-DD) and tag (repeatable or
+// comma-separated, all must match); group_by=context|energy|time groups the
+// result. With mode=now it answers "what can I do now" for the minutes,
+// energy and location given, best first, up to limit.
+func (h *Handler) HandleListNextActions(w http.ResponseWriter, r *http.Request) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	q := r.URL.Query()
+	f := vault.ActionFilter{
+		Context:  q.Get("context"),
+		Project:  q.Get("project"),
+		Priority: q.Get("priority"),
+		Status:   q.Get("status"),
+	}
+	for _, v := range q["tag"] {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				f.Tags = append(f.Tags, tag)
+			}
+		}
+	}
+	if v := q.Get("due_before"); v != "" {
+		due, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "due_before must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		f.DueBefore = due
+	}
+	actions := vault.ListActions(h.Index, f)
+
+	switch mode := q.Get("mode"); mode {
+	case "":
+	case "now":
+		h.writeActionsNow(w, r, actions)
+		return
+	default:
+		http.Error(w, "mode must be now", http.StatusBadRequest)
+		return
+	}
+
+	groupBy := q.Get("group_by")
+	if groupBy == "" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(actions), "actions": nonNilActions(actions)})
+		return
+	}
+	key, ok := actionGroupers[groupBy]
+	if !ok {
+		http.Error(w, "group_by must be context, energy or time", http.StatusBadRequest)
+		return
+	}
+	groups := map[string]*actionGroup{}
+	for _, a := range actions {
+		k := key(a)
+		if groups[k] == nil {
+			groups[k] = &actionGroup{Key: k}
+		}
+		groups[k].Actions = append(groups[k].Actions, a)
+	}
+	out := make([]*actionGroup, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(actions), "groups": out})
+}
+
+// writeActionsNow ranks the actions doable with the availability in the query
+func (h *Handler) writeActionsNow(w http.ResponseWriter, r *http.Request, actions []vault.Action) {
+	minutes, ok := positiveParam(w, r, "minutes", 0)
+	if !ok {
+		return
+	}
+	limit, ok := positiveParam(w, r, "limit", 10)
+	if !ok {
+		return
+	}
+	avail := vault.Availability{
+		Minutes:  minutes,
+		Energy:   r.URL.Query().Get("energy"),
+		Location: r.URL.Query().Get("location"),
+	}
+	ranked, err := vault.RankActions(actions, avail, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	if ranked == nil {
+		ranked = []vault.RankedAction{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(ranked), "actions": ranked})
+}
+
+func nonNilActions(v []vault.Action) []vault.Action {
+	if v == nil {
+		return []vault.Action{}
+	}
+	return v
+}
+
+type completeActionResponse struct {
+	*vault.CompletionResult
+	Warning string `json:"warning,omitempty"`
+}
+
+// HandleNextActionSubresource serves POST /next-actions/{path...}/complete
+func (h *Handler) HandleNextActionSubresource(w http.ResponseWriter, r *http.Request) {
+	if rest, ok := strings.CutSuffix(r.PathValue("path"), "/complete"); ok {
+		h.handleCompleteAction(w, r, rest)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// handleCompleteAction marks a next action done, ticks it in its project and
+// spawns the next instance of a recurring action. The path may be relative to
+// the vault or to the next actions folder; ?archive=1 moves the completed
+// action to the archive.
+func (h *Handler) handleCompleteAction(w http.ResponseWriter, r *http.Request, ref string) {
+	if h.Completer == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	rel, err := vault.CleanNotePath(ref)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !vault.InFolder(rel, vault.NextActionsFolder) {
+		rel = filepath.Join(vault.NextActionsFolder, rel)
+	}
+	if vault.InFolder(rel, vault.ActionArchiveFolder) {
+		http.Error(w, "archived actions cannot be completed", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Completer.Complete(rel, r.URL.Query().Get("archive") == "1")
+	switch {
+	case result != nil && err != nil:
+		// The action was completed; a follow-up step failed.
+		log.Printf("Complete %s: %v", rel, err)
+		writeJSON(w, http.StatusOK, completeActionResponse{CompletionResult: result, Warning: err.Error()})
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "next action not found", http.StatusNotFound)
+	case errors.Is(err, vault.ErrAlreadyDone):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, vault.ErrInvalidRecurrence):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, completeActionResponse{CompletionResult: result})
+	}
+}
+
+// HandleListCompletions handles GET /next-actions/completions?days=7&limit=100.
+// It returns the completions of the last days, newest first, with counts per
+// day, context and project for reviews.
+func (h *Handler) HandleListCompletions(w http.ResponseWriter, r *http.Request) {
+	days, ok := positiveParam(w, r, "days", 7)
+	if !ok {
+		return
+	}
+	limit, ok := positiveParam(w, r, "limit", 100)
+	if !ok {
+		return
+	}
+	from := time.Now().AddDate(0, 0, 1-days).Format("2006-01-02")
+	completions, err := h.Repo.ListCompletions(from, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stats, err := h.Repo.CompletionStatsSince(from)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if completions == nil {
+		completions = []db.CompletionRecord{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"from": from, "stats": stats, "completions": completions})
+}
@@ -0,0 +1,424 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+type noteSummary struct {
+	Path        string                 `json:"path"`
+	Title       string                 `json:"title"`
+	Kind        string                 `json:"kind,omitempty"`
+	ModTime     time.Time              `json:"mod_time"`
+	Frontmatter map[string]interface{} `json:"frontmatter,omitempty"`
+}
+
+type noteResponse struct {
+	*vault.IndexedNote
+	Kind    string                  `json:"kind,omitempty"`
+	ETag    string                  `json:"etag"`
+	Content string                  `json:"content"` // body without frontmatter
+	Issues  []vault.ValidationIssue `json:"issues,omitempty"`
+}
+
+type createNoteRequest struct {
+	Path        string                 `json:"path"`
+	Template    string                 `json:"template,omitempty"`  // template name, e.g. "Project Template"
+	Variables   map[string]interface{} `json:"variables,omitempty"` // template variables; title defaults to the file name
+	Content     string                 `json:"content,omitempty"`   // full note content when no template is used
+	Frontmatter map[string]interface{} `json:"frontmatter,omitempty"`
+	Sections    map[string]string      `json:"sections,omitempty"` // heading -> replacement content
+}
+
+type sectionEdit struct {
+	Heading string `json:"heading"`
+	Action  string `json:"action"` // replace (default), append, insert or checklist
+	Content string `json:"content"`
+}
+
+type patchNoteRequest struct {
+	Frontmatter map[string]interface{} `json:"frontmatter,omitempty"` // null values delete the key
+	Sections    []sectionEdit          `json:"sections,omitempty"`
+}
+
+// errPreconditionFailed is returned when If-Match does not match the note
+var errPreconditionFailed = errors.New("note has changed since it was read")
+
+// HandleListNotes handles GET /notes. Notes can be filtered by folder,
+// status, type, context, project, tag and kind, and by any frontmatter field
+// with fm.<key>=<value>.
+func (h *Handler) HandleListNotes(w http.ResponseWriter, r *http.Request) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	params := r.URL.Query()
+	q := vault.Query{
+		Folder:  params.Get("folder"),
+		Status:  params.Get("status"),
+		Type:    params.Get("type"),
+		Context: params.Get("context"),
+		Project: params.Get("project"),
+		Tag:     params.Get("tag"),
+		Fields:  make(map[string]string),
+	}
+	for key, values := range params {
+		if field, ok := strings.CutPrefix(key, "fm."); ok && len(values) > 0 {
+			q.Fields[field] = values[0]
+		}
+	}
+	kind := params.Get("kind")
+
+	notes := []noteSummary{}
+	for _, n := range h.Index.Query(q) {
+		node := nodeFor(&n)
+		if kind != "" && node.Kind != kind {
+			continue
+		}
+		notes = append(notes, noteSummary{
+			Path:        n.Path,
+			Title:       n.Title,
+			Kind:        node.Kind,
+			ModTime:     n.ModTime,
+			Frontmatter: n.Frontmatter,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count": len(notes),
+		"notes": notes,
+	})
+}
+
+// handleGetNote serves GET /notes/{path}. ?section= returns a single section
+// and ?format=markdown the raw file.
+func (h *Handler) handleGetNote(w http.ResponseWriter, r *http.Request, ref string) {
+	rel, abs, err := h.resolveNotePath(ref)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := os.ReadFile(abs)
+	if os.IsNotExist(err) {
+		http.Error(w, "note not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etagFor(data))
+
+	if heading := r.URL.Query().Get("section"); heading != "" {
+		content, ok := vault.ParseDocument(string(data)).Content(heading)
+		if !ok {
+			http.Error(w, "section not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"path": rel, "section": heading, "content": content})
+		return
+	}
+	if r.URL.Query().Get("format") == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write(data)
+		return
+	}
+	writeJSON(w, http.StatusOK, newNoteResponse(rel, abs, data))
+}
+
+// HandleCreateNote handles POST /notes. The note is rendered from a template
+// or taken verbatim from content, then frontmatter and sections are applied.
+func (h *Handler) HandleCreateNote(w http.ResponseWriter, r *http.Request) {
+	var req createNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Template != "" && req.Content != "" {
+		http.Error(w, "template and content are mutually exclusive", http.StatusBadRequest)
+		return
+	}
+	rel, abs, err := h.resolveNotePath(req.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	content := req.Content
+	if req.Template != "" {
+		if h.TmplEngine == nil {
+			http.Error(w, "templates are not configured", http.StatusServiceUnavailable)
+			return
+		}
+		data := vault.TemplateData{"title": strings.TrimSuffix(filepath.Base(rel), ".md")}
+		for k, v := range req.Variables {
+			data[k] = v
+		}
+		content, err = h.TmplEngine.RenderTemplate(req.Template, data)
+		if errors.Is(err, vault.ErrInvalidTemplateName) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if os.IsNotExist(err) {
+			http.Error(w, fmt.Sprintf("template %q not found", req.Template), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to render template: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	sections := make([]sectionEdit, 0, len(req.Sections))
+	for heading, body := range req.Sections {
+		sections = append(sections, sectionEdit{Heading: heading, Content: body})
+	}
+	out, err := applyNoteEdits([]byte(content), req.Frontmatter, sections)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defer h.lockNotes()()
+	if _, err := os.Stat(abs); err == nil {
+		http.Error(w, fmt.Sprintf("%s: %v", rel, vault.ErrNoteExists), http.StatusConflict)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(abs, out, 0644); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to write note: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.noteChanged(rel, "Create "+rel)
+
+	w.Header().Set("ETag", etagFor(out))
+	w.Header().Set("Location", "/notes/"+filepath.ToSlash(rel))
+	writeJSON(w, http.StatusCreated, newNoteResponse(rel, abs, out))
+}
+
+// HandlePatchNote handles PATCH /notes/{path...}. It sets or deletes
+// frontmatter fields and edits sections. An If-Match header with the note's
+// ETag makes the write fail with 412 if the note changed in the meantime.
+func (h *Handler) HandlePatchNote(w http.ResponseWriter, r *http.Request) {
+	var req patchNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rel, abs, err := h.resolveNotePath(r.PathValue("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defer h.lockNotes()()
+	data, ok := h.readForWrite(w, r, abs)
+	if !ok {
+		return
+	}
+	out, err := applyNoteEdits(data, req.Frontmatter, req.Sections)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if string(out) != string(data) {
+		if err := os.WriteFile(abs, out, 0644); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to write note: %v", err), http.StatusInternalServerError)
+			return
+		}
+		h.noteChanged(rel, "Update "+rel)
+	}
+
+	w.Header().Set("ETag", etagFor(out))
+	writeJSON(w, http.StatusOK, newNoteResponse(rel, abs, out))
+}
+
+// HandleDeleteNote handles DELETE /notes/{path...}, honouring If-Match.
+func (h *Handler) HandleDeleteNote(w http.ResponseWriter, r *http.Request) {
+	rel, abs, err := h.resolveNotePath(r.PathValue("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defer h.lockNotes()()
+	if _, ok := h.readForWrite(w, r, abs); !ok {
+		return
+	}
+	if err := os.Remove(abs); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete note: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.noteChanged(rel, "Delete "+rel)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readForWrite reads a note about to be changed and checks If-Match. It
+// writes the error response and returns false on failure.
+func (h *Handler) readForWrite(w http.ResponseWriter, r *http.Request, abs string) ([]byte, bool) {
+	data, err := os.ReadFile(abs)
+	if os.IsNotExist(err) {
+		http.Error(w, "note not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if err := checkIfMatch(r, data); err != nil {
+		w.Header().Set("ETag", etagFor(data))
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return nil, false
+	}
+	return data, true
+}
+
+// noteChanged refreshes the index entry of a written note and commits it
+func (h *Handler) noteChanged(rel, message string) {
+	if h.Index != nil {
+		if err := h.Index.Update(rel); err != nil {
+			log.Printf("Index %s: %v", rel, err)
+		}
+	}
+	if commit := gitCommitter(h.Git); commit != nil {
+		if err := commit(message); err != nil {
+			log.Printf("Git commit failed: %v", err)
+		}
+	}
+}
+
+// resolveNotePath validates a note path from a request and returns it relative
+// to the vault and absolute. Symlinks leading out of the vault are rejected.
+func (h *Handler) resolveNotePath(ref string) (string, string, error) {
+	rel, err := vault.CleanNotePath(ref)
+	if err != nil {
+		return "", "", err
+	}
+	abs := filepath.Join(h.VaultPath, rel)
+
+	root, err := filepath.EvalSymlinks(h.VaultPath)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve vault path: %w", err)
+	}
+	// Resolve the deepest existing part of the path
+	existing := abs
+	for {
+		if real, err := filepath.EvalSymlinks(existing); err == nil {
+			if real != root && !strings.HasPrefix(real, root+string(filepath.Separator)) {
+				return "", "", fmt.Errorf("note path %q is outside the vault", ref)
+			}
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	return rel, abs, nil
+}
+
+// applyNoteEdits sets frontmatter fields and edits sections of note content
+func applyNoteEdits(data []byte, fields map[string]interface{}, sections []sectionEdit) ([]byte, error) {
+	if len(fields) > 0 {
+		e, err := vault.ParseFrontmatter(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse frontmatter: %w", err)
+		}
+		for _, key := range sortedFieldKeys(fields) {
+			if fields[key] == nil {
+				e.Delete(key)
+				continue
+			}
+			if err := e.Set(key, fields[key]); err != nil {
+				return nil, err
+			}
+		}
+		if data, err = e.Bytes(); err != nil {
+			return nil, err
+		}
+	}
+	if len(sections) == 0 {
+		return data, nil
+	}
+
+	doc := vault.ParseDocument(string(data))
+	for _, s := range sections {
+		var err error
+		switch s.Action {
+		case "", "replace":
+			err = doc.Replace(s.Heading, s.Content)
+		case "append":
+			err = doc.Append(s.Heading, s.Content)
+		case "insert":
+			err = doc.Insert(s.Heading, s.Content)
+		case "checklist":
+			err = doc.AddChecklistItem(s.Heading, s.Content)
+		default:
+			err = fmt.Errorf("unknown section action %q", s.Action)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []byte(doc.String()), nil
+}
+
+func sortedFieldKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newNoteResponse(rel, abs string, data []byte) noteResponse {
+	n := vault.NewIndexedNote(rel, data)
+	if info, err := os.Stat(abs); err == nil {
+		n.ModTime = info.ModTime()
+		n.Size = info.Size()
+	}
+	resp := noteResponse{IndexedNote: n, ETag: etagFor(data), Content: string(data)}
+	if e, err := vault.ParseFrontmatter(data); err == nil {
+		resp.Content = e.Body()
+	}
+	if s, issues := n.Validate(); s != nil {
+		resp.Kind = s.Name
+		resp.Issues = issues
+	}
+	return resp
+}
+
+// etagFor returns the strong ETag of note content
+func etagFor(data []byte) string {
+	return `"` + vault.ContentHash(data) + `"`
+}
+
+// checkIfMatch compares the If-Match header, when present, with the ETag of data
+func checkIfMatch(r *http.Request, data []byte) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+	current := etagFor(data)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return nil
+		}
+	}
+	return errPreconditionFailed
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// projectDetail is a project with its status history
+type projectDetail struct {
+	vault.ProjectSummary
+	History []vault.ProjectTransition `json:"history"`
+}
+
+type projectStatusRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+type projectStatusResponse struct {
+	Transition *vault.ProjectTransition `json:"transition"`
+	Project    *projectDetail           `json:"project,omitempty"`
+	Warning    string                   `json:"warning,omitempty"`
+}
+
+// HandleListProjects handles GET /projects. It lists the projects with their
+// next actions, waiting-fors and health; ?status= keeps one status and
+// ?stalled=1 only the active projects without a next action.
+func (h *Handler) HandleListProjects(w http.ResponseWriter, r *http.Request) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && !slices.Contains(vault.ProjectStatuses, status) {
+		http.Error(w, "status must be one of "+strings.Join(vault.ProjectStatuses, ", "), http.StatusBadRequest)
+		return
+	}
+	projects := vault.ListProjects(h.Index, status, time.Now())
+	if r.URL.Query().Get("stalled") == "1" {
+		projects = slices.DeleteFunc(projects, func(p vault.ProjectSummary) bool { return !p.Stalled })
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(projects), "projects": projects})
+}
+
+// HandleProjectSubresource serves GET /projects/{ref...}, by name or vault
+// path, and POST /projects/{ref...}/status.
+func (h *Handler) HandleProjectSubresource(w http.ResponseWriter, r *http.Request) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	ref := r.PathValue("ref")
+	if r.Method == http.MethodPost {
+		rest, ok := strings.CutSuffix(ref, "/status")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		h.handleProjectStatus(w, r, rest)
+		return
+	}
+	p, ok := h.project(ref)
+	if !ok {
+		http.Error(w, "project not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// project returns a project with its history
+func (h *Handler) project(ref string) (*projectDetail, bool) {
+	p, ok := vault.GetProject(h.Index, ref, time.Now())
+	if !ok {
+		return nil, false
+	}
+	d := &projectDetail{ProjectSummary: p, History: []vault.ProjectTransition{}}
+	if h.Repo != nil {
+		history, err := h.Repo.ProjectHistory(p.Path)
+		if err != nil {
+			log.Printf("Project history of %s: %v", p.Path, err)
+		} else if history != nil {
+			d.History = history
+		}
+	}
+	return d, true
+}
+
+// handleProjectStatus changes a project's status. Completing or cancelling
+// it moves it to the archive with a closing note.
+func (h *Handler) handleProjectStatus(w http.ResponseWriter, r *http.Request, ref string) {
+	if h.Projects == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req projectStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains(vault.ProjectStatuses, req.Status) {
+		http.Error(w, "status must be one of "+strings.Join(vault.ProjectStatuses, ", "), http.StatusBadRequest)
+		return
+	}
+	p, ok := vault.GetProject(h.Index, ref, time.Now())
+	if !ok {
+		http.Error(w, "project not found", http.StatusNotFound)
+		return
+	}
+
+	t, err := h.Projects.SetStatus(p.Path, req.Status, req.Note)
+	switch {
+	case t != nil:
+		resp := projectStatusResponse{Transition: t}
+		if err != nil {
+			// The status changed; a follow-up step failed.
+			log.Printf("Project status %s: %v", p.Path, err)
+			resp.Warning = err.Error()
+		}
+		resp.Project, _ = h.project(t.Path)
+		writeJSON(w, http.StatusOK, resp)
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "project not found", http.StatusNotFound)
+	case errors.Is(err, vault.ErrInvalidTransition):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,16 +1,22 @@
 package api
 
 import (
+	"log"
 	"net/http"
+	"path/filepath"
 
 	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/assistant"
 	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/semantic"
 	"github.com/mklimuk/vault-pilot/pkg/sync"
 	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
 
-// NewRouter creates a new HTTP router
-func NewRouter(repo *db.Repository, aiClient ai.Generator, tmplEngine *vault.TemplateEngine, vaultPath string, gitManager *sync.GitManager) *http.ServeMux {
+// NewRouter creates a new HTTP router. search may be nil when semantic search
+// is disabled, and chat when the assistant is. writers and prompts may be nil,
+// in which case the router builds its own.
+func NewRouter(repo *db.Repository, aiClient ai.Generator, tmplEngine *vault.TemplateEngine, vaultPath string, gitManager *sync.GitManager, index *vault.Index, search *semantic.Index, chat *assistant.Assistant, writers *Writers, prompts *ai.PromptLibrary) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	h := &Handler{
@@ -19,11 +25,54 @@ func NewRouter(repo *db.Repository, aiClient ai.Generator, tmplEngine *vault.Tem
 		TmplEngine: tmplEngine,
 		VaultPath:  vaultPath,
 		Git:        gitManager,
+		Index:      index,
+		Prompts:    prompts,
+		Search:     search,
+		Assistant:  chat,
 	}
+	if prompts == nil {
+		h.Prompts = ai.NewPromptLibrary(filepath.Join(vaultPath, ai.PromptDir))
+		if search != nil {
+			h.Prompts.Retriever = search
+		}
+	}
+	if writers == nil {
+		writers = NewWriters(repo, index, tmplEngine, gitManager)
+	}
+	h.Mover = writers.Mover
+	h.Completer = writers.Completer
+	h.Projects = writers.Projects
+	h.Processor = writers.Processor
 
 	mux.HandleFunc("POST /inbox", h.HandleCreateInboxItem)
+	mux.HandleFunc("GET /inbox", h.HandleListInbox)
+	mux.HandleFunc("GET /inbox/next", h.HandleNextInboxItem)
+	mux.HandleFunc("POST /inbox/process", h.HandleProcessInboxItem)
+	mux.HandleFunc("GET /inbox/decisions", h.HandleListInboxDecisions)
+	mux.HandleFunc("GET /inbox/suggestions", h.HandleListInboxSuggestions)
+	mux.HandleFunc("POST /inbox/suggestions/{id}/accept", h.HandleAcceptInboxSuggestion)
+	mux.HandleFunc("POST /inbox/suggestions/{id}/reject", h.HandleRejectInboxSuggestion)
 	mux.HandleFunc("GET /projects", h.HandleListProjects)
+	mux.HandleFunc("GET /projects/{ref...}", h.HandleProjectSubresource)
+	mux.HandleFunc("POST /projects/{ref...}", h.HandleProjectSubresource)
+	mux.HandleFunc("GET /waiting", h.HandleListWaiting)
+	mux.HandleFunc("GET /next-actions", h.HandleListNextActions)
+	mux.HandleFunc("GET /next-actions/completions", h.HandleListCompletions)
+	mux.HandleFunc("POST /next-actions/{path...}", h.HandleNextActionSubresource)
 	mux.HandleFunc("POST /review/weekly", h.HandleGenerateWeeklyReview)
+	mux.HandleFunc("GET /ai/usage", h.HandleAIUsage)
+	mux.HandleFunc("GET /vault/health", h.HandleVaultHealth)
+	mux.HandleFunc("POST /vault/health", h.HandleVaultHealth)
+	mux.HandleFunc("GET /notes", h.HandleListNotes)
+	mux.HandleFunc("POST /notes", h.HandleCreateNote)
+	mux.HandleFunc("GET /notes/{path...}", h.HandleNoteSubresource)
+	mux.HandleFunc("PATCH /notes/{path...}", h.HandlePatchNote)
+	mux.HandleFunc("DELETE /notes/{path...}", h.HandleDeleteNote)
+	mux.HandleFunc("GET /graph", h.HandleGraph)
+	mux.HandleFunc("GET /search/semantic", h.HandleSemanticSearch)
+	mux.HandleFunc("POST /notes/move", h.HandleMoveNote)
+	mux.HandleFunc("POST /assistant/chat", h.HandleAssistantChat)
+	mux.HandleFunc("DELETE /assistant/conversations/{id}", h.HandleDeleteConversation)
 	mux.HandleFunc("POST /automations", h.HandleCreateAutomation)
 	mux.HandleFunc("GET /automations", h.HandleListAutomations)
 	mux.HandleFunc("PATCH /automations/{id}", h.HandleUpdateAutomation)
@@ -31,3 +80,64 @@ func NewRouter(repo *db.Repository, aiClient ai.Generator, tmplEngine *vault.Tem
 
 	return mux
 }
+
+// Writers are the vault writers that change notes on behalf of users. One set
+// is shared by the API and the assistant so both serialise on the same
+// completer and commit the same way.
+type Writers struct {
+	Commit    func(message string) error // nil without git
+	Mover     *vault.Mover
+	Completer *vault.Completer
+	Projects  *vault.ProjectManager
+	Processor *vault.InboxProcessor
+}
+
+// NewWriters builds the vault writers, recording their changes in repo and
+// committing them with gitManager. Both may be nil. Without an index the
+// writers are nil, and the processor also needs tmplEngine.
+func NewWriters(repo *db.Repository, index *vault.Index, tmplEngine *vault.TemplateEngine, gitManager *sync.GitManager) *Writers {
+	w := &Writers{Commit: gitCommitter(gitManager)}
+	if index == nil {
+		return w
+	}
+	var (
+		hooks           []vault.MoveHook
+		completionHooks []vault.CompletionHook
+		projectHooks    []vault.ProjectHook
+		processHooks    []vault.ProcessHook
+	)
+	if repo != nil {
+		hooks = append(hooks, repo.MoveNoteRecords)
+		completionHooks = append(completionHooks, repo.RecordCompletion)
+		projectHooks = append(projectHooks, repo.RecordProjectTransition)
+		processHooks = append(processHooks, repo.RecordInboxDecision, repo.ResolveInboxSuggestions)
+	}
+	w.Mover = vault.NewMover(index, w.Commit, hooks...)
+	w.Completer = vault.NewCompleter(index, w.Commit, completionHooks...)
+	w.Completer.MoveHooks = hooks
+	w.Projects = vault.NewProjectManager(index, w.Commit, projectHooks...)
+	w.Projects.MoveHooks = hooks
+	if tmplEngine != nil {
+		w.Processor = vault.NewInboxProcessor(index, tmplEngine, w.Commit, processHooks...)
+	}
+	return w
+}
+
+// gitCommitter returns a function that commits vault changes right away and
+// pushes in the background, or nil without a git manager.
+func gitCommitter(g *sync.GitManager) func(message string) error {
+	if g == nil {
+		return nil
+	}
+	return func(message string) error {
+		if err := g.Commit(message); err != nil {
+			return err
+		}
+		go func() {
+			if err := g.Push(); err != nil {
+				log.Printf("Git push failed: %v", err)
+			}
+		}()
+		return nil
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+)
+
+// HandleSemanticSearch handles GET /search/semantic?q=...&limit=10. It
+// returns the notes whose passages are closest in meaning to q, best first,
+// each with its best matching passage.
+func (h *Handler) HandleSemanticSearch(w http.ResponseWriter, r *http.Request) {
+	if h.Search == nil {
+		http.Error(w, "semantic search is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit, ok := positiveParam(w, r, "limit", 10)
+	if !ok {
+		return
+	}
+
+	results, err := h.Search.Search(ai.WithFeature(r.Context(), "semantic_search"), q, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query":   q,
+		"results": results,
+	})
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// actionForType maps the analysed item type to a processing action when the
+// model gave no action
+var actionForType = map[string]string{
+	"task":      vault.ActionNextAction,
+	"idea":      vault.ActionSomeday,
+	"reference": vault.ActionReference,
+	"project":   vault.ActionProject,
+}
+
+// suggestedDecision turns the analysis into a processing decision. Values the
+// vault would not accept are dropped; the project must be one of
+// activeProjects.
+func suggestedDecision(a *ai.InboxAnalysis, activeProjects []string) (vault.InboxDecision, bool) {
+	action := strings.ToLower(strings.TrimSpace(a.Action))
+	if action == "" {
+		action = actionForType[strings.ToLower(strings.TrimSpace(a.Type))]
+	}
+	valid := false
+	for _, known := range vault.InboxActions {
+		valid = valid || action == known
+	}
+	if !valid {
+		return vault.InboxDecision{}, false
+	}
+
+	d := vault.InboxDecision{Action: action}
+	switch p := strings.ToLower(strings.TrimSpace(a.Priority)); p {
+	case "low", "medium", "high", "urgent":
+		d.Priority = p
+	}
+	if ctx := strings.TrimSpace(a.Context); ctx != "" && action == vault.ActionNextAction {
+		if !strings.HasPrefix(ctx, "@") {
+			ctx = "@" + ctx
+		}
+		d.Context = strings.ToLower(ctx)
+	}
+	if a.Project != "" {
+		want := vault.StripLink(strings.TrimSpace(a.Project))
+		for _, p := range activeProjects {
+			if strings.EqualFold(p, want) {
+				d.Project = p
+			}
+		}
+	}
+	if _, err := time.Parse("2006-01-02", strings.TrimSpace(a.DueDate)); err == nil {
+		switch action {
+		case vault.ActionNextAction, vault.ActionProject:
+			d.DueDate = strings.TrimSpace(a.DueDate)
+		case vault.ActionWaitingFor:
+			d.FollowUp = strings.TrimSpace(a.DueDate)
+		}
+	}
+	if action == vault.ActionWaitingFor {
+		d.WaitingFor = strings.TrimSpace(a.WaitingFor)
+	}
+	return d, true
+}
+
+// clampConfidence limits a model's confidence to [0, 1]
+func clampConfidence(c float64) float64 {
+	if math.IsNaN(c) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, c))
+}
+
+// HandleListInboxSuggestions handles GET /inbox/suggestions?status=pending&limit=50.
+// status defaults to pending; use status=all for every suggestion.
+func (h *Handler) HandleListInboxSuggestions(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = db.SuggestionPending
+	case "all":
+		status = ""
+	case db.SuggestionPending, db.SuggestionAccepted, db.SuggestionEdited, db.SuggestionRejected:
+	default:
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	suggestions, err := h.Repo.ListInboxSuggestions(status, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if suggestions == nil {
+		suggestions = []db.InboxSuggestion{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"suggestions": suggestions})
+}
+
+// pendingSuggestion loads the suggestion named in the URL and writes an error
+// unless it is waiting for review
+func (h *Handler) pendingSuggestion(w http.ResponseWriter, r *http.Request) *db.InboxSuggestion {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid suggestion id", http.StatusBadRequest)
+		return nil
+	}
+	s, err := h.Repo.GetInboxSuggestion(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	if s == nil {
+		http.Error(w, "suggestion not found", http.StatusNotFound)
+		return nil
+	}
+	if s.Status != db.SuggestionPending {
+		http.Error(w, "suggestion is already "+s.Status, http.StatusConflict)
+		return nil
+	}
+	return s
+}
+
+// HandleAcceptInboxSuggestion handles POST /inbox/suggestions/{id}/accept.
+// The body is optional; decision fields in it override the suggestion, which
+// is then recorded as edited.
+func (h *Handler) HandleAcceptInboxSuggestion(w http.ResponseWriter, r *http.Request) {
+	if h.Processor == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	s := h.pendingSuggestion(w, r)
+	if s == nil {
+		return
+	}
+	d := s.Decision
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Processor.Process(s.InboxPath, d)
+	h.writeProcessResult(w, s.InboxPath, result, err)
+}
+
+// HandleRejectInboxSuggestion handles POST /inbox/suggestions/{id}/reject.
+// The optional {"reason": "..."} is kept as feedback; the inbox item stays.
+func (h *Handler) HandleRejectInboxSuggestion(w http.ResponseWriter, r *http.Request) {
+	s := h.pendingSuggestion(w, r)
+	if s == nil {
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	ok, err := h.Repo.RejectInboxSuggestion(s.ID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "suggestion is no longer pending", http.StatusConflict)
+		return
+	}
+	s, err = h.Repo.GetInboxSuggestion(s.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
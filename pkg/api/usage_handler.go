@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/db"
+)
+
+// HandleAIUsage handles GET /ai/usage?days=30&months=12. It returns AI usage
+// per day and per month, by provider and feature, with the estimated cost and
+// the monthly budget.
+func (h *Handler) HandleAIUsage(w http.ResponseWriter, r *http.Request) {
+	days, ok := positiveParam(w, r, "days", 30)
+	if !ok {
+		return
+	}
+	months, ok := positiveParam(w, r, "months", 12)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	daily, err := h.Repo.AIUsageByDay(now.AddDate(0, 0, 1-days).Format("2006-01-02"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	monthly, err := h.Repo.AIUsageByMonth(ai.MonthStart(now).AddDate(0, 1-months, 0).Format("2006-01"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	spent, err := h.Repo.AICostSince(ai.MonthStart(now))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if daily == nil {
+		daily = []db.AIUsageAggregate{}
+	}
+	if monthly == nil {
+		monthly = []db.AIUsageAggregate{}
+	}
+
+	resp := map[string]interface{}{
+		"daily":                daily,
+		"monthly":              monthly,
+		"month_to_date_usd":    spent,
+		"monthly_budget_usd":   nil,
+		"budget_remaining_usd": nil,
+	}
+	if m, ok := h.AI.(*ai.MeteredGenerator); ok && m.MonthlyBudget > 0 {
+		resp["monthly_budget_usd"] = m.MonthlyBudget
+		resp["budget_remaining_usd"] = max(m.MonthlyBudget-spent, 0)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// positiveParam reads an optional positive integer query parameter, writing a
+// 400 response when it is invalid
+func positiveParam(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		http.Error(w, name+" must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
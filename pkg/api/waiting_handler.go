@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// HandleListWaiting handles GET /waiting. It lists the open waiting-for items
+// by follow-up date; ?overdue=1 keeps those whose follow-up is due, ?person=
+// and ?project= filter by who they wait on and their project.
+func (h *Handler) HandleListWaiting(w http.ResponseWriter, r *http.Request) {
+	if h.Index == nil {
+		http.Error(w, "vault index is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	q := r.URL.Query()
+	items := vault.ListWaiting(h.Index, vault.WaitingFilter{
+		Person:  q.Get("person"),
+		Project: q.Get("project"),
+		Overdue: q.Get("overdue") == "1",
+	}, time.Now())
+	overdue := 0
+	for _, item := range items {
+		if item.Overdue {
+			overdue++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(items), "overdue": overdue, "items": items})
+}
@@ -0,0 +1,223 @@
+// Package assistant answers chat messages with an AI model that can call
+// vault tools. It backs POST /assistant/chat and the chat bots. The tools can
+// read and change the vault, so the bots answer only allowlisted chats.
+package assistant
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// Defaults for a new Assistant
+const (
+	DefaultMaxSteps   = 6
+	DefaultMaxHistory = 40
+)
+
+// ErrEmptyMessage is returned for a message without text
+var ErrEmptyMessage = errors.New("empty message")
+
+// ToolRun is a tool call made while answering a message
+type ToolRun struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Reply is the answer to a chat message
+type Reply struct {
+	ConversationID string    `json:"conversation_id"`
+	Text           string    `json:"reply"`
+	ToolRuns       []ToolRun `json:"tool_calls,omitempty"`
+	Steps          int       `json:"steps"` // model calls made
+	StepLimit      bool      `json:"step_limit_reached,omitempty"`
+}
+
+// Assistant runs conversations in which the model may call the tools of a
+// Registry. Conversations are stored in SQLite, so a conversation can be
+// continued by ID, e.g. one per chat of a bot.
+type Assistant struct {
+	MaxSteps   int // model calls per message; tool calls after the last are not answered
+	MaxHistory int // stored messages sent with each request
+
+	gen     ai.Generator
+	tools   *Registry
+	prompts *ai.PromptLibrary
+	repo    *db.Repository
+	now     func() time.Time
+
+	locksMu sync.Mutex
+	locks   map[string]*conversationLock // held or awaited, by conversation ID
+}
+
+// conversationLock serialises the messages of one conversation. It is
+// dropped once nobody holds or waits for it.
+type conversationLock struct {
+	sync.Mutex
+	refs int
+}
+
+// New creates an assistant answering with gen, which must support tool
+// calling (see ai.ToolCaller)
+func New(gen ai.Generator, tools *Registry, prompts *ai.PromptLibrary, repo *db.Repository) *Assistant {
+	return &Assistant{
+		MaxSteps:   DefaultMaxSteps,
+		MaxHistory: DefaultMaxHistory,
+		gen:        gen,
+		tools:      tools,
+		prompts:    prompts,
+		repo:       repo,
+		now:        time.Now,
+		locks:      make(map[string]*conversationLock),
+	}
+}
+
+// lock locks the conversation and returns the function unlocking it
+func (a *Assistant) lock(conversationID string) func() {
+	a.locksMu.Lock()
+	l := a.locks[conversationID]
+	if l == nil {
+		l = &conversationLock{}
+		a.locks[conversationID] = l
+	}
+	l.refs++
+	a.locksMu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		a.locksMu.Lock()
+		defer a.locksMu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(a.locks, conversationID)
+		}
+	}
+}
+
+// Chat answers text in a conversation, starting a new one when
+// conversationID is empty. channel records where a new conversation
+// happens, e.g. "api" or "telegram". Messages of one conversation are
+// answered one at a time.
+func (a *Assistant) Chat(ctx context.Context, conversationID, channel, text string) (*Reply, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrEmptyMessage
+	}
+	if conversationID == "" {
+		conversationID = newConversationID()
+	}
+	defer a.lock(conversationID)()
+
+	history, err := a.repo.ConversationMessages(conversationID, a.MaxHistory)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := a.prompts.AssistantPrompt(a.now().Format("2006-01-02"), vault.Contexts)
+	if err != nil {
+		return nil, err
+	}
+	ctx = prompt.Context(ai.WithFeature(ctx, "assistant"))
+	req := prompt.Request()
+	req.System = prompt.Text
+	req.Tools = a.tools.Tools()
+
+	reply := &Reply{ConversationID: conversationID}
+	turn := []ai.Message{{Role: ai.RoleUser, Content: text}}
+	done := false
+	for !done && reply.Steps < a.MaxSteps {
+		req.Messages = append(trimHistory(history), turn...)
+		msg, err := ai.Chat(ctx, a.gen, req)
+		reply.Steps++
+		if err != nil {
+			if len(reply.ToolRuns) > 0 {
+				// Keep what the tools did in the history
+				turn = append(turn, ai.Message{Role: ai.RoleAssistant, Content: "I could not finish my answer: " + err.Error()})
+				a.save(conversationID, channel, turn)
+			}
+			return nil, fmt.Errorf("assistant: %w", err)
+		}
+		msg.Role = ai.RoleAssistant
+		turn = append(turn, msg)
+		if len(msg.ToolCalls) == 0 {
+			reply.Text, done = msg.Content, true
+			continue
+		}
+		for _, call := range msg.ToolCalls {
+			run := ToolRun{Name: call.Name, Arguments: call.Arguments}
+			result, err := a.tools.Call(ctx, call)
+			if err != nil {
+				run.Error = err.Error()
+				result = "Error: " + err.Error()
+			} else {
+				run.Result = result
+			}
+			reply.ToolRuns = append(reply.ToolRuns, run)
+			turn = append(turn, ai.NewToolResult(call, result))
+		}
+	}
+	if !done {
+		reply.StepLimit = true
+		reply.Text = fmt.Sprintf("I stopped after %d steps without an answer. Please try a narrower question.", a.MaxSteps)
+		turn = append(turn, ai.Message{Role: ai.RoleAssistant, Content: reply.Text})
+	}
+
+	a.save(conversationID, channel, turn)
+	return reply, nil
+}
+
+// save appends a turn to the conversation history
+func (a *Assistant) save(conversationID, channel string, turn []ai.Message) {
+	if err := a.repo.AppendConversation(conversationID, channel, turn); err != nil {
+		log.Printf("Assistant: save conversation %s: %v", conversationID, err)
+	}
+}
+
+// Reset forgets a conversation
+func (a *Assistant) Reset(conversationID string) error {
+	return a.repo.DeleteConversation(conversationID)
+}
+
+// trimHistory drops the messages before the first user message, which
+// belong to a turn cut off by the history limit. It returns a copy.
+func trimHistory(history []ai.Message) []ai.Message {
+	for i, m := range history {
+		if m.Role == ai.RoleUser {
+			return append([]ai.Message(nil), history[i:]...)
+		}
+	}
+	return nil
+}
+
+// Truncate shortens text to at most n bytes, keeping whole runes, for chat
+// services that limit the length of a message
+func Truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	cut := n - len("…")
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+// newConversationID returns a random conversation ID
+func newConversationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,221 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// scriptedCaller answers Chat with queued replies, then err if set, and keeps
+// the requests
+type scriptedCaller struct {
+	replies  []ai.Message
+	err      error
+	requests []ai.Request
+}
+
+func (s *scriptedCaller) Chat(ctx context.Context, req ai.Request) (ai.Message, error) {
+	s.requests = append(s.requests, req)
+	if len(s.replies) == 0 {
+		if s.err != nil {
+			return ai.Message{}, s.err
+		}
+		return ai.Message{Role: ai.RoleAssistant, ToolCalls: []ai.ToolCall{{ID: "loop", Name: "list_next_actions"}}}, nil
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply, nil
+}
+
+func (s *scriptedCaller) GenerateText(ctx context.Context, prompt string) (string, error) {
+	return "", nil
+}
+
+func (s *scriptedCaller) Generate(ctx context.Context, req ai.Request) (string, error) {
+	return "", nil
+}
+
+func (s *scriptedCaller) Stream(ctx context.Context, req ai.Request, fn ai.StreamFunc) (string, error) {
+	return "", nil
+}
+
+func (s *scriptedCaller) GenerateJSON(ctx context.Context, req ai.Request, schema *ai.Schema) (string, error) {
+	return "", nil
+}
+
+func writeNote(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func call(id, name, args string) ai.ToolCall {
+	return ai.ToolCall{ID: id, Name: name, Arguments: json.RawMessage(args)}
+}
+
+func setup(t *testing.T, gen ai.Generator) (*Assistant, *vault.Index, *db.Repository, *[]string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeNote(t, dir, "0. GTD System/Templates/Inbox Item Template.md", "---\nstatus: inbox\n---\n# {{title}}\n\n## Description\n")
+	writeNote(t, dir, "3. Projects/Active/Garden Fence.md", "---\nstatus: active\n---\n# Garden Fence\n\nReplace the broken panels.\n")
+	writeNote(t, dir, "2. Next Actions/@calls/Call Bob.md", "---\nstatus: next\ncontext: \"@calls\"\nproject: \"[[Garden Fence]]\"\npriority: high\n---\n# Call Bob\n")
+	writeNote(t, dir, "2. Next Actions/@home/Buy paint.md", "---\nstatus: next\ncontext: \"@home\"\n---\n# Buy paint\n")
+
+	index := vault.NewIndex(dir, nil)
+	if err := index.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.NewDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+	repo := db.NewRepository(database)
+
+	var commits []string
+	commit := func(message string) error {
+		commits = append(commits, message)
+		return nil
+	}
+	tools := NewVaultRegistry(index, vault.NewTemplateEngine(filepath.Join(dir, "0. GTD System/Templates")), commit, vault.NewCompleter(index, commit))
+	a := New(gen, tools, ai.NewPromptLibrary(t.TempDir()), repo)
+	return a, index, repo, &commits
+}
+
+func TestAssistantCallsVaultTools(t *testing.T) {
+	gen := &scriptedCaller{replies: []ai.Message{
+		{Role: ai.RoleAssistant, ToolCalls: []ai.ToolCall{call("1", "list_next_actions", `{"context":"@calls"}`), call("2", "read_project", `{"name":"garden fence"}`)}},
+		{Role: ai.RoleAssistant, ToolCalls: []ai.ToolCall{call("3", "complete_action", `{"path":"2. Next Actions/@calls/Call Bob.md"}`)}},
+		{Role: ai.RoleAssistant, Content: "Done: Call Bob."},
+	}}
+	a, index, repo, _ := setup(t, gen)
+
+	reply, err := a.Chat(context.Background(), "", "api", "I called Bob about the fence")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if reply.Text != "Done: Call Bob." || reply.Steps != 3 || len(reply.ToolRuns) != 3 || reply.ConversationID == "" {
+		t.Fatalf("unexpected reply: %+v", reply)
+	}
+	if !strings.Contains(reply.ToolRuns[0].Result, "Call Bob (path: 2. Next Actions/@calls/Call Bob.md, context: @calls, project: Garden Fence, priority: high)") ||
+		strings.Contains(reply.ToolRuns[0].Result, "Buy paint") {
+		t.Errorf("unexpected action list: %q", reply.ToolRuns[0].Result)
+	}
+	if !strings.Contains(reply.ToolRuns[1].Result, "Replace the broken panels.") || !strings.Contains(reply.ToolRuns[1].Result, "- Call Bob") {
+		t.Errorf("unexpected project: %q", reply.ToolRuns[1].Result)
+	}
+	if n, _ := index.Get("2. Next Actions/@calls/Call Bob.md"); n.Status() != "done" || n.String("completed") == "" {
+		t.Errorf("expected the action to be done, got %v", n.Frontmatter)
+	}
+
+	// The second request carries both tool results
+	if last := gen.requests[1].Messages; len(last) != 4 || last[3].ToolCallID != "2" || len(gen.requests[1].Tools) != 4 || gen.requests[1].System == "" {
+		t.Errorf("unexpected second request: %+v", gen.requests[1])
+	}
+
+	// The conversation continues from the stored history
+	gen.replies = []ai.Message{{Role: ai.RoleAssistant, Content: "You're welcome."}}
+	if _, err := a.Chat(context.Background(), reply.ConversationID, "api", "thanks"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if msgs := gen.requests[3].Messages; len(msgs) != 8 || msgs[0].Content != "I called Bob about the fence" {
+		t.Errorf("expected the history to be sent, got %+v", msgs)
+	}
+	if stored, _ := repo.ConversationMessages(reply.ConversationID, 100); len(stored) != 9 {
+		t.Errorf("expected 9 stored messages, got %d", len(stored))
+	}
+}
+
+func TestAssistantStepLimit(t *testing.T) {
+	gen := &scriptedCaller{}
+	a, _, repo, _ := setup(t, gen)
+	a.MaxSteps = 2
+
+	reply, err := a.Chat(context.Background(), "telegram:1", "telegram", "what's on my plate?")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if !reply.StepLimit || reply.Steps != 2 || len(gen.requests) != 2 {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+	if len(a.locks) != 0 {
+		t.Errorf("conversation locks kept after the reply: %v", a.locks)
+	}
+	stored, _ := repo.ConversationMessages("telegram:1", 100)
+	if len(stored) == 0 || stored[len(stored)-1].Role != ai.RoleAssistant || stored[len(stored)-1].Content != reply.Text {
+		t.Errorf("expected the conversation to end with the assistant, got %+v", stored)
+	}
+
+	if err := a.Reset("telegram:1"); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if _, err := a.Chat(context.Background(), "", "api", "  "); err != ErrEmptyMessage {
+		t.Errorf("expected ErrEmptyMessage, got %v", err)
+	}
+}
+
+func TestAssistantKeepsToolRunsOnError(t *testing.T) {
+	gen := &scriptedCaller{
+		replies: []ai.Message{{Role: ai.RoleAssistant, ToolCalls: []ai.ToolCall{call("1", "complete_action", `{"path":"2. Next Actions/@calls/Call Bob.md"}`)}}},
+		err:     errors.New("model unavailable"),
+	}
+	a, _, repo, _ := setup(t, gen)
+
+	if _, err := a.Chat(context.Background(), "api:1", "api", "I called Bob"); err == nil {
+		t.Fatal("expected the model error")
+	}
+	stored, _ := repo.ConversationMessages("api:1", 100)
+	if len(stored) != 4 || stored[2].Role != ai.RoleTool || !strings.Contains(stored[3].Content, "model unavailable") {
+		t.Errorf("expected the turn and an error note, got %+v", stored)
+	}
+}
+
+func TestCreateInboxItemTool(t *testing.T) {
+	a, index, _, commits := setup(t, &scriptedCaller{})
+	result, err := a.tools.Call(context.Background(), call("1", "create_inbox_item", `{"title":"Order fence panels","content":"Five panels"}`))
+	if err != nil {
+		t.Fatalf("create_inbox_item failed: %v", err)
+	}
+	if result != "Created inbox item 1. Inbox/Order fence panels.md" {
+		t.Errorf("unexpected result: %q", result)
+	}
+	if _, ok := index.Get("1. Inbox/Order fence panels.md"); !ok {
+		t.Error("expected the new item to be indexed")
+	}
+	if len(*commits) != 1 || (*commits)[0] != "Add inbox item: Order fence panels" {
+		t.Errorf("unexpected commits: %v", *commits)
+	}
+
+	if _, err := a.tools.Call(context.Background(), call("2", "complete_action", `{"path":"3. Projects/Active/Garden Fence.md"}`)); err == nil {
+		t.Error("expected an error completing a project")
+	}
+	if _, err := a.tools.Call(context.Background(), call("3", "delete_vault", `{}`)); err == nil {
+		t.Error("expected an error for an unknown tool")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("short", 10); got != "short" {
+		t.Errorf("Truncate = %q", got)
+	}
+	// "é" is two bytes; the cut must not split it
+	if got := Truncate("aéééé", 7); got != "aé…" {
+		t.Errorf("Truncate = %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+)
+
+// ToolFunc runs a tool with the JSON arguments chosen by the model and
+// returns the result shown to the model
+type ToolFunc func(ctx context.Context, args json.RawMessage) (string, error)
+
+// Registry holds the tools offered to the model
+type Registry struct {
+	tools []ai.Tool
+	funcs map[string]ToolFunc
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{funcs: make(map[string]ToolFunc)}
+}
+
+// Register adds a tool, replacing any tool of the same name
+func (r *Registry) Register(tool ai.Tool, fn ToolFunc) {
+	if _, ok := r.funcs[tool.Name]; ok {
+		for i, t := range r.tools {
+			if t.Name == tool.Name {
+				r.tools[i] = tool
+			}
+		}
+	} else {
+		r.tools = append(r.tools, tool)
+	}
+	r.funcs[tool.Name] = fn
+}
+
+// Tools returns the registered tools in registration order
+func (r *Registry) Tools() []ai.Tool {
+	return r.tools
+}
+
+// Call runs the tool the model asked for
+func (r *Registry) Call(ctx context.Context, call ai.ToolCall) (string, error) {
+	fn, ok := r.funcs[call.Name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Name)
+	}
+	args := call.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	return fn(ctx, args)
+}
+
+// decodeArgs unmarshals tool arguments into out
+func decodeArgs(args json.RawMessage, out interface{}) error {
+	if err := json.Unmarshal(args, out); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
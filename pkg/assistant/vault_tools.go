@@ -0,0 +1,233 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// Limits keeping tool results small enough for a prompt
+const (
+	maxListedActions = 50
+	maxProjectLen    = 6000
+)
+
+// vaultTools implements the tools backed by the vault
+type vaultTools struct {
+	index     *vault.Index
+	templates *vault.TemplateEngine
+	commit    func(message string) error
+	completer *vault.Completer
+}
+
+// NewVaultRegistry returns a registry with the vault tools: listing next
+// actions, reading a project, creating an inbox item and marking an action
+// done with completer. commit records changes in git and may be nil.
+func NewVaultRegistry(index *vault.Index, templates *vault.TemplateEngine, commit func(message string) error, completer *vault.Completer) *Registry {
+	t := &vaultTools{index: index, templates: templates, commit: commit, completer: completer}
+	r := NewRegistry()
+	r.Register(ai.Tool{
+		Name:        "list_next_actions",
+		Description: "List next actions, optionally filtered by context, project or status. Returns one action per line with its path.",
+		Parameters: &ai.Schema{
+			Type: "object",
+			Properties: map[string]*ai.Schema{
+				"context": {Type: "string", Description: "GTD context, one of " + strings.Join(vault.Contexts, ", ") + "; empty for all"},
+				"project": {Type: "string", Description: "Project name; empty for all"},
+				"status":  {Type: "string", Description: "Action status, next by default", Enum: vault.NextActionStatuses},
+			},
+		},
+	}, t.listNextActions)
+	r.Register(ai.Tool{
+		Name:        "read_project",
+		Description: "Read a project note and list its open next actions.",
+		Parameters: &ai.Schema{
+			Type:       "object",
+			Properties: map[string]*ai.Schema{"name": {Type: "string", Description: "Project name as shown in the vault"}},
+			Required:   []string{"name"},
+		},
+	}, t.readProject)
+	r.Register(ai.Tool{
+		Name:        "create_inbox_item",
+		Description: "Capture a new item in the GTD inbox.",
+		Parameters: &ai.Schema{
+			Type: "object",
+			Properties: map[string]*ai.Schema{
+				"title":   {Type: "string", Description: "A concise title"},
+				"content": {Type: "string", Description: "Details of the item"},
+			},
+			Required: []string{"title"},
+		},
+	}, t.createInboxItem)
+	r.Register(ai.Tool{
+		Name:        "complete_action",
+		Description: "Mark a next action done. Use the path returned by list_next_actions or read_project.",
+		Parameters: &ai.Schema{
+			Type:       "object",
+			Properties: map[string]*ai.Schema{"path": {Type: "string", Description: "Path of the action note"}},
+			Required:   []string{"path"},
+		},
+	}, t.completeAction)
+	return r
+}
+
+func (t *vaultTools) listNextActions(ctx context.Context, args json.RawMessage) (string, error) {
+	var in struct {
+		Context string `json:"context"`
+		Project string `json:"project"`
+		Status  string `json:"status"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return "", err
+	}
+	if in.Status == "" {
+		in.Status = "next"
+	}
+	actions := vault.ListActions(t.index, vault.ActionFilter{Status: in.Status, Context: in.Context, Project: in.Project})
+	if len(actions) == 0 {
+		return "No matching next actions.", nil
+	}
+	return formatActions(actions), nil
+}
+
+// formatActions lists actions one per line
+func formatActions(actions []vault.Action) string {
+	var sb strings.Builder
+	for i, a := range actions {
+		if i == maxListedActions {
+			fmt.Fprintf(&sb, "... and %d more\n", len(actions)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "- %s (path: %s", a.Title, filepath.ToSlash(a.Path))
+		for _, f := range []struct{ name, value string }{
+			{"context", a.Context},
+			{"project", a.Project},
+			{"priority", a.Priority},
+			{"due", a.DueDate},
+		} {
+			if f.value != "" {
+				fmt.Fprintf(&sb, ", %s: %s", f.name, f.value)
+			}
+		}
+		if a.Minutes > 0 {
+			fmt.Fprintf(&sb, ", estimate: %d min", a.Minutes)
+		}
+		sb.WriteString(")\n")
+	}
+	return sb.String()
+}
+
+func (t *vaultTools) readProject(ctx context.Context, args json.RawMessage) (string, error) {
+	var in struct {
+		Name string `json:"name"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return "", err
+	}
+	name := vault.StripLink(strings.TrimSpace(in.Name))
+	var project *vault.IndexedNote
+	var names []string
+	for _, n := range t.index.Query(vault.Query{Folder: vault.ProjectsFolder}) {
+		if strings.EqualFold(n.Title, name) {
+			project = &n
+			break
+		}
+		if n.Status() == "active" {
+			names = append(names, n.Title)
+		}
+	}
+	if project == nil {
+		return "", fmt.Errorf("no project named %q; active projects: %s", name, strings.Join(names, ", "))
+	}
+
+	data, err := os.ReadFile(filepath.Join(t.index.VaultPath(), project.Path))
+	if err != nil {
+		return "", fmt.Errorf("read project: %w", err)
+	}
+	content := string(data)
+	if len(content) > maxProjectLen {
+		content = content[:maxProjectLen] + "\n[truncated]"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Path: %s\n\n%s\n\nOpen next actions:\n", filepath.ToSlash(project.Path), content)
+	open := vault.ListActions(t.index, vault.ActionFilter{Project: project.Title})
+	if len(open) == 0 {
+		sb.WriteString("(none)\n")
+	} else {
+		sb.WriteString(formatActions(open))
+	}
+	return sb.String(), nil
+}
+
+func (t *vaultTools) createInboxItem(ctx context.Context, args json.RawMessage) (string, error) {
+	var in struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return "", err
+	}
+	title := strings.TrimSpace(in.Title)
+	if title == "" {
+		return "", fmt.Errorf("title is required")
+	}
+	t.index.LockNotes()
+	rel, err := vault.CreateInboxNote(t.index.VaultPath(), t.templates, title, in.Content)
+	t.index.UnlockNotes()
+	if err != nil {
+		return "", fmt.Errorf("create inbox item: %w", err)
+	}
+	t.changed(rel, "Add inbox item: "+title)
+	return "Created inbox item " + filepath.ToSlash(rel), nil
+}
+
+func (t *vaultTools) completeAction(ctx context.Context, args json.RawMessage) (string, error) {
+	var in struct {
+		Path string `json:"path"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return "", err
+	}
+	rel, err := vault.CleanNotePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	note, ok := t.index.Get(rel)
+	if !ok || !vault.InFolder(rel, vault.NextActionsFolder) {
+		return "", fmt.Errorf("no next action at %s", in.Path)
+	}
+	if note.Status() == "done" {
+		return note.Title + " is already done", nil
+	}
+	result, err := t.completer.Complete(rel, false)
+	if result == nil {
+		return "", fmt.Errorf("complete action: %w", err)
+	}
+	if err != nil {
+		log.Printf("Assistant: complete %s: %v", rel, err)
+	}
+	if result.NextDue != "" {
+		return fmt.Sprintf("Marked %s done; it recurs, next due %s", note.Title, result.NextDue), nil
+	}
+	return "Marked " + note.Title + " done", nil
+}
+
+// changed re-indexes a note written by a tool and commits it
+func (t *vaultTools) changed(rel, message string) {
+	if err := t.index.Update(rel); err != nil {
+		log.Printf("Assistant: re-index %s: %v", rel, err)
+	}
+	if t.commit != nil {
+		if err := t.commit(message); err != nil {
+			log.Printf("Assistant: commit %q: %v", message, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// defaultRepeatDays is how long an overdue item stays quiet after a nudge
+const defaultRepeatDays = 3
+
+// Notifier sends a message to the user, e.g. through a chat bot
+type Notifier interface {
+	Notify(ctx context.Context, text string) error
+}
+
+// EmailDrafter saves an email as a draft and returns its ID
+type EmailDrafter interface {
+	CreateDraft(ctx context.Context, to, subject, body string) (string, error)
+}
+
+// FollowUps nudges the user about waiting-for items whose follow-up date has
+// come. Its Run method is the waiting_followups action.
+type FollowUps struct {
+	Notifiers []Notifier        // each gets the nudges; at least one is needed
+	Drafts    EmailDrafter      // drafts follow-up emails, if set
+	AI        ai.Generator      // writes the email drafts
+	Prompts   *ai.PromptLibrary // may be nil for the built-in prompt
+
+	index *vault.Index
+	repo  *db.Repository
+	now   func() time.Time
+}
+
+// NewFollowUps creates the follow-up action for the waiting-for items in index
+func NewFollowUps(index *vault.Index, repo *db.Repository) *FollowUps {
+	return &FollowUps{index: index, repo: repo, now: time.Now}
+}
+
+// followUpPayload is the payload of a waiting_followups automation
+type followUpPayload struct {
+	Person     string `json:"person"`
+	Project    string `json:"project"`
+	RepeatDays *int   `json:"repeat_days"` // days before nudging again, 0 for once per follow-up date
+	DraftEmail bool   `json:"draft_email"`
+}
+
+// Run sends one message listing the overdue follow-ups not nudged recently
+// and, with draft_email, drafts a follow-up email to each person with a known
+// address. An email is drafted once per follow-up date.
+func (f *FollowUps) Run(ctx context.Context, def db.AutomationDefinition) (string, error) {
+	var payload followUpPayload
+	if strings.TrimSpace(def.PayloadJSON) != "" {
+		if err := json.Unmarshal([]byte(def.PayloadJSON), &payload); err != nil {
+			return "", fmt.Errorf("invalid payload_json: %w", err)
+		}
+	}
+	if len(f.Notifiers) == 0 {
+		return "", fmt.Errorf("no chat bot is configured to send nudges")
+	}
+	repeat := defaultRepeatDays
+	if payload.RepeatDays != nil {
+		repeat = *payload.RepeatDays
+	}
+
+	now := f.now()
+	items := vault.ListWaiting(f.index, vault.WaitingFilter{Person: payload.Person, Project: payload.Project, Overdue: true}, now)
+	var due []vault.WaitingItem
+	drafts := map[string]string{} // path -> draft ID
+	for _, w := range items {
+		last, err := f.repo.LastWaitingNudge(w.Path)
+		if err != nil {
+			return "", err
+		}
+		current := last != nil && last.FollowUpDate == w.FollowUpDate
+		if current && !last.NudgedAt.IsZero() && (repeat <= 0 || now.Sub(last.NudgedAt) < time.Duration(repeat)*24*time.Hour) {
+			continue
+		}
+		due = append(due, w)
+		if current {
+			drafts[w.Path] = last.DraftID
+		} else if payload.DraftEmail && w.Email != "" {
+			id, err := f.draft(ctx, w)
+			if err != nil {
+				log.Printf("waiting_followups: draft email for %s: %v", w.Path, err)
+				continue
+			}
+			drafts[w.Path] = id
+			// Keep the draft even if no nudge goes out, so a failed run does
+			// not draft the email again. A zero NudgedAt means not yet sent.
+			if err := f.repo.RecordWaitingNudge(&db.WaitingNudge{Path: w.Path, FollowUpDate: w.FollowUpDate, DraftID: id}); err != nil {
+				log.Printf("waiting_followups: %v", err)
+			}
+		}
+	}
+	if len(due) == 0 {
+		return "no follow-ups due", nil
+	}
+
+	text := followUpMessage(due, drafts)
+	sent := 0
+	for _, n := range f.Notifiers {
+		if err := n.Notify(ctx, text); err != nil {
+			log.Printf("waiting_followups: notify: %v", err)
+			continue
+		}
+		sent++
+	}
+	if sent == 0 {
+		return "", fmt.Errorf("failed to send the nudges")
+	}
+
+	drafted := 0
+	for _, w := range due {
+		n := &db.WaitingNudge{Path: w.Path, FollowUpDate: w.FollowUpDate, DraftID: drafts[w.Path], NudgedAt: now}
+		if err := f.repo.RecordWaitingNudge(n); err != nil {
+			log.Printf("waiting_followups: %v", err)
+		}
+		if n.DraftID != "" {
+			drafted++
+		}
+	}
+	return fmt.Sprintf("sent %d follow-up nudge(s), %d with an email draft", len(due), drafted), nil
+}
+
+// draft writes a follow-up email for w and saves it as a draft
+func (f *FollowUps) draft(ctx context.Context, w vault.WaitingItem) (string, error) {
+	if f.Drafts == nil || f.AI == nil {
+		return "", fmt.Errorf("email drafts are not configured")
+	}
+	details := ""
+	if data, err := os.ReadFile(filepath.Join(f.index.VaultPath(), w.Path)); err == nil {
+		if e, err := vault.ParseFrontmatter(data); err == nil {
+			details = strings.TrimSpace(e.Body())
+		}
+	}
+	ctx = ai.WithFeature(ctx, "waiting_follow_up")
+	prompt, err := f.Prompts.FollowUpEmailPrompt(ctx, w.Title, w.WaitingFor, w.DateRequested, w.DaysWaiting, details)
+	if err != nil {
+		return "", err
+	}
+	body, err := f.AI.Generate(prompt.Context(ctx), prompt.Request())
+	if err != nil {
+		return "", fmt.Errorf("generate email: %w", err)
+	}
+	return f.Drafts.CreateDraft(ctx, w.Email, "Following up: "+w.Title, strings.TrimSpace(body))
+}
+
+// followUpMessage formats the nudge for the overdue items. drafts maps the
+// items with an email draft to its ID.
+func followUpMessage(items []vault.WaitingItem, drafts map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Waiting-for follow-ups due (%d):\n", len(items))
+	for _, w := range items {
+		fmt.Fprintf(&b, "- %s, from %s", w.Title, w.WaitingFor)
+		if w.Project != "" {
+			fmt.Fprintf(&b, " [%s]", w.Project)
+		}
+		if w.DaysWaiting > 0 {
+			fmt.Fprintf(&b, ", waiting %d days", w.DaysWaiting)
+		}
+		switch w.DaysOverdue {
+		case 0:
+			b.WriteString(", follow up today")
+		case 1:
+			b.WriteString(", follow-up 1 day overdue")
+		default:
+			fmt.Fprintf(&b, ", follow-up %d days overdue", w.DaysOverdue)
+		}
+		if drafts[w.Path] != "" {
+			b.WriteString(" (email draft ready)")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+type recordingNotifier struct {
+	messages []string
+	fail     int // number of calls to fail before recording
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, text string) error {
+	if n.fail > 0 {
+		n.fail--
+		return errors.New("chat is down")
+	}
+	n.messages = append(n.messages, text)
+	return nil
+}
+
+type recordingDrafter struct{ to, subjects, bodies []string }
+
+func (d *recordingDrafter) CreateDraft(ctx context.Context, to, subject, body string) (string, error) {
+	d.to = append(d.to, to)
+	d.subjects = append(d.subjects, subject)
+	d.bodies = append(d.bodies, body)
+	return "draft-" + to, nil
+}
+
+type cannedGenerator struct{ text string }
+
+func (g cannedGenerator) GenerateText(ctx context.Context, prompt string) (string, error) {
+	return g.text, nil
+}
+
+func (g cannedGenerator) Generate(ctx context.Context, req ai.Request) (string, error) {
+	return g.text, nil
+}
+
+func (g cannedGenerator) GenerateJSON(ctx context.Context, req ai.Request, schema *ai.Schema) (string, error) {
+	return g.text, nil
+}
+
+func (g cannedGenerator) Stream(ctx context.Context, req ai.Request, fn ai.StreamFunc) (string, error) {
+	return g.text, fn(g.text)
+}
+
+func TestFollowUps(t *testing.T) {
+	f, notifier, drafter := newTestFollowUps(t)
+	now := time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	def := db.AutomationDefinition{ActionType: "waiting_followups", PayloadJSON: `{"draft_email":true}`}
+
+	out, err := f.Run(context.Background(), def)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if out != "sent 2 follow-up nudge(s), 1 with an email draft" {
+		t.Errorf("output = %q", out)
+	}
+	want := "Waiting-for follow-ups due (2):\n" +
+		"- Quote, from Bob <bob@example.com>, waiting 9 days, follow-up 2 days overdue (email draft ready)\n" +
+		"- Invoice, from Accounting [Taxes], follow up today\n"
+	if len(notifier.messages) != 1 || notifier.messages[0] != want {
+		t.Errorf("messages = %q", notifier.messages)
+	}
+	if len(drafter.to) != 1 || drafter.to[0] != "bob@example.com" || drafter.subjects[0] != "Following up: Quote" || drafter.bodies[0] != "Hi Bob, any news on the quote?" {
+		t.Errorf("drafts = %+v", drafter)
+	}
+
+	// Nudged items stay quiet for repeat_days, and drafts are not repeated
+	if out, _ := f.Run(context.Background(), def); out != "no follow-ups due" {
+		t.Errorf("second run = %q", out)
+	}
+	now = now.AddDate(0, 0, 3)
+	if _, err := f.Run(context.Background(), def); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.messages) != 2 || !strings.Contains(notifier.messages[1], "Quote, from Bob <bob@example.com>, waiting 12 days, follow-up 5 days overdue (email draft ready)") || len(drafter.to) != 1 {
+		t.Errorf("messages = %q, drafts = %d", notifier.messages, len(drafter.to))
+	}
+
+	f.Notifiers = nil
+	if _, err := f.Run(context.Background(), def); err == nil {
+		t.Error("expected an error without a chat bot")
+	}
+}
+
+func TestFollowUpsDraftOnceWhenNotifyFails(t *testing.T) {
+	f, notifier, drafter := newTestFollowUps(t)
+	notifier.fail = 2
+	f.now = func() time.Time { return time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC) }
+	def := db.AutomationDefinition{ActionType: "waiting_followups", PayloadJSON: `{"draft_email":true}`}
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.Run(context.Background(), def); err == nil {
+			t.Fatalf("run %d: expected an error when no nudge is sent", i+1)
+		}
+	}
+	out, err := f.Run(context.Background(), def)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if out != "sent 2 follow-up nudge(s), 1 with an email draft" {
+		t.Errorf("output = %q", out)
+	}
+	if len(drafter.to) != 1 {
+		t.Errorf("drafts = %d, want 1", len(drafter.to))
+	}
+	if len(notifier.messages) != 1 || !strings.Contains(notifier.messages[0], "(email draft ready)") {
+		t.Errorf("messages = %q", notifier.messages)
+	}
+}
+
+// newTestFollowUps creates a follow-up action over a vault with two overdue
+// waiting-for items, one of them with an email address
+func newTestFollowUps(t *testing.T) (*FollowUps, *recordingNotifier, *recordingDrafter) {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"2. Next Actions/@waiting/Quote.md":    "---\nstatus: waiting\nwaiting_for: Bob <bob@example.com>\ndate_requested: 2026-05-01\nfollow_up_date: 2026-05-08\n---\n# Quote\n\n## What I'm Waiting For\nA quote for the fence\n",
+		"2. Next Actions/@waiting/Invoice.md":  "---\nstatus: waiting\nwaiting_for: Accounting\nfollow_up_date: 2026-05-10\nproject: \"[[Taxes]]\"\n---\n",
+		"2. Next Actions/@waiting/Contract.md": "---\nstatus: waiting\nwaiting_for: Legal\nfollow_up_date: 2026-06-01\n---\n",
+	} {
+		abs := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := vault.NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.NewDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := &recordingNotifier{}
+	drafter := &recordingDrafter{}
+	f := NewFollowUps(idx, db.NewRepository(database))
+	f.Notifiers = []Notifier{notifier}
+	f.Drafts = drafter
+	f.AI = cannedGenerator{text: "Hi Bob, any news on the quote?\n"}
+	return f, notifier, drafter
+}
@@ -2,8 +2,15 @@ package db
 
 import (
 	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
 
 // Repository handles data access
@@ -300,11 +307,11 @@ func boolToInt(v bool) int {
 	return 0
 }
 
-type automationRowScanner interface {
+type rowScanner interface {
 	Scan(dest ...interface{}) error
 }
 
-func scanAutomationDefinition(scanner automationRowScanner) (*AutomationDefinition, error) {
+func scanAutomationDefinition(scanner rowScanner) (*AutomationDefinition, error) {
 	var def AutomationDefinition
 	var enabled int
 	var nextRun sql.NullTime
@@ -402,6 +409,16 @@ func (r *Repository) UpdateCalendarSync(eventID, syncKey string) error {
 	return nil
 }
 
+// UpdateCalendarSyncVaultPath points sync records for oldPath at newPath.
+func (r *Repository) UpdateCalendarSyncVaultPath(oldPath, newPath string) error {
+	query := `UPDATE calendar_sync SET vault_path = ?, updated_at = CURRENT_TIMESTAMP WHERE vault_path = ?`
+	_, err := r.db.Exec(query, newPath, oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to update calendar sync path: %w", err)
+	}
+	return nil
+}
+
 // --- Drive sync (backup) ---
 
 // DriveSyncRecord represents a row in the drive_sync table.
@@ -451,6 +468,16 @@ func (r *Repository) UpdateDriveSync(driveFileID string, lastSyncedAt time.Time)
 	return nil
 }
 
+// UpdateDriveSyncLocalPath points sync records for oldPath at newPath.
+func (r *Repository) UpdateDriveSyncLocalPath(oldPath, newPath string) error {
+	query := `UPDATE drive_sync SET local_path = ?, updated_at = CURRENT_TIMESTAMP WHERE local_path = ?`
+	_, err := r.db.Exec(query, newPath, oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to update drive sync path: %w", err)
+	}
+	return nil
+}
+
 // --- Drive watch ---
 
 // DriveWatchRecord represents a row in the drive_watch table.
@@ -487,3 +514,857 @@ func (r *Repository) InsertDriveWatch(driveFileID, fileName string, processedAt
 	}
 	return nil
 }
+
+// --- Note index ---
+
+// Ensure Repository can back a vault.Index.
+var _ vault.IndexStore = (*Repository)(nil)
+
+// LoadIndexedNotes returns every note stored in the index table.
+func (r *Repository) LoadIndexedNotes() ([]vault.IndexedNote, error) {
+	query := `
+		SELECT path, mod_time, size, hash, title, frontmatter_json, tags_json, links_json, headings_json, parse_error
+		FROM notes
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load indexed notes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []vault.IndexedNote
+	for rows.Next() {
+		var n vault.IndexedNote
+		var fmJSON, tagsJSON, linksJSON, headingsJSON string
+		if err := rows.Scan(&n.Path, &n.ModTime, &n.Size, &n.Hash, &n.Title, &fmJSON, &tagsJSON, &linksJSON, &headingsJSON, &n.ParseError); err != nil {
+			return nil, fmt.Errorf("failed to scan indexed note: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fmJSON), &n.Frontmatter); err != nil {
+			return nil, fmt.Errorf("failed to decode frontmatter for %s: %w", n.Path, err)
+		}
+		if err := json.Unmarshal([]byte(tagsJSON), &n.Tags); err != nil {
+			return nil, fmt.Errorf("failed to decode tags for %s: %w", n.Path, err)
+		}
+		if err := json.Unmarshal([]byte(linksJSON), &n.Links); err != nil {
+			// Entries from before links were structured; force a re-index on the next scan.
+			n.Links = nil
+			n.ModTime = time.Time{}
+		}
+		if err := json.Unmarshal([]byte(headingsJSON), &n.Headings); err != nil {
+			return nil, fmt.Errorf("failed to decode headings for %s: %w", n.Path, err)
+		}
+		out = append(out, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load indexed notes rows: %w", err)
+	}
+	return out, nil
+}
+
+// SaveIndexedNote inserts or replaces an index entry.
+func (r *Repository) SaveIndexedNote(n *vault.IndexedNote) error {
+	fmJSON, err := json.Marshal(n.Frontmatter)
+	if err != nil {
+		return fmt.Errorf("failed to encode frontmatter: %w", err)
+	}
+	if n.Frontmatter == nil {
+		fmJSON = []byte("{}")
+	}
+	tagsJSON, _ := json.Marshal(nonNilStrings(n.Tags))
+	links := n.Links
+	if links == nil {
+		links = []vault.Link{}
+	}
+	linksJSON, _ := json.Marshal(links)
+	headings := n.Headings
+	if headings == nil {
+		headings = []vault.Heading{}
+	}
+	headingsJSON, _ := json.Marshal(headings)
+
+	query := `
+		INSERT INTO notes
+			(path, mod_time, size, hash, title, frontmatter_json, tags_json, links_json, headings_json, parse_error, indexed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(path) DO UPDATE SET
+			mod_time = excluded.mod_time,
+			size = excluded.size,
+			hash = excluded.hash,
+			title = excluded.title,
+			frontmatter_json = excluded.frontmatter_json,
+			tags_json = excluded.tags_json,
+			links_json = excluded.links_json,
+			headings_json = excluded.headings_json,
+			parse_error = excluded.parse_error,
+			indexed_at = CURRENT_TIMESTAMP
+	`
+	_, err = r.db.Exec(query, n.Path, n.ModTime, n.Size, n.Hash, n.Title,
+		string(fmJSON), string(tagsJSON), string(linksJSON), string(headingsJSON), n.ParseError)
+	if err != nil {
+		return fmt.Errorf("failed to save indexed note: %w", err)
+	}
+	return nil
+}
+
+// DeleteIndexedNote removes an index entry by path.
+func (r *Repository) DeleteIndexedNote(path string) error {
+	_, err := r.db.Exec(`DELETE FROM notes WHERE path = ?`, path)
+	if err != nil {
+		return fmt.Errorf("failed to delete indexed note: %w", err)
+	}
+	return nil
+}
+
+// MoveNoteRecords points calendar and drive sync records and project history
+// for a moved note at its new path. It has the vault.MoveHook signature.
+func (r *Repository) MoveNoteRecords(oldPath, newPath string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin note move tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE calendar_sync SET vault_path = ?, updated_at = CURRENT_TIMESTAMP WHERE vault_path = ?`, newPath, oldPath); err != nil {
+		return fmt.Errorf("failed to update calendar sync path: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE drive_sync SET local_path = ?, updated_at = CURRENT_TIMESTAMP WHERE local_path = ?`, newPath, oldPath); err != nil {
+		return fmt.Errorf("failed to update drive sync path: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE project_transitions SET path = ? WHERE path = ?`, newPath, oldPath); err != nil {
+		return fmt.Errorf("failed to update project history path: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE waiting_nudges SET path = ? WHERE path = ?`, newPath, oldPath); err != nil {
+		return fmt.Errorf("failed to update waiting nudge path: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit note move: %w", err)
+	}
+	return nil
+}
+
+// --- Inbox processing ---
+
+// InboxDecisionRecord is a processed inbox item. OriginalContent keeps the
+// inbox file so trashed items can be recovered.
+type InboxDecisionRecord struct {
+	ID              int64               `json:"id"`
+	InboxPath       string              `json:"inbox_path"`
+	Title           string              `json:"title"`
+	Action          string              `json:"action"`
+	NotePath        string              `json:"note_path,omitempty"`
+	Decision        vault.InboxDecision `json:"decision"`
+	OriginalContent string              `json:"original_content"`
+	ProcessedAt     time.Time           `json:"processed_at"`
+}
+
+// RecordInboxDecision logs a processed inbox item. It has the
+// vault.ProcessHook signature.
+func (r *Repository) RecordInboxDecision(result *vault.ProcessResult, decision vault.InboxDecision) error {
+	decisionJSON, err := json.Marshal(decision)
+	if err != nil {
+		return fmt.Errorf("failed to encode inbox decision: %w", err)
+	}
+	query := `
+		INSERT INTO inbox_decisions (inbox_path, title, action, note_path, decision_json, original_content, processed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = r.db.Exec(query, result.InboxPath, result.Title, result.Action, result.NotePath,
+		string(decisionJSON), result.Original, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record inbox decision: %w", err)
+	}
+	return nil
+}
+
+// ListInboxDecisions returns the most recent inbox decisions first.
+func (r *Repository) ListInboxDecisions(limit int) ([]InboxDecisionRecord, error) {
+	query := `
+		SELECT id, inbox_path, title, action, note_path, decision_json, original_content, processed_at
+		FROM inbox_decisions
+		ORDER BY processed_at DESC, id DESC
+		LIMIT ?
+	`
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inbox decisions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []InboxDecisionRecord
+	for rows.Next() {
+		var rec InboxDecisionRecord
+		var decisionJSON string
+		if err := rows.Scan(&rec.ID, &rec.InboxPath, &rec.Title, &rec.Action, &rec.NotePath, &decisionJSON, &rec.OriginalContent, &rec.ProcessedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan inbox decision: %w", err)
+		}
+		if err := json.Unmarshal([]byte(decisionJSON), &rec.Decision); err != nil {
+			return nil, fmt.Errorf("failed to decode inbox decision %d: %w", rec.ID, err)
+		}
+		out = append(out, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list inbox decisions rows: %w", err)
+	}
+	return out, nil
+}
+
+// --- Inbox suggestions ---
+
+// Inbox suggestion statuses
+const (
+	SuggestionPending  = "pending"
+	SuggestionAccepted = "accepted" // processed as proposed
+	SuggestionEdited   = "edited"   // processed with a different decision
+	SuggestionRejected = "rejected"
+)
+
+// InboxSuggestion is an AI-proposed processing decision for an inbox item.
+// FinalDecision holds what was actually done once the item was processed, so
+// edits and rejections can be used to tune the prompt.
+type InboxSuggestion struct {
+	ID            int64                `json:"id"`
+	InboxPath     string               `json:"inbox_path"`
+	Title         string               `json:"title"`
+	Decision      vault.InboxDecision  `json:"decision"`
+	TargetFolder  string               `json:"target_folder,omitempty"`
+	Confidence    float64              `json:"confidence"`
+	Reason        string               `json:"reason,omitempty"`
+	Status        string               `json:"status"`
+	FinalDecision *vault.InboxDecision `json:"final_decision,omitempty"`
+	NotePath      string               `json:"note_path,omitempty"`
+	Feedback      string               `json:"feedback,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	ResolvedAt    *time.Time           `json:"resolved_at,omitempty"`
+}
+
+// CreateInboxSuggestion stores a pending suggestion and sets its ID.
+func (r *Repository) CreateInboxSuggestion(s *InboxSuggestion) error {
+	decisionJSON, err := json.Marshal(s.Decision)
+	if err != nil {
+		return fmt.Errorf("failed to encode inbox suggestion: %w", err)
+	}
+	s.Status = SuggestionPending
+	s.TargetFolder = s.Decision.Folder()
+	s.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO inbox_suggestions (inbox_path, title, action, target_folder, context, project, due_date, confidence, reason, decision_json, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	res, err := r.db.Exec(query, s.InboxPath, s.Title, s.Decision.Action, s.TargetFolder, s.Decision.Context,
+		s.Decision.Project, s.Decision.DueDate, s.Confidence, s.Reason, string(decisionJSON), s.Status, s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create inbox suggestion: %w", err)
+	}
+	s.ID, err = res.LastInsertId()
+	return err
+}
+
+const inboxSuggestionColumns = `id, inbox_path, title, target_folder, confidence, reason, decision_json, status, final_decision_json, note_path, feedback, created_at, resolved_at`
+
+func scanInboxSuggestion(scanner rowScanner) (*InboxSuggestion, error) {
+	var s InboxSuggestion
+	var decisionJSON, finalJSON string
+	var resolved sql.NullTime
+	if err := scanner.Scan(&s.ID, &s.InboxPath, &s.Title, &s.TargetFolder, &s.Confidence, &s.Reason,
+		&decisionJSON, &s.Status, &finalJSON, &s.NotePath, &s.Feedback, &s.CreatedAt, &resolved); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(decisionJSON), &s.Decision); err != nil {
+		return nil, fmt.Errorf("failed to decode inbox suggestion %d: %w", s.ID, err)
+	}
+	if finalJSON != "" {
+		s.FinalDecision = &vault.InboxDecision{}
+		if err := json.Unmarshal([]byte(finalJSON), s.FinalDecision); err != nil {
+			return nil, fmt.Errorf("failed to decode final decision of suggestion %d: %w", s.ID, err)
+		}
+	}
+	if resolved.Valid {
+		t := resolved.Time
+		s.ResolvedAt = &t
+	}
+	return &s, nil
+}
+
+// GetInboxSuggestion returns a suggestion by ID, or nil if it does not exist.
+func (r *Repository) GetInboxSuggestion(id int64) (*InboxSuggestion, error) {
+	row := r.db.QueryRow(`SELECT `+inboxSuggestionColumns+` FROM inbox_suggestions WHERE id = ?`, id)
+	s, err := scanInboxSuggestion(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get inbox suggestion: %w", err)
+	}
+	return s, nil
+}
+
+// ListInboxSuggestions returns suggestions with the given status (all when
+// empty). Pending suggestions come oldest first, the others newest first.
+func (r *Repository) ListInboxSuggestions(status string, limit int) ([]InboxSuggestion, error) {
+	order := "created_at DESC, id DESC"
+	if status == SuggestionPending {
+		order = "created_at ASC, id ASC"
+	}
+	query := `SELECT ` + inboxSuggestionColumns + ` FROM inbox_suggestions
+		WHERE (? = '' OR status = ?)
+		ORDER BY ` + order + `
+		LIMIT ?`
+	rows, err := r.db.Query(query, status, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inbox suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []InboxSuggestion
+	for rows.Next() {
+		s, err := scanInboxSuggestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inbox suggestion: %w", err)
+		}
+		out = append(out, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list inbox suggestions rows: %w", err)
+	}
+	return out, nil
+}
+
+// RejectInboxSuggestion marks a pending suggestion as rejected and keeps the
+// feedback. It returns false if the suggestion is not pending.
+func (r *Repository) RejectInboxSuggestion(id int64, feedback string) (bool, error) {
+	res, err := r.db.Exec(`UPDATE inbox_suggestions SET status = ?, feedback = ?, resolved_at = ? WHERE id = ? AND status = ?`,
+		SuggestionRejected, feedback, time.Now().UTC(), id, SuggestionPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to reject inbox suggestion: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ResolveInboxSuggestions records how an inbox item was processed against
+// its suggestions: pending ones become accepted or edited, rejected ones get
+// the decision that was made instead. A suggestion whose decision cannot be
+// read is left pending and reported in the error. It has the
+// vault.ProcessHook signature.
+func (r *Repository) ResolveInboxSuggestions(result *vault.ProcessResult, decision vault.InboxDecision) error {
+	if decision.Title == "" {
+		decision.Title = result.Title
+	}
+	finalJSON, err := json.Marshal(decision)
+	if err != nil {
+		return fmt.Errorf("failed to encode inbox decision: %w", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, decision_json FROM inbox_suggestions WHERE inbox_path = ? AND status = ?`,
+		result.InboxPath, SuggestionPending)
+	if err != nil {
+		return fmt.Errorf("failed to find inbox suggestions: %w", err)
+	}
+	statuses := map[int64]string{}
+	var skipped []error
+	for rows.Next() {
+		var id int64
+		var proposedJSON string
+		if err := rows.Scan(&id, &proposedJSON); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan inbox suggestion: %w", err)
+		}
+		var proposed vault.InboxDecision
+		if err := json.Unmarshal([]byte(proposedJSON), &proposed); err != nil {
+			skipped = append(skipped, fmt.Errorf("inbox suggestion %d has an invalid decision: %w", id, err))
+			continue
+		}
+		if proposed.Title == "" {
+			proposed.Title = result.Title
+		}
+		statuses[id] = SuggestionEdited
+		if proposed == decision {
+			statuses[id] = SuggestionAccepted
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find inbox suggestions: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for id, status := range statuses {
+		if _, err := tx.Exec(`UPDATE inbox_suggestions SET status = ?, final_decision_json = ?, note_path = ?, resolved_at = ? WHERE id = ?`,
+			status, string(finalJSON), result.NotePath, now, id); err != nil {
+			return fmt.Errorf("failed to resolve inbox suggestion: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE inbox_suggestions SET final_decision_json = ?, note_path = ? WHERE inbox_path = ? AND status = ? AND final_decision_json = ''`,
+		string(finalJSON), result.NotePath, result.InboxPath, SuggestionRejected); err != nil {
+		return fmt.Errorf("failed to record decision on rejected suggestions: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return errors.Join(skipped...)
+}
+
+func nonNilStrings(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+// --- AI usage ---
+
+// Ensure Repository implements ai.UsageStore
+var _ ai.UsageStore = (*Repository)(nil)
+
+// RecordAIUsage stores a metered AI call. It implements ai.UsageStore.
+func (r *Repository) RecordAIUsage(rec *ai.UsageRecord) error {
+	query := `
+		INSERT INTO ai_usage (feature, provider, model, prompt_tokens, completion_tokens, latency_ms, cost_usd, error, day, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query, rec.Feature, rec.Provider, rec.Model, rec.PromptTokens, rec.CompletionTokens,
+		rec.Latency.Milliseconds(), rec.CostUSD, rec.Error, rec.CreatedAt.Format("2006-01-02"), rec.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record AI usage: %w", err)
+	}
+	return nil
+}
+
+// AICostSince returns the estimated cost of the AI calls made since t. It
+// implements ai.UsageStore.
+func (r *Repository) AICostSince(t time.Time) (float64, error) {
+	var cost float64
+	err := r.db.QueryRow(`SELECT COALESCE(SUM(cost_usd), 0) FROM ai_usage WHERE created_at >= ?`, t.UTC()).Scan(&cost)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum AI cost: %w", err)
+	}
+	return cost, nil
+}
+
+// AIUsageAggregate sums the AI calls of one period, provider and feature
+type AIUsageAggregate struct {
+	Period           string  `json:"period"` // YYYY-MM-DD or YYYY-MM
+	Provider         string  `json:"provider"`
+	Feature          string  `json:"feature"`
+	Calls            int     `json:"calls"`
+	Errors           int     `json:"errors"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	AvgLatencyMS     float64 `json:"avg_latency_ms"`
+}
+
+// AIUsageByDay aggregates AI usage per day, from the given day (YYYY-MM-DD)
+// on, newest first.
+func (r *Repository) AIUsageByDay(fromDay string) ([]AIUsageAggregate, error) {
+	return r.aiUsage("day", fromDay)
+}
+
+// AIUsageByMonth aggregates AI usage per month, from the given month
+// (YYYY-MM) on, newest first.
+func (r *Repository) AIUsageByMonth(fromMonth string) ([]AIUsageAggregate, error) {
+	return r.aiUsage("substr(day, 1, 7)", fromMonth)
+}
+
+func (r *Repository) aiUsage(period, from string) ([]AIUsageAggregate, error) {
+	query := `
+		SELECT ` + period + ` AS period, provider, feature, COUNT(*), SUM(error != ''),
+		       SUM(prompt_tokens), SUM(completion_tokens), SUM(cost_usd), AVG(latency_ms)
+		FROM ai_usage
+		WHERE ` + period + ` >= ?
+		GROUP BY period, provider, feature
+		ORDER BY period DESC, provider, feature
+	`
+	rows, err := r.db.Query(query, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate AI usage: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AIUsageAggregate
+	for rows.Next() {
+		var a AIUsageAggregate
+		if err := rows.Scan(&a.Period, &a.Provider, &a.Feature, &a.Calls, &a.Errors,
+			&a.PromptTokens, &a.CompletionTokens, &a.CostUSD, &a.AvgLatencyMS); err != nil {
+			return nil, fmt.Errorf("failed to scan AI usage: %w", err)
+		}
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate AI usage rows: %w", err)
+	}
+	return out, nil
+}
+
+// --- Embeddings ---
+
+// Embedding is an embedded passage of a note
+type Embedding struct {
+	Path     string
+	NoteHash string // content hash of the note when it was embedded
+	Model    string
+	Heading  string
+	Content  string
+	Vector   []float32
+}
+
+// ReplaceEmbeddings replaces the stored passages of a note, of any model,
+// with the given ones
+func (r *Repository) ReplaceEmbeddings(path string, embeddings []Embedding) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin embeddings tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM embeddings WHERE path = ?`, path); err != nil {
+		return fmt.Errorf("failed to delete embeddings: %w", err)
+	}
+	for _, e := range embeddings {
+		_, err := tx.Exec(`INSERT INTO embeddings (path, note_hash, model, heading, content, vector) VALUES (?, ?, ?, ?, ?, ?)`,
+			path, e.NoteHash, e.Model, e.Heading, e.Content, encodeVector(e.Vector))
+		if err != nil {
+			return fmt.Errorf("failed to save embedding: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit embeddings: %w", err)
+	}
+	return nil
+}
+
+// DeleteEmbeddings removes the stored passages of a note
+func (r *Repository) DeleteEmbeddings(path string) error {
+	if _, err := r.db.Exec(`DELETE FROM embeddings WHERE path = ?`, path); err != nil {
+		return fmt.Errorf("failed to delete embeddings: %w", err)
+	}
+	return nil
+}
+
+// ListEmbeddings returns the passages embedded with model, in note order
+func (r *Repository) ListEmbeddings(model string) ([]Embedding, error) {
+	rows, err := r.db.Query(`SELECT path, note_hash, model, heading, content, vector FROM embeddings WHERE model = ? ORDER BY path, id`, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Embedding
+	for rows.Next() {
+		var e Embedding
+		var blob []byte
+		if err := rows.Scan(&e.Path, &e.NoteHash, &e.Model, &e.Heading, &e.Content, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		e.Vector = decodeVector(blob)
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list embeddings rows: %w", err)
+	}
+	return out, nil
+}
+
+// --- Conversations ---
+
+// AppendConversation adds messages to a conversation, creating it on first
+// use. channel tells where the conversation happens, e.g. "api" or "telegram".
+func (r *Repository) AppendConversation(id, channel string, messages []ai.Message) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin conversation tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO conversations (id, channel) VALUES (?, ?)
+		ON CONFLICT(id) DO UPDATE SET updated_at = CURRENT_TIMESTAMP
+	`, id, channel)
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	for _, m := range messages {
+		calls := ""
+		if len(m.ToolCalls) > 0 {
+			data, err := json.Marshal(m.ToolCalls)
+			if err != nil {
+				return fmt.Errorf("failed to encode tool calls: %w", err)
+			}
+			calls = string(data)
+		}
+		_, err := tx.Exec(`INSERT INTO conversation_messages (conversation_id, role, content, tool_calls, tool_call_id, tool_name) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, m.Role, m.Content, calls, m.ToolCallID, m.ToolName)
+		if err != nil {
+			return fmt.Errorf("failed to save conversation message: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit conversation: %w", err)
+	}
+	return nil
+}
+
+// ConversationMessages returns the last limit messages of a conversation,
+// oldest first. An unknown conversation has no messages.
+func (r *Repository) ConversationMessages(id string, limit int) ([]ai.Message, error) {
+	query := `
+		SELECT role, content, tool_calls, tool_call_id, tool_name FROM (
+			SELECT * FROM conversation_messages WHERE conversation_id = ? ORDER BY id DESC LIMIT ?
+		) ORDER BY id
+	`
+	rows, err := r.db.Query(query, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversation messages: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ai.Message
+	for rows.Next() {
+		var m ai.Message
+		var calls string
+		if err := rows.Scan(&m.Role, &m.Content, &calls, &m.ToolCallID, &m.ToolName); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation message: %w", err)
+		}
+		if calls != "" {
+			if err := json.Unmarshal([]byte(calls), &m.ToolCalls); err != nil {
+				return nil, fmt.Errorf("failed to decode tool calls: %w", err)
+			}
+		}
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list conversation message rows: %w", err)
+	}
+	return out, nil
+}
+
+// DeleteConversation removes a conversation and its messages
+func (r *Repository) DeleteConversation(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin conversation tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM conversation_messages WHERE conversation_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete conversation messages: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM conversations WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit conversation delete: %w", err)
+	}
+	return nil
+}
+
+// --- Action completions ---
+
+// CompletionRecord is a logged next action completion
+type CompletionRecord struct {
+	ID int64 `json:"id"`
+	vault.CompletionResult
+}
+
+// RecordCompletion logs a completed next action. It has the
+// vault.CompletionHook signature.
+func (r *Repository) RecordCompletion(result *vault.CompletionResult) error {
+	query := `
+		INSERT INTO action_completions (path, done_path, title, context, project, due_date, recur, next_due, day, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query, result.Path, result.DonePath, result.Title, result.Context, result.Project,
+		result.DueDate, result.Recur, result.NextDue, result.Completed.Format("2006-01-02"), result.Completed.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record completion: %w", err)
+	}
+	return nil
+}
+
+// ListCompletions returns the completions from the given day (YYYY-MM-DD)
+// on, newest first.
+func (r *Repository) ListCompletions(fromDay string, limit int) ([]CompletionRecord, error) {
+	query := `
+		SELECT id, path, done_path, title, context, project, due_date, recur, next_due, completed_at
+		FROM action_completions
+		WHERE day >= ?
+		ORDER BY completed_at DESC, id DESC
+		LIMIT ?
+	`
+	rows, err := r.db.Query(query, fromDay, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list completions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []CompletionRecord
+	for rows.Next() {
+		var rec CompletionRecord
+		if err := rows.Scan(&rec.ID, &rec.Path, &rec.DonePath, &rec.Title, &rec.Context, &rec.Project,
+			&rec.DueDate, &rec.Recur, &rec.NextDue, &rec.Completed); err != nil {
+			return nil, fmt.Errorf("failed to scan completion: %w", err)
+		}
+		out = append(out, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list completions rows: %w", err)
+	}
+	return out, nil
+}
+
+// CompletionStats counts completions per day, context and project
+type CompletionStats struct {
+	Total     int            `json:"total"`
+	OnTime    int            `json:"on_time"` // done by their due date
+	Overdue   int            `json:"overdue"` // done after their due date
+	ByDay     map[string]int `json:"by_day"`
+	ByContext map[string]int `json:"by_context"`
+	ByProject map[string]int `json:"by_project"`
+}
+
+// CompletionStatsSince counts the completions from the given day
+// (YYYY-MM-DD) on. Actions without a context or project are counted under "".
+func (r *Repository) CompletionStatsSince(fromDay string) (*CompletionStats, error) {
+	stats := &CompletionStats{ByDay: map[string]int{}, ByContext: map[string]int{}, ByProject: map[string]int{}}
+	err := r.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(due_date != '' AND day <= due_date), 0), COALESCE(SUM(due_date != '' AND day > due_date), 0)
+		FROM action_completions WHERE day >= ?
+	`, fromDay).Scan(&stats.Total, &stats.OnTime, &stats.Overdue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count completions: %w", err)
+	}
+	for column, counts := range map[string]map[string]int{"day": stats.ByDay, "context": stats.ByContext, "project": stats.ByProject} {
+		rows, err := r.db.Query(`SELECT `+column+`, COUNT(*) FROM action_completions WHERE day >= ? GROUP BY `+column, fromDay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count completions by %s: %w", column, err)
+		}
+		for rows.Next() {
+			var key string
+			var n int
+			if err := rows.Scan(&key, &n); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan completion count: %w", err)
+			}
+			counts[key] = n
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count completions by %s: %w", column, err)
+		}
+	}
+	return stats, nil
+}
+
+// --- Project history ---
+
+// RecordProjectTransition logs a project status change. It has the
+// vault.ProjectHook signature.
+func (r *Repository) RecordProjectTransition(t *vault.ProjectTransition) error {
+	query := `
+		INSERT INTO project_transitions (path, title, from_status, to_status, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	if _, err := r.db.Exec(query, t.Path, t.Title, t.From, t.To, t.Note, t.At.UTC()); err != nil {
+		return fmt.Errorf("failed to record project transition: %w", err)
+	}
+	return nil
+}
+
+// ProjectHistory returns the status changes of the project at path, oldest
+// first. Moves of the project are followed by MoveNoteRecords.
+func (r *Repository) ProjectHistory(path string) ([]vault.ProjectTransition, error) {
+	query := `
+		SELECT path, title, from_status, to_status, note, created_at
+		FROM project_transitions
+		WHERE path = ?
+		ORDER BY created_at, id
+	`
+	rows, err := r.db.Query(query, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project history: %w", err)
+	}
+	defer rows.Close()
+
+	var out []vault.ProjectTransition
+	for rows.Next() {
+		var t vault.ProjectTransition
+		if err := rows.Scan(&t.Path, &t.Title, &t.From, &t.To, &t.Note, &t.At); err != nil {
+			return nil, fmt.Errorf("failed to scan project transition: %w", err)
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list project history rows: %w", err)
+	}
+	return out, nil
+}
+
+// --- Waiting-for follow-ups ---
+
+// WaitingNudge is the last follow-up reminder sent for a waiting-for item
+type WaitingNudge struct {
+	Path         string    `json:"path"`
+	FollowUpDate string    `json:"follow_up_date"` // the follow-up date it was sent for
+	DraftID      string    `json:"draft_id,omitempty"`
+	NudgedAt     time.Time `json:"nudged_at"` // zero while only the draft exists
+}
+
+// RecordWaitingNudge stores the last nudge for a waiting-for item, replacing
+// the previous one
+func (r *Repository) RecordWaitingNudge(n *WaitingNudge) error {
+	query := `
+		INSERT INTO waiting_nudges (path, follow_up_date, draft_id, nudged_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			follow_up_date = excluded.follow_up_date,
+			draft_id = excluded.draft_id,
+			nudged_at = excluded.nudged_at
+	`
+	if _, err := r.db.Exec(query, n.Path, n.FollowUpDate, n.DraftID, n.NudgedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record waiting nudge: %w", err)
+	}
+	return nil
+}
+
+// LastWaitingNudge returns the last nudge for the item at path, or nil if it
+// has never been nudged
+func (r *Repository) LastWaitingNudge(path string) (*WaitingNudge, error) {
+	query := `SELECT path, follow_up_date, draft_id, nudged_at FROM waiting_nudges WHERE path = ?`
+	var n WaitingNudge
+	err := r.db.QueryRow(query, path).Scan(&n.Path, &n.FollowUpDate, &n.DraftID, &n.NudgedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waiting nudge: %w", err)
+	}
+	return &n, nil
+}
+
+// encodeVector stores a vector as little-endian float32s
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
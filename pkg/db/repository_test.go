@@ -1,8 +1,12 @@
 package db
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
 
 func setupTestDB(t *testing.T) *Repository {
@@ -171,3 +175,335 @@ func TestReviewLog(t *testing.T) {
 		t.Errorf("expected week 2026-W05, got %+v", rev)
 	}
 }
+
+func TestIndexedNotes(t *testing.T) {
+	repo := setupTestDB(t)
+
+	note := &vault.IndexedNote{
+		Path:        "3. Projects/Website.md",
+		ModTime:     time.Now().Truncate(time.Second),
+		Size:        42,
+		Hash:        "abc",
+		Title:       "Website",
+		Frontmatter: map[string]interface{}{"status": "active"},
+		Tags:        []string{"web"},
+		Links:       []vault.Link{{Target: "Design Doc", Heading: "Goals"}},
+		Headings:    []vault.Heading{{Level: 1, Text: "Website"}},
+	}
+	if err := repo.SaveIndexedNote(note); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	note.Hash = "def"
+	if err := repo.SaveIndexedNote(note); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+
+	notes, err := repo.LoadIndexedNotes()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(notes) != 1 {
+		t.Fatalf("expected 1 note, got %d", len(notes))
+	}
+	got := notes[0]
+	if got.Hash != "def" || got.Status() != "active" || !got.HasTag("web") || got.Headings[0].Text != "Website" {
+		t.Errorf("unexpected note: %+v", got)
+	}
+	if len(got.Links) != 1 || got.Links[0] != note.Links[0] {
+		t.Errorf("links = %+v", got.Links)
+	}
+	if !got.ModTime.Equal(note.ModTime) {
+		t.Errorf("mod time = %v, want %v", got.ModTime, note.ModTime)
+	}
+
+	if err := repo.DeleteIndexedNote(note.Path); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	notes, _ = repo.LoadIndexedNotes()
+	if len(notes) != 0 {
+		t.Errorf("expected no notes after delete, got %d", len(notes))
+	}
+}
+
+func TestMoveNoteRecords(t *testing.T) {
+	repo := setupTestDB(t)
+
+	if err := repo.InsertCalendarSync("evt-1", "2. Next Actions/Old.md", "key", "pull"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.InsertDriveSync("file-1", "2. Next Actions/Old.md", time.Now(), "push"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MoveNoteRecords("2. Next Actions/Old.md", "3. Projects/New.md"); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+
+	cal, _ := repo.GetCalendarSyncByVaultPath("3. Projects/New.md")
+	if cal == nil || cal.EventID != "evt-1" {
+		t.Errorf("calendar record not moved: %+v", cal)
+	}
+	drv, _ := repo.GetDriveSyncByLocalPath("3. Projects/New.md")
+	if drv == nil || drv.DriveFileID != "file-1" {
+		t.Errorf("drive record not moved: %+v", drv)
+	}
+}
+
+func TestInboxSuggestions(t *testing.T) {
+	repo := setupTestDB(t)
+	s := &InboxSuggestion{
+		InboxPath:  "1. Inbox/Call mum.md",
+		Title:      "Call mum",
+		Decision:   vault.InboxDecision{Action: vault.ActionNextAction, Context: "@calls"},
+		Confidence: 0.9,
+	}
+	if err := repo.CreateInboxSuggestion(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID == 0 || s.TargetFolder != "2. Next Actions/@calls" {
+		t.Fatalf("created = %+v", s)
+	}
+
+	// Processed as suggested; the title defaults to the inbox item's
+	result := &vault.ProcessResult{Action: vault.ActionNextAction, InboxPath: s.InboxPath, NotePath: "2. Next Actions/@calls/Call mum.md", Title: "Call mum"}
+	if err := repo.ResolveInboxSuggestions(result, vault.InboxDecision{Action: vault.ActionNextAction, Context: "@calls"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetInboxSuggestion(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != SuggestionAccepted || got.NotePath != result.NotePath || got.ResolvedAt == nil {
+		t.Errorf("resolved = %+v", got)
+	}
+	if ok, err := repo.RejectInboxSuggestion(s.ID, "too late"); err != nil || ok {
+		t.Errorf("rejected a resolved suggestion: %v %v", ok, err)
+	}
+	pending, err := repo.ListInboxSuggestions(SuggestionPending, 10)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("pending = %+v, %v", pending, err)
+	}
+}
+
+func TestResolveInboxSuggestionsSkipsCorruptDecisions(t *testing.T) {
+	repo := setupTestDB(t)
+	good := &InboxSuggestion{InboxPath: "1. Inbox/Call mum.md", Title: "Call mum", Decision: vault.InboxDecision{Action: vault.ActionTrash}}
+	bad := &InboxSuggestion{InboxPath: good.InboxPath, Title: "Call mum", Decision: vault.InboxDecision{Action: vault.ActionTrash}}
+	for _, s := range []*InboxSuggestion{good, bad} {
+		if err := repo.CreateInboxSuggestion(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.db.Exec(`UPDATE inbox_suggestions SET decision_json = '{' WHERE id = ?`, bad.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &vault.ProcessResult{Action: vault.ActionTrash, InboxPath: good.InboxPath, Title: "Call mum"}
+	if err := repo.ResolveInboxSuggestions(result, vault.InboxDecision{Action: vault.ActionTrash}); err == nil {
+		t.Error("expected an error for the corrupt suggestion")
+	}
+	var goodStatus, badStatus string
+	repo.db.QueryRow(`SELECT status FROM inbox_suggestions WHERE id = ?`, good.ID).Scan(&goodStatus)
+	repo.db.QueryRow(`SELECT status FROM inbox_suggestions WHERE id = ?`, bad.ID).Scan(&badStatus)
+	if goodStatus != SuggestionAccepted || badStatus != SuggestionPending {
+		t.Errorf("statuses = %s, %s", goodStatus, badStatus)
+	}
+}
+
+func TestAIUsage(t *testing.T) {
+	repo := setupTestDB(t)
+
+	march := time.Date(2024, time.March, 30, 10, 0, 0, 0, time.UTC)
+	records := []*ai.UsageRecord{
+		{Feature: "inbox", Provider: "openai", Model: "gpt-4o-mini", PromptTokens: 100, CompletionTokens: 20, Latency: 200 * time.Millisecond, CostUSD: 0.5, CreatedAt: march},
+		{Feature: "inbox", Provider: "openai", Model: "gpt-4o-mini", PromptTokens: 50, CompletionTokens: 10, Latency: 400 * time.Millisecond, CostUSD: 0.25, CreatedAt: march.Add(time.Hour)},
+		{Feature: "inbox", Provider: "", Error: "all AI providers failed", CreatedAt: march.Add(2 * time.Hour)},
+		{Feature: "weekly_review", Provider: "anthropic", Model: "claude-3-5-haiku-latest", PromptTokens: 300, CostUSD: 1, CreatedAt: march.AddDate(0, 0, 3)},
+	}
+	for _, rec := range records {
+		if err := repo.RecordAIUsage(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	daily, err := repo.AIUsageByDay("2024-03-30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 3 || daily[0].Period != "2024-04-02" || daily[2].Provider != "openai" {
+		t.Fatalf("daily = %+v", daily)
+	}
+	if a := daily[2]; a.Calls != 2 || a.PromptTokens != 150 || a.CostUSD != 0.75 || a.AvgLatencyMS != 300 {
+		t.Errorf("openai inbox = %+v", a)
+	}
+	if daily[1].Errors != 1 {
+		t.Errorf("failed call = %+v", daily[1])
+	}
+
+	monthly, err := repo.AIUsageByMonth("2024-04")
+	if err != nil || len(monthly) != 1 || monthly[0].Period != "2024-04" || monthly[0].Feature != "weekly_review" {
+		t.Errorf("monthly = %+v, %v", monthly, err)
+	}
+
+	cost, err := repo.AICostSince(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || cost != 1.75 {
+		t.Errorf("cost since March = %v, %v", cost, err)
+	}
+	if cost, _ := repo.AICostSince(time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)); cost != 1 {
+		t.Errorf("cost since April = %v", cost)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	repo := setupTestDB(t)
+
+	note := []Embedding{
+		{NoteHash: "h1", Model: "m1", Heading: "Intro", Content: "first", Vector: []float32{0.5, -1.25}},
+		{NoteHash: "h1", Model: "m1", Content: "second", Vector: []float32{1, 0}},
+	}
+	if err := repo.ReplaceEmbeddings("a.md", note); err != nil {
+		t.Fatalf("ReplaceEmbeddings failed: %v", err)
+	}
+	if err := repo.ReplaceEmbeddings("b.md", []Embedding{{NoteHash: "h2", Model: "m2", Content: "other model", Vector: []float32{1}}}); err != nil {
+		t.Fatalf("ReplaceEmbeddings failed: %v", err)
+	}
+
+	got, err := repo.ListEmbeddings("m1")
+	if err != nil {
+		t.Fatalf("ListEmbeddings failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Path != "a.md" || got[0].Heading != "Intro" || got[0].Vector[1] != -1.25 || got[1].Content != "second" {
+		t.Errorf("unexpected embeddings: %+v", got)
+	}
+
+	// Re-embedding a note replaces its passages
+	if err := repo.ReplaceEmbeddings("a.md", note[1:]); err != nil {
+		t.Fatalf("ReplaceEmbeddings failed: %v", err)
+	}
+	if got, _ := repo.ListEmbeddings("m1"); len(got) != 1 {
+		t.Errorf("expected 1 passage after replacing, got %d", len(got))
+	}
+
+	if err := repo.DeleteEmbeddings("b.md"); err != nil {
+		t.Fatalf("DeleteEmbeddings failed: %v", err)
+	}
+	if got, _ := repo.ListEmbeddings("m2"); len(got) != 0 {
+		t.Errorf("expected no passages after deleting, got %+v", got)
+	}
+}
+
+func TestConversations(t *testing.T) {
+	repo := setupTestDB(t)
+
+	call := ai.ToolCall{ID: "call_1", Name: "list_next_actions", Arguments: json.RawMessage(`{"context":"@home"}`)}
+	first := []ai.Message{
+		{Role: ai.RoleUser, Content: "What's next at home?"},
+		{Role: ai.RoleAssistant, ToolCalls: []ai.ToolCall{call}},
+		ai.NewToolResult(call, "- Fix the fence"),
+	}
+	if err := repo.AppendConversation("c1", "api", first); err != nil {
+		t.Fatalf("AppendConversation failed: %v", err)
+	}
+	if err := repo.AppendConversation("c1", "api", []ai.Message{{Role: ai.RoleAssistant, Content: "Fix the fence."}}); err != nil {
+		t.Fatalf("AppendConversation failed: %v", err)
+	}
+
+	got, err := repo.ConversationMessages("c1", 3)
+	if err != nil {
+		t.Fatalf("ConversationMessages failed: %v", err)
+	}
+	if len(got) != 3 || got[0].ToolCalls[0].Name != "list_next_actions" || string(got[0].ToolCalls[0].Arguments) != `{"context":"@home"}` ||
+		got[1].ToolCallID != "call_1" || got[1].ToolName != "list_next_actions" || got[2].Content != "Fix the fence." {
+		t.Errorf("unexpected messages: %+v", got)
+	}
+
+	if err := repo.DeleteConversation("c1"); err != nil {
+		t.Fatalf("DeleteConversation failed: %v", err)
+	}
+	if got, _ := repo.ConversationMessages("c1", 10); len(got) != 0 {
+		t.Errorf("expected no messages after deleting, got %+v", got)
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	repo := setupTestDB(t)
+
+	day := time.Date(2026, time.March, 5, 18, 0, 0, 0, time.UTC)
+	results := []*vault.CompletionResult{
+		{Path: "2. Next Actions/@calls/Call Bob.md", DonePath: "2. Next Actions/Archive/@calls/Call Bob.md", Title: "Call Bob", Context: "@calls", Project: "Website", DueDate: "2026-03-06", Completed: day},
+		{Path: "2. Next Actions/@home/Water plants.md", DonePath: "2. Next Actions/@home/Water plants 2026-03-05.md", Title: "Water plants", Context: "@home", DueDate: "2026-03-02", Recur: "every 2 weeks", NextDue: "2026-03-16", Completed: day.Add(time.Minute)},
+		{Path: "2. Next Actions/@home/Old.md", DonePath: "2. Next Actions/@home/Old.md", Title: "Old", Context: "@home", Completed: day.AddDate(0, 0, -10)},
+	}
+	for _, r := range results {
+		if err := repo.RecordCompletion(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recent, err := repo.ListCompletions("2026-03-01", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 || recent[0].Title != "Water plants" || recent[0].NextDue != "2026-03-16" || !recent[1].Completed.Equal(day) {
+		t.Fatalf("recent = %+v", recent)
+	}
+
+	stats, err := repo.CompletionStatsSince("2026-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 2 || stats.OnTime != 1 || stats.Overdue != 1 || stats.ByDay["2026-03-05"] != 2 || stats.ByContext["@home"] != 1 || stats.ByProject[""] != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestProjectHistory(t *testing.T) {
+	repo := setupTestDB(t)
+
+	at := time.Date(2026, time.May, 1, 9, 0, 0, 0, time.UTC)
+	for i, tr := range []vault.ProjectTransition{
+		{Path: "3. Projects/Active/Website.md", Title: "Website", From: "planning", To: "active"},
+		{Path: "3. Projects/Archive/Website.md", OldPath: "3. Projects/Active/Website.md", Title: "Website", From: "active", To: "completed", Note: "Shipped."},
+	} {
+		if tr.OldPath != "" {
+			if err := repo.MoveNoteRecords(tr.OldPath, tr.Path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tr.At = at.AddDate(0, 0, i)
+		if err := repo.RecordProjectTransition(&tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := repo.ProjectHistory("3. Projects/Archive/Website.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].To != "active" || history[1].Note != "Shipped." || !history[1].At.Equal(at.AddDate(0, 0, 1)) {
+		t.Errorf("history = %+v", history)
+	}
+}
+
+func TestWaitingNudges(t *testing.T) {
+	repo := setupTestDB(t)
+
+	path := "2. Next Actions/@waiting/Quote.md"
+	if n, err := repo.LastWaitingNudge(path); err != nil || n != nil {
+		t.Fatalf("expected no nudge, got %+v, %v", n, err)
+	}
+	at := time.Date(2026, time.May, 8, 9, 0, 0, 0, time.UTC)
+	if err := repo.RecordWaitingNudge(&WaitingNudge{Path: path, FollowUpDate: "2026-05-08", DraftID: "r-1", NudgedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RecordWaitingNudge(&WaitingNudge{Path: path, FollowUpDate: "2026-05-08", NudgedAt: at.AddDate(0, 0, 3)}); err != nil {
+		t.Fatal(err)
+	}
+	moved := "2. Next Actions/@waiting/Garden quote.md"
+	if err := repo.MoveNoteRecords(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	n, err := repo.LastWaitingNudge(moved)
+	if err != nil || n == nil || n.DraftID != "" || !n.NudgedAt.Equal(at.AddDate(0, 0, 3)) {
+		t.Errorf("last nudge = %+v, %v", n, err)
+	}
+}
@@ -117,6 +117,131 @@ func (d *DB) InitSchema() error {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (automation_id) REFERENCES automations(id) ON DELETE CASCADE
 	);
+
+	CREATE TABLE IF NOT EXISTS notes (
+		path TEXT PRIMARY KEY,
+		mod_time DATETIME NOT NULL,
+		size INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		title TEXT NOT NULL,
+		frontmatter_json TEXT NOT NULL DEFAULT '{}',
+		tags_json TEXT NOT NULL DEFAULT '[]',
+		links_json TEXT NOT NULL DEFAULT '[]',
+		headings_json TEXT NOT NULL DEFAULT '[]',
+		parse_error TEXT NOT NULL DEFAULT '',
+		indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS inbox_decisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		inbox_path TEXT NOT NULL,
+		title TEXT NOT NULL,
+		action TEXT NOT NULL,
+		note_path TEXT NOT NULL DEFAULT '',
+		decision_json TEXT NOT NULL DEFAULT '{}',
+		original_content TEXT NOT NULL DEFAULT '',
+		processed_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS inbox_suggestions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		inbox_path TEXT NOT NULL,
+		title TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target_folder TEXT NOT NULL DEFAULT '',
+		context TEXT NOT NULL DEFAULT '',
+		project TEXT NOT NULL DEFAULT '',
+		due_date TEXT NOT NULL DEFAULT '',
+		confidence REAL NOT NULL DEFAULT 0,
+		reason TEXT NOT NULL DEFAULT '',
+		decision_json TEXT NOT NULL DEFAULT '{}',
+		status TEXT NOT NULL DEFAULT 'pending',
+		final_decision_json TEXT NOT NULL DEFAULT '',
+		note_path TEXT NOT NULL DEFAULT '',
+		feedback TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		resolved_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS ai_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		feature TEXT NOT NULL DEFAULT '',
+		provider TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		prompt_tokens INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		cost_usd REAL NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		day TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS embeddings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		note_hash TEXT NOT NULL,
+		model TEXT NOT NULL,
+		heading TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		vector BLOB NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_embeddings_path ON embeddings (path);
+
+	CREATE TABLE IF NOT EXISTS conversations (
+		id TEXT PRIMARY KEY,
+		channel TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS conversation_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_id TEXT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+		role TEXT NOT NULL,
+		content TEXT NOT NULL DEFAULT '',
+		tool_calls TEXT NOT NULL DEFAULT '', -- JSON array
+		tool_call_id TEXT NOT NULL DEFAULT '',
+		tool_name TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation ON conversation_messages (conversation_id, id);
+
+	CREATE TABLE IF NOT EXISTS action_completions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		done_path TEXT NOT NULL,
+		title TEXT NOT NULL,
+		context TEXT NOT NULL DEFAULT '',
+		project TEXT NOT NULL DEFAULT '',
+		due_date TEXT NOT NULL DEFAULT '',
+		recur TEXT NOT NULL DEFAULT '',
+		next_due TEXT NOT NULL DEFAULT '',
+		day TEXT NOT NULL,
+		completed_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS project_transitions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		title TEXT NOT NULL,
+		from_status TEXT NOT NULL DEFAULT '',
+		to_status TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_project_transitions_path ON project_transitions (path);
+
+	CREATE TABLE IF NOT EXISTS waiting_nudges (
+		path TEXT PRIMARY KEY,
+		follow_up_date TEXT NOT NULL DEFAULT '',
+		draft_id TEXT NOT NULL DEFAULT '',
+		nudged_at DATETIME NOT NULL
+	);
 	`
 
 	_, err := d.Exec(schema)
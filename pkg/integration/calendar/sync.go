@@ -6,7 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
+	gosync "sync"
 	"time"
 
 	"github.com/mklimuk/vault-pilot/pkg/db"
@@ -21,9 +21,11 @@ type Syncer struct {
 	vaultPath  string
 	tmplEngine *vault.TemplateEngine
 	git        *sync.GitManager
+	index      *vault.Index
 	interval   time.Duration
 	horizon    time.Duration
 	stopCh     chan struct{}
+	pushMu     gosync.Mutex // serialises pushes so an event is not created twice
 }
 
 // NewSyncer creates a new calendar syncer.
@@ -33,6 +35,7 @@ func NewSyncer(
 	vaultPath string,
 	tmplEngine *vault.TemplateEngine,
 	git *sync.GitManager,
+	index *vault.Index,
 	interval, horizon time.Duration,
 ) *Syncer {
 	return &Syncer{
@@ -41,13 +44,15 @@ func NewSyncer(
 		vaultPath:  vaultPath,
 		tmplEngine: tmplEngine,
 		git:        git,
+		index:      index,
 		interval:   interval,
 		horizon:    horizon,
 		stopCh:     make(chan struct{}),
 	}
 }
 
-// Start begins the periodic sync loop.
+// Start runs a full sync and repeats it every interval. Vault changes are
+// also pushed as they arrive through HandleChanges.
 func (s *Syncer) Start() error {
 	// Run once immediately
 	if err := s.syncOnce(); err != nil {
@@ -105,6 +110,31 @@ func (s *Syncer) syncOnce() error {
 	return nil
 }
 
+// HandleChanges pushes due dates of notes changed in the vault to Calendar.
+func (s *Syncer) HandleChanges(events []vault.ChangeEvent) {
+	ctx := context.Background()
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
+	for _, e := range events {
+		if e.Kind == vault.ChangeDeleted {
+			continue
+		}
+		if e.Kind == vault.ChangeRenamed {
+			if err := s.repo.UpdateCalendarSyncVaultPath(e.OldPath, e.Path); err != nil {
+				log.Printf("Calendar push: rename %s: %v", e.OldPath, err)
+			}
+		}
+		if !vault.InFolder(e.Path, "2. Next Actions") && !vault.InFolder(e.Path, "3. Projects") {
+			continue
+		}
+		note, ok := s.index.Get(e.Path)
+		if !ok {
+			continue
+		}
+		s.pushNote(ctx, note)
+	}
+}
+
 // pull fetches events from Calendar and creates/updates vault notes.
 func (s *Syncer) pull(ctx context.Context) (bool, error) {
 	events, err := s.service.FetchUpcoming(ctx, s.horizon)
@@ -132,6 +162,7 @@ func (s *Syncer) pull(ctx context.Context) (bool, error) {
 				log.Printf("Calendar pull: insert sync for %s: %v", evt.ID, err)
 				continue
 			}
+			s.reindex(vaultFilePath)
 			modified = true
 		} else if rec.SyncKey != syncKey {
 			// Changed event — update vault note
@@ -143,6 +174,7 @@ func (s *Syncer) pull(ctx context.Context) (bool, error) {
 				log.Printf("Calendar pull: update sync for %s: %v", evt.ID, err)
 				continue
 			}
+			s.reindex(rec.VaultPath)
 			modified = true
 		}
 	}
@@ -150,102 +182,84 @@ func (s *Syncer) pull(ctx context.Context) (bool, error) {
 	return modified, nil
 }
 
-// push scans vault for notes with due_date and pushes new/changed events to Calendar.
+// push looks up indexed notes with due_date and pushes new/changed events to Calendar.
 func (s *Syncer) push(ctx context.Context) (bool, error) {
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
 	modified := false
 
-	dirs := []string{
-		filepath.Join(s.vaultPath, "2. Next Actions"),
-		filepath.Join(s.vaultPath, "3. Projects"),
-	}
-
-	for _, dir := range dirs {
-		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil // skip inaccessible
-			}
-			if info.IsDir() || !strings.HasSuffix(path, ".md") {
-				return nil
-			}
-
-			note, err := vault.ReadNote(path)
-			if err != nil {
-				return nil
-			}
-
-			fm, ok := note.Frontmatter.(map[string]interface{})
-			if !ok {
-				return nil
+	for _, folder := range []string{"2. Next Actions", "3. Projects"} {
+		for _, note := range s.index.Query(vault.Query{Folder: folder}) {
+			if s.pushNote(ctx, note) {
+				modified = true
 			}
+		}
+	}
 
-			// Skip notes that came from calendar pull (have calendar_id)
-			if _, hasCalID := fm["calendar_id"]; hasCalID {
-				return nil
-			}
+	return modified, nil
+}
 
-			dueDateStr, ok := fm["due_date"].(string)
-			if !ok || dueDateStr == "" {
-				return nil
-			}
+// pushNote creates or updates the Calendar event for a single note.
+func (s *Syncer) pushNote(ctx context.Context, note vault.IndexedNote) bool {
+	// Skip notes that came from calendar pull (have calendar_id)
+	if _, hasCalID := note.Frontmatter["calendar_id"]; hasCalID {
+		return false
+	}
 
-			relPath, _ := filepath.Rel(s.vaultPath, path)
-			rec, err := s.repo.GetCalendarSyncByVaultPath(relPath)
-			if err != nil {
-				return nil
-			}
+	dueDateStr := note.String("due_date")
+	if dueDateStr == "" {
+		return false
+	}
 
-			dueDate, err := time.Parse("2006-01-02", dueDateStr)
-			if err != nil {
-				return nil
-			}
+	relPath := note.Path
+	rec, err := s.repo.GetCalendarSyncByVaultPath(relPath)
+	if err != nil {
+		return false
+	}
 
-			title := ""
-			if t, ok := fm["title"].(string); ok {
-				title = t
-			} else {
-				// Use filename without extension
-				title = strings.TrimSuffix(filepath.Base(path), ".md")
-			}
+	dueDate, err := time.Parse("2006-01-02", dueDateStr)
+	if err != nil {
+		return false
+	}
 
-			evt := Event{
-				Summary:   title,
-				StartTime: dueDate,
-				EndTime:   dueDate.Add(time.Hour),
-			}
+	title := note.String("title")
+	if title == "" {
+		// Use filename without extension
+		title = note.Title
+	}
 
-			if rec == nil {
-				// New — create event
-				eventID, err := s.service.CreateEvent(ctx, evt)
-				if err != nil {
-					log.Printf("Calendar push: create event for %s: %v", relPath, err)
-					return nil
-				}
-				if err := s.repo.InsertCalendarSync(eventID, relPath, dueDateStr, "push"); err != nil {
-					log.Printf("Calendar push: insert sync for %s: %v", relPath, err)
-					return nil
-				}
-				modified = true
-			} else if rec.SyncKey != dueDateStr {
-				// Changed due_date — update event
-				if err := s.service.UpdateEvent(ctx, rec.EventID, evt); err != nil {
-					log.Printf("Calendar push: update event for %s: %v", relPath, err)
-					return nil
-				}
-				if err := s.repo.UpdateCalendarSync(rec.EventID, dueDateStr); err != nil {
-					log.Printf("Calendar push: update sync for %s: %v", relPath, err)
-					return nil
-				}
-				modified = true
-			}
+	evt := Event{
+		Summary:   title,
+		StartTime: dueDate,
+		EndTime:   dueDate.Add(time.Hour),
+	}
 
-			return nil
-		})
+	if rec == nil {
+		// New — create event
+		eventID, err := s.service.CreateEvent(ctx, evt)
 		if err != nil {
-			log.Printf("Calendar push: walk %s: %v", dir, err)
+			log.Printf("Calendar push: create event for %s: %v", relPath, err)
+			return false
+		}
+		if err := s.repo.InsertCalendarSync(eventID, relPath, dueDateStr, "push"); err != nil {
+			log.Printf("Calendar push: insert sync for %s: %v", relPath, err)
+			return false
+		}
+		return true
+	} else if rec.SyncKey != dueDateStr {
+		// Changed due_date — update event
+		if err := s.service.UpdateEvent(ctx, rec.EventID, evt); err != nil {
+			log.Printf("Calendar push: update event for %s: %v", relPath, err)
+			return false
 		}
+		if err := s.repo.UpdateCalendarSync(rec.EventID, dueDateStr); err != nil {
+			log.Printf("Calendar push: update sync for %s: %v", relPath, err)
+			return false
+		}
+		return true
 	}
 
-	return modified, nil
+	return false
 }
 
 func (s *Syncer) createCalendarNote(evt Event) (string, error) {
@@ -293,19 +307,6 @@ func (s *Syncer) createCalendarNote(evt Event) (string, error) {
 func (s *Syncer) updateCalendarNote(relVaultPath string, evt Event) error {
 	fullPath := filepath.Join(s.vaultPath, relVaultPath)
 
-	note, err := vault.ReadNote(fullPath)
-	if err != nil {
-		return fmt.Errorf("read note: %w", err)
-	}
-
-	fm, ok := note.Frontmatter.(map[string]interface{})
-	if !ok {
-		fm = make(map[string]interface{})
-	}
-
-	fm["due_date"] = evt.StartTime.Format("2006-01-02")
-	note.Frontmatter = fm
-
 	body := fmt.Sprintf("\n# %s\n", evt.Summary)
 	if evt.Description != "" {
 		body += "\n" + evt.Description + "\n"
@@ -317,9 +318,32 @@ func (s *Syncer) updateCalendarNote(relVaultPath string, evt Event) error {
 		evt.StartTime.Format("15:04"),
 		evt.EndTime.Format("15:04"))
 
-	note.Content = body
+	// Edit in place so hand-made changes to the rest of the frontmatter survive.
+	if s.index != nil {
+		s.index.LockNotes()
+		defer s.index.UnlockNotes()
+	}
+	err := vault.EditFrontmatter(fullPath, func(fm *vault.FrontmatterEditor) error {
+		if err := fm.Set("due_date", evt.StartTime.Format("2006-01-02")); err != nil {
+			return err
+		}
+		fm.SetBody(body)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update note: %w", err)
+	}
+	return nil
+}
 
-	return vault.WriteNote(note)
+// reindex refreshes the index entry for a note written by pull.
+func (s *Syncer) reindex(relPath string) {
+	if s.index == nil {
+		return
+	}
+	if err := s.index.Update(relPath); err != nil {
+		log.Printf("Calendar pull: reindex %s: %v", relPath, err)
+	}
 }
 
 func buildSyncKey(evt Event) string {
@@ -97,7 +97,7 @@ func TestPullNewEvent(t *testing.T) {
 		{ID: "evt-1", Summary: "Team Standup", StartTime: start, EndTime: end},
 	})
 
-	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, time.Hour, 14*24*time.Hour)
+	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, vault.NewIndex(vaultDir, nil), time.Hour, 14*24*time.Hour)
 
 	modified, err := syncer.pull(context.Background())
 	if err != nil {
@@ -144,7 +144,7 @@ func TestPullUpdatedEvent(t *testing.T) {
 	evt := Event{ID: "evt-1", Summary: "Team Standup", StartTime: start, EndTime: end}
 
 	mock := newMockCalendarAPI([]Event{evt})
-	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, time.Hour, 14*24*time.Hour)
+	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, vault.NewIndex(vaultDir, nil), time.Hour, 14*24*time.Hour)
 
 	// First pull creates the note
 	syncer.pull(context.Background())
@@ -184,7 +184,7 @@ func TestPullNoChange(t *testing.T) {
 	evt := Event{ID: "evt-1", Summary: "Team Standup", StartTime: start, EndTime: end}
 
 	mock := newMockCalendarAPI([]Event{evt})
-	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, time.Hour, 14*24*time.Hour)
+	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, vault.NewIndex(vaultDir, nil), time.Hour, 14*24*time.Hour)
 
 	// First pull
 	syncer.pull(context.Background())
@@ -218,8 +218,9 @@ func TestPushNewItem(t *testing.T) {
 	vault.WriteNote(note)
 
 	mock := newMockCalendarAPI(nil)
-	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, time.Hour, 14*24*time.Hour)
+	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, vault.NewIndex(vaultDir, nil), time.Hour, 14*24*time.Hour)
 
+	syncer.index.Scan()
 	modified, err := syncer.push(context.Background())
 	if err != nil {
 		t.Fatalf("push: %v", err)
@@ -267,8 +268,9 @@ func TestPushSkipsCalendarItems(t *testing.T) {
 	vault.WriteNote(note)
 
 	mock := newMockCalendarAPI(nil)
-	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, time.Hour, 14*24*time.Hour)
+	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, vault.NewIndex(vaultDir, nil), time.Hour, 14*24*time.Hour)
 
+	syncer.index.Scan()
 	modified, _ := syncer.push(context.Background())
 	if modified {
 		t.Error("expected modified=false; should skip calendar items")
@@ -297,9 +299,10 @@ func TestPushUpdatedItem(t *testing.T) {
 	vault.WriteNote(note)
 
 	mock := newMockCalendarAPI(nil)
-	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, time.Hour, 14*24*time.Hour)
+	syncer := NewSyncer(mock, repo, vaultDir, tmplEngine, nil, vault.NewIndex(vaultDir, nil), time.Hour, 14*24*time.Hour)
 
 	// First push creates event
+	syncer.index.Scan()
 	syncer.push(context.Background())
 
 	// Update due_date in the note
@@ -311,6 +314,7 @@ func TestPushUpdatedItem(t *testing.T) {
 	}
 	vault.WriteNote(note)
 
+	syncer.index.Scan()
 	modified, err := syncer.push(context.Background())
 	if err != nil {
 		t.Fatalf("push: %v", err)
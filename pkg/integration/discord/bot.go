@@ -1,20 +1,30 @@
 package discord
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/mklimuk/vault-pilot/pkg/assistant"
 	"github.com/mklimuk/vault-pilot/pkg/sync"
 	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
 
+// maxMessageLen is the longest message Discord accepts
+const maxMessageLen = 2000
+
 // Bot wraps the Discord session and dependencies
 type Bot struct {
-	Session    *discordgo.Session
-	VaultPath  string
-	TmplEngine *vault.TemplateEngine
-	Git        *sync.GitManager
+	Session         *discordgo.Session
+	VaultPath       string
+	TmplEngine      *vault.TemplateEngine
+	Git             *sync.GitManager
+	Assistant       *assistant.Assistant // answers !ask and direct messages, if set
+	AllowedUsers    map[string]bool      // user IDs the assistant answers; none when empty
+	NotifyChannelID string               // channel for Notify, e.g. follow-up reminders
 }
 
 // NewBot creates a new Discord bot
@@ -46,6 +56,17 @@ func (b *Bot) Stop() error {
 	return b.Session.Close()
 }
 
+// Notify posts text to the notification channel
+func (b *Bot) Notify(ctx context.Context, text string) error {
+	if b.NotifyChannelID == "" {
+		return fmt.Errorf("discord notification channel is not configured")
+	}
+	if _, err := b.Session.ChannelMessageSend(b.NotifyChannelID, assistant.Truncate(text, maxMessageLen), discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("discord notify: %w", err)
+	}
+	return nil
+}
+
 func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore messages from self
 	if m.Author.ID == s.State.User.ID {
@@ -58,9 +79,23 @@ func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		b.handleInbox(s, m, content)
 	} else if m.Content == "!status" {
 		b.handleStatus(s, m)
+	} else if !b.canAsk(m.Author.ID) {
+		return
+	} else if m.Content == "!reset" {
+		b.handleReset(s, m)
+	} else if strings.HasPrefix(m.Content, "!ask ") {
+		b.handleAsk(s, m, strings.TrimPrefix(m.Content, "!ask "))
+	} else if m.GuildID == "" && !strings.HasPrefix(m.Content, "!") {
+		// Direct messages go to the assistant without a command
+		b.handleAsk(s, m, m.Content)
 	}
 }
 
+// canAsk reports whether the assistant answers the user
+func (b *Bot) canAsk(userID string) bool {
+	return b.Assistant != nil && b.AllowedUsers[userID]
+}
+
 func (b *Bot) handleInbox(s *discordgo.Session, m *discordgo.MessageCreate, content string) {
 	// Create Inbox Item
 	title := "Discord Entry"
@@ -92,3 +127,26 @@ func (b *Bot) handleStatus(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// For MVP, just say "Online"
 	s.ChannelMessageSend(m.ChannelID, "🤖 Vault Pilot is Online. Ready to capture.")
 }
+
+// handleAsk answers a message with the assistant. Each channel is one
+// conversation.
+func (b *Bot) handleAsk(s *discordgo.Session, m *discordgo.MessageCreate, text string) {
+	s.ChannelTyping(m.ChannelID)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	reply, err := b.Assistant.Chat(ctx, "discord:"+m.ChannelID, "discord", text)
+	if err != nil {
+		log.Printf("Discord assistant error: %v", err)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+	s.ChannelMessageSend(m.ChannelID, assistant.Truncate(reply.Text, maxMessageLen))
+}
+
+func (b *Bot) handleReset(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if err := b.Assistant.Reset("discord:" + m.ChannelID); err != nil {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+	s.ChannelMessageSend(m.ChannelID, "Conversation cleared")
+}
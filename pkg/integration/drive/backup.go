@@ -3,12 +3,12 @@ package drive
 import (
 	"context"
 	"log"
-	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
 
 // Backup performs incremental vault backup to Google Drive.
@@ -16,24 +16,29 @@ type Backup struct {
 	service   DriveAPI
 	repo      *db.Repository
 	vaultPath string
+	index     *vault.Index
 	interval  time.Duration
 	stopCh    chan struct{}
+	mu        sync.Mutex // serialises uploads so a note is not created twice
 }
 
-// NewBackup creates a new Drive backup service.
-func NewBackup(service DriveAPI, repo *db.Repository, vaultPath string, interval time.Duration) *Backup {
+// NewBackup creates a new Drive backup service. Every interval it uploads
+// whatever the change events missed.
+func NewBackup(service DriveAPI, repo *db.Repository, vaultPath string, index *vault.Index, interval time.Duration) *Backup {
 	return &Backup{
 		service:   service,
 		repo:      repo,
 		vaultPath: vaultPath,
+		index:     index,
 		interval:  interval,
 		stopCh:    make(chan struct{}),
 	}
 }
 
-// Start begins the periodic backup loop.
+// Start uploads every new or modified note, then does so again every
+// interval. Changes in between are uploaded as they arrive through
+// HandleChanges.
 func (b *Backup) Start() error {
-	// Run once immediately
 	if err := b.backupOnce(); err != nil {
 		log.Printf("Drive backup initial error: %v", err)
 	}
@@ -61,56 +66,111 @@ func (b *Backup) Stop() {
 	close(b.stopCh)
 }
 
-func (b *Backup) backupOnce() error {
-	return filepath.Walk(b.vaultPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
+// HandleChanges uploads notes changed in the vault.
+func (b *Backup) HandleChanges(events []vault.ChangeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range events {
+		switch e.Kind {
+		case vault.ChangeDeleted:
+			// Keep the Drive copy of deleted notes.
+			continue
+		case vault.ChangeRenamed:
+			b.backupRenamed(e.OldPath, e.Path)
+		default:
+			if note, ok := b.index.Get(e.Path); ok {
+				b.backupNote(note)
+			}
 		}
+	}
+}
 
-		// Skip hidden dirs (.git, .obsidian)
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") {
-			return filepath.SkipDir
-		}
+func (b *Backup) backupOnce() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, note := range b.index.All() {
+		b.backupNote(note)
+	}
+	return nil
+}
 
-		// Only back up .md files
-		if info.IsDir() || !strings.HasSuffix(info.Name(), ".md") {
-			return nil
-		}
+// backupNote uploads a single indexed note if it is new or modified.
+func (b *Backup) backupNote(note vault.IndexedNote) {
+	relPath := note.Path
+	path := filepath.Join(b.vaultPath, relPath)
+
+	rec, err := b.repo.GetDriveSyncByLocalPath(relPath)
+	if err != nil {
+		log.Printf("Drive backup: db error for %s: %v", relPath, err)
+		return
+	}
 
-		relPath, _ := filepath.Rel(b.vaultPath, path)
+	modTime := note.ModTime.Truncate(time.Second)
 
-		rec, err := b.repo.GetDriveSyncByLocalPath(relPath)
+	if rec == nil {
+		// New file — upload
+		ctx := context.Background()
+		fileID, err := b.service.UploadFile(ctx, path, relPath, "")
 		if err != nil {
-			log.Printf("Drive backup: db error for %s: %v", relPath, err)
-			return nil
+			log.Printf("Drive backup: upload %s: %v", relPath, err)
+			return
 		}
+		if err := b.repo.InsertDriveSync(fileID, relPath, modTime, "upload"); err != nil {
+			log.Printf("Drive backup: insert sync %s: %v", relPath, err)
+		}
+	} else if modTime.After(rec.LastSyncedAt) {
+		// Modified file — re-upload
+		ctx := context.Background()
+		_, err := b.service.UploadFile(ctx, path, relPath, rec.DriveFileID)
+		if err != nil {
+			log.Printf("Drive backup: re-upload %s: %v", relPath, err)
+			return
+		}
+		if err := b.repo.UpdateDriveSync(rec.DriveFileID, modTime); err != nil {
+			log.Printf("Drive backup: update sync %s: %v", relPath, err)
+		}
+	}
+}
 
-		modTime := info.ModTime().Truncate(time.Second)
-
+// backupRenamed moves the Drive copy of a renamed note to its new name.
+func (b *Backup) backupRenamed(oldPath, newPath string) {
+	rec, err := b.repo.GetDriveSyncByLocalPath(oldPath)
+	if err != nil {
+		log.Printf("Drive backup: db error for %s: %v", oldPath, err)
+		return
+	}
+	note, ok := b.index.Get(newPath)
+	if !ok {
+		return
+	}
+	moved := false
+	if rec == nil {
+		// The Mover points the record at the new path before the watcher
+		// reports the rename; the Drive copy still has the old name.
+		rec, err = b.repo.GetDriveSyncByLocalPath(newPath)
+		if err != nil {
+			log.Printf("Drive backup: db error for %s: %v", newPath, err)
+			return
+		}
 		if rec == nil {
-			// New file — upload
-			ctx := context.Background()
-			fileID, err := b.service.UploadFile(ctx, path, relPath, "")
-			if err != nil {
-				log.Printf("Drive backup: upload %s: %v", relPath, err)
-				return nil
-			}
-			if err := b.repo.InsertDriveSync(fileID, relPath, modTime, "upload"); err != nil {
-				log.Printf("Drive backup: insert sync %s: %v", relPath, err)
-			}
-		} else if modTime.After(rec.LastSyncedAt) {
-			// Modified file — re-upload
-			ctx := context.Background()
-			_, err := b.service.UploadFile(ctx, path, relPath, rec.DriveFileID)
-			if err != nil {
-				log.Printf("Drive backup: re-upload %s: %v", relPath, err)
-				return nil
-			}
-			if err := b.repo.UpdateDriveSync(rec.DriveFileID, modTime); err != nil {
-				log.Printf("Drive backup: update sync %s: %v", relPath, err)
-			}
+			b.backupNote(note)
+			return
 		}
+		moved = true
+	}
 
-		return nil
-	})
+	ctx := context.Background()
+	if _, err := b.service.UploadFile(ctx, filepath.Join(b.vaultPath, newPath), newPath, rec.DriveFileID); err != nil {
+		log.Printf("Drive backup: rename %s: %v", oldPath, err)
+		return
+	}
+	if !moved {
+		if err := b.repo.UpdateDriveSyncLocalPath(oldPath, newPath); err != nil {
+			log.Printf("Drive backup: update sync path %s: %v", oldPath, err)
+			return
+		}
+	}
+	if err := b.repo.UpdateDriveSync(rec.DriveFileID, note.ModTime.Truncate(time.Second)); err != nil {
+		log.Printf("Drive backup: update sync %s: %v", newPath, err)
+	}
 }
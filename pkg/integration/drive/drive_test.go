@@ -18,6 +18,7 @@ type mockDriveAPI struct {
 	files        []FileInfo
 	uploadedIDs  map[string]string // localPath -> id
 	updatedFiles map[string]bool   // fileID -> true
+	fileNames    map[string]string // fileID -> last name uploaded
 	downloads    map[string]string // fileID -> content
 	nextID       int
 }
@@ -26,6 +27,7 @@ func newMockDriveAPI() *mockDriveAPI {
 	return &mockDriveAPI{
 		uploadedIDs:  make(map[string]string),
 		updatedFiles: make(map[string]bool),
+		fileNames:    make(map[string]string),
 		downloads:    make(map[string]string),
 		nextID:       100,
 	}
@@ -38,11 +40,13 @@ func (m *mockDriveAPI) ListFiles(_ context.Context) ([]FileInfo, error) {
 func (m *mockDriveAPI) UploadFile(_ context.Context, localPath, fileName, existingFileID string) (string, error) {
 	if existingFileID != "" {
 		m.updatedFiles[existingFileID] = true
+		m.fileNames[existingFileID] = fileName
 		return existingFileID, nil
 	}
 	m.nextID++
 	id := "drv-" + fileName
 	m.uploadedIDs[localPath] = id
+	m.fileNames[id] = fileName
 	return id, nil
 }
 
@@ -95,8 +99,9 @@ func TestBackupNewFile(t *testing.T) {
 	os.WriteFile(notePath, []byte("# Test"), 0644)
 
 	mock := newMockDriveAPI()
-	backup := NewBackup(mock, repo, vaultDir, time.Hour)
+	backup := NewBackup(mock, repo, vaultDir, vault.NewIndex(vaultDir, nil), time.Hour)
 
+	backup.index.Scan()
 	if err := backup.backupOnce(); err != nil {
 		t.Fatalf("backup: %v", err)
 	}
@@ -124,13 +129,15 @@ func TestBackupUnmodifiedFile(t *testing.T) {
 	os.WriteFile(notePath, []byte("# Test"), 0644)
 
 	mock := newMockDriveAPI()
-	backup := NewBackup(mock, repo, vaultDir, time.Hour)
+	backup := NewBackup(mock, repo, vaultDir, vault.NewIndex(vaultDir, nil), time.Hour)
 
 	// First backup
+	backup.index.Scan()
 	backup.backupOnce()
 	uploadCount := len(mock.uploadedIDs)
 
 	// Second backup — file not modified
+	backup.index.Scan()
 	backup.backupOnce()
 
 	if len(mock.updatedFiles) != 0 {
@@ -149,9 +156,10 @@ func TestBackupModifiedFile(t *testing.T) {
 	os.WriteFile(notePath, []byte("# Test"), 0644)
 
 	mock := newMockDriveAPI()
-	backup := NewBackup(mock, repo, vaultDir, time.Hour)
+	backup := NewBackup(mock, repo, vaultDir, vault.NewIndex(vaultDir, nil), time.Hour)
 
 	// First backup
+	backup.index.Scan()
 	backup.backupOnce()
 
 	// Modify the file (change mod time to be after the recorded sync time)
@@ -159,6 +167,7 @@ func TestBackupModifiedFile(t *testing.T) {
 	os.WriteFile(notePath, []byte("# Updated"), 0644)
 
 	// Second backup
+	backup.index.Scan()
 	backup.backupOnce()
 
 	if len(mock.updatedFiles) == 0 {
@@ -166,6 +175,41 @@ func TestBackupModifiedFile(t *testing.T) {
 	}
 }
 
+func TestBackupRenamedByMover(t *testing.T) {
+	repo := setupTestDB(t)
+	vaultDir, _ := setupVault(t)
+	oldPath := filepath.Join("1. Inbox", "test-note.md")
+	os.WriteFile(filepath.Join(vaultDir, oldPath), []byte("# Test"), 0644)
+
+	mock := newMockDriveAPI()
+	index := vault.NewIndex(vaultDir, nil)
+	backup := NewBackup(mock, repo, vaultDir, index, time.Hour)
+	index.Scan()
+	backup.backupOnce()
+	rec, _ := repo.GetDriveSyncByLocalPath(oldPath)
+	if rec == nil {
+		t.Fatal("expected sync record")
+	}
+	uploads := len(mock.uploadedIDs)
+
+	// The Mover moves the sync record before the watcher reports the rename
+	newPath := filepath.Join("1. Inbox", "renamed.md")
+	if _, err := vault.NewMover(index, nil, repo.MoveNoteRecords).Move(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	backup.HandleChanges([]vault.ChangeEvent{{Kind: vault.ChangeRenamed, Path: newPath, OldPath: oldPath}})
+
+	if got := mock.fileNames[rec.DriveFileID]; got != newPath {
+		t.Errorf("Drive file name = %q, want %q", got, newPath)
+	}
+	if n := len(mock.uploadedIDs); n != uploads {
+		t.Errorf("uploads = %d, want no new one", n-uploads)
+	}
+	if moved, _ := repo.GetDriveSyncByLocalPath(newPath); moved == nil || moved.DriveFileID != rec.DriveFileID {
+		t.Errorf("sync record = %+v", moved)
+	}
+}
+
 func TestBackupSkipsHiddenDirs(t *testing.T) {
 	repo := setupTestDB(t)
 	vaultDir, _ := setupVault(t)
@@ -176,8 +220,9 @@ func TestBackupSkipsHiddenDirs(t *testing.T) {
 	os.WriteFile(filepath.Join(gitDir, "config.md"), []byte("# config"), 0644)
 
 	mock := newMockDriveAPI()
-	backup := NewBackup(mock, repo, vaultDir, time.Hour)
+	backup := NewBackup(mock, repo, vaultDir, vault.NewIndex(vaultDir, nil), time.Hour)
 
+	backup.index.Scan()
 	backup.backupOnce()
 
 	// .git/config.md should not be uploaded
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/mail"
 
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
@@ -45,6 +47,23 @@ func (s *Service) FetchUnreadEmails(ctx context.Context) ([]*gmail.Message, erro
 	return messages, nil
 }
 
+// CreateDraft saves a plain text email as a draft and returns the draft ID.
+// to must be a single valid address.
+func (s *Service) CreateDraft(ctx context.Context, to, subject, body string) (string, error) {
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return "", fmt.Errorf("invalid recipient %q: %w", to, err)
+	}
+	raw := fmt.Sprintf("To: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"UTF-8\"\r\n\r\n%s",
+		addr.String(), mime.QEncoding.Encode("utf-8", subject), body)
+	draft := &gmail.Draft{Message: &gmail.Message{Raw: base64.URLEncoding.EncodeToString([]byte(raw))}}
+	d, err := s.srv.Users.Drafts.Create("me", draft).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("unable to create draft: %w", err)
+	}
+	return d.Id, nil
+}
+
 // GetBody extracts the body from a message
 func GetBody(msg *gmail.Message) string {
 	// Logic to decode body from parts (text/plain vs text/html)
@@ -1,22 +1,32 @@
 package telegram
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mklimuk/vault-pilot/pkg/assistant"
 	"github.com/mklimuk/vault-pilot/pkg/sync"
 	"github.com/mklimuk/vault-pilot/pkg/vault"
 )
 
+// maxMessageLen is the longest message Telegram accepts
+const maxMessageLen = 4096
+
 // Bot wraps the Telegram bot API and dependencies
 type Bot struct {
-	API        *tgbotapi.BotAPI
-	VaultPath  string
-	TmplEngine *vault.TemplateEngine
-	Git        *sync.GitManager
-	stopCh     chan struct{}
+	API          *tgbotapi.BotAPI
+	VaultPath    string
+	TmplEngine   *vault.TemplateEngine
+	Git          *sync.GitManager
+	Assistant    *assistant.Assistant // answers messages that are not commands, if set
+	AllowedChats map[int64]bool       // chats the assistant answers; none when empty
+	NotifyChatID int64                // chat for Notify, e.g. follow-up reminders
+	stopCh       chan struct{}
 }
 
 // NewBot creates a new Telegram bot
@@ -67,6 +77,17 @@ func (b *Bot) Stop() {
 	b.API.StopReceivingUpdates()
 }
 
+// Notify sends text to the notification chat
+func (b *Bot) Notify(ctx context.Context, text string) error {
+	if b.NotifyChatID == 0 {
+		return fmt.Errorf("telegram notification chat is not configured")
+	}
+	if _, err := b.API.Send(tgbotapi.NewMessage(b.NotifyChatID, assistant.Truncate(text, maxMessageLen))); err != nil {
+		return fmt.Errorf("telegram notify: %w", err)
+	}
+	return nil
+}
+
 func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 	text := msg.Text
 	if strings.HasPrefix(text, "/inbox ") {
@@ -74,6 +95,52 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 		b.handleInbox(msg, content)
 	} else if text == "/status" {
 		b.handleStatus(msg)
+	} else if !b.canAsk(msg.Chat.ID) {
+		return
+	} else if text == "/reset" {
+		b.handleReset(msg)
+	} else if text != "" && !strings.HasPrefix(text, "/") {
+		// Answers can take minutes, so they must not hold up the update
+		// loop. The assistant serialises messages of one conversation.
+		go b.handleAsk(msg, text)
+	}
+}
+
+// canAsk reports whether the assistant answers the chat
+func (b *Bot) canAsk(chatID int64) bool {
+	return b.Assistant != nil && b.AllowedChats[chatID]
+}
+
+// conversationID is the assistant conversation of a chat
+func conversationID(msg *tgbotapi.Message) string {
+	return "telegram:" + strconv.FormatInt(msg.Chat.ID, 10)
+}
+
+// handleAsk answers a message with the assistant
+func (b *Bot) handleAsk(msg *tgbotapi.Message, text string) {
+	b.API.Request(tgbotapi.NewChatAction(msg.Chat.ID, tgbotapi.ChatTyping))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	answer := ""
+	reply, err := b.Assistant.Chat(ctx, conversationID(msg), "telegram", text)
+	if err != nil {
+		log.Printf("Telegram assistant error: %v", err)
+		answer = fmt.Sprintf("Error: %v", err)
+	} else {
+		answer = assistant.Truncate(reply.Text, maxMessageLen)
+	}
+	if _, err := b.API.Send(tgbotapi.NewMessage(msg.Chat.ID, answer)); err != nil {
+		log.Printf("Failed to send Telegram assistant reply: %v", err)
+	}
+}
+
+func (b *Bot) handleReset(msg *tgbotapi.Message) {
+	answer := "Conversation cleared"
+	if err := b.Assistant.Reset(conversationID(msg)); err != nil {
+		answer = fmt.Sprintf("Error: %v", err)
+	}
+	if _, err := b.API.Send(tgbotapi.NewMessage(msg.Chat.ID, answer)); err != nil {
+		log.Printf("Failed to send Telegram reset reply: %v", err)
 	}
 }
 
@@ -112,13 +179,13 @@ func (b *Bot) handleStatus(msg *tgbotapi.Message) {
 }
 
 // ParseCommand extracts the command and content from a message text.
-// Returns the command (e.g. "/inbox", "/status") and the remaining content.
+// Returns the command (e.g. "/inbox", "/status", "/reset") and the remaining content.
 func ParseCommand(text string) (command, content string) {
 	if strings.HasPrefix(text, "/inbox ") {
 		return "/inbox", strings.TrimPrefix(text, "/inbox ")
 	}
-	if text == "/status" {
-		return "/status", ""
+	if text == "/status" || text == "/reset" {
+		return text, ""
 	}
 	return "", text
 }
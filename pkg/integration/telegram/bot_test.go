@@ -1,6 +1,10 @@
 package telegram
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/mklimuk/vault-pilot/pkg/assistant"
+)
 
 func TestParseCommand(t *testing.T) {
 	tests := []struct {
@@ -27,6 +31,12 @@ func TestParseCommand(t *testing.T) {
 			wantCmd:     "/status",
 			wantContent: "",
 		},
+		{
+			name:        "reset command",
+			input:       "/reset",
+			wantCmd:     "/reset",
+			wantContent: "",
+		},
 		{
 			name:        "unknown command",
 			input:       "/help",
@@ -102,3 +112,18 @@ func TestTruncateTitle(t *testing.T) {
 		})
 	}
 }
+
+func TestCanAsk(t *testing.T) {
+	b := &Bot{}
+	if b.canAsk(42) {
+		t.Error("no assistant, but chat 42 may ask")
+	}
+	b.Assistant = &assistant.Assistant{}
+	if b.canAsk(42) {
+		t.Error("chat 42 may ask without an allowlist")
+	}
+	b.AllowedChats = map[int64]bool{42: true}
+	if !b.canAsk(42) || b.canAsk(7) {
+		t.Error("only chat 42 should be allowed")
+	}
+}
@@ -0,0 +1,372 @@
+// Package semantic keeps a vector index of vault passages in SQLite for
+// semantic search and for grounding AI prompts in the vault.
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// Defaults for a new Index
+const (
+	DefaultChunkLen       = 1200
+	DefaultBatchSize      = 32
+	DefaultRetrieveLimit  = 5
+	DefaultMaxContextLen  = 4000
+	DefaultChangeDebounce = time.Minute
+)
+
+// Result is a note matching a search, with its best matching passage
+type Result struct {
+	Path    string  `json:"path"`
+	Title   string  `json:"title"`
+	Heading string  `json:"heading,omitempty"`
+	Text    string  `json:"text"`
+	Score   float32 `json:"score"`
+}
+
+// entry is the embedded state of one note
+type entry struct {
+	hash     string
+	passages []db.Embedding
+}
+
+// Index embeds the passages of the notes in a vault.Index and searches them.
+// Only notes whose content changed since they were embedded are sent to the
+// embedder again; changing the embedding model re-embeds everything.
+type Index struct {
+	ChunkLen       int // maximum passage length in bytes
+	BatchSize      int // passages per embedding request
+	RetrieveLimit  int // notes added to a prompt by Retrieve
+	MaxContextLen  int // bytes of passages added to a prompt by Retrieve
+	ChangeDebounce time.Duration
+	Exclude        []string // folders that are not indexed
+
+	notes    *vault.Index
+	embedder ai.Embedder
+	repo     *db.Repository
+
+	syncMu  sync.Mutex // one Sync at a time
+	mu      sync.RWMutex
+	entries map[string]*entry
+
+	changed  chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// Ensure Index can ground prompts
+var _ ai.Retriever = (*Index)(nil)
+
+// NewIndex creates an index of the notes in notes. Templates and prompts are
+// excluded.
+func NewIndex(notes *vault.Index, embedder ai.Embedder, repo *db.Repository) *Index {
+	return &Index{
+		ChunkLen:       DefaultChunkLen,
+		BatchSize:      DefaultBatchSize,
+		RetrieveLimit:  DefaultRetrieveLimit,
+		MaxContextLen:  DefaultMaxContextLen,
+		ChangeDebounce: DefaultChangeDebounce,
+		Exclude:        []string{"0. GTD System/Templates", ai.PromptDir},
+		notes:          notes,
+		embedder:       embedder,
+		repo:           repo,
+		entries:        make(map[string]*entry),
+		changed:        make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// Load reads the passages embedded with the current model from the database
+func (x *Index) Load() error {
+	stored, err := x.repo.ListEmbeddings(x.embedder.EmbeddingModel())
+	if err != nil {
+		return fmt.Errorf("load embeddings: %w", err)
+	}
+	entries := make(map[string]*entry)
+	for _, e := range stored {
+		en := entries[e.Path]
+		if en == nil {
+			en = &entry{hash: e.NoteHash}
+			entries[e.Path] = en
+		}
+		en.passages = append(en.passages, e)
+	}
+	x.mu.Lock()
+	x.entries = entries
+	x.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of embedded passages
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	n := 0
+	for _, en := range x.entries {
+		n += len(en.passages)
+	}
+	return n
+}
+
+// pendingNote is a note waiting to be embedded
+type pendingNote struct {
+	path, hash, title string
+	chunks            []vault.Chunk
+}
+
+// Sync embeds the notes that changed and drops the ones that are gone. It
+// returns the number of notes embedded. On an embedding error the notes done
+// so far are kept and the rest wait for the next Sync.
+func (x *Index) Sync(ctx context.Context) (int, error) {
+	x.syncMu.Lock()
+	defer x.syncMu.Unlock()
+
+	seen := make(map[string]bool)
+	var batch []pendingNote
+	queued, embedded := 0, 0
+	flush := func() error {
+		n, err := x.embed(ctx, batch)
+		embedded += n
+		batch, queued = nil, 0
+		return err
+	}
+	for _, n := range x.notes.All() {
+		if x.excluded(n.Path) {
+			continue
+		}
+		seen[n.Path] = true
+		if x.hash(n.Path) == n.Hash {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(x.notes.VaultPath(), n.Path))
+		if err != nil {
+			log.Printf("Semantic index: read %s: %v", n.Path, err)
+			continue
+		}
+		p := pendingNote{path: n.Path, hash: vault.ContentHash(data), title: n.Title, chunks: vault.SplitChunks(string(data), x.ChunkLen)}
+		if x.hash(n.Path) == p.hash {
+			continue
+		}
+		batch = append(batch, p)
+		queued += len(p.chunks)
+		if queued >= x.BatchSize {
+			if err := flush(); err != nil {
+				return embedded, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return embedded, err
+	}
+
+	x.mu.RLock()
+	var gone []string
+	for path := range x.entries {
+		if !seen[path] {
+			gone = append(gone, path)
+		}
+	}
+	x.mu.RUnlock()
+	for _, path := range gone {
+		if err := x.repo.DeleteEmbeddings(path); err != nil {
+			return embedded, err
+		}
+		x.mu.Lock()
+		delete(x.entries, path)
+		x.mu.Unlock()
+	}
+	return embedded, nil
+}
+
+// embed embeds and stores a batch of notes
+func (x *Index) embed(ctx context.Context, notes []pendingNote) (int, error) {
+	var texts []string
+	for _, n := range notes {
+		for _, c := range n.chunks {
+			texts = append(texts, passageText(n.title, c))
+		}
+	}
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += x.BatchSize {
+		end := min(start+x.BatchSize, len(texts))
+		v, err := x.embedder.Embed(ctx, texts[start:end])
+		if err != nil {
+			return 0, fmt.Errorf("embed passages: %w", err)
+		}
+		vectors = append(vectors, v...)
+	}
+
+	model := x.embedder.EmbeddingModel()
+	for _, n := range notes {
+		passages := make([]db.Embedding, len(n.chunks))
+		for i, c := range n.chunks {
+			passages[i] = db.Embedding{
+				Path:     n.path,
+				NoteHash: n.hash,
+				Model:    model,
+				Heading:  c.Heading,
+				Content:  c.Text,
+				Vector:   ai.Normalize(vectors[i]),
+			}
+		}
+		vectors = vectors[len(n.chunks):]
+		if err := x.repo.ReplaceEmbeddings(n.path, passages); err != nil {
+			return 0, err
+		}
+		x.mu.Lock()
+		x.entries[n.path] = &entry{hash: n.hash, passages: passages}
+		x.mu.Unlock()
+	}
+	return len(notes), nil
+}
+
+// passageText is the text embedded for a passage. The title and heading give
+// short passages their context.
+func passageText(title string, c vault.Chunk) string {
+	if c.Heading != "" {
+		title += vault.SectionPathSeparator + c.Heading
+	}
+	return title + "\n\n" + c.Text
+}
+
+func (x *Index) hash(path string) string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if en, ok := x.entries[path]; ok {
+		return en.hash
+	}
+	return ""
+}
+
+func (x *Index) excluded(path string) bool {
+	for _, folder := range x.Exclude {
+		if vault.InFolder(path, folder) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns up to limit notes whose passages are most similar to query
+func (x *Index) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	vectors, err := x.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	q := ai.Normalize(vectors[0])
+
+	x.mu.RLock()
+	results := make([]Result, 0, len(x.entries))
+	for path, en := range x.entries {
+		best := Result{Score: 0}
+		for _, p := range en.passages {
+			if score := ai.Dot(q, p.Vector); score > best.Score {
+				best = Result{Path: path, Heading: p.Heading, Text: p.Content, Score: score}
+			}
+		}
+		if best.Path != "" {
+			results = append(results, best)
+		}
+	}
+	x.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	for i := range results {
+		results[i].Title = strings.TrimSuffix(filepath.Base(results[i].Path), ".md")
+	}
+	return results, nil
+}
+
+// Retrieve returns the passages most relevant to query as Markdown, for a
+// prompt. It implements ai.Retriever.
+func (x *Index) Retrieve(ctx context.Context, query string) (string, error) {
+	results, err := x.Search(ctx, query, x.RetrieveLimit)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, r := range results {
+		heading := "[[" + r.Title + "]]"
+		if r.Heading != "" {
+			heading += vault.SectionPathSeparator + r.Heading
+		}
+		passage := fmt.Sprintf("### %s\n%s\n\n", heading, r.Text)
+		if sb.Len() > 0 && sb.Len()+len(passage) > x.MaxContextLen {
+			break
+		}
+		sb.WriteString(passage)
+	}
+	return sb.String(), nil
+}
+
+// HandleChanges schedules a Sync after vault changes. Changes are collected
+// for ChangeDebounce, so a note being typed is embedded once.
+func (x *Index) HandleChanges(events []vault.ChangeEvent) {
+	select {
+	case x.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Start syncs in the background now, every interval and after changes,
+// until Stop is called
+func (x *Index) Start(interval time.Duration) {
+	go func() {
+		x.sync()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ticker.C:
+				x.sync()
+			case <-x.changed:
+				if debounce == nil {
+					debounce = time.After(x.ChangeDebounce)
+				}
+			case <-debounce:
+				debounce = nil
+				x.sync()
+			case <-x.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background sync. It may be called more than once.
+func (x *Index) Stop() {
+	x.stopOnce.Do(func() { close(x.stopCh) })
+}
+
+func (x *Index) sync() {
+	start := time.Now()
+	n, err := x.Sync(context.Background())
+	if err != nil {
+		log.Printf("Semantic index sync error: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Semantic index: embedded %d note(s) in %s", n, time.Since(start).Round(time.Millisecond))
+	}
+}
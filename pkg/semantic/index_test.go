@@ -0,0 +1,103 @@
+package semantic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mklimuk/vault-pilot/pkg/ai"
+	"github.com/mklimuk/vault-pilot/pkg/db"
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// countingEmbedder counts the passages sent to the hash embedder
+type countingEmbedder struct {
+	*ai.HashEmbedder
+	texts int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.HashEmbedder.Embed(ctx, texts)
+}
+
+func writeNote(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexSyncAndSearch(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "3. Projects/Garden Fence.md", "---\nstatus: active\n---\n# Garden Fence\n\nBob quoted 300 EUR for the fence panels.\n\n## Risks\nThe neighbour has not agreed to the fence height yet.\n")
+	writeNote(t, dir, "5. Reference/Tax Return.md", "# Tax Return\n\nSubmit the tax return documents to the accountant before April.\n")
+	writeNote(t, dir, "0. GTD System/Templates/Project Template.md", "# Project\n\nfence fence fence\n")
+
+	notes := vault.NewIndex(dir, nil)
+	if err := notes.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := database.InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+	repo := db.NewRepository(database)
+
+	embedder := &countingEmbedder{HashEmbedder: ai.NewHashEmbedder()}
+	x := NewIndex(notes, embedder, repo)
+	if n, err := x.Sync(context.Background()); err != nil || n != 2 {
+		t.Fatalf("Sync = %d, %v; want 2 notes", n, err)
+	}
+	if x.Len() != 3 {
+		t.Errorf("expected 3 passages, got %d", x.Len())
+	}
+
+	results, err := x.Search(context.Background(), "fence height", 5)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) == 0 || results[0].Path != "3. Projects/Garden Fence.md" || results[0].Heading != "Garden Fence > Risks" || results[0].Title != "Garden Fence" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	notesText, err := x.Retrieve(context.Background(), "fence height")
+	if err != nil || !strings.HasPrefix(notesText, "### [[Garden Fence]] > Garden Fence > Risks\nThe neighbour") {
+		t.Errorf("Retrieve = %q, %v", notesText, err)
+	}
+
+	// Unchanged notes are not embedded again, also after a restart
+	embedder.texts = 0
+	writeNote(t, dir, "5. Reference/Tax Return.md", "# Tax Return\n\nThe accountant has the documents.\n")
+	os.Remove(filepath.Join(dir, "3. Projects/Garden Fence.md"))
+	if err := notes.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewIndex(notes, embedder, repo)
+	if err := restarted.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if n, err := restarted.Sync(context.Background()); err != nil || n != 1 || embedder.texts != 1 {
+		t.Fatalf("Sync = %d, %v after %d passages; want 1 note", n, err, embedder.texts)
+	}
+	if results, _ := restarted.Search(context.Background(), "fence", 5); len(results) != 0 {
+		t.Errorf("expected the deleted note to be dropped, got %+v", results)
+	}
+	if stored, _ := repo.ListEmbeddings(embedder.EmbeddingModel()); len(stored) != 1 {
+		t.Errorf("expected 1 stored passage, got %d", len(stored))
+	}
+
+	// Stop may be called more than once
+	restarted.Stop()
+	restarted.Stop()
+}
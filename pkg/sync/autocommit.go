@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"fmt"
+	"log"
+	gosync "sync"
+	"time"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// AutoCommitter commits vault edits reported by the watcher once they settle
+type AutoCommitter struct {
+	git   *GitManager
+	delay time.Duration
+
+	mu      gosync.Mutex
+	timer   *time.Timer
+	changed map[string]bool
+}
+
+// NewAutoCommitter creates an AutoCommitter that waits delay after the last
+// change before committing
+func NewAutoCommitter(git *GitManager, delay time.Duration) *AutoCommitter {
+	return &AutoCommitter{
+		git:     git,
+		delay:   delay,
+		changed: make(map[string]bool),
+	}
+}
+
+// HandleChanges records changed notes and (re)schedules a commit
+func (a *AutoCommitter) HandleChanges(events []vault.ChangeEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, e := range events {
+		a.changed[e.Path] = true
+	}
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	a.timer = time.AfterFunc(a.delay, a.commit)
+}
+
+// Stop cancels any scheduled commit
+func (a *AutoCommitter) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+}
+
+// Flush commits the changes of a scheduled commit now instead of waiting for
+// the delay. It does nothing when no commit is scheduled or one is running.
+func (a *AutoCommitter) Flush() {
+	a.mu.Lock()
+	pending := a.timer != nil && a.timer.Stop()
+	a.mu.Unlock()
+	if pending {
+		a.commit()
+	}
+}
+
+func (a *AutoCommitter) commit() {
+	a.mu.Lock()
+	count := len(a.changed)
+	a.changed = make(map[string]bool)
+	a.timer = nil
+	a.mu.Unlock()
+
+	// Changes made through the API are usually committed already.
+	dirty, err := a.git.HasChanges()
+	if err != nil {
+		log.Printf("Auto-commit: %v", err)
+		return
+	}
+	if !dirty {
+		return
+	}
+	if err := a.git.Sync(fmt.Sprintf("Auto-commit: %d note(s) changed", count)); err != nil {
+		log.Printf("Auto-commit: %v", err)
+	}
+}
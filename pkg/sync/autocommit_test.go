@@ -0,0 +1,97 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/mklimuk/vault-pilot/pkg/vault"
+)
+
+// commitCount returns the number of commits on HEAD, 0 before the first
+func commitCount(t *testing.T, dir string) int {
+	t.Helper()
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Head(); err != nil {
+		return 0
+	}
+	commits, err := r.Log(&git.LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	commits.ForEach(func(*object.Commit) error { n++; return nil })
+	return n
+}
+
+func writeFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, rel), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHasChanges(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	g := NewGitManager(dir)
+
+	if dirty, err := g.HasChanges(); err != nil || dirty {
+		t.Errorf("empty repo: HasChanges = %v, %v", dirty, err)
+	}
+	writeFile(t, dir, "Note.md", "a")
+	if dirty, err := g.HasChanges(); err != nil || !dirty {
+		t.Errorf("new note: HasChanges = %v, %v", dirty, err)
+	}
+	if err := g.Commit("add note"); err != nil {
+		t.Fatal(err)
+	}
+	if dirty, err := g.HasChanges(); err != nil || dirty {
+		t.Errorf("after commit: HasChanges = %v, %v", dirty, err)
+	}
+	if _, err := NewGitManager(t.TempDir()).HasChanges(); err == nil {
+		t.Error("expected an error outside a repository")
+	}
+}
+
+func TestAutoCommitterCommitsOncePerBurst(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	// The timer never fires during the test; Flush runs the scheduled commit
+	a := NewAutoCommitter(NewGitManager(dir), time.Hour)
+	defer a.Stop()
+
+	for _, name := range []string{"A.md", "B.md", "C.md"} {
+		writeFile(t, dir, name, name)
+		a.HandleChanges([]vault.ChangeEvent{{Kind: vault.ChangeModified, Path: name}})
+	}
+	a.Flush()
+	if n := commitCount(t, dir); n != 1 {
+		t.Fatalf("commits after a burst = %d, want 1", n)
+	}
+
+	// Changes already committed, e.g. through the API, add nothing
+	a.HandleChanges([]vault.ChangeEvent{{Kind: vault.ChangeModified, Path: "A.md"}})
+	a.Flush()
+	if n := commitCount(t, dir); n != 1 {
+		t.Errorf("commits with a clean tree = %d, want 1", n)
+	}
+
+	writeFile(t, dir, "A.md", "changed")
+	a.HandleChanges([]vault.ChangeEvent{{Kind: vault.ChangeModified, Path: "A.md"}})
+	a.Flush()
+	if n := commitCount(t, dir); n != 2 {
+		t.Errorf("commits after a second burst = %d, want 2", n)
+	}
+}
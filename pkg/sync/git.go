@@ -3,6 +3,7 @@ package sync
 import (
 	"fmt"
 	"os"
+	gosync "sync"
 	"time"
 
 	"github.com/go-git/go-git/v5"
@@ -13,6 +14,8 @@ import (
 // GitManager handles git operations
 type GitManager struct {
 	RepoPath string
+
+	mu gosync.Mutex
 }
 
 // NewGitManager creates a new GitManager
@@ -22,6 +25,30 @@ func NewGitManager(repoPath string) *GitManager {
 
 // Sync commits all changes and pushes to remote
 func (g *GitManager) Sync(message string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.commit(message); err != nil {
+		return err
+	}
+	return g.push()
+}
+
+// Commit commits all changes without pushing
+func (g *GitManager) Commit(message string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.commit(message)
+}
+
+// Push pushes committed changes to remote
+func (g *GitManager) Push() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.push()
+}
+
+func (g *GitManager) commit(message string) error {
 	// Open Repo
 	r, err := git.PlainOpen(g.RepoPath)
 	if err != nil {
@@ -54,6 +81,14 @@ func (g *GitManager) Sync(message string) error {
 	if err != nil {
 		return fmt.Errorf("failed to commit: %w", err)
 	}
+	return nil
+}
+
+func (g *GitManager) push() error {
+	r, err := git.PlainOpen(g.RepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repo: %w", err)
+	}
 
 	// Push
 	// Auth is tricky. Let's try to use SSH agent if available, or just default.
@@ -90,3 +125,23 @@ func (g *GitManager) Sync(message string) error {
 
 	return nil
 }
+
+// HasChanges reports whether the worktree has uncommitted changes
+func (g *GitManager) HasChanges() (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r, err := git.PlainOpen(g.RepoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open repo: %w", err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return false, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := w.Status()
+	if err != nil {
+		return false, fmt.Errorf("failed to get status: %w", err)
+	}
+	return !status.IsClean(), nil
+}
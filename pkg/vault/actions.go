@@ -0,0 +1,252 @@
+package vault
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NextActionsFolder holds the next actions, one subfolder per context
+const NextActionsFolder = "2. Next Actions"
+
+// LocationContexts lists the contexts workable at each location, for
+// RankActions. @calls and @computer only need a phone or a laptop.
+var LocationContexts = map[string][]string{
+	"home":   {"@home", "@computer", "@calls"},
+	"office": {"@office", "@computer", "@calls"},
+	"out":    {"@errands", "@calls"},
+}
+
+// Action is a next action with its time estimate and energy
+type Action struct {
+	Path     string   `json:"path"`
+	Title    string   `json:"title"`
+	Status   string   `json:"status"`
+	Context  string   `json:"context,omitempty"`
+	Project  string   `json:"project,omitempty"`
+	Priority string   `json:"priority,omitempty"`
+	DueDate  string   `json:"due_date,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Minutes  int      `json:"minutes,omitempty"` // estimate, 0 if unknown
+	Energy   string   `json:"energy,omitempty"`  // low, medium or high
+}
+
+// NewAction reads an indexed next action. The estimate comes from the
+// time_estimate field or a tag such as #5min or #1h, the energy from the
+// energy field or a tag such as #high-energy.
+func NewAction(n IndexedNote) Action {
+	a := Action{
+		Path:     n.Path,
+		Title:    n.Title,
+		Status:   n.Status(),
+		Context:  n.Context(),
+		Project:  n.Project(),
+		Priority: n.String("priority"),
+		DueDate:  n.String("due_date"),
+		Tags:     n.Tags,
+		Minutes:  parseMinutes(n.String("time_estimate")),
+		Energy:   strings.ToLower(n.String("energy")),
+	}
+	for _, tag := range n.Tags {
+		tag = strings.ToLower(tag)
+		if a.Minutes == 0 {
+			a.Minutes = parseMinutes(tag)
+		}
+		if level, ok := strings.CutSuffix(tag, "-energy"); ok && a.Energy == "" && energyRank(level) > 0 {
+			a.Energy = level
+		}
+	}
+	return a
+}
+
+var minutesRe = regexp.MustCompile(`^(\d+)\s*(m|min|mins|minutes|h|hr|hrs|hour|hours)?$`)
+
+// parseMinutes reads an estimate such as "15 min", "2h" or "30"
+func parseMinutes(s string) int {
+	m := minutesRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	if strings.HasPrefix(m[2], "h") {
+		n *= 60
+	}
+	return n
+}
+
+// ActionFilter selects next actions. Empty fields match everything.
+type ActionFilter struct {
+	Context   string
+	Project   string
+	Priority  string
+	Status    string    // without a status, done actions are left out
+	DueBefore time.Time // due on or before, inclusive
+	Tags      []string  // all must be present
+}
+
+// ListActions returns the next actions matching f, by due date, then
+// priority, then title
+func ListActions(x *Index, f ActionFilter) []Action {
+	q := Query{Folder: NextActionsFolder, Status: f.Status, Context: f.Context, Project: f.Project, DueTo: f.DueBefore}
+	if f.Priority != "" {
+		q.Fields = map[string]string{"priority": f.Priority}
+	}
+	var out []Action
+	for _, n := range x.Query(q) {
+		if f.Status == "" && strings.EqualFold(n.Status(), "done") {
+			continue
+		}
+		if !hasTags(&n, f.Tags) {
+			continue
+		}
+		out = append(out, NewAction(n))
+	}
+	sortActions(out)
+	return out
+}
+
+func hasTags(n *IndexedNote, tags []string) bool {
+	for _, t := range tags {
+		if !n.HasTag(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortActions(actions []Action) {
+	sort.SliceStable(actions, func(i, j int) bool {
+		a, b := actions[i], actions[j]
+		if (a.DueDate != "") != (b.DueDate != "") {
+			return a.DueDate != ""
+		}
+		if a.DueDate != b.DueDate {
+			return a.DueDate < b.DueDate
+		}
+		if pa, pb := PriorityRank(a.Priority), PriorityRank(b.Priority); pa != pb {
+			return pa > pb
+		}
+		return a.Title < b.Title
+	})
+}
+
+// PriorityRank orders priorities from 1 (low) to 4 (urgent); unknown ones are 0
+func PriorityRank(p string) int {
+	for i, v := range Priorities {
+		if strings.EqualFold(p, v) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// energyRank orders energy levels from 1 (low) to 3 (high); unknown ones are 0
+func energyRank(e string) int {
+	for i, v := range Energies {
+		if strings.EqualFold(e, v) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Availability describes what the user can take on right now. Zero values
+// do not constrain.
+type Availability struct {
+	Minutes  int    // time available
+	Energy   string // low, medium or high
+	Location string // a LocationContexts key, or a context such as @home
+}
+
+// contexts returns the contexts workable at the location, nil for any
+func (a Availability) contexts() ([]string, error) {
+	loc := strings.ToLower(strings.TrimSpace(a.Location))
+	switch {
+	case loc == "":
+		return nil, nil
+	case strings.HasPrefix(loc, "@"):
+		return []string{loc}, nil
+	}
+	if c, ok := LocationContexts[loc]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown location %q", a.Location)
+}
+
+// RankedAction is an action doable now, with the reasons for its score
+type RankedAction struct {
+	Action
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// RankActions answers "what can I do now": it keeps the actions with status
+// next that fit the available time, energy and location, and ranks them by
+// urgency, priority and how well they fit. Actions without an estimate,
+// energy or context are assumed to fit.
+func RankActions(actions []Action, avail Availability, today time.Time) ([]RankedAction, error) {
+	contexts, err := avail.contexts()
+	if err != nil {
+		return nil, err
+	}
+	if avail.Energy != "" && energyRank(avail.Energy) == 0 {
+		return nil, fmt.Errorf("unknown energy %q", avail.Energy)
+	}
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+
+	var out []RankedAction
+	for _, a := range actions {
+		if !strings.EqualFold(a.Status, "next") {
+			continue
+		}
+		if contexts != nil && a.Context != "" && !containsFold(contexts, a.Context) {
+			continue
+		}
+		if avail.Minutes > 0 && a.Minutes > avail.Minutes {
+			continue
+		}
+		if avail.Energy != "" && energyRank(a.Energy) > energyRank(avail.Energy) {
+			continue
+		}
+
+		r := RankedAction{Action: a, Reasons: []string{}}
+		add := func(score int, reason string) {
+			r.Score += score
+			r.Reasons = append(r.Reasons, reason)
+		}
+		if due, ok := parseDate(a.DueDate); ok {
+			switch days := int(due.Sub(today).Hours() / 24); {
+			case days < 0:
+				add(50, fmt.Sprintf("overdue by %d day(s)", -days))
+			case days == 0:
+				add(40, "due today")
+			case days <= 3:
+				add(20, fmt.Sprintf("due in %d day(s)", days))
+			}
+		}
+		if p := PriorityRank(a.Priority); p > 1 {
+			add(10*(p-1), a.Priority+" priority")
+		}
+		if avail.Minutes > 0 && a.Minutes > 0 {
+			add(10, fmt.Sprintf("fits in %d min", avail.Minutes))
+		}
+		if avail.Energy != "" && strings.EqualFold(a.Energy, avail.Energy) {
+			add(5, "matches "+a.Energy+" energy")
+		}
+		out = append(out, r)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
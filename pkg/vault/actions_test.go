@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListAndRankActions(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "2. Next Actions/@calls/Call Bob.md", "---\nstatus: next\ncontext: \"@calls\"\nproject: \"[[Website]]\"\npriority: high\ntags: [5min]\n---\n# Call Bob\n")
+	writeTestFile(t, root, "2. Next Actions/@home/Paint fence.md", "---\nstatus: next\npriority: low\nenergy: high\ntime_estimate: 2h\n---\n# Paint fence\n")
+	writeTestFile(t, root, "2. Next Actions/@errands/Buy stamps.md", "---\nstatus: next\ndue_date: 2026-03-01\n---\n# Buy stamps\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Pay invoice.md", "---\nstatus: next\ndue_date: 2026-03-04\ntags: [low-energy, 15min]\n---\n# Pay invoice\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Old task.md", "---\nstatus: done\ncompleted: 2026-02-01\n---\n# Old task\n")
+	writeTestFile(t, root, "2. Next Actions/@waiting/Quote from Bob.md", "---\nstatus: waiting\nwaiting_for: Bob\n---\n# Quote from Bob\n")
+
+	idx := NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	all := ListActions(idx, ActionFilter{})
+	var titles []string
+	for _, a := range all {
+		titles = append(titles, a.Title)
+	}
+	if len(titles) != 5 || titles[0] != "Buy stamps" || titles[1] != "Pay invoice" || titles[2] != "Call Bob" {
+		t.Fatalf("unexpected order: %v", titles)
+	}
+	if a := all[1]; a.Minutes != 15 || a.Energy != "low" || a.Context != "@computer" {
+		t.Errorf("unexpected action: %+v", a)
+	}
+	if got := ListActions(idx, ActionFilter{Tags: []string{"#5min"}, Priority: "HIGH"}); len(got) != 1 || got[0].Title != "Call Bob" {
+		t.Errorf("tag filter = %+v", got)
+	}
+	if got := ListActions(idx, ActionFilter{DueBefore: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}); len(got) != 1 || got[0].Title != "Buy stamps" {
+		t.Errorf("due filter = %+v", got)
+	}
+	if got := ListActions(idx, ActionFilter{Status: "done"}); len(got) != 1 || got[0].Title != "Old task" {
+		t.Errorf("status filter = %+v", got)
+	}
+
+	today := time.Date(2026, 3, 3, 9, 0, 0, 0, time.Local)
+	ranked, err := RankActions(all, Availability{Minutes: 30, Energy: "low", Location: "home"}, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Paint fence takes too long, Buy stamps needs to be out
+	if len(ranked) != 2 || ranked[0].Title != "Pay invoice" || ranked[1].Title != "Call Bob" {
+		t.Fatalf("unexpected ranking: %+v", ranked)
+	}
+	if ranked[0].Score != 35 || len(ranked[0].Reasons) != 3 || ranked[0].Reasons[0] != "due in 1 day(s)" {
+		t.Errorf("unexpected reasons: %+v", ranked[0])
+	}
+
+	ranked, _ = RankActions(all, Availability{Location: "out"}, today)
+	if len(ranked) != 2 || ranked[0].Title != "Buy stamps" || ranked[0].Reasons[0] != "overdue by 2 day(s)" {
+		t.Errorf("unexpected ranking out: %+v", ranked)
+	}
+	if _, err := RankActions(all, Availability{Location: "moon"}, today); err == nil {
+		t.Error("expected an error for an unknown location")
+	}
+}
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk is a passage of a note, the unit of semantic search
+type Chunk struct {
+	Heading string `json:"heading"` // section path, empty before the first heading
+	Text    string `json:"text"`
+}
+
+// SplitChunks splits note content into passages of at most maxLen bytes. Each
+// section becomes its own passage; long sections are split between
+// paragraphs, and long paragraphs between words. Frontmatter and sections
+// without text are dropped.
+func SplitChunks(content string, maxLen int) []Chunk {
+	d := ParseDocument(content)
+	var chunks []Chunk
+	add := func(heading string, lines []string) {
+		for _, text := range packParagraphs(lines, maxLen) {
+			chunks = append(chunks, Chunk{Heading: heading, Text: text})
+		}
+	}
+
+	first := len(d.lines)
+	if len(d.sections) > 0 {
+		first = d.sections[0].line
+	}
+	add("", d.lines[:first])
+
+	var walk func(sections []*Section, parent string)
+	walk = func(sections []*Section, parent string) {
+		for _, s := range sections {
+			heading := s.Heading.Text
+			if parent != "" {
+				heading = parent + SectionPathSeparator + heading
+			}
+			add(heading, d.lines[s.line+1:s.ownEnd])
+			walk(s.Children, heading)
+		}
+	}
+	walk(d.sections, "")
+	return chunks
+}
+
+// packParagraphs joins paragraphs into passages of at most maxLen bytes
+func packParagraphs(lines []string, maxLen int) []string {
+	var out []string
+	var cur strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(cur.String()); text != "" {
+			out = append(out, text)
+		}
+		cur.Reset()
+	}
+	for _, para := range strings.Split(strings.Join(lines, "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if cur.Len() > 0 && cur.Len()+2+len(para) > maxLen {
+			flush()
+		}
+		for len(para) > maxLen {
+			cut := splitPoint(para, maxLen)
+			cur.WriteString(para[:cut])
+			flush()
+			para = strings.TrimSpace(para[cut:])
+		}
+		if cur.Len() > 0 {
+			cur.WriteString("\n\n")
+		}
+		cur.WriteString(para)
+	}
+	flush()
+	return out
+}
+
+// splitPoint returns where to cut s to at most maxLen bytes: the last space
+// before maxLen, or the last rune boundary
+func splitPoint(s string, maxLen int) int {
+	if i := strings.LastIndexAny(s[:maxLen], " \n\t"); i > 0 {
+		return i
+	}
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		_, size := utf8.DecodeRuneInString(s)
+		return size
+	}
+	return cut
+}
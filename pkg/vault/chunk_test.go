@@ -0,0 +1,34 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitChunks(t *testing.T) {
+	chunks := SplitChunks(strings.Replace(sectionNote, "\n# Weekly Review", "Intro line.\n# Weekly Review", 1), 1000)
+	want := []Chunk{
+		{Heading: "", Text: "Intro line."},
+		{Heading: "Weekly Review > Project Review > Active Projects", Text: "Review each project for:\n- [ ] Clear next action identified\n- [x] Any stalled projects need attention"},
+		{Heading: "Weekly Review > Project Review > Someday/Maybe Review", Text: "- [ ] Any items ready to activate?"},
+		{Heading: "Weekly Review > Reflections", Text: "What worked well?\n```\n# not a heading\n```"},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("chunks = %+v", chunks)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("chunk %d = %+v, want %+v", i, chunks[i], want[i])
+		}
+	}
+
+	chunks = SplitChunks("# Notes\n\nfirst paragraph here\n\nsecond paragraph here\n\n"+strings.Repeat("word ", 10), 25)
+	if len(chunks) != 4 || chunks[0].Text != "first paragraph here" || chunks[2].Text != "word word word word word" {
+		t.Errorf("long section chunks = %+v", chunks)
+	}
+	for _, c := range chunks {
+		if len(c.Text) > 25 || c.Heading != "Notes" {
+			t.Errorf("chunk %+v exceeds the limit", c)
+		}
+	}
+}
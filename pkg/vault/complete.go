@@ -0,0 +1,247 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ActionArchiveFolder holds archived next actions, in their context
+// subfolders
+const ActionArchiveFolder = NextActionsFolder + "/Archive"
+
+// ErrAlreadyDone is returned when completing a next action that is done
+var ErrAlreadyDone = errors.New("next action is already done")
+
+// CompletionResult describes a completed next action
+type CompletionResult struct {
+	Path        string    `json:"path"`      // where the action was
+	DonePath    string    `json:"done_path"` // where the completed action is now
+	Title       string    `json:"title"`
+	Context     string    `json:"context,omitempty"`
+	Project     string    `json:"project,omitempty"`
+	ProjectPath string    `json:"project_path,omitempty"` // project note whose checklist item was ticked
+	DueDate     string    `json:"due_date,omitempty"`
+	Completed   time.Time `json:"completed"`
+	Recur       string    `json:"recur,omitempty"`
+	NextPath    string    `json:"next_path,omitempty"` // the next instance of a recurring action
+	NextDue     string    `json:"next_due,omitempty"`
+}
+
+// CompletionHook is called after a next action has been completed, e.g. to
+// log it
+type CompletionHook func(result *CompletionResult) error
+
+// Completer marks next actions done and keeps their projects and
+// recurrences up to date
+type Completer struct {
+	MoveHooks []MoveHook // run when an action is archived
+
+	index  *Index
+	commit func(message string) error // may be nil
+	hooks  []CompletionHook
+	now    func() time.Time
+}
+
+// NewCompleter creates a completer. commit is called once per completed
+// action and may be nil.
+func NewCompleter(index *Index, commit func(message string) error, hooks ...CompletionHook) *Completer {
+	return &Completer{index: index, commit: commit, hooks: hooks, now: time.Now}
+}
+
+// Complete marks the next action at relPath done today and ticks its item in
+// the linked project note. A recurring action (recur field, see
+// ParseRecurrence) is kept as "<title> <date>.md" and its next instance takes
+// its place, due on the next date of the rule. With archive the completed
+// action is moved to ActionArchiveFolder.
+func (c *Completer) Complete(relPath string, archive bool) (*CompletionResult, error) {
+	c.index.LockNotes()
+	defer c.index.UnlockNotes()
+	rel, err := CleanNotePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+	if !InFolder(rel, NextActionsFolder) || InFolder(rel, ActionArchiveFolder) {
+		return nil, fmt.Errorf("%s is not an open next action", rel)
+	}
+	vaultPath := c.index.VaultPath()
+	abs := filepath.Join(vaultPath, rel)
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("read next action: %w", err)
+	}
+	n := NewIndexedNote(rel, data)
+	if n.Status() == "done" {
+		return nil, fmt.Errorf("%s: %w", rel, ErrAlreadyDone)
+	}
+
+	day := c.now()
+	result := &CompletionResult{
+		Path:      rel,
+		DonePath:  rel,
+		Title:     n.Title,
+		Context:   n.Context(),
+		Project:   n.Project(),
+		DueDate:   n.String("due_date"),
+		Completed: day,
+		Recur:     n.String("recur"),
+	}
+	var next []byte
+	if result.Recur != "" {
+		rule, err := ParseRecurrence(result.Recur)
+		if err != nil {
+			return nil, err
+		}
+		due, _ := n.DueDate()
+		if nextDue, ok := rule.Next(due, day); ok {
+			result.NextDue = nextDue.Format("2006-01-02")
+			if next, err = nextInstance(data, result.NextDue, followingRule(result.Recur, rule, due)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := CompleteNextAction(vaultPath, rel, day); err != nil {
+		return nil, fmt.Errorf("complete next action: %w", err)
+	}
+
+	var errs []error
+	switch {
+	case next != nil:
+		// The completed instance makes way for the next one, so links to
+		// the action keep pointing at the open task
+		folder := filepath.Dir(rel)
+		if archive {
+			folder = archivedPath(folder)
+		}
+		result.DonePath = availablePath(vaultPath, filepath.Join(folder, result.Title+" "+day.Format("2006-01-02")+".md"))
+		doneAbs := filepath.Join(vaultPath, result.DonePath)
+		if err := os.MkdirAll(filepath.Dir(doneAbs), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(abs, doneAbs); err != nil {
+			return nil, fmt.Errorf("keep completed instance: %w", err)
+		}
+		if err := os.WriteFile(abs, next, 0644); err != nil {
+			return nil, fmt.Errorf("write next instance: %w", err)
+		}
+		result.NextPath = rel
+		c.reindex(result.DonePath)
+		c.reindex(rel)
+	case archive:
+		moved, err := NewMover(c.index, nil, c.MoveHooks...).move(rel, availablePath(vaultPath, archivedPath(rel)), nil)
+		if moved == nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		result.DonePath = moved.NewPath
+	default:
+		c.reindex(rel)
+	}
+
+	if result.Project != "" {
+		if err := c.tickProject(result, next != nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, hook := range c.hooks {
+		if err := hook(result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.commit != nil {
+		msg := "Complete next action: " + result.Title
+		if result.NextDue != "" {
+			msg += ", next due " + result.NextDue
+		}
+		if err := c.commit(msg); err != nil {
+			errs = append(errs, fmt.Errorf("commit: %w", err))
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// nextInstance returns the content of the next instance of a recurring
+// action: open again, due on due, with the rule for the instances after it
+func nextInstance(data []byte, due, recur string) ([]byte, error) {
+	e, err := ParseFrontmatter(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse next action: %w", err)
+	}
+	e.Delete("completed")
+	if err := e.Set("due_date", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: due}); err != nil {
+		return nil, err
+	}
+	if old, _ := e.Get("recur"); old != recur {
+		if err := e.Set("recur", recur); err != nil {
+			return nil, err
+		}
+	}
+	return e.Bytes()
+}
+
+// tickProject checks the action's item in its project note. The item may
+// link to the action or name it.
+func (c *Completer) tickProject(result *CompletionResult, keepOpen bool) error {
+	project, ok := c.index.Graph().Resolve(result.Project)
+	if !ok {
+		return nil
+	}
+	match := func(text string) bool {
+		for _, l := range ParseLinks(text) {
+			if strings.EqualFold(filepath.Base(l.Target), result.Title) {
+				return true
+			}
+		}
+		return strings.EqualFold(strings.TrimSpace(text), result.Title)
+	}
+	ticked := false
+	err := EditSections(filepath.Join(c.index.VaultPath(), project), func(d *Document) error {
+		_, ticked = d.TickChecklistItem(match, keepOpen)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("tick %s: %w", project, err)
+	}
+	if ticked {
+		result.ProjectPath = project
+		c.reindex(project)
+	}
+	return nil
+}
+
+func (c *Completer) reindex(rel string) {
+	if err := c.index.Update(rel); err != nil {
+		log.Printf("Complete: index %s: %v", rel, err)
+	}
+}
+
+// archivedPath maps a path under NextActionsFolder to ActionArchiveFolder
+func archivedPath(rel string) string {
+	inner, err := filepath.Rel(filepath.FromSlash(NextActionsFolder), rel)
+	if err != nil {
+		inner = filepath.Base(rel)
+	}
+	return filepath.Join(filepath.FromSlash(ActionArchiveFolder), inner)
+}
+
+// availablePath returns rel, or rel with a number added when a note exists
+// there already
+func availablePath(vaultPath, rel string) string {
+	base := strings.TrimSuffix(rel, ".md")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(vaultPath, rel)); os.IsNotExist(err) {
+			return rel
+		}
+		rel = base + " " + strconv.Itoa(i) + ".md"
+	}
+}
@@ -0,0 +1,166 @@
+package vault
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		rule, due, done, want string // want "" when the rule has run out
+	}{
+		{"daily", "", "2026-03-05", "2026-03-06"},
+		{"every 2 weeks", "2026-03-02", "2026-03-05", "2026-03-16"},
+		{"every 2 weeks when done", "2026-03-02", "2026-03-05", "2026-03-19"},
+		{"every day", "2026-02-01", "2026-03-05", "2026-03-06"},
+		{"every monday, thursday", "2026-03-02", "2026-03-02", "2026-03-05"},
+		{"every other week on friday", "2026-03-06", "2026-03-06", "2026-03-20"},
+		{"every weekday", "2026-03-06", "2026-03-06", "2026-03-09"},
+		{"monthly", "2026-01-31", "2026-01-31", "2026-02-28"},
+		{"every 3 months", "2026-01-15", "2026-01-20", "2026-04-15"},
+		{"yearly", "2026-03-01", "2026-03-01", "2027-03-01"},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", "2026-03-02", "2026-03-02", "2026-03-16"},
+		{"RRULE:FREQ=MONTHLY;BYMONTHDAY=31", "2026-01-31", "2026-01-31", "2026-02-28"},
+		{"FREQ=DAILY;COUNT=1", "", "2026-03-05", ""},
+		{"FREQ=DAILY;UNTIL=20260305", "", "2026-03-05", ""},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Errorf("%q: %v", tt.rule, err)
+			continue
+		}
+		var due time.Time
+		if tt.due != "" {
+			due = day(tt.due)
+		}
+		next, ok := r.Next(due, day(tt.done))
+		if got := next.Format("2006-01-02"); !ok && tt.want != "" || ok && got != tt.want {
+			t.Errorf("%q due %s done %s: next = %s, %v; want %q", tt.rule, tt.due, tt.done, got, ok, tt.want)
+		}
+	}
+
+	for _, rule := range []string{"sometimes", "every 0 days", "every month on monday", "FREQ=HOURLY", "FREQ=DAILY;BYDAY=1MO"} {
+		if _, err := ParseRecurrence(rule); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("%q: expected ErrInvalidRecurrence, got %v", rule, err)
+		}
+	}
+}
+
+func TestFollowingRuleKeepsMonthDay(t *testing.T) {
+	tests := []struct{ rule, due, want string }{
+		{"monthly", "2026-01-31", "FREQ=MONTHLY;BYMONTHDAY=31"},
+		{"every 3 months", "2026-01-30", "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=30"},
+		{"RRULE:FREQ=MONTHLY;COUNT=3", "2026-01-29", "RRULE:FREQ=MONTHLY;COUNT=2;BYMONTHDAY=29"},
+		{"monthly", "2026-01-15", "monthly"},
+		{"monthly when done", "2026-01-31", "monthly when done"},
+		{"FREQ=MONTHLY;BYMONTHDAY=31", "2026-02-28", "FREQ=MONTHLY;BYMONTHDAY=31"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		due, _ := time.Parse("2006-01-02", tt.due)
+		if got := followingRule(tt.rule, r, due); got != tt.want {
+			t.Errorf("%q due %s: got %q, want %q", tt.rule, tt.due, got, tt.want)
+		}
+	}
+
+	// Jan 31 -> Feb 28 -> Mar 31, not Mar 28
+	r, _ := ParseRecurrence("FREQ=MONTHLY;BYMONTHDAY=31")
+	feb28 := time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC)
+	if next, _ := r.Next(feb28, feb28); next.Format("2006-01-02") != "2026-03-31" {
+		t.Errorf("next = %s", next.Format("2006-01-02"))
+	}
+}
+
+func TestCompleteNextAction(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "3. Projects/Website.md", "---\nstatus: active\n---\n# Website\n\n## Next Actions\n- [ ] [[Call Bob|call Bob]] about hosting\n- [ ] Water plants\n")
+	writeTestFile(t, root, "2. Next Actions/@calls/Call Bob.md", "---\nstatus: next\nproject: \"[[Website]]\"\n---\n# Call Bob\n")
+	writeTestFile(t, root, "2. Next Actions/@home/Water plants.md", "---\nstatus: next\nproject: \"[[Website]]\"\ndue_date: 2026-03-02\nrecur: FREQ=WEEKLY;INTERVAL=2;COUNT=3\n---\n# Water plants\n")
+	writeTestFile(t, root, "2. Next Actions/@home/Paint fence.md", "---\nstatus: next\nrecur: now and then\n---\n")
+	idx := NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	read := func(rel string) string {
+		data, err := os.ReadFile(filepath.Join(root, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	var logged []*CompletionResult
+	var commits []string
+	c := NewCompleter(idx, func(message string) error {
+		commits = append(commits, message)
+		return nil
+	}, func(r *CompletionResult) error {
+		logged = append(logged, r)
+		return nil
+	})
+	c.now = func() time.Time { return time.Date(2026, 3, 5, 18, 0, 0, 0, time.UTC) }
+
+	result, err := c.Complete("2. Next Actions/@calls/Call Bob", true)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if result.DonePath != filepath.Join("2. Next Actions", "Archive", "@calls", "Call Bob.md") || result.ProjectPath != filepath.Join("3. Projects", "Website.md") {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if got := read(result.DonePath); !strings.Contains(got, "status: done\n") || !strings.Contains(got, "completed: 2026-03-05\n") {
+		t.Errorf("archived action:\n%s", got)
+	}
+	if got := read(result.ProjectPath); !strings.Contains(got, "- [x] [[Call Bob|call Bob]] about hosting\n- [ ] Water plants\n") {
+		t.Errorf("project after completing Call Bob:\n%s", got)
+	}
+
+	// A recurring action leaves a dated copy behind and comes back
+	result, err = c.Complete("2. Next Actions/@home/Water plants.md", false)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if result.NextDue != "2026-03-16" || result.NextPath != filepath.Join("2. Next Actions", "@home", "Water plants.md") || result.DonePath != filepath.Join("2. Next Actions", "@home", "Water plants 2026-03-05.md") {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if got := read(result.NextPath); got != "---\nstatus: next\nproject: \"[[Website]]\"\ndue_date: 2026-03-16\nrecur: FREQ=WEEKLY;INTERVAL=2;COUNT=2\n---\n# Water plants\n" {
+		t.Errorf("next instance:\n%s", got)
+	}
+	if got := read(result.DonePath); !strings.Contains(got, "status: done\n") || !strings.Contains(got, "due_date: 2026-03-02\n") {
+		t.Errorf("completed instance:\n%s", got)
+	}
+	if got := read(result.ProjectPath); !strings.HasSuffix(got, "- [x] Water plants\n- [ ] Water plants\n") {
+		t.Errorf("project after completing Water plants:\n%s", got)
+	}
+	if n, ok := idx.Get(result.NextPath); !ok || n.Status() != "next" {
+		t.Errorf("next instance not indexed: %+v", n)
+	}
+
+	if _, err := c.Complete(result.DonePath, false); !errors.Is(err, ErrAlreadyDone) {
+		t.Errorf("expected ErrAlreadyDone, got %v", err)
+	}
+	if _, err := c.Complete("2. Next Actions/@calls/Call Bob.md", false); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := c.Complete("2. Next Actions/@home/Paint fence.md", false); !errors.Is(err, ErrInvalidRecurrence) {
+		t.Errorf("expected ErrInvalidRecurrence, got %v", err)
+	}
+	if got := read("2. Next Actions/@home/Paint fence.md"); !strings.Contains(got, "status: next") {
+		t.Errorf("an invalid rule must leave the action open:\n%s", got)
+	}
+	if len(logged) != 2 || len(commits) != 2 || commits[1] != "Complete next action: Water plants, next due 2026-03-16" {
+		t.Errorf("logged %d completions, commits %v", len(logged), commits)
+	}
+}
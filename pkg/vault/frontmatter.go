@@ -0,0 +1,342 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontmatterEditor edits the YAML frontmatter of a note without re-marshalling
+// the whole block. Only the lines of fields that are set or deleted change, so
+// key order, comments and quoting of everything else survive untouched.
+type FrontmatterEditor struct {
+	hasFrontmatter bool
+	lines          []string // frontmatter lines between the --- delimiters
+	closing        string   // closing delimiter as it appeared in the file
+	body           string   // everything after the closing delimiter
+}
+
+// ParseFrontmatter splits a note into frontmatter and body for editing. A note
+// without frontmatter is accepted; the first Set adds a frontmatter block.
+func ParseFrontmatter(data []byte) (*FrontmatterEditor, error) {
+	e := &FrontmatterEditor{body: string(data)}
+	fm, closing, body, ok := splitFrontmatter(string(data))
+	if !ok {
+		return e, nil
+	}
+	e.hasFrontmatter = true
+	e.closing = closing
+	e.body = body
+	if fm != "" {
+		e.lines = strings.Split(fm, "\n")
+	}
+	if _, err := e.mapping(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// EditFrontmatter reads the note at path, applies fn and writes the note back
+// if anything changed.
+func EditFrontmatter(path string, fn func(*FrontmatterEditor) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	e, err := ParseFrontmatter(data)
+	if err != nil {
+		return err
+	}
+	if err := fn(e); err != nil {
+		return err
+	}
+	out, err := e.Bytes()
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(out, data) {
+		return nil
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// Keys returns the top-level frontmatter keys in file order.
+func (e *FrontmatterEditor) Keys() []string {
+	m, err := e.mapping()
+	if err != nil || m == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(m.Content)/2)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		keys = append(keys, m.Content[i].Value)
+	}
+	return keys
+}
+
+// Get returns the value of a scalar field.
+func (e *FrontmatterEditor) Get(key string) (string, bool) {
+	m, err := e.mapping()
+	if err != nil || m == nil {
+		return "", false
+	}
+	i := findKey(m, key)
+	if i < 0 || m.Content[i+1].Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return m.Content[i+1].Value, true
+}
+
+// Set sets a field, keeping the existing scalar style and comments when the
+// field is already present. New fields are appended after the last field.
+func (e *FrontmatterEditor) Set(key string, value interface{}) error {
+	m, err := e.mapping()
+	if err != nil {
+		return err
+	}
+
+	var val yaml.Node
+	if err := val.Encode(value); err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+
+	i := -1
+	if m != nil {
+		i = findKey(m, key)
+	}
+	if i < 0 {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+		rendered, err := renderField(keyNode, &val)
+		if err != nil {
+			return err
+		}
+		at := len(e.lines)
+		if m != nil && len(m.Content) > 0 {
+			_, at = e.span(m, len(m.Content)-2)
+		}
+		e.splice(at, at, rendered)
+		e.hasFrontmatter = true
+		return nil
+	}
+
+	oldKey, oldVal := m.Content[i], m.Content[i+1]
+	if oldVal.Kind == yaml.ScalarNode && val.Kind == yaml.ScalarNode {
+		if oldVal.Value == val.Value && oldVal.ShortTag() == val.ShortTag() {
+			return nil
+		}
+		kept := yaml.Node{Kind: yaml.ScalarNode, Style: oldVal.Style, Value: val.Value}
+		// Keep the old style only while the value keeps its type. Callers
+		// pass dates as strings, so a string may stay a plain date.
+		if tag := kept.ShortTag(); tag == val.ShortTag() || tag == "!!timestamp" && val.ShortTag() == "!!str" {
+			val = kept
+		}
+	}
+	val.LineComment = oldVal.LineComment
+
+	keyNode := *oldKey
+	keyNode.HeadComment, keyNode.FootComment = "", ""
+	rendered, err := renderField(&keyNode, &val)
+	if err != nil {
+		return err
+	}
+	start, end := e.span(m, i)
+	e.splice(start, end, rendered)
+	return nil
+}
+
+// Delete removes a field. It reports whether the field was present.
+func (e *FrontmatterEditor) Delete(key string) bool {
+	m, err := e.mapping()
+	if err != nil || m == nil {
+		return false
+	}
+	i := findKey(m, key)
+	if i < 0 {
+		return false
+	}
+	start, end := e.span(m, i)
+	e.splice(start, end, nil)
+	return true
+}
+
+// Frontmatter fields recording how a note was generated
+const (
+	PromptVersionField = "prompt_version" // the prompt, e.g. "inbox-analysis@3"
+	GeneratedByField   = "generated_by"   // the model, e.g. "openai/gpt-4o-mini"
+)
+
+// SetGeneratedBy records the prompt version promptRef and, when known, the
+// model that answered it.
+func (e *FrontmatterEditor) SetGeneratedBy(promptRef, model string) error {
+	if err := e.Set(PromptVersionField, promptRef); err != nil {
+		return err
+	}
+	if model != "" {
+		return e.Set(GeneratedByField, model)
+	}
+	return nil
+}
+
+// Body returns the note content after the frontmatter.
+func (e *FrontmatterEditor) Body() string {
+	return e.body
+}
+
+// SetBody replaces the note content after the frontmatter.
+func (e *FrontmatterEditor) SetBody(body string) {
+	e.body = body
+}
+
+// Bytes returns the full note.
+func (e *FrontmatterEditor) Bytes() ([]byte, error) {
+	if !e.hasFrontmatter {
+		return []byte(e.body), nil
+	}
+	var b strings.Builder
+	b.WriteString("---\n")
+	for _, line := range e.lines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	closing := e.closing
+	if closing == "" {
+		closing = "---\n"
+	}
+	b.WriteString(closing)
+	b.WriteString(e.body)
+	return []byte(b.String()), nil
+}
+
+// mapping parses the current frontmatter lines. It returns nil for an empty block.
+func (e *FrontmatterEditor) mapping() (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(e.lines, "\n")), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("frontmatter is not a mapping")
+	}
+	if m.Style&yaml.FlowStyle != 0 {
+		return nil, fmt.Errorf("flow-style frontmatter cannot be edited")
+	}
+	return m, nil
+}
+
+// span returns the line range [start, end) of the field whose key is at
+// m.Content[i]. Blank and comment lines before the next key are left out, as
+// they belong to the next field.
+func (e *FrontmatterEditor) span(m *yaml.Node, i int) (int, int) {
+	start := m.Content[i].Line - 1
+	end := len(e.lines)
+	if i+2 < len(m.Content) {
+		end = m.Content[i+2].Line - 1
+	}
+	for end > start+1 {
+		line := e.lines[end-1]
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		end--
+	}
+	return start, end
+}
+
+func (e *FrontmatterEditor) splice(start, end int, lines []string) {
+	out := make([]string, 0, len(e.lines)-(end-start)+len(lines))
+	out = append(out, e.lines[:start]...)
+	out = append(out, lines...)
+	out = append(out, e.lines[end:]...)
+	e.lines = out
+}
+
+func findKey(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// renderField renders a single key/value pair as frontmatter lines.
+func renderField(key, val *yaml.Node) ([]string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	field := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, val}}
+	if err := enc.Encode(field); err != nil {
+		return nil, fmt.Errorf("render %s: %w", key.Value, err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"), nil
+}
+
+// splitFrontmatter splits a note at its --- delimiters. fm excludes the
+// trailing newline before the closing delimiter.
+func splitFrontmatter(s string) (fm, closing, body string, ok bool) {
+	if !strings.HasPrefix(s, "---\n") {
+		return "", "", s, false
+	}
+	rest := s[4:]
+	if strings.HasPrefix(rest, "---\n") || rest == "---" {
+		return "", rest[:min(4, len(rest))], rest[min(4, len(rest)):], true
+	}
+	for pos := 0; ; {
+		idx := strings.Index(rest[pos:], "\n---")
+		if idx < 0 {
+			return "", "", s, false
+		}
+		end := pos + idx
+		after := rest[end+4:]
+		if after == "" || after[0] == '\n' {
+			closing = "---"
+			if after != "" {
+				closing = "---\n"
+				after = after[1:]
+			}
+			return rest[:end], closing, after, true
+		}
+		pos = end + 1
+	}
+}
+
+// applyFrontmatter updates the editor to match fm, touching only the fields
+// that differ from the frontmatter it was parsed from.
+func applyFrontmatter(e *FrontmatterEditor, original, fm map[string]interface{}) error {
+	for _, key := range e.Keys() {
+		if _, ok := fm[key]; !ok {
+			e.Delete(key)
+		}
+	}
+	var added []string
+	for key, val := range fm {
+		orig, existed := original[key]
+		if !existed {
+			added = append(added, key)
+			continue
+		}
+		if reflect.DeepEqual(orig, val) {
+			continue
+		}
+		if err := e.Set(key, val); err != nil {
+			return err
+		}
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		if err := e.Set(key, fm[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
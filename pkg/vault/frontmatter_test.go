@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const handEditedNote = `---
+# Synced from calendar
+created: 2026-01-10
+status: "scheduled"   # keep quoted
+context: '@calendar'
+tags:
+- meeting
+- team
+
+calendar_id: evt-1
+due_date: 2026-02-05
+---
+
+# Team Standup
+`
+
+func TestFrontmatterEditorMinimalChanges(t *testing.T) {
+	e, err := ParseFrontmatter([]byte(handEditedNote))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	out, _ := e.Bytes()
+	if string(out) != handEditedNote {
+		t.Fatalf("untouched note changed:\n%s", out)
+	}
+
+	if err := e.Set("due_date", "2026-02-07"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("status", "next"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("priority", "high"); err != nil {
+		t.Fatal(err)
+	}
+	e.Delete("calendar_id")
+
+	want := `---
+# Synced from calendar
+created: 2026-01-10
+status: "next" # keep quoted
+context: '@calendar'
+tags:
+- meeting
+- team
+
+due_date: 2026-02-07
+priority: high
+---
+
+# Team Standup
+`
+	out, _ = e.Bytes()
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+	if v, _ := e.Get("status"); v != "next" {
+		t.Errorf("status = %q", v)
+	}
+}
+
+func TestFrontmatterEditorQuotesAmbiguousValues(t *testing.T) {
+	e, _ := ParseFrontmatter([]byte("---\nstatus: next\n---\n"))
+	e.Set("status", "true")
+	e.Set("project", "[[Website]]")
+
+	out, _ := e.Bytes()
+	want := "---\nstatus: \"true\"\nproject: '[[Website]]'\n---\n"
+	if string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestFrontmatterEditorRetypesQuotedValues(t *testing.T) {
+	e, _ := ParseFrontmatter([]byte("---\ndone: \"no\"\ncount: '3'\nname: \"old\"\n---\n"))
+	e.Set("done", true)
+	e.Set("count", 4)
+	e.Set("name", "new")
+
+	out, _ := e.Bytes()
+	want := "---\ndone: true\ncount: 4\nname: \"new\"\n---\n"
+	if string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestFrontmatterEditorAddsBlock(t *testing.T) {
+	e, _ := ParseFrontmatter([]byte("# Plain note\n"))
+	e.Set("status", "inbox")
+
+	out, _ := e.Bytes()
+	if string(out) != "---\nstatus: inbox\n---\n# Plain note\n" {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestFrontmatterEditorSetGeneratedBy(t *testing.T) {
+	e, _ := ParseFrontmatter([]byte("# Summary\n"))
+	if err := e.SetGeneratedBy("daily-summary@2", "ollama/qwen2.5: 7b"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _ := e.Bytes()
+	want := "---\nprompt_version: daily-summary@2\ngenerated_by: 'ollama/qwen2.5: 7b'\n---\n# Summary\n"
+	if string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestWriteNoteKeepsFormatting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.md")
+	os.WriteFile(path, []byte(handEditedNote), 0644)
+
+	note, err := ReadNote(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteNote(note); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != handEditedNote {
+		t.Errorf("round trip changed note:\n%s", data)
+	}
+
+	note.Frontmatter.(map[string]interface{})["context"] = "@office"
+	WriteNote(note)
+	data, _ = os.ReadFile(path)
+	e, _ := ParseFrontmatter(data)
+	if v, _ := e.Get("context"); v != "@office" {
+		t.Errorf("context = %q", v)
+	}
+	if keys := e.Keys(); len(keys) != 6 || keys[2] != "context" {
+		t.Errorf("key order changed: %v", keys)
+	}
+}
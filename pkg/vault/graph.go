@@ -0,0 +1,123 @@
+package vault
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Edge is a link from one note to another
+type Edge struct {
+	Source string `json:"source"`           // vault-relative path of the linking note
+	Target string `json:"target,omitempty"` // vault-relative path of the linked note; empty when unresolved
+	Link   Link   `json:"link"`
+}
+
+// Graph holds the forward links and backlinks between notes. It is an
+// immutable snapshot; Index.Graph returns a fresh one after the vault changes.
+type Graph struct {
+	forward map[string][]Edge
+	back    map[string][]Edge
+	names   map[string][]string // lower-cased note title -> paths
+	paths   map[string]string   // lower-cased path without .md -> path
+}
+
+// NewGraph builds the link graph for a set of notes.
+func NewGraph(notes []IndexedNote) *Graph {
+	g := &Graph{
+		forward: make(map[string][]Edge),
+		back:    make(map[string][]Edge),
+		names:   make(map[string][]string),
+		paths:   make(map[string]string),
+	}
+	for _, n := range notes {
+		key := strings.ToLower(strings.TrimSuffix(filepath.ToSlash(n.Path), ".md"))
+		g.paths[key] = n.Path
+		name := strings.ToLower(n.Title)
+		g.names[name] = append(g.names[name], n.Path)
+	}
+	for _, paths := range g.names {
+		// Prefer the shallowest match, like Obsidian's shortest-path links
+		sort.Slice(paths, func(i, j int) bool {
+			di, dj := strings.Count(paths[i], string(filepath.Separator)), strings.Count(paths[j], string(filepath.Separator))
+			if di != dj {
+				return di < dj
+			}
+			return paths[i] < paths[j]
+		})
+	}
+
+	for _, n := range notes {
+		for _, l := range n.Links {
+			e := Edge{Source: n.Path, Link: l}
+			if l.Target == "" {
+				e.Target = n.Path
+			} else if p, ok := g.Resolve(l.Target); ok {
+				e.Target = p
+			}
+			g.forward[n.Path] = append(g.forward[n.Path], e)
+			if e.Target != "" && e.Target != n.Path {
+				g.back[e.Target] = append(g.back[e.Target], e)
+			}
+		}
+	}
+	for _, edges := range g.back {
+		sort.SliceStable(edges, func(i, j int) bool { return edges[i].Source < edges[j].Source })
+	}
+	return g
+}
+
+// Resolve finds the note a link target points at: by path relative to the
+// vault root when it contains a slash, otherwise by note name.
+func (g *Graph) Resolve(target string) (string, bool) {
+	key := strings.ToLower(strings.TrimSuffix(filepath.ToSlash(strings.TrimSpace(target)), ".md"))
+	if p, ok := g.paths[key]; ok {
+		return p, true
+	}
+	if strings.Contains(key, "/") {
+		// Partial paths like [[Active/Website]] match by suffix
+		suffix := "/" + key
+		var best string
+		for k, p := range g.paths {
+			if strings.HasSuffix(k, suffix) && (best == "" || p < best) {
+				best = p
+			}
+		}
+		return best, best != ""
+	}
+	if paths := g.names[key]; len(paths) > 0 {
+		return paths[0], true
+	}
+	return "", false
+}
+
+// Links returns the outgoing links of a note.
+func (g *Graph) Links(path string) []Edge {
+	return append([]Edge(nil), g.forward[path]...)
+}
+
+// Backlinks returns the links pointing at a note from other notes, sorted by source.
+func (g *Graph) Backlinks(path string) []Edge {
+	return append([]Edge(nil), g.back[path]...)
+}
+
+// Edges returns every link in the vault, sorted by source path.
+func (g *Graph) Edges() []Edge {
+	sources := sortedKeys(g.forward)
+	var out []Edge
+	for _, s := range sources {
+		out = append(out, g.forward[s]...)
+	}
+	return out
+}
+
+// Unresolved returns links whose target does not exist.
+func (g *Graph) Unresolved() []Edge {
+	var out []Edge
+	for _, e := range g.Edges() {
+		if e.Target == "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
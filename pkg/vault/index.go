@@ -0,0 +1,623 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Heading is a markdown heading found in a note body
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+// IndexedNote is the catalog entry kept by Index for a single note
+type IndexedNote struct {
+	Path        string                 `json:"path"` // relative to the vault root
+	ModTime     time.Time              `json:"mod_time"`
+	Size        int64                  `json:"size"`
+	Hash        string                 `json:"hash"`
+	Title       string                 `json:"title"`
+	Frontmatter map[string]interface{} `json:"frontmatter,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Links       []Link                 `json:"links,omitempty"`
+	Headings    []Heading              `json:"headings,omitempty"`
+	ParseError  string                 `json:"parse_error,omitempty"`
+}
+
+// String returns a frontmatter field as a string. Dates are formatted as YYYY-MM-DD.
+func (n *IndexedNote) String(key string) string {
+	return stringValue(n.Frontmatter[key])
+}
+
+// Status returns the frontmatter status field
+func (n *IndexedNote) Status() string {
+	return n.String("status")
+}
+
+// Type returns the frontmatter type field
+func (n *IndexedNote) Type() string {
+	return n.String("type")
+}
+
+// Context returns the frontmatter context, falling back to the @context folder
+// the note lives in under "2. Next Actions".
+func (n *IndexedNote) Context() string {
+	if c := n.String("context"); c != "" {
+		return c
+	}
+	parts := strings.Split(filepath.ToSlash(n.Path), "/")
+	if len(parts) > 2 && parts[0] == "2. Next Actions" && strings.HasPrefix(parts[1], "@") {
+		return parts[1]
+	}
+	return ""
+}
+
+// Project returns the linked project name with any [[ ]] brackets removed
+func (n *IndexedNote) Project() string {
+	return StripLink(n.String("project"))
+}
+
+// DueDate returns the parsed due_date field, if present
+func (n *IndexedNote) DueDate() (time.Time, bool) {
+	return parseDate(n.String("due_date"))
+}
+
+// HasTag reports whether the note carries the given tag (with or without '#')
+func (n *IndexedNote) HasTag(tag string) bool {
+	tag = strings.TrimPrefix(tag, "#")
+	for _, t := range n.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Query selects notes from the index. Empty fields match everything.
+type Query struct {
+	Folder  string // path prefix relative to the vault root, e.g. "3. Projects"
+	Status  string
+	Type    string
+	Context string
+	Project string
+	Tag     string
+	DueFrom time.Time         // inclusive; notes without a due date never match a due range
+	DueTo   time.Time         // inclusive
+	Fields  map[string]string // arbitrary frontmatter fields; list fields match any element
+}
+
+// Matches reports whether a note satisfies the query
+func (q Query) Matches(n *IndexedNote) bool {
+	if q.Folder != "" && !InFolder(n.Path, q.Folder) {
+		return false
+	}
+	if q.Status != "" && !strings.EqualFold(n.Status(), q.Status) {
+		return false
+	}
+	if q.Type != "" && !strings.EqualFold(n.Type(), q.Type) {
+		return false
+	}
+	if q.Context != "" && !strings.EqualFold(n.Context(), q.Context) {
+		return false
+	}
+	if q.Project != "" && !strings.EqualFold(n.Project(), StripLink(q.Project)) {
+		return false
+	}
+	if q.Tag != "" && !n.HasTag(q.Tag) {
+		return false
+	}
+	for key, want := range q.Fields {
+		if !fieldMatches(n.Frontmatter[key], want) {
+			return false
+		}
+	}
+	if !q.DueFrom.IsZero() || !q.DueTo.IsZero() {
+		due, ok := n.DueDate()
+		if !ok {
+			return false
+		}
+		if !q.DueFrom.IsZero() && due.Before(q.DueFrom) {
+			return false
+		}
+		if !q.DueTo.IsZero() && due.After(q.DueTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldMatches compares a frontmatter value with a query value, ignoring case
+// and wikilink brackets
+func fieldMatches(v interface{}, want string) bool {
+	if list, ok := v.([]interface{}); ok && stringValue(list) != "" && !strings.HasPrefix(stringValue(list), "[[") {
+		for _, item := range list {
+			if fieldMatches(item, want) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.EqualFold(StripLink(stringValue(v)), StripLink(want))
+}
+
+// IndexStore persists index entries between runs
+type IndexStore interface {
+	LoadIndexedNotes() ([]IndexedNote, error)
+	SaveIndexedNote(n *IndexedNote) error
+	DeleteIndexedNote(path string) error
+}
+
+// Index is a catalog of every markdown note in the vault. It is kept in memory
+// for queries and mirrored to an optional IndexStore so restarts only need to
+// re-parse notes that changed on disk.
+type Index struct {
+	vaultPath string
+	store     IndexStore
+
+	mu    sync.RWMutex
+	notes map[string]*IndexedNote
+	graph *Graph // built on demand, dropped when a note changes
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+
+	notesMu sync.Mutex // see LockNotes
+}
+
+// NewIndex creates an empty index for the vault. store may be nil.
+func NewIndex(vaultPath string, store IndexStore) *Index {
+	return &Index{
+		vaultPath: vaultPath,
+		store:     store,
+		notes:     make(map[string]*IndexedNote),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// LockNotes serialises writes to the notes of the vault. Mover, Completer,
+// ProjectManager and InboxProcessor hold it while they change notes; any other
+// writer that reads a note before writing it back should hold it too.
+func (x *Index) LockNotes() { x.notesMu.Lock() }
+
+// UnlockNotes releases the lock taken by LockNotes
+func (x *Index) UnlockNotes() { x.notesMu.Unlock() }
+
+// VaultPath returns the vault root the index was built for
+func (x *Index) VaultPath() string {
+	return x.vaultPath
+}
+
+// Load populates the in-memory catalog from the store
+func (x *Index) Load() error {
+	if x.store == nil {
+		return nil
+	}
+	entries, err := x.store.LoadIndexedNotes()
+	if err != nil {
+		return fmt.Errorf("load index: %w", err)
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for i := range entries {
+		e := entries[i]
+		x.notes[e.Path] = &e
+	}
+	x.graph = nil
+	return nil
+}
+
+// Scan walks the vault and re-indexes notes whose size or mtime changed.
+// Notes that disappeared from disk are dropped.
+func (x *Index) Scan() error {
+	seen := make(map[string]bool)
+	err := filepath.Walk(x.vaultPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != x.vaultPath && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(info.Name(), ".md") {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(x.vaultPath, path)
+		if err != nil {
+			return nil
+		}
+		seen[relPath] = true
+
+		x.mu.RLock()
+		existing := x.notes[relPath]
+		x.mu.RUnlock()
+		if existing != nil && existing.Size == info.Size() && existing.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+		if err := x.index(relPath, info); err != nil {
+			log.Printf("Index: %s: %v", relPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scan vault: %w", err)
+	}
+
+	x.mu.RLock()
+	var gone []string
+	for p := range x.notes {
+		if !seen[p] {
+			gone = append(gone, p)
+		}
+	}
+	x.mu.RUnlock()
+	for _, p := range gone {
+		if err := x.Remove(p); err != nil {
+			log.Printf("Index: remove %s: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// Update re-indexes a single note. A note that no longer exists is removed.
+func (x *Index) Update(relPath string) error {
+	info, err := os.Stat(filepath.Join(x.vaultPath, relPath))
+	if os.IsNotExist(err) {
+		return x.Remove(relPath)
+	}
+	if err != nil {
+		return err
+	}
+	return x.index(relPath, info)
+}
+
+// Remove drops a note from the index
+func (x *Index) Remove(relPath string) error {
+	x.mu.Lock()
+	delete(x.notes, relPath)
+	x.graph = nil
+	x.mu.Unlock()
+	if x.store != nil {
+		return x.store.DeleteIndexedNote(relPath)
+	}
+	return nil
+}
+
+// Get returns the entry for a note path relative to the vault root
+func (x *Index) Get(relPath string) (IndexedNote, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	n, ok := x.notes[relPath]
+	if !ok {
+		return IndexedNote{}, false
+	}
+	return *n, true
+}
+
+// All returns every indexed note sorted by path
+func (x *Index) All() []IndexedNote {
+	return x.Query(Query{})
+}
+
+// Query returns the notes matching q, sorted by path
+func (x *Index) Query(q Query) []IndexedNote {
+	x.mu.RLock()
+	var out []IndexedNote
+	for _, n := range x.notes {
+		if q.Matches(n) {
+			out = append(out, *n)
+		}
+	}
+	x.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// Graph returns the link graph of the indexed notes.
+func (x *Index) Graph() *Graph {
+	x.mu.RLock()
+	g := x.graph
+	x.mu.RUnlock()
+	if g != nil {
+		return g
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.graph == nil {
+		notes := make([]IndexedNote, 0, len(x.notes))
+		for _, n := range x.notes {
+			notes = append(notes, *n)
+		}
+		x.graph = NewGraph(notes)
+	}
+	return x.graph
+}
+
+// HandleChanges applies watcher events to the index
+func (x *Index) HandleChanges(events []ChangeEvent) {
+	for _, e := range events {
+		var err error
+		switch e.Kind {
+		case ChangeDeleted:
+			err = x.Remove(e.Path)
+		case ChangeRenamed:
+			if err = x.Remove(e.OldPath); err == nil {
+				err = x.Update(e.Path)
+			}
+		default:
+			err = x.Update(e.Path)
+		}
+		if err != nil {
+			log.Printf("Index: apply %s %s: %v", e.Kind, e.Path, err)
+		}
+	}
+}
+
+// Start runs Scan periodically until Stop is called.
+func (x *Index) Start(interval time.Duration) error {
+	if err := x.Scan(); err != nil {
+		log.Printf("Index initial scan error: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := x.Scan(); err != nil {
+					log.Printf("Index scan error: %v", err)
+				}
+			case <-x.stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops the rescan loop. It may be called more than once.
+func (x *Index) Stop() {
+	x.stopOnce.Do(func() { close(x.stopCh) })
+}
+
+func (x *Index) index(relPath string, info os.FileInfo) error {
+	data, err := os.ReadFile(filepath.Join(x.vaultPath, relPath))
+	if err != nil {
+		return err
+	}
+	entry := NewIndexedNote(relPath, data)
+	entry.ModTime = info.ModTime()
+	entry.Size = info.Size()
+
+	x.mu.Lock()
+	x.notes[relPath] = entry
+	x.graph = nil
+	x.mu.Unlock()
+
+	if x.store != nil {
+		return x.store.SaveIndexedNote(entry)
+	}
+	return nil
+}
+
+// ContentHash returns the hex SHA-256 of note content, as stored in IndexedNote.Hash
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// NewIndexedNote builds the catalog entry for note content without touching
+// the index. ModTime and Size are left for the caller.
+func NewIndexedNote(relPath string, data []byte) *IndexedNote {
+	entry := &IndexedNote{
+		Path:  relPath,
+		Hash:  ContentHash(data),
+		Title: strings.TrimSuffix(filepath.Base(relPath), ".md"),
+	}
+
+	note, err := ParseNote(relPath, data)
+	if err != nil {
+		// Keep the entry so unparseable notes are still visible to callers.
+		entry.ParseError = err.Error()
+		note, _ = ParseNote(relPath, stripFrontmatter(data))
+	}
+	if fm, ok := note.Frontmatter.(map[string]interface{}); ok {
+		entry.Frontmatter = normalizeFrontmatter(fm)
+	}
+
+	entry.Tags = extractTags(entry.Frontmatter, note.Content)
+	entry.Links = extractLinks(entry.Frontmatter, note.Content)
+	entry.Headings = extractHeadings(note.Content)
+	return entry
+}
+
+// InFolder reports whether a vault-relative path lives under folder
+func InFolder(relPath, folder string) bool {
+	folder = filepath.Clean(folder)
+	return strings.HasPrefix(relPath, folder+string(filepath.Separator))
+}
+
+// StripLink removes surrounding [[ ]] from a wikilink value
+func StripLink(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "[[")
+	s = strings.TrimSuffix(s, "]]")
+	return s
+}
+
+func stripFrontmatter(data []byte) []byte {
+	s := string(data)
+	if !strings.HasPrefix(s, "---\n") {
+		return data
+	}
+	if end := strings.Index(s[4:], "\n---"); end >= 0 {
+		return []byte(strings.TrimPrefix(s[4+end+4:], "\n"))
+	}
+	return data
+}
+
+// normalizeFrontmatter converts YAML timestamps to date strings so entries look
+// the same whether they came from disk or from the store.
+func normalizeFrontmatter(fm map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(fm))
+	for k, v := range fm {
+		out[k] = normalizeValue(v)
+	}
+	return out
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case time.Time:
+		return formatDate(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		return normalizeFrontmatter(val)
+	}
+	return v
+}
+
+func formatDate(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(val)
+	case time.Time:
+		return formatDate(val)
+	case []interface{}:
+		// Unquoted [[Link]] values parse as nested sequences
+		if len(val) == 1 {
+			if inner, ok := val[0].([]interface{}); ok && len(inner) == 1 {
+				return "[[" + stringValue(inner[0]) + "]]"
+			}
+		}
+		parts := make([]string, 0, len(val))
+		for _, item := range val {
+			parts = append(parts, stringValue(item))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+func parseDate(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var (
+	inlineTagRe = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_\-/]*[\p{L}_\-/][\p{L}\p{N}_\-/]*)`)
+	headingRe   = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+)
+
+func extractTags(fm map[string]interface{}, content string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	add := func(t string) {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "#")
+		if t == "" || seen[strings.ToLower(t)] {
+			return
+		}
+		seen[strings.ToLower(t)] = true
+		tags = append(tags, t)
+	}
+
+	switch v := fm["tags"].(type) {
+	case []interface{}:
+		for _, t := range v {
+			add(stringValue(t))
+		}
+	case string:
+		for _, t := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }) {
+			add(t)
+		}
+	}
+
+	forEachBodyLine(content, func(line string) {
+		for _, m := range inlineTagRe.FindAllStringSubmatch(line, -1) {
+			add(m[1])
+		}
+	})
+	return tags
+}
+
+func extractLinks(fm map[string]interface{}, content string) []Link {
+	seen := make(map[Link]bool)
+	var links []Link
+	add := func(field, raw string) {
+		for _, l := range ParseLinks(raw) {
+			l.Field = field
+			if seen[l] {
+				continue
+			}
+			seen[l] = true
+			links = append(links, l)
+		}
+	}
+	for _, key := range sortedKeys(fm) {
+		add(key, stringValue(fm[key]))
+	}
+	forEachBodyLine(content, func(line string) { add("", line) })
+	return links
+}
+
+func extractHeadings(content string) []Heading {
+	var headings []Heading
+	forEachBodyLine(content, func(line string) {
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			headings = append(headings, Heading{Level: len(m[1]), Text: m[2]})
+		}
+	})
+	return headings
+}
+
+// forEachBodyLine calls fn for each line outside fenced code blocks
+func forEachBodyLine(content string, fn func(line string)) {
+	inFence := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		fn(line)
+	}
+}
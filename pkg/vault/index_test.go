@@ -0,0 +1,112 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexScanAndQuery(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "3. Projects/Active/Website.md", "---\nstatus: active\ntype: project\ntags: [web]\n---\n# Website\n\nSee [[Design Doc|design]] #urgent\n")
+	writeTestFile(t, root, "3. Projects/Active/Garden.md", "---\nstatus: on-hold\ntype: project\n---\n# Garden\n")
+	writeTestFile(t, root, "2. Next Actions/@calls/Call Bob.md", "---\nstatus: next\nproject: \"[[Website]]\"\ndue_date: 2026-02-10\n---\n# Call Bob\n")
+	writeTestFile(t, root, "1. Inbox/Broken.md", "---\nstatus: [unclosed\n---\nbody\n")
+	writeTestFile(t, root, ".obsidian/ignored.md", "# hidden")
+
+	idx := NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if got := len(idx.All()); got != 4 {
+		t.Fatalf("expected 4 notes, got %d", got)
+	}
+
+	active := idx.Query(Query{Folder: "3. Projects", Status: "active"})
+	if len(active) != 1 || active[0].Title != "Website" {
+		t.Fatalf("unexpected active projects: %+v", active)
+	}
+	if !active[0].HasTag("web") || !active[0].HasTag("#urgent") {
+		t.Errorf("tags = %v", active[0].Tags)
+	}
+	if len(active[0].Links) != 1 || active[0].Links[0].Target != "Design Doc" {
+		t.Errorf("links = %v", active[0].Links)
+	}
+	if len(active[0].Headings) != 1 || active[0].Headings[0].Text != "Website" {
+		t.Errorf("headings = %v", active[0].Headings)
+	}
+
+	calls := idx.Query(Query{Context: "@calls", Project: "Website"})
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 call action, got %d", len(calls))
+	}
+	if calls[0].String("due_date") != "2026-02-10" {
+		t.Errorf("due_date = %q", calls[0].String("due_date"))
+	}
+
+	due := idx.Query(Query{
+		DueFrom: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		DueTo:   time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC),
+	})
+	if len(due) != 1 {
+		t.Errorf("expected 1 note in due range, got %d", len(due))
+	}
+
+	if byField := idx.Query(Query{Fields: map[string]string{"tags": "WEB", "type": "project"}}); len(byField) != 1 {
+		t.Errorf("expected 1 note matching fields, got %d", len(byField))
+	}
+	if byLink := idx.Query(Query{Fields: map[string]string{"project": "Website"}}); len(byLink) != 1 {
+		t.Errorf("expected 1 note linking the project field, got %d", len(byLink))
+	}
+
+	broken, ok := idx.Get(filepath.Join("1. Inbox", "Broken.md"))
+	if !ok || broken.ParseError == "" {
+		t.Errorf("expected parse error recorded, got %+v", broken)
+	}
+}
+
+func TestIndexIncrementalUpdate(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "1. Inbox/A.md", "---\nstatus: inbox\n---\nA\n")
+	idx := NewIndex(root, nil)
+	idx.Scan()
+
+	rel := filepath.Join("1. Inbox", "A.md")
+	before, _ := idx.Get(rel)
+
+	writeTestFile(t, root, "1. Inbox/A.md", "---\nstatus: processed\n---\nA changed\n")
+	if err := idx.Update(rel); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	after, _ := idx.Get(rel)
+	if after.Hash == before.Hash || after.Status() != "processed" {
+		t.Errorf("entry not refreshed: %+v", after)
+	}
+
+	os.Remove(filepath.Join(root, rel))
+	idx.Scan()
+	if _, ok := idx.Get(rel); ok {
+		t.Error("expected deleted note to be removed from index")
+	}
+}
+
+func TestIndexStopTwice(t *testing.T) {
+	idx := NewIndex(t.TempDir(), nil)
+	if err := idx.Start(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	idx.Stop()
+	idx.Stop()
+}
@@ -0,0 +1,155 @@
+package vault
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Link is a parsed [[wikilink]]
+type Link struct {
+	Target  string `json:"target"`            // note name or path, without .md; empty for links within the same note
+	Heading string `json:"heading,omitempty"` // [[Note#Heading]]
+	BlockID string `json:"block_id,omitempty"`
+	Alias   string `json:"alias,omitempty"` // [[Note|Alias]]
+	Embed   bool   `json:"embed,omitempty"` // ![[Note]]
+	Field   string `json:"field,omitempty"` // frontmatter key the link appeared in; empty for the body
+}
+
+var (
+	linkTokenRe  = regexp.MustCompile(`(!?)\[\[([^\[\]]+)\]\]`)
+	inlineCodeRe = regexp.MustCompile("`[^`]*`")
+)
+
+// ParseLinks returns the wikilinks in text, skipping inline code spans.
+func ParseLinks(text string) []Link {
+	text = inlineCodeRe.ReplaceAllStringFunc(text, func(s string) string {
+		return strings.Repeat(" ", len(s))
+	})
+	var links []Link
+	for _, m := range linkTokenRe.FindAllStringSubmatch(text, -1) {
+		if l, ok := parseLinkBody(m[2]); ok {
+			l.Embed = m[1] == "!"
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// ParseLink parses a single [[wikilink]] or ![[embed]]. Bare names without
+// brackets are accepted too, as used in frontmatter fields like project.
+func ParseLink(s string) (Link, bool) {
+	s = strings.TrimSpace(s)
+	embed := strings.HasPrefix(s, "![[")
+	s = strings.TrimPrefix(s, "!")
+	if strings.HasPrefix(s, "[[") && strings.HasSuffix(s, "]]") {
+		s = s[2 : len(s)-2]
+	}
+	l, ok := parseLinkBody(s)
+	l.Embed = embed
+	return l, ok
+}
+
+func parseLinkBody(body string) (Link, bool) {
+	var l Link
+	// Links inside tables escape the alias separator
+	body = strings.ReplaceAll(body, `\|`, "|")
+	if i := strings.Index(body, "|"); i >= 0 {
+		l.Alias = strings.TrimSpace(body[i+1:])
+		body = body[:i]
+	}
+	if i := strings.Index(body, "#"); i >= 0 {
+		ref := strings.TrimSpace(body[i+1:])
+		if strings.HasPrefix(ref, "^") {
+			l.BlockID = strings.TrimPrefix(ref, "^")
+		} else {
+			l.Heading = ref
+		}
+		body = body[:i]
+	}
+	l.Target = strings.TrimSuffix(strings.TrimSpace(body), ".md")
+	if l.Target == "" && l.Heading == "" && l.BlockID == "" {
+		return Link{}, false
+	}
+	return l, true
+}
+
+// String renders the link back to wikilink syntax.
+func (l Link) String() string {
+	var b strings.Builder
+	if l.Embed {
+		b.WriteString("!")
+	}
+	b.WriteString("[[")
+	b.WriteString(l.Target)
+	if l.BlockID != "" {
+		b.WriteString("#^" + l.BlockID)
+	} else if l.Heading != "" {
+		b.WriteString("#" + l.Heading)
+	}
+	if l.Alias != "" {
+		b.WriteString("|" + l.Alias)
+	}
+	b.WriteString("]]")
+	return b.String()
+}
+
+// RewriteLinks calls fn for every wikilink in content outside code blocks and
+// inline code, replacing the link when fn returns true.
+func RewriteLinks(content string, fn func(Link) (Link, bool)) string {
+	lines := strings.Split(content, "\n")
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || !strings.Contains(line, "[[") {
+			continue
+		}
+		lines[i] = rewriteLine(line, fn)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func rewriteLine(line string, fn func(Link) (Link, bool)) string {
+	code := inlineCodeRe.FindAllStringIndex(line, -1)
+	inCode := func(pos int) bool {
+		for _, c := range code {
+			if pos >= c[0] && pos < c[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range linkTokenRe.FindAllStringSubmatchIndex(line, -1) {
+		if inCode(m[0]) {
+			continue
+		}
+		l, ok := parseLinkBody(line[m[4]:m[5]])
+		if !ok {
+			continue
+		}
+		l.Embed = m[3] > m[2]
+		escaped := strings.Contains(line[m[4]:m[5]], `\|`)
+		updated, changed := fn(l)
+		if !changed {
+			continue
+		}
+		text := updated.String()
+		if escaped {
+			text = strings.Replace(text, "|", `\|`, 1)
+		}
+		b.WriteString(line[last:m[0]])
+		b.WriteString(text)
+		last = m[1]
+	}
+	if last == 0 {
+		return line
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package vault
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLinks(t *testing.T) {
+	links := ParseLinks("See [[Website|the site]], ![[diagram.png]], [[Plan#Goals]], [[Plan#^abc123]], [[#Local]] and `[[not a link]]`. " +
+		"| [[Table\\|alias]] |")
+
+	want := []Link{
+		{Target: "Website", Alias: "the site"},
+		{Target: "diagram.png", Embed: true},
+		{Target: "Plan", Heading: "Goals"},
+		{Target: "Plan", BlockID: "abc123"},
+		{Heading: "Local"},
+		{Target: "Table", Alias: "alias"},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("got %+v", links)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Errorf("link %d = %+v, want %+v", i, links[i], want[i])
+		}
+	}
+	if s := links[3].String(); s != "[[Plan#^abc123]]" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestGraphBacklinks(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "3. Projects/Active/Website.md", "---\nstatus: active\n---\nSee [[Launch checklist]]\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Deploy.md", "---\nstatus: next\nproject: \"[[Website]]\"\n---\n")
+	writeTestFile(t, root, "5. Reference/Hosting.md", "Notes for [[3. Projects/Active/Website#Hosting|hosting]]\n")
+	writeTestFile(t, root, "Inbox.md", "[[Nowhere]]\n")
+
+	index := NewIndex(root, nil)
+	if err := index.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	g := index.Graph()
+
+	website := filepath.Join("3. Projects", "Active", "Website.md")
+	back := g.Backlinks(website)
+	if len(back) != 2 {
+		t.Fatalf("expected 2 backlinks, got %+v", back)
+	}
+	if back[0].Source != filepath.Join("2. Next Actions", "@computer", "Deploy.md") || back[0].Link.Field != "project" {
+		t.Errorf("unexpected backlink %+v", back[0])
+	}
+	if back[1].Link.Heading != "Hosting" {
+		t.Errorf("unexpected backlink %+v", back[1])
+	}
+	if unresolved := g.Unresolved(); len(unresolved) != 2 {
+		t.Errorf("expected 2 unresolved links, got %+v", unresolved)
+	}
+
+	// The graph is rebuilt after the index changes
+	writeTestFile(t, root, "Launch checklist.md", "# Launch\n")
+	index.Update("Launch checklist.md")
+	if back := index.Graph().Backlinks("Launch checklist.md"); len(back) != 1 {
+		t.Errorf("expected backlink after update, got %+v", back)
+	}
+}
@@ -0,0 +1,296 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Lint checks reported in a HealthReport
+const (
+	CheckBrokenLink     = "broken_link"
+	CheckMissingProject = "missing_project"
+	CheckStalledProject = "project_without_next_action"
+	CheckStaleInbox     = "stale_inbox"
+	CheckUnparseable    = "unparseable_frontmatter"
+	CheckPlaceholder    = "template_placeholder"
+	CheckInvalidField   = "invalid_field"
+)
+
+// HealthReportPath is where the Markdown health report is written in the vault
+const HealthReportPath = "0. GTD System/Vault Health.md"
+
+const templatesFolder = "0. GTD System/Templates"
+
+// promptsFolder holds the AI prompt templates, which are not GTD notes
+const promptsFolder = "0. GTD System/Prompts"
+
+// LintOptions tunes the vault lint checks
+type LintOptions struct {
+	InboxMaxAge time.Duration // inbox items older than this are reported (default 7 days)
+	Now         time.Time     // defaults to time.Now()
+}
+
+// LintIssue is one problem found in the vault
+type LintIssue struct {
+	Check   string `json:"check"`
+	Path    string `json:"path"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// HealthReport is the result of linting the vault
+type HealthReport struct {
+	GeneratedAt  time.Time      `json:"generated_at"`
+	NotesChecked int            `json:"notes_checked"`
+	Counts       map[string]int `json:"counts"`
+	Issues       []LintIssue    `json:"issues"`
+}
+
+// Lint checks every indexed note for broken links, dangling project
+// references, stalled projects, stale inbox items, bad frontmatter and
+// leftover template placeholders. Templates, prompts and the report itself
+// are skipped.
+func Lint(index *Index, opts LintOptions) *HealthReport {
+	if opts.InboxMaxAge <= 0 {
+		opts.InboxMaxAge = 7 * 24 * time.Hour
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	all := index.All()
+	var notes []IndexedNote
+	for _, n := range all {
+		if InFolder(n.Path, templatesFolder) || InFolder(n.Path, promptsFolder) || n.Path == filepath.FromSlash(HealthReportPath) {
+			continue
+		}
+		notes = append(notes, n)
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Path < notes[j].Path })
+
+	report := &HealthReport{
+		GeneratedAt:  opts.Now,
+		NotesChecked: len(notes),
+		Counts:       make(map[string]int),
+		Issues:       []LintIssue{},
+	}
+	add := func(check, path, field, format string, args ...interface{}) {
+		report.Issues = append(report.Issues, LintIssue{
+			Check:   check,
+			Path:    path,
+			Field:   field,
+			Message: fmt.Sprintf(format, args...),
+		})
+		report.Counts[check]++
+	}
+
+	graph := index.Graph()
+	attachments := &attachmentFinder{vaultPath: index.VaultPath()}
+	projects := make(map[string]IndexedNote)
+	for _, n := range notes {
+		if s := SchemaFor(n.Path, n.Frontmatter); s != nil && s.Name == "project" {
+			projects[n.Path] = n
+		}
+	}
+	actionsByProject := make(map[string]int)
+	actionPaths := make(map[string]bool)
+
+	for _, n := range notes {
+		if n.ParseError != "" {
+			add(CheckUnparseable, n.Path, "", "frontmatter could not be parsed: %s", n.ParseError)
+		}
+
+		placeholders := make(map[string]bool)
+		for _, key := range sortedKeys(n.Frontmatter) {
+			if p, ok := placeholderValue(n.Frontmatter[key]); ok {
+				placeholders[key] = true
+				add(CheckPlaceholder, n.Path, key, "template placeholder %s was not filled in", p)
+			}
+		}
+
+		schema, issues := n.Validate()
+		for _, issue := range issues {
+			if !placeholders[issue.Field] {
+				add(CheckInvalidField, n.Path, issue.Field, "%s", issue.Message)
+			}
+		}
+		isAction := schema != nil && (schema.Name == "next-action" || schema.Name == "waiting-for")
+		if isAction {
+			actionPaths[n.Path] = true
+		}
+
+		for _, e := range graph.Links(n.Path) {
+			if placeholders[e.Link.Field] {
+				continue
+			}
+			if isAction && e.Link.Field == "project" {
+				if _, ok := projects[e.Target]; ok {
+					actionsByProject[e.Target]++
+				} else {
+					add(CheckMissingProject, n.Path, "project", "project %q does not exist", e.Link.Target)
+				}
+				continue
+			}
+			if e.Target == "" && !attachments.exists(e.Link.Target) {
+				add(CheckBrokenLink, n.Path, "", "link to missing note %q", e.Link.Target)
+			}
+		}
+	}
+
+	for _, n := range notes {
+		if InFolder(n.Path, "1. Inbox") {
+			created, ok := parseDate(n.String("created"))
+			if !ok {
+				created = n.ModTime
+			}
+			if age := opts.Now.Sub(created); age > opts.InboxMaxAge {
+				add(CheckStaleInbox, n.Path, "", "in inbox for %d days", int(age.Hours()/24))
+			}
+		}
+	}
+
+	for _, path := range sortedKeys(projects) {
+		if p := projects[path]; p.Status() != "active" || actionsByProject[path] > 0 {
+			continue
+		}
+		linked := false
+		for _, e := range graph.Links(path) {
+			if actionPaths[e.Target] {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			add(CheckStalledProject, path, "", "active project has no next action")
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		if report.Issues[i].Check != report.Issues[j].Check {
+			return report.Issues[i].Check < report.Issues[j].Check
+		}
+		return report.Issues[i].Path < report.Issues[j].Path
+	})
+	return report
+}
+
+// Markdown renders the report as a vault note.
+func (r *HealthReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ncreated: %s\ntype: vault-health\ntags: [vault-health]\n---\n\n", r.GeneratedAt.Format("2006-01-02"))
+	b.WriteString("# Vault Health\n\n")
+	fmt.Fprintf(&b, "Generated %s. Checked %d notes, found %d issue(s).\n",
+		r.GeneratedAt.Format("2006-01-02 15:04"), r.NotesChecked, len(r.Issues))
+
+	sections := []struct{ check, title string }{
+		{CheckUnparseable, "Unparseable Frontmatter"},
+		{CheckPlaceholder, "Template Placeholders"},
+		{CheckInvalidField, "Invalid Fields"},
+		{CheckBrokenLink, "Broken Links"},
+		{CheckMissingProject, "Actions With Missing Projects"},
+		{CheckStalledProject, "Projects Without Next Actions"},
+		{CheckStaleInbox, "Stale Inbox Items"},
+	}
+	for _, sec := range sections {
+		if r.Counts[sec.check] == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", sec.title, r.Counts[sec.check])
+		for _, issue := range r.Issues {
+			if issue.Check != sec.check {
+				continue
+			}
+			// Messages may quote missing notes, so keep them out of wikilinks.
+			link := strings.TrimSuffix(filepath.ToSlash(issue.Path), ".md")
+			if issue.Field != "" {
+				fmt.Fprintf(&b, "- [[%s]] `%s`: %s\n", link, issue.Field, issue.Message)
+			} else {
+				fmt.Fprintf(&b, "- [[%s]]: %s\n", link, issue.Message)
+			}
+		}
+	}
+	if len(r.Issues) == 0 {
+		b.WriteString("\nNo issues found.\n")
+	}
+	return b.String()
+}
+
+// WriteHealthReport writes the Markdown report to HealthReportPath in the vault.
+func WriteHealthReport(vaultPath string, r *HealthReport) error {
+	path := filepath.Join(vaultPath, filepath.FromSlash(HealthReportPath))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(r.Markdown()), 0644)
+}
+
+// attachmentFinder checks links to non-note files (images, PDFs), which
+// Obsidian resolves by file name anywhere in the vault.
+type attachmentFinder struct {
+	vaultPath string
+	names     map[string]bool // lower-cased file names, loaded on first use
+}
+
+func (f *attachmentFinder) exists(target string) bool {
+	ext := filepath.Ext(target)
+	if ext == "" || strings.Contains(ext, " ") {
+		return false
+	}
+	if f.names == nil {
+		f.names = make(map[string]bool)
+		filepath.Walk(f.vaultPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() && path != f.vaultPath && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() {
+				f.names[strings.ToLower(info.Name())] = true
+			}
+			return nil
+		})
+	}
+	return f.names[strings.ToLower(filepath.Base(target))]
+}
+
+var placeholderRe = regexp.MustCompile(`\{\{[^}]*\}\}`)
+
+// placeholderValue reports whether v is an unfilled template placeholder such
+// as [low/medium/high/urgent], {{date}} or "[[Project Name]]".
+func placeholderValue(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		// {{date:YYYY-MM-DD}} parses as a flow mapping
+		return "{{...}}", true
+	case []interface{}:
+		if len(val) == 1 {
+			if s, ok := val[0].(string); ok && strings.Contains(s, "/") && !strings.Contains(s, " ") {
+				return "[" + s + "]", true
+			}
+		}
+	case string:
+		if m := placeholderRe.FindString(val); m != "" {
+			return m, true
+		}
+	}
+	switch s := stringValue(v); s {
+	case "[[Project Name]]", "Person/Organization":
+		return fmt.Sprintf("%q", s), true
+	}
+	return "", false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,71 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLint(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "3. Projects/Active/Website.md", "---\nstatus: active\ntype: project\n---\n# Website\n")
+	writeTestFile(t, root, "3. Projects/Active/Garden.md", "---\nstatus: active\ntype: project\n---\n# Garden\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Deploy.md",
+		"---\nstatus: next\ncontext: \"@computer\"\nproject: \"[[Website]]\"\n---\nSee [[Runbook]] and ![[diagram.png]].\n")
+	writeTestFile(t, root, "2. Next Actions/@calls/Call vendor.md",
+		"---\nstatus: next\nproject: \"[[Kitchen]]\"\npriority: [low/medium/high/urgent]\n---\n")
+	writeTestFile(t, root, "1. Inbox/Old idea.md", "---\ncreated: 2026-01-01\nstatus: inbox\n---\n")
+	writeTestFile(t, root, "1. Inbox/Broken.md", "---\nstatus: [unclosed\n---\n")
+	writeTestFile(t, root, "0. GTD System/Templates/Next Action Template.md",
+		"---\nstatus: [next/waiting/scheduled/delegated]\nproject: \"[[Project Name]]\"\n---\n")
+	writeTestFile(t, root, "diagram.png", "png")
+
+	index := NewIndex(root, nil)
+	if err := index.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)
+	report := Lint(index, LintOptions{Now: now})
+
+	found := map[string]string{}
+	for _, issue := range report.Issues {
+		found[issue.Check+" "+filepath.ToSlash(issue.Path)] = issue.Message
+		if strings.Contains(issue.Path, "Templates") {
+			t.Errorf("templates should be skipped: %+v", issue)
+		}
+	}
+	for _, want := range []string{
+		CheckBrokenLink + " 2. Next Actions/@computer/Deploy.md",
+		CheckMissingProject + " 2. Next Actions/@calls/Call vendor.md",
+		CheckPlaceholder + " 2. Next Actions/@calls/Call vendor.md",
+		CheckStalledProject + " 3. Projects/Active/Garden.md",
+		CheckStaleInbox + " 1. Inbox/Old idea.md",
+		CheckUnparseable + " 1. Inbox/Broken.md",
+	} {
+		if _, ok := found[want]; !ok {
+			t.Errorf("missing issue %q in %+v", want, report.Issues)
+		}
+	}
+	if msg := found[CheckBrokenLink+" 2. Next Actions/@computer/Deploy.md"]; !strings.Contains(msg, "Runbook") {
+		t.Errorf("broken link message = %q", msg)
+	}
+	if _, ok := found[CheckStalledProject+" 3. Projects/Active/Website.md"]; ok {
+		t.Error("Website has a next action")
+	}
+	if report.Counts[CheckBrokenLink] != 1 {
+		t.Errorf("attachment link should resolve, got %d broken links", report.Counts[CheckBrokenLink])
+	}
+
+	if err := WriteHealthReport(root, report); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, HealthReportPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "## Stale Inbox Items (1)") {
+		t.Errorf("unexpected report:\n%s", data)
+	}
+}
@@ -21,15 +21,18 @@ type Project struct {
 	Type              string `yaml:"type"`   // project
 	DueDate           string `yaml:"due_date,omitempty"`
 	ReviewDate        string `yaml:"review_date,omitempty"`
+	Closed            string `yaml:"closed,omitempty"` // completed or cancelled on
 }
 
 // NextAction represents a task with a context
 type NextAction struct {
 	CommonFrontmatter `yaml:",inline"`
-	Status            string `yaml:"status"`  // next, waiting, scheduled, delegated
+	Status            string `yaml:"status"`  // next, waiting, scheduled, delegated, done
 	Context           string `yaml:"context"` // @calls, @computer, etc.
 	Project           string `yaml:"project,omitempty"`
 	DueDate           string `yaml:"due_date,omitempty"`
+	Completed         string `yaml:"completed,omitempty"`
+	Recur             string `yaml:"recur,omitempty"` // e.g. "every 2 weeks" or an RRULE
 }
 
 // WeeklyReview represents a weekly review note
@@ -39,9 +42,58 @@ type WeeklyReview struct {
 	WeekOf            string `yaml:"week_of"`
 }
 
+// WaitingFor represents an item delegated to or expected from someone else
+type WaitingFor struct {
+	CommonFrontmatter `yaml:",inline"`
+	Status            string `yaml:"status"` // waiting, done
+	WaitingFor        string `yaml:"waiting_for"`
+	Email             string `yaml:"email,omitempty"`
+	DateRequested     string `yaml:"date_requested,omitempty"`
+	FollowUpDate      string `yaml:"follow_up_date,omitempty"`
+	Completed         string `yaml:"completed,omitempty"`
+	Project           string `yaml:"project,omitempty"`
+}
+
+// Reference represents a reference note
+type Reference struct {
+	CommonFrontmatter `yaml:",inline"`
+	Type              string `yaml:"type"`     // reference
+	Category          string `yaml:"category"` // process, contact, document, knowledge
+}
+
+// SomedayMaybe represents an item or list in Someday Maybe
+type SomedayMaybe struct {
+	CommonFrontmatter `yaml:",inline"`
+	Type              string `yaml:"type,omitempty"` // someday-maybe
+	Status            string `yaml:"status,omitempty"`
+	ReviewDate        string `yaml:"review_date,omitempty"`
+}
+
+// DailyCapture represents a daily capture note
+type DailyCapture struct {
+	CommonFrontmatter `yaml:",inline"`
+	Type              string `yaml:"type"` // daily-capture
+}
+
+// QuarterlyReview represents a quarterly review note
+type QuarterlyReview struct {
+	CommonFrontmatter `yaml:",inline"`
+	Type              string `yaml:"type"`    // quarterly-review
+	Quarter           string `yaml:"quarter"` // e.g. 2024-Q4
+}
+
+// AnnualReview represents an annual review note
+type AnnualReview struct {
+	CommonFrontmatter `yaml:",inline"`
+	Type              string `yaml:"type"` // annual-review
+	Year              string `yaml:"year"`
+}
+
 // Note represents a parsed markdown note
 type Note struct {
 	Path        string
 	Frontmatter interface{}
 	Content     string // The markdown content after frontmatter
+
+	source []byte // file contents the note was parsed from, used by WriteNote
 }
@@ -0,0 +1,261 @@
+package vault
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// momentTokens lists Moment.js format tokens, longest first so that e.g.
+// MMMM wins over MM at the same position.
+var momentTokens = []string{
+	"YYYYYY", "YYYY", "GGGG", "gggg", "MMMM", "dddd", "DDDD", "SSSS",
+	"DDDo", "MMM", "ddd", "DDD", "SSS",
+	"YY", "GG", "gg", "Qo", "MM", "Mo", "DD", "Do", "dd", "do", "WW", "Wo", "ww", "wo",
+	"HH", "hh", "kk", "mm", "ss", "SS", "ZZ",
+	"Y", "Q", "M", "D", "d", "e", "E", "W", "w", "H", "h", "k", "m", "s", "S", "a", "A", "Z", "X", "x",
+}
+
+// FormatMoment formats t using a Moment.js format string, as used by Obsidian
+// templates. Text in [brackets] is copied literally. Locale-dependent tokens
+// use Moment's default English locale.
+func FormatMoment(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		if format[i] == '[' {
+			if end := strings.IndexByte(format[i:], ']'); end > 0 {
+				b.WriteString(format[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+		token := ""
+		for _, tok := range momentTokens {
+			if strings.HasPrefix(format[i:], tok) {
+				token = tok
+				break
+			}
+		}
+		if token == "" {
+			b.WriteByte(format[i])
+			i++
+			continue
+		}
+		b.WriteString(formatMomentToken(t, token))
+		i += len(token)
+	}
+	return b.String()
+}
+
+func formatMomentToken(t time.Time, token string) string {
+	switch token {
+	// Year
+	case "YYYYYY":
+		return fmt.Sprintf("%+07d", t.Year())
+	case "YYYY":
+		return fmt.Sprintf("%04d", t.Year())
+	case "YY":
+		return fmt.Sprintf("%02d", t.Year()%100)
+	case "Y":
+		return strconv.Itoa(t.Year())
+	case "GGGG":
+		y, _ := weekOfYear(t, 1, 4)
+		return fmt.Sprintf("%04d", y)
+	case "GG":
+		y, _ := weekOfYear(t, 1, 4)
+		return fmt.Sprintf("%02d", y%100)
+	case "gggg":
+		y, _ := weekOfYear(t, 0, 6)
+		return fmt.Sprintf("%04d", y)
+	case "gg":
+		y, _ := weekOfYear(t, 0, 6)
+		return fmt.Sprintf("%02d", y%100)
+
+	// Quarter
+	case "Q":
+		return strconv.Itoa(quarterOf(t))
+	case "Qo":
+		return ordinal(quarterOf(t))
+
+	// Month
+	case "M":
+		return strconv.Itoa(int(t.Month()))
+	case "Mo":
+		return ordinal(int(t.Month()))
+	case "MM":
+		return fmt.Sprintf("%02d", int(t.Month()))
+	case "MMM":
+		return t.Month().String()[:3]
+	case "MMMM":
+		return t.Month().String()
+
+	// Day of month / year
+	case "D":
+		return strconv.Itoa(t.Day())
+	case "Do":
+		return ordinal(t.Day())
+	case "DD":
+		return fmt.Sprintf("%02d", t.Day())
+	case "DDD":
+		return strconv.Itoa(t.YearDay())
+	case "DDDo":
+		return ordinal(t.YearDay())
+	case "DDDD":
+		return fmt.Sprintf("%03d", t.YearDay())
+
+	// Day of week
+	case "d":
+		return strconv.Itoa(int(t.Weekday()))
+	case "do":
+		return ordinal(int(t.Weekday()))
+	case "dd":
+		return t.Weekday().String()[:2]
+	case "ddd":
+		return t.Weekday().String()[:3]
+	case "dddd":
+		return t.Weekday().String()
+	case "e":
+		return strconv.Itoa(int(t.Weekday()))
+	case "E":
+		return strconv.Itoa(isoWeekday(t))
+
+	// Week of year
+	case "w":
+		_, w := weekOfYear(t, 0, 6)
+		return strconv.Itoa(w)
+	case "wo":
+		_, w := weekOfYear(t, 0, 6)
+		return ordinal(w)
+	case "ww":
+		_, w := weekOfYear(t, 0, 6)
+		return fmt.Sprintf("%02d", w)
+	case "W":
+		_, w := t.ISOWeek()
+		return strconv.Itoa(w)
+	case "Wo":
+		_, w := t.ISOWeek()
+		return ordinal(w)
+	case "WW":
+		_, w := t.ISOWeek()
+		return fmt.Sprintf("%02d", w)
+
+	// Time
+	case "H":
+		return strconv.Itoa(t.Hour())
+	case "HH":
+		return fmt.Sprintf("%02d", t.Hour())
+	case "h":
+		return strconv.Itoa(hour12(t))
+	case "hh":
+		return fmt.Sprintf("%02d", hour12(t))
+	case "k":
+		return strconv.Itoa(hour24From1(t))
+	case "kk":
+		return fmt.Sprintf("%02d", hour24From1(t))
+	case "m":
+		return strconv.Itoa(t.Minute())
+	case "mm":
+		return fmt.Sprintf("%02d", t.Minute())
+	case "s":
+		return strconv.Itoa(t.Second())
+	case "ss":
+		return fmt.Sprintf("%02d", t.Second())
+	case "S", "SS", "SSS", "SSSS":
+		frac := fmt.Sprintf("%09d", t.Nanosecond())
+		return frac[:len(token)]
+	case "a":
+		if t.Hour() < 12 {
+			return "am"
+		}
+		return "pm"
+	case "A":
+		if t.Hour() < 12 {
+			return "AM"
+		}
+		return "PM"
+
+	// Zone and timestamps
+	case "Z":
+		return t.Format("-07:00")
+	case "ZZ":
+		return t.Format("-0700")
+	case "X":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "x":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return token
+}
+
+func quarterOf(t time.Time) int {
+	return (int(t.Month())-1)/3 + 1
+}
+
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+func hour12(t time.Time) int {
+	h := t.Hour() % 12
+	if h == 0 {
+		return 12
+	}
+	return h
+}
+
+func hour24From1(t time.Time) int {
+	if t.Hour() == 0 {
+		return 24
+	}
+	return t.Hour()
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// weekOfYear returns the week-year and week number of t for weeks starting on
+// weekday dow, where week 1 is the week containing January 7+dow-doy. This is
+// Moment's algorithm: (1, 4) gives ISO weeks, (0, 6) English locale weeks.
+func weekOfYear(t time.Time, dow, doy int) (int, int) {
+	year := t.Year()
+	offset := firstWeekOffset(year, dow, doy)
+	week := (t.YearDay()-offset-1)/7 + 1
+	if t.YearDay()-offset-1 < 0 {
+		week = 0
+	}
+	switch {
+	case week < 1:
+		year--
+		week += weeksInYear(year, dow, doy)
+	case week > weeksInYear(year, dow, doy):
+		week -= weeksInYear(year, dow, doy)
+		year++
+	}
+	return year, week
+}
+
+func firstWeekOffset(year, dow, doy int) int {
+	fwd := 7 + dow - doy
+	fwdlw := (7 + int(time.Date(year, time.January, fwd, 0, 0, 0, 0, time.UTC).Weekday()) - dow) % 7
+	return -fwdlw + fwd - 1
+}
+
+func weeksInYear(year, dow, doy int) int {
+	days := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	return (days - firstWeekOffset(year, dow, doy) + firstWeekOffset(year+1, dow, doy)) / 7
+}
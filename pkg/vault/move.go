@@ -0,0 +1,225 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoteExists is returned when a note would overwrite an existing one
+var ErrNoteExists = errors.New("note already exists")
+
+// MoveHook is called after a note has been moved, e.g. to migrate database
+// rows that reference the old path. Paths are relative to the vault root.
+type MoveHook func(oldPath, newPath string) error
+
+// MoveResult describes a completed move
+type MoveResult struct {
+	OldPath   string   `json:"old_path"`
+	NewPath   string   `json:"new_path"`
+	Rewritten []string `json:"rewritten"` // notes whose links were updated
+}
+
+// Mover renames notes and keeps every reference to them intact
+type Mover struct {
+	index  *Index
+	commit func(message string) error // may be nil
+	hooks  []MoveHook
+}
+
+// NewMover creates a mover. commit is called once per move with a commit
+// message and may be nil.
+func NewMover(index *Index, commit func(message string) error, hooks ...MoveHook) *Mover {
+	return &Mover{index: index, commit: commit, hooks: hooks}
+}
+
+// Move renames the note at oldPath to newPath, rewrites [[links]] and project
+// fields that point at it, runs the move hooks and commits everything at once.
+func (m *Mover) Move(oldPath, newPath string) (*MoveResult, error) {
+	return m.MoveIf(oldPath, newPath, nil)
+}
+
+// MoveIf is Move that first passes the content of the note to check, under
+// the note lock, and gives up with its error unless it returns nil. check may
+// be nil.
+func (m *Mover) MoveIf(oldPath, newPath string, check func(data []byte) error) (*MoveResult, error) {
+	m.index.LockNotes()
+	defer m.index.UnlockNotes()
+	return m.move(oldPath, newPath, check)
+}
+
+// move is MoveIf for callers that already hold the note lock
+func (m *Mover) move(oldPath, newPath string, check func(data []byte) error) (*MoveResult, error) {
+	oldPath, err := CleanNotePath(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newPath, err = CleanNotePath(newPath)
+	if err != nil {
+		return nil, err
+	}
+	if oldPath == newPath {
+		return nil, fmt.Errorf("source and destination are the same")
+	}
+
+	vaultPath := m.index.VaultPath()
+	oldAbs := filepath.Join(vaultPath, oldPath)
+	newAbs := filepath.Join(vaultPath, newPath)
+	if _, err := os.Stat(oldAbs); err != nil {
+		return nil, fmt.Errorf("stat %s: %w", oldPath, err)
+	}
+	if check != nil {
+		data, err := os.ReadFile(oldAbs)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", oldPath, err)
+		}
+		if err := check(data); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := os.Stat(newAbs); err == nil {
+		return nil, fmt.Errorf("%s: %w", newPath, ErrNoteExists)
+	}
+	if err := m.index.Update(oldPath); err != nil {
+		return nil, fmt.Errorf("index %s: %w", oldPath, err)
+	}
+
+	graph := m.index.Graph()
+	oldTitle := strings.TrimSuffix(filepath.Base(oldPath), ".md")
+	newTitle := strings.TrimSuffix(filepath.Base(newPath), ".md")
+
+	sources := make(map[string]bool)
+	for _, e := range graph.Backlinks(oldPath) {
+		sources[e.Source] = true
+	}
+	for _, e := range graph.Links(oldPath) {
+		if e.Target == oldPath {
+			sources[oldPath] = true
+		}
+	}
+	for _, n := range m.index.Query(Query{Project: oldTitle}) {
+		sources[n.Path] = true
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newAbs), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(oldAbs, newAbs); err != nil {
+		return nil, fmt.Errorf("move note: %w", err)
+	}
+	if err := m.index.Remove(oldPath); err != nil {
+		log.Printf("Move: unindex %s: %v", oldPath, err)
+	}
+	if err := m.index.Update(newPath); err != nil {
+		log.Printf("Move: index %s: %v", newPath, err)
+	}
+
+	// Name links keep using the bare name unless it became ambiguous.
+	byName := newTitle
+	if p, ok := m.index.Graph().Resolve(newTitle); !ok || p != newPath {
+		byName = strings.TrimSuffix(filepath.ToSlash(newPath), ".md")
+	}
+	retarget := func(l Link) (Link, bool) {
+		if l.Target == "" {
+			return l, false
+		}
+		if p, ok := graph.Resolve(l.Target); !ok || p != oldPath {
+			return l, false
+		}
+		if strings.Contains(l.Target, "/") {
+			l.Target = strings.TrimSuffix(filepath.ToSlash(newPath), ".md")
+		} else {
+			l.Target = byName
+		}
+		return l, true
+	}
+
+	result := &MoveResult{OldPath: oldPath, NewPath: newPath, Rewritten: []string{}}
+	var errs []error
+	for _, src := range sortedKeys(sources) {
+		if src == oldPath {
+			src = newPath
+		}
+		changed, err := rewriteReferences(filepath.Join(vaultPath, src), oldTitle, byName, retarget)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src, err))
+			continue
+		}
+		if changed {
+			result.Rewritten = append(result.Rewritten, src)
+			if err := m.index.Update(src); err != nil {
+				log.Printf("Move: reindex %s: %v", src, err)
+			}
+		}
+	}
+
+	for _, hook := range m.hooks {
+		if err := hook(oldPath, newPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if m.commit != nil {
+		msg := fmt.Sprintf("Move %s to %s", oldPath, newPath)
+		if n := len(result.Rewritten); n > 0 {
+			msg += fmt.Sprintf(" and update links in %d note(s)", n)
+		}
+		if err := m.commit(msg); err != nil {
+			errs = append(errs, fmt.Errorf("commit: %w", err))
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// rewriteReferences updates the wikilinks in a note and a bare project: name
+// that refers to the moved note. It reports whether the file changed.
+func rewriteReferences(path, oldTitle, newName string, retarget func(Link) (Link, bool)) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	content := RewriteLinks(string(data), retarget)
+
+	if e, err := ParseFrontmatter([]byte(content)); err == nil {
+		if project, ok := e.Get("project"); ok && !strings.Contains(project, "[[") && strings.EqualFold(strings.TrimSpace(project), oldTitle) {
+			if err := e.Set("project", newName); err != nil {
+				return false, err
+			}
+			out, _ := e.Bytes()
+			content = string(out)
+		}
+	}
+
+	if content == string(data) {
+		return false, nil
+	}
+	return true, os.WriteFile(path, []byte(content), 0644)
+}
+
+// CleanNotePath validates a vault-relative note path and adds the .md
+// extension when missing. Absolute paths and paths leaving the vault are rejected.
+func CleanNotePath(relPath string) (string, error) {
+	relPath = strings.TrimSpace(filepath.FromSlash(relPath))
+	if relPath == "" {
+		return "", fmt.Errorf("empty note path")
+	}
+	if filepath.IsAbs(relPath) {
+		return "", fmt.Errorf("note path %q must be relative to the vault", relPath)
+	}
+	clean := filepath.Clean(relPath)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("note path %q is outside the vault", relPath)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(clean), "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("note path %q points into a hidden folder", relPath)
+		}
+	}
+	if !strings.HasSuffix(clean, ".md") {
+		clean += ".md"
+	}
+	return clean, nil
+}
@@ -0,0 +1,120 @@
+package vault
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMoveRewritesReferences(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "3. Projects/Website.md", "---\nstatus: active\n---\n# Website\nSee [[Website#Goals]].\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Deploy.md",
+		"---\n# keep this comment\nstatus: next\nproject: \"[[Website]]\"\n---\nShip it.\n")
+	writeTestFile(t, root, "2. Next Actions/@calls/Call host.md", "---\nstatus: next\nproject: Website\n---\n")
+	writeTestFile(t, root, "5. Reference/Hosting.md",
+		"Notes for [[3. Projects/Website|the site]] and ![[Website#^summary]].\n\n```\n[[Website]]\n```\n")
+
+	index := NewIndex(root, nil)
+	if err := index.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	var hookCalls [][2]string
+	var commits []string
+	mover := NewMover(index,
+		func(msg string) error { commits = append(commits, msg); return nil },
+		func(oldPath, newPath string) error {
+			hookCalls = append(hookCalls, [2]string{oldPath, newPath})
+			return nil
+		},
+	)
+
+	result, err := mover.Move("3. Projects/Website", "3. Projects/Archive/Company Site.md")
+	if err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if len(result.Rewritten) != 4 {
+		t.Errorf("expected 4 rewritten notes, got %v", result.Rewritten)
+	}
+
+	read := func(rel string) string {
+		data, err := os.ReadFile(filepath.Join(root, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if got := read("2. Next Actions/@computer/Deploy.md"); got != "---\n# keep this comment\nstatus: next\nproject: \"[[Company Site]]\"\n---\nShip it.\n" {
+		t.Errorf("Deploy.md:\n%s", got)
+	}
+	if got := read("2. Next Actions/@calls/Call host.md"); !strings.Contains(got, "project: Company Site\n") {
+		t.Errorf("Call host.md:\n%s", got)
+	}
+	got := read("5. Reference/Hosting.md")
+	if !strings.Contains(got, "[[3. Projects/Archive/Company Site|the site]]") || !strings.Contains(got, "![[Company Site#^summary]]") {
+		t.Errorf("Hosting.md:\n%s", got)
+	}
+	if !strings.Contains(got, "```\n[[Website]]\n```") {
+		t.Errorf("code block should be untouched:\n%s", got)
+	}
+	if got := read("3. Projects/Archive/Company Site.md"); !strings.Contains(got, "[[Company Site#Goals]]") {
+		t.Errorf("self link not updated:\n%s", got)
+	}
+
+	if len(hookCalls) != 1 || hookCalls[0] != [2]string{filepath.Join("3. Projects", "Website.md"), filepath.Join("3. Projects", "Archive", "Company Site.md")} {
+		t.Errorf("unexpected hook calls %v", hookCalls)
+	}
+	if len(commits) != 1 {
+		t.Errorf("expected one commit, got %v", commits)
+	}
+	if back := index.Graph().Backlinks(result.NewPath); len(back) != 3 {
+		t.Errorf("expected 3 backlinks after move, got %+v", back)
+	}
+}
+
+func TestMoveRejectsBadPaths(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "A.md", "a")
+	writeTestFile(t, root, "B.md", "b")
+	index := NewIndex(root, nil)
+	index.Scan()
+	mover := NewMover(index, nil)
+
+	if _, err := mover.Move("A.md", "../outside.md"); err == nil {
+		t.Error("expected error for path outside the vault")
+	}
+	if _, err := mover.Move("A.md", "B.md"); err == nil {
+		t.Error("expected error when destination exists")
+	}
+	if _, err := mover.Move("Missing.md", "C.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestMoveIfChecksContent(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "A.md", "a")
+	index := NewIndex(root, nil)
+	index.Scan()
+	mover := NewMover(index, nil)
+
+	stale := errors.New("stale")
+	if _, err := mover.MoveIf("A.md", "B.md", func(data []byte) error {
+		if string(data) != "a" {
+			t.Errorf("check got %q", data)
+		}
+		return stale
+	}); err != stale {
+		t.Fatalf("expected the check's error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "A.md")); err != nil {
+		t.Errorf("note moved despite failed check: %v", err)
+	}
+	if _, err := mover.MoveIf("A.md", "B.md", func([]byte) error { return nil }); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+}
@@ -0,0 +1,419 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InboxFolder holds unprocessed captures
+const InboxFolder = "1. Inbox"
+
+// Inbox processing actions
+const (
+	ActionTrash      = "trash"
+	ActionSomeday    = "someday"
+	ActionReference  = "reference"
+	ActionNextAction = "next_action"
+	ActionProject    = "project"
+	ActionWaitingFor = "waiting_for"
+)
+
+// ErrInvalidDecision is returned when an inbox decision cannot be applied as
+// given, e.g. a next action without a context
+var ErrInvalidDecision = errors.New("invalid inbox decision")
+
+// InboxActions lists the decisions that can be applied to an inbox item
+var InboxActions = []string{ActionTrash, ActionSomeday, ActionReference, ActionNextAction, ActionProject, ActionWaitingFor}
+
+// somedayTemplate is used when the vault has no "Someday Maybe Template"
+const somedayTemplate = `---
+created: {{date:YYYY-MM-DD}}
+type: someday-maybe
+review_date:
+tags: []
+---
+
+# {{title}}
+
+## Idea
+What is this and why might it matter?
+
+## Notes
+`
+
+// InboxDecision says what to do with an inbox item
+type InboxDecision struct {
+	Action      string `json:"action"`
+	Title       string `json:"title,omitempty"`          // title of the new note; defaults to the inbox item's
+	Context     string `json:"context,omitempty"`        // next_action: e.g. @calls
+	Project     string `json:"project,omitempty"`        // next_action, waiting_for: project name
+	Priority    string `json:"priority,omitempty"`       // next_action, project
+	DueDate     string `json:"due_date,omitempty"`       // next_action, project: YYYY-MM-DD
+	WaitingFor  string `json:"waiting_for,omitempty"`    // waiting_for: person or organization
+	FollowUp    string `json:"follow_up_date,omitempty"` // waiting_for: YYYY-MM-DD
+	Category    string `json:"category,omitempty"`       // reference: process, contact, document or knowledge
+	Outcome     string `json:"outcome,omitempty"`        // project: what done looks like
+	FirstAction string `json:"first_action,omitempty"`   // project: first next action
+	ReviewDate  string `json:"review_date,omitempty"`    // someday: when to look at it again
+}
+
+// Folder returns the folder the decision files the item into, or "" for
+// trash and unknown actions.
+func (d InboxDecision) Folder() string {
+	switch d.Action {
+	case ActionSomeday:
+		return "4. Someday Maybe"
+	case ActionReference:
+		return "5. Reference"
+	case ActionNextAction:
+		return filepath.Join("2. Next Actions", d.Context)
+	case ActionProject:
+		return filepath.Join("3. Projects", "Active")
+	case ActionWaitingFor:
+		return filepath.Join("2. Next Actions", "@waiting")
+	}
+	return ""
+}
+
+// ProcessResult describes a processed inbox item
+type ProcessResult struct {
+	Action    string `json:"action"`
+	InboxPath string `json:"inbox_path"`
+	NotePath  string `json:"note_path,omitempty"` // empty for trash
+	Title     string `json:"title"`
+	Original  string `json:"-"` // inbox file content before processing
+}
+
+// ProcessHook is called after an inbox item has been processed, e.g. to log
+// the decision.
+type ProcessHook func(result *ProcessResult, decision InboxDecision) error
+
+// InboxProcessor applies GTD processing decisions to inbox items
+type InboxProcessor struct {
+	index     *Index
+	templates *TemplateEngine
+	commit    func(message string) error // may be nil
+	hooks     []ProcessHook
+}
+
+// NewInboxProcessor creates a processor. commit is called once per processed
+// item and may be nil.
+func NewInboxProcessor(index *Index, templates *TemplateEngine, commit func(message string) error, hooks ...ProcessHook) *InboxProcessor {
+	return &InboxProcessor{index: index, templates: templates, commit: commit, hooks: hooks}
+}
+
+// Items returns the inbox items in processing order, oldest first.
+func (p *InboxProcessor) Items() []IndexedNote {
+	items := p.index.Query(Query{Folder: InboxFolder})
+	created := func(n *IndexedNote) string {
+		if c := n.String("created"); c != "" {
+			return c
+		}
+		return formatDate(n.ModTime)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		ci, cj := created(&items[i]), created(&items[j])
+		if ci != cj {
+			return ci < cj
+		}
+		return items[i].Path < items[j].Path
+	})
+	return items
+}
+
+// Next returns the inbox item to process next.
+func (p *InboxProcessor) Next() (IndexedNote, bool) {
+	items := p.Items()
+	if len(items) == 0 {
+		return IndexedNote{}, false
+	}
+	return items[0], true
+}
+
+// Process applies a decision to the inbox item at inboxPath: it renders the
+// matching template with the item's content, writes the new note, deletes the
+// inbox file, runs the hooks and commits the change.
+func (p *InboxProcessor) Process(inboxPath string, d InboxDecision) (*ProcessResult, error) {
+	p.index.LockNotes()
+	defer p.index.UnlockNotes()
+	inboxPath, err := CleanNotePath(inboxPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDecision, err)
+	}
+	if !InFolder(inboxPath, InboxFolder) {
+		return nil, fmt.Errorf("%w: %s is not in %s", ErrInvalidDecision, inboxPath, InboxFolder)
+	}
+	vaultPath := p.index.VaultPath()
+	inboxAbs := filepath.Join(vaultPath, inboxPath)
+	data, err := os.ReadFile(inboxAbs)
+	if err != nil {
+		return nil, fmt.Errorf("read inbox item: %w", err)
+	}
+
+	title := strings.TrimSpace(d.Title)
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(inboxPath), ".md")
+	}
+	result := &ProcessResult{Action: d.Action, InboxPath: inboxPath, Title: title, Original: string(data)}
+
+	if d.Action != ActionTrash {
+		rel, content, err := p.render(d, title, data)
+		if err != nil {
+			return nil, err
+		}
+		abs := filepath.Join(vaultPath, rel)
+		if _, err := os.Stat(abs); err == nil {
+			return nil, fmt.Errorf("%s: %w", rel, ErrNoteExists)
+		}
+		if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(abs, []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", rel, err)
+		}
+		if err := p.index.Update(rel); err != nil {
+			log.Printf("Process inbox: index %s: %v", rel, err)
+		}
+		result.NotePath = rel
+	}
+
+	if err := os.Remove(inboxAbs); err != nil {
+		return nil, fmt.Errorf("remove inbox item: %w", err)
+	}
+	if err := p.index.Remove(inboxPath); err != nil {
+		log.Printf("Process inbox: unindex %s: %v", inboxPath, err)
+	}
+
+	var errs []error
+	for _, hook := range p.hooks {
+		if err := hook(result, d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.commit != nil {
+		msg := fmt.Sprintf("Process inbox: trash %s", title)
+		if result.NotePath != "" {
+			msg = fmt.Sprintf("Process inbox: %s -> %s", title, result.NotePath)
+		}
+		if err := p.commit(msg); err != nil {
+			errs = append(errs, fmt.Errorf("commit: %w", err))
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// render builds the new note for a decision and returns its vault path and content
+func (p *InboxProcessor) render(d InboxDecision, title string, inbox []byte) (string, string, error) {
+	var (
+		templateName, notesSection string
+		fields                     = map[string]interface{}{}
+		sections                   = map[string]string{}
+	)
+	var dateErr error
+	setIf := func(key, value string) {
+		if value == "" {
+			return
+		}
+		fields[key] = value
+		if strings.HasSuffix(key, "_date") {
+			// Keep dates unquoted, as Obsidian writes them
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				dateErr = fmt.Errorf("%w: %s must be YYYY-MM-DD, got %q", ErrInvalidDecision, key, value)
+			}
+			fields[key] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: value}
+		}
+	}
+	projectLink := func() {
+		if d.Project != "" {
+			fields["project"] = "[[" + StripLink(d.Project) + "]]"
+		}
+	}
+
+	switch d.Action {
+	case ActionSomeday:
+		templateName, notesSection = "Someday Maybe Template", "Notes"
+		fields["type"] = "someday-maybe"
+		setIf("review_date", d.ReviewDate)
+	case ActionReference:
+		templateName, notesSection = "Reference Template", "Content"
+		fields["type"] = "reference"
+		setIf("category", d.Category)
+	case ActionNextAction:
+		if !strings.HasPrefix(d.Context, "@") || strings.ContainsAny(d.Context, `/\`) {
+			return "", "", fmt.Errorf("%w: a next action needs a context like @home, got %q", ErrInvalidDecision, d.Context)
+		}
+		templateName, notesSection = "Next Action Template", "Notes"
+		fields["status"] = "next"
+		fields["context"] = d.Context
+		projectLink()
+		setIf("priority", d.Priority)
+		setIf("due_date", d.DueDate)
+		sections["Action Required"] = title
+	case ActionProject:
+		templateName, notesSection = "Project Template", "Notes"
+		fields["status"] = "active"
+		fields["type"] = "project"
+		setIf("priority", d.Priority)
+		setIf("due_date", d.DueDate)
+		if d.Outcome != "" {
+			sections["Outcome"] = d.Outcome
+		}
+		if d.FirstAction != "" {
+			sections["Next Actions"] = "- [ ] " + d.FirstAction
+		}
+	case ActionWaitingFor:
+		if d.WaitingFor == "" {
+			return "", "", fmt.Errorf("%w: waiting_for is required to delegate an item", ErrInvalidDecision)
+		}
+		templateName, notesSection = "Waiting For Template", "Notes"
+		fields["status"] = "waiting"
+		fields["waiting_for"] = d.WaitingFor
+		setIf("follow_up_date", d.FollowUp)
+		projectLink()
+		sections["Who"] = d.WaitingFor
+	default:
+		return "", "", fmt.Errorf("%w: unknown inbox action %q", ErrInvalidDecision, d.Action)
+	}
+
+	if dateErr != nil {
+		return "", "", dateErr
+	}
+
+	tmpl, err := p.templates.LoadTemplate(templateName)
+	if os.IsNotExist(err) && d.Action == ActionSomeday {
+		tmpl, err = somedayTemplate, nil
+	}
+	if err != nil {
+		// Not wrapped: a missing template must not read as a missing inbox item
+		return "", "", fmt.Errorf("load template: %v", err)
+	}
+	content, err := p.templates.RenderWith(tmpl, TemplateData{"title": title})
+	if err != nil {
+		return "", "", fmt.Errorf("render %s: %w", templateName, err)
+	}
+
+	// Carry the inbox tags over and drop placeholders nobody filled in
+	if note, err := ParseNote("", inbox); err == nil {
+		if fm, ok := note.Frontmatter.(map[string]interface{}); ok {
+			if tags, ok := fm["tags"].([]interface{}); ok && len(tags) > 0 {
+				fields["tags"] = tags
+			}
+		}
+	}
+	content = clearOptionLists(content)
+	e, err := ParseFrontmatter([]byte(content))
+	if err != nil {
+		return "", "", fmt.Errorf("parse %s: %w", templateName, err)
+	}
+	if note, err := ParseNote("", []byte(content)); err == nil {
+		if fm, ok := note.Frontmatter.(map[string]interface{}); ok {
+			for key, v := range fm {
+				if _, set := fields[key]; !set {
+					if _, ok := placeholderValue(v); ok {
+						e.Delete(key)
+					}
+				}
+			}
+		}
+	}
+	for _, key := range sortedKeys(fields) {
+		if err := e.Set(key, fields[key]); err != nil {
+			return "", "", err
+		}
+	}
+	out, err := e.Bytes()
+	if err != nil {
+		return "", "", err
+	}
+
+	doc := ParseDocument(string(out))
+	for _, heading := range sortedKeys(sections) {
+		if err := doc.Replace(heading, sections[heading]); err != nil {
+			log.Printf("Process inbox: %s: %v", templateName, err)
+		}
+	}
+	if original := p.inboxContent(inbox); original != "" {
+		if err := doc.Replace(notesSection, original); err != nil {
+			doc.AppendSection(2, notesSection, original)
+		}
+	}
+
+	rel, err := CleanNotePath(filepath.Join(d.Folder(), SanitizeFilename(title)))
+	if err != nil {
+		return "", "", err
+	}
+	return rel, doc.String(), nil
+}
+
+// optionListRe matches template fields listing their allowed values, like
+// "status: [next/waiting]". Values such as [@calls/@home] are not valid YAML.
+var optionListRe = regexp.MustCompile(`(?m)^(\w+):[ \t]*\[[^\[\]\n]*/[^\[\]\n]*\][ \t]*$`)
+
+// clearOptionLists empties option-list placeholders in the frontmatter
+func clearOptionLists(content string) string {
+	fm, closing, body, ok := splitFrontmatter(content)
+	if !ok {
+		return content
+	}
+	return "---\n" + optionListRe.ReplaceAllString(fm, "$1:") + "\n" + closing + body
+}
+
+// inboxContent returns what the user wrote in an inbox item: its body without
+// the title heading and without sections still holding the template's text.
+// Headings are demoted so they nest under the section the content goes into.
+func (p *InboxProcessor) inboxContent(data []byte) string {
+	doc := ParseDocument(string(data))
+	sections := doc.Sections()
+	if len(sections) == 1 && sections[0].Heading.Level == 1 {
+		// The usual "# Title" wrapper; look at its subsections instead
+		body, _ := doc.Content(sections[0].Heading.Text)
+		doc = ParseDocument(body)
+		sections = doc.Sections()
+	}
+	var tmpl *Document
+	if t, err := p.templates.LoadTemplate("Inbox Item Template"); err == nil {
+		tmpl = ParseDocument(t)
+	}
+
+	var parts []string
+	add := func(lines []string) {
+		if s := strings.TrimSpace(strings.Join(lines, "\n")); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	lines := doc.lines
+	if len(sections) == 0 {
+		add(lines)
+	} else {
+		add(lines[:sections[0].line])
+	}
+	for _, s := range sections {
+		body := strings.TrimSpace(strings.Join(lines[s.line+1:s.end], "\n"))
+		if body == "" {
+			continue
+		}
+		if tmpl != nil {
+			if def, ok := tmpl.Content(s.Heading.Text); ok && strings.TrimSpace(def) == body {
+				continue
+			}
+		}
+		block := append([]string(nil), lines[s.line:s.end]...)
+		for i, line := range block {
+			if m := headingRe.FindStringSubmatch(line); m != nil && s.Heading.Level < 3 {
+				level := len(m[1]) + 3 - s.Heading.Level
+				block[i] = strings.Repeat("#", min(level, 6)) + " " + m[2]
+			}
+		}
+		add(block)
+	}
+	return strings.Join(parts, "\n\n")
+}
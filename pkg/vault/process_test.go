@@ -0,0 +1,172 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// repoTemplates are the templates shipped with the sample vault
+var repoTemplates = filepath.Join("..", "..", "GTD", "0. GTD System", "Templates")
+
+const inboxItem = `---
+created: 2024-03-01
+status: inbox
+type: [task/idea/reference/project]
+priority: [low/medium/high/urgent]
+tags: [garden]
+---
+
+# Fix the fence
+
+## Description
+The north fence panel blew over in the storm.
+
+## Context
+Where did this come from? What triggered this thought?
+
+## Next Steps
+What needs to happen next?
+
+## Notes
+Additional details, links, references
+`
+
+func newTestProcessor(t *testing.T, root string, hooks ...ProcessHook) (*InboxProcessor, *Index) {
+	t.Helper()
+	idx := NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewTemplateEngine(repoTemplates)
+	engine.Clock = func() time.Time { return time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC) }
+	return NewInboxProcessor(idx, engine, nil, hooks...), idx
+}
+
+func TestProcessNextAction(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "1. Inbox/Fix the fence.md", inboxItem)
+	writeTestFile(t, root, "1. Inbox/Later.md", "---\ncreated: 2024-03-03\nstatus: inbox\n---\n# Later\n")
+
+	var logged []*ProcessResult
+	p, idx := newTestProcessor(t, root, func(r *ProcessResult, d InboxDecision) error {
+		logged = append(logged, r)
+		return nil
+	})
+	if next, ok := p.Next(); !ok || next.Title != "Fix the fence" {
+		t.Fatalf("Next = %+v", next)
+	}
+
+	res, err := p.Process("1. Inbox/Fix the fence.md", InboxDecision{
+		Action: ActionNextAction, Title: "Call fence company", Context: "@calls", Project: "Garden",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join("2. Next Actions", "@calls", "Call fence company.md")
+	if res.NotePath != want {
+		t.Fatalf("NotePath = %q", res.NotePath)
+	}
+	if _, err := os.Stat(filepath.Join(root, "1. Inbox", "Fix the fence.md")); !os.IsNotExist(err) {
+		t.Error("inbox item was not removed")
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0].Original, "north fence") {
+		t.Errorf("hook not called with the original content: %+v", logged)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(root, want))
+	content := string(data)
+	for _, s := range []string{
+		"created: 2024-03-04\n", "status: next\n", "context: '@calls'\n", `project: "[[Garden]]"`, "tags:\n  - garden\n",
+		"## Action Required\nCall fence company\n",
+		"## Notes\n### Description\nThe north fence panel blew over in the storm.\n",
+	} {
+		if !strings.Contains(content, s) {
+			t.Errorf("note is missing %q:\n%s", s, content)
+		}
+	}
+	if strings.Contains(content, "[low/medium/high/urgent]") || strings.Contains(content, "What triggered this thought") {
+		t.Errorf("template placeholders carried over:\n%s", content)
+	}
+
+	n, ok := idx.Get(want)
+	if !ok {
+		t.Fatal("new note not indexed")
+	}
+	if s, issues := n.Validate(); s == nil || s.Name != "next-action" || len(issues) > 0 {
+		t.Errorf("validate = %v %v", s, issues)
+	}
+	if items := p.Items(); len(items) != 1 || items[0].Title != "Later" {
+		t.Errorf("remaining items = %+v", items)
+	}
+}
+
+func TestProcessOtherDecisions(t *testing.T) {
+	tests := []struct {
+		decision InboxDecision
+		path     string
+		contains string
+	}{
+		{InboxDecision{Action: ActionTrash}, "", ""},
+		{InboxDecision{Action: ActionSomeday}, "4. Someday Maybe/Fix the fence.md", "type: someday-maybe"},
+		{InboxDecision{Action: ActionReference, Category: "knowledge"}, "5. Reference/Fix the fence.md", "## Content\n### Description"},
+		{InboxDecision{Action: ActionProject, Outcome: "Fence is standing", FirstAction: "Measure panel"}, "3. Projects/Active/Fix the fence.md", "- [ ] Measure panel"},
+		{InboxDecision{Action: ActionWaitingFor, WaitingFor: "Neighbour", FollowUp: "2024-03-11"}, "2. Next Actions/@waiting/Fix the fence.md", "follow_up_date: 2024-03-11\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.decision.Action, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFile(t, root, "1. Inbox/Fix the fence.md", inboxItem)
+			p, idx := newTestProcessor(t, root)
+
+			res, err := p.Process("1. Inbox/Fix the fence.md", tt.decision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.NotePath != filepath.FromSlash(tt.path) {
+				t.Fatalf("NotePath = %q, want %q", res.NotePath, tt.path)
+			}
+			if tt.path == "" {
+				return
+			}
+			data, _ := os.ReadFile(filepath.Join(root, tt.path))
+			if !strings.Contains(string(data), tt.contains) {
+				t.Errorf("note is missing %q:\n%s", tt.contains, data)
+			}
+			n, _ := idx.Get(res.NotePath)
+			if _, issues := n.Validate(); len(issues) > 0 {
+				t.Errorf("validation issues: %v\n%s", issues, data)
+			}
+		})
+	}
+}
+
+func TestProcessRejectsBadDecisions(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "1. Inbox/Fix the fence.md", inboxItem)
+	writeTestFile(t, root, "2. Next Actions/@home/Fix the fence.md", "# existing\n")
+	p, _ := newTestProcessor(t, root)
+
+	for _, d := range []InboxDecision{
+		{Action: "archive"},
+		{Action: ActionNextAction},
+		{Action: ActionNextAction, Context: "@../../x"},
+		{Action: ActionWaitingFor},
+	} {
+		if _, err := p.Process("1. Inbox/Fix the fence.md", d); err == nil {
+			t.Errorf("Process(%+v) succeeded", d)
+		}
+	}
+	if _, err := p.Process("1. Inbox/Fix the fence.md", InboxDecision{Action: ActionNextAction, Context: "@home"}); !errors.Is(err, ErrNoteExists) {
+		t.Errorf("expected ErrNoteExists, got %v", err)
+	}
+	if _, err := p.Process("3. Projects/Website.md", InboxDecision{Action: ActionTrash}); err == nil {
+		t.Error("processed a note outside the inbox")
+	}
+	if _, err := os.Stat(filepath.Join(root, "1. Inbox", "Fix the fence.md")); err != nil {
+		t.Errorf("inbox item removed after failed decisions: %v", err)
+	}
+}
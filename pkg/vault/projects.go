@@ -0,0 +1,297 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Project folders. Open projects live in Active, completed and cancelled
+// ones in Archive.
+const (
+	ProjectsFolder       = "3. Projects"
+	ActiveProjectsFolder = ProjectsFolder + "/Active"
+	ProjectArchiveFolder = ProjectsFolder + "/Archive"
+)
+
+// ProjectTransitions lists the statuses a project may move to from each
+// status. Completed and cancelled projects can be reopened.
+var ProjectTransitions = map[string][]string{
+	"planning":  {"active", "on-hold", "cancelled"},
+	"active":    {"planning", "on-hold", "completed", "cancelled"},
+	"on-hold":   {"planning", "active", "cancelled"},
+	"completed": {"active"},
+	"cancelled": {"planning", "active"},
+}
+
+// ErrInvalidTransition is returned for a status change ProjectTransitions
+// does not allow
+var ErrInvalidTransition = errors.New("invalid project status transition")
+
+// ErrNotProject is returned when a note is not a project
+var ErrNotProject = errors.New("not a project")
+
+// closedStatus reports whether a project with the status belongs in the archive
+func closedStatus(status string) bool {
+	return status == "completed" || status == "cancelled"
+}
+
+// ProjectSummary is a project with its actions and health
+type ProjectSummary struct {
+	Path              string    `json:"path"`
+	Title             string    `json:"title"`
+	Status            string    `json:"status"`
+	Priority          string    `json:"priority,omitempty"`
+	DueDate           string    `json:"due_date,omitempty"`
+	ReviewDate        string    `json:"review_date,omitempty"`
+	NextActions       []Action  `json:"next_actions"`
+	WaitingFor        []Action  `json:"waiting_for"`
+	HasNextAction     bool      `json:"has_next_action"`
+	Stalled           bool      `json:"stalled"` // active without a next action
+	LastActivity      time.Time `json:"last_activity"`
+	DaysSinceActivity int       `json:"days_since_activity"`
+}
+
+// IsProject reports whether a note is a project
+func IsProject(n *IndexedNote) bool {
+	s := SchemaFor(n.Path, n.Frontmatter)
+	return s != nil && s.Name == "project"
+}
+
+// ListProjects returns the projects with the given status, or all of them
+// for "", sorted by title. An action belongs to a project when its project
+// field points at it or the project note links to it. The last activity is
+// the latest change to the project note or one of its actions.
+func ListProjects(x *Index, status string, now time.Time) []ProjectSummary {
+	g := x.Graph()
+	projects := make(map[string]*ProjectSummary)
+	var order []string
+	for _, n := range x.All() {
+		if !IsProject(&n) || status != "" && n.Status() != status {
+			continue
+		}
+		projects[n.Path] = &ProjectSummary{
+			Path:         n.Path,
+			Title:        n.Title,
+			Status:       n.Status(),
+			Priority:     n.String("priority"),
+			DueDate:      n.String("due_date"),
+			ReviewDate:   n.String("review_date"),
+			NextActions:  []Action{},
+			WaitingFor:   []Action{},
+			LastActivity: n.ModTime,
+		}
+		order = append(order, n.Path)
+	}
+
+	linked := make(map[string][]string) // project path -> action paths
+	for _, path := range order {
+		for _, e := range g.Links(path) {
+			if e.Target != "" && InFolder(e.Target, NextActionsFolder) {
+				linked[path] = append(linked[path], e.Target)
+			}
+		}
+	}
+	for _, n := range x.Query(Query{Folder: NextActionsFolder}) {
+		if target, ok := g.Resolve(n.Project()); ok && projects[target] != nil && !slices.Contains(linked[target], n.Path) {
+			linked[target] = append(linked[target], n.Path)
+		}
+	}
+
+	out := make([]ProjectSummary, 0, len(order))
+	for _, path := range order {
+		p := projects[path]
+		for _, actionPath := range linked[path] {
+			n, ok := x.Get(actionPath)
+			if !ok {
+				continue
+			}
+			if n.ModTime.After(p.LastActivity) {
+				p.LastActivity = n.ModTime
+			}
+			a := NewAction(n)
+			switch a.Status {
+			case "done":
+			case "waiting":
+				p.WaitingFor = append(p.WaitingFor, a)
+			default:
+				p.NextActions = append(p.NextActions, a)
+			}
+		}
+		sortActions(p.NextActions)
+		sortActions(p.WaitingFor)
+		p.HasNextAction = len(p.NextActions) > 0
+		p.Stalled = p.Status == "active" && !p.HasNextAction
+		if !p.LastActivity.IsZero() && now.After(p.LastActivity) {
+			p.DaysSinceActivity = int(now.Sub(p.LastActivity).Hours() / 24)
+		}
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Title != out[j].Title {
+			return out[i].Title < out[j].Title
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// GetProject returns the project with the given name or vault path
+func GetProject(x *Index, ref string, now time.Time) (ProjectSummary, bool) {
+	path, ok := x.Graph().Resolve(strings.TrimSuffix(filepath.ToSlash(ref), ".md"))
+	if !ok {
+		return ProjectSummary{}, false
+	}
+	for _, p := range ListProjects(x, "", now) {
+		if p.Path == path {
+			return p, true
+		}
+	}
+	return ProjectSummary{}, false
+}
+
+// ProjectTransition is a change of a project's status
+type ProjectTransition struct {
+	Path    string    `json:"path"`               // where the project is after the change
+	OldPath string    `json:"old_path,omitempty"` // where it was, when it moved
+	Title   string    `json:"title"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Note    string    `json:"note,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// ProjectHook is called after a project changed status, e.g. to keep its
+// history
+type ProjectHook func(t *ProjectTransition) error
+
+// ProjectManager changes project statuses and files projects accordingly
+type ProjectManager struct {
+	MoveHooks []MoveHook // run when a project moves between Active and Archive
+
+	index  *Index
+	commit func(message string) error // may be nil
+	hooks  []ProjectHook
+	now    func() time.Time
+}
+
+// NewProjectManager creates a project manager. commit is called once per
+// status change and may be nil.
+func NewProjectManager(index *Index, commit func(message string) error, hooks ...ProjectHook) *ProjectManager {
+	return &ProjectManager{index: index, commit: commit, hooks: hooks, now: time.Now}
+}
+
+// SetStatus changes the status of the project at relPath. Completing or
+// cancelling a project records the closed date, adds a "Closing Note"
+// section with note and moves it to ProjectArchiveFolder; reopening it moves
+// it back to ActiveProjectsFolder.
+func (m *ProjectManager) SetStatus(relPath, status, note string) (*ProjectTransition, error) {
+	m.index.LockNotes()
+	defer m.index.UnlockNotes()
+	rel, err := CleanNotePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+	vaultPath := m.index.VaultPath()
+	abs := filepath.Join(vaultPath, rel)
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("read project: %w", err)
+	}
+	n := NewIndexedNote(rel, data)
+	if !IsProject(n) {
+		return nil, fmt.Errorf("%s: %w", rel, ErrNotProject)
+	}
+	from := n.Status()
+	allowed := ProjectTransitions[from]
+	if allowed == nil {
+		// A project without a valid status may take any
+		allowed = ProjectStatuses
+	}
+	if !slices.Contains(allowed, status) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, orDash(from), status)
+	}
+
+	t := &ProjectTransition{Path: rel, Title: n.Title, From: from, To: status, Note: strings.TrimSpace(note), At: m.now()}
+	e, err := ParseFrontmatter(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse project: %w", err)
+	}
+	if err := e.Set("status", status); err != nil {
+		return nil, err
+	}
+	day := t.At.Format("2006-01-02")
+	if closedStatus(status) {
+		if err := e.Set("closed", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: day}); err != nil {
+			return nil, err
+		}
+	} else {
+		e.Delete("closed")
+	}
+	out, err := e.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if closedStatus(status) {
+		closing := fmt.Sprintf("%s on %s.", strings.ToUpper(status[:1])+status[1:], day)
+		if t.Note != "" {
+			closing += "\n\n" + t.Note
+		}
+		doc := ParseDocument(string(out))
+		doc.AppendSection(2, "Closing Note", closing)
+		out = []byte(doc.String())
+	}
+	if err := os.WriteFile(abs, out, 0644); err != nil {
+		return nil, fmt.Errorf("write project: %w", err)
+	}
+	if err := m.index.Update(rel); err != nil {
+		log.Printf("Project status: index %s: %v", rel, err)
+	}
+
+	var errs []error
+	dest := ""
+	switch {
+	case closedStatus(status) && !InFolder(rel, ProjectArchiveFolder):
+		dest = filepath.Join(filepath.FromSlash(ProjectArchiveFolder), filepath.Base(rel))
+	case !closedStatus(status) && InFolder(rel, ProjectArchiveFolder):
+		dest = filepath.Join(filepath.FromSlash(ActiveProjectsFolder), filepath.Base(rel))
+	}
+	if dest != "" {
+		moved, err := NewMover(m.index, nil, m.MoveHooks...).move(rel, availablePath(vaultPath, dest), nil)
+		if moved == nil {
+			errs = append(errs, fmt.Errorf("move project: %w", err))
+		} else {
+			if err != nil {
+				errs = append(errs, err)
+			}
+			t.OldPath, t.Path = rel, moved.NewPath
+		}
+	}
+
+	for _, hook := range m.hooks {
+		if err := hook(t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if m.commit != nil {
+		if err := m.commit(fmt.Sprintf("Project %s: %s -> %s", t.Title, orDash(from), status)); err != nil {
+			errs = append(errs, fmt.Errorf("commit: %w", err))
+		}
+	}
+	return t, errors.Join(errs...)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
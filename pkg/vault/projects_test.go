@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListProjects(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "3. Projects/Active/Website.md", "---\nstatus: active\ntype: project\npriority: high\n---\n# Website\n\n- [ ] [[Review copy]]\n")
+	writeTestFile(t, root, "3. Projects/Active/Garden.md", "---\nstatus: active\ntype: project\n---\n# Garden\n")
+	writeTestFile(t, root, "3. Projects/Active/Boat.md", "---\nstatus: on-hold\ntype: project\n---\n# Boat\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Deploy.md", "---\nstatus: next\nproject: \"[[Website]]\"\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Review copy.md", "---\nstatus: next\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Old.md", "---\nstatus: done\nproject: \"[[Website]]\"\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@waiting/Quote.md", "---\nstatus: waiting\nwaiting_for: Bob\nproject: \"[[Garden]]\"\n---\n")
+	idx := NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Add(72 * time.Hour)
+	all := ListProjects(idx, "", now)
+	if len(all) != 3 || all[0].Title != "Boat" || all[2].Title != "Website" {
+		t.Fatalf("unexpected projects: %+v", all)
+	}
+	web := all[2]
+	if len(web.NextActions) != 2 || !web.HasNextAction || web.Stalled || web.Priority != "high" || web.DaysSinceActivity != 3 {
+		t.Errorf("website = %+v", web)
+	}
+	garden, ok := GetProject(idx, "garden", now)
+	if !ok || len(garden.WaitingFor) != 1 || garden.HasNextAction || !garden.Stalled {
+		t.Errorf("garden = %+v, %v", garden, ok)
+	}
+	if active := ListProjects(idx, "active", now); len(active) != 2 {
+		t.Errorf("expected 2 active projects, got %+v", active)
+	}
+}
+
+func TestProjectSetStatus(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "3. Projects/Active/Website.md", "---\nstatus: active\ntype: project\n---\n# Website\n\n## Notes\nLaunched in May.\n")
+	writeTestFile(t, root, "2. Next Actions/@computer/Deploy.md", "---\nstatus: done\nproject: \"[[3. Projects/Active/Website]]\"\n---\n")
+	idx := NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	read := func(rel string) string {
+		data, _ := os.ReadFile(filepath.Join(root, rel))
+		return string(data)
+	}
+
+	var history []ProjectTransition
+	var commits []string
+	m := NewProjectManager(idx, func(message string) error {
+		commits = append(commits, message)
+		return nil
+	}, func(tr *ProjectTransition) error {
+		history = append(history, *tr)
+		return nil
+	})
+	m.now = func() time.Time { return time.Date(2026, 5, 20, 9, 0, 0, 0, time.UTC) }
+
+	if _, err := m.SetStatus("3. Projects/Active/Website.md", "planning", ""); err != nil {
+		t.Fatalf("SetStatus failed: %v", err)
+	}
+	if _, err := m.SetStatus("3. Projects/Active/Website.md", "completed", ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition for planning -> completed, got %v", err)
+	}
+	if _, err := m.SetStatus("2. Next Actions/@computer/Deploy.md", "active", ""); !errors.Is(err, ErrNotProject) {
+		t.Errorf("expected ErrNotProject, got %v", err)
+	}
+	m.SetStatus("3. Projects/Active/Website.md", "active", "")
+
+	tr, err := m.SetStatus("3. Projects/Active/Website.md", "completed", "Shipped on time.")
+	if err != nil {
+		t.Fatalf("SetStatus failed: %v", err)
+	}
+	archived := filepath.Join("3. Projects", "Archive", "Website.md")
+	if tr.Path != archived || tr.OldPath != filepath.Join("3. Projects", "Active", "Website.md") || tr.From != "active" {
+		t.Errorf("unexpected transition: %+v", tr)
+	}
+	want := "---\nstatus: completed\ntype: project\nclosed: 2026-05-20\n---\n# Website\n\n## Notes\nLaunched in May.\n\n## Closing Note\nCompleted on 2026-05-20.\n\nShipped on time.\n"
+	if got := read(archived); got != want {
+		t.Errorf("archived project:\n%s\nwant:\n%s", got, want)
+	}
+	if got := read("2. Next Actions/@computer/Deploy.md"); !strings.Contains(got, "[[3. Projects/Archive/Website]]") {
+		t.Errorf("expected the action's project link to follow the move:\n%s", got)
+	}
+
+	// Reopening brings the project back to Active
+	tr, err = m.SetStatus(archived, "active", "")
+	if err != nil || tr.Path != filepath.Join("3. Projects", "Active", "Website.md") {
+		t.Fatalf("reopen = %+v, %v", tr, err)
+	}
+	if got := read(tr.Path); strings.Contains(got, "closed:") || !strings.Contains(got, "## Closing Note") {
+		t.Errorf("reopened project:\n%s", got)
+	}
+	if len(history) != 4 || len(commits) != 4 || commits[2] != "Project Website: active -> completed" {
+		t.Errorf("history = %+v, commits = %v", history, commits)
+	}
+}
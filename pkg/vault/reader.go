@@ -2,6 +2,7 @@ package vault
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"strings"
@@ -11,13 +12,17 @@ import (
 
 // ReadNote reads a markdown file and parses its frontmatter and content
 func ReadNote(path string) (*Note, error) {
-	file, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	return ParseNote(path, data)
+}
 
-	scanner := bufio.NewScanner(file)
+// ParseNote parses raw note bytes into frontmatter and content
+func ParseNote(path string, data []byte) (*Note, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
 	var frontmatterLines []string
 	var contentLines []string
 	inFrontmatter := false
@@ -67,17 +72,14 @@ func ReadNote(path string) (*Note, error) {
 		Path:        path,
 		Frontmatter: rawFM,
 		Content:     strings.Join(contentLines, "\n"),
+		source:      data,
 	}, nil
 }
 
 // ParseInboxItem parses the frontmatter into an InboxItem struct
 func ParseInboxItem(n *Note) (*InboxItem, error) {
-	data, err := yaml.Marshal(n.Frontmatter)
-	if err != nil {
-		return nil, err
-	}
 	var item InboxItem
-	if err := yaml.Unmarshal(data, &item); err != nil {
+	if err := decodeFrontmatter(n, &item); err != nil {
 		return nil, err
 	}
 	return &item, nil
@@ -85,13 +87,117 @@ func ParseInboxItem(n *Note) (*InboxItem, error) {
 
 // ParseProject parses the frontmatter into a Project struct
 func ParseProject(n *Note) (*Project, error) {
-	data, err := yaml.Marshal(n.Frontmatter)
-	if err != nil {
+	var item Project
+	if err := decodeFrontmatter(n, &item); err != nil {
 		return nil, err
 	}
-	var item Project
-	if err := yaml.Unmarshal(data, &item); err != nil {
+	return &item, nil
+}
+
+// ParseNextAction parses the frontmatter into a NextAction struct
+func ParseNextAction(n *Note) (*NextAction, error) {
+	var item NextAction
+	if err := decodeFrontmatter(n, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ParseWaitingFor parses the frontmatter into a WaitingFor struct
+func ParseWaitingFor(n *Note) (*WaitingFor, error) {
+	var item WaitingFor
+	if err := decodeFrontmatter(n, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ParseReference parses the frontmatter into a Reference struct
+func ParseReference(n *Note) (*Reference, error) {
+	var item Reference
+	if err := decodeFrontmatter(n, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ParseSomedayMaybe parses the frontmatter into a SomedayMaybe struct
+func ParseSomedayMaybe(n *Note) (*SomedayMaybe, error) {
+	var item SomedayMaybe
+	if err := decodeFrontmatter(n, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ParseWeeklyReview parses the frontmatter into a WeeklyReview struct
+func ParseWeeklyReview(n *Note) (*WeeklyReview, error) {
+	var item WeeklyReview
+	if err := decodeFrontmatter(n, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ParseDailyCapture parses the frontmatter into a DailyCapture struct
+func ParseDailyCapture(n *Note) (*DailyCapture, error) {
+	var item DailyCapture
+	if err := decodeFrontmatter(n, &item); err != nil {
 		return nil, err
 	}
 	return &item, nil
 }
+
+// ParseQuarterlyReview parses the frontmatter into a QuarterlyReview struct
+func ParseQuarterlyReview(n *Note) (*QuarterlyReview, error) {
+	var item QuarterlyReview
+	if err := decodeFrontmatter(n, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ParseAnnualReview parses the frontmatter into an AnnualReview struct
+func ParseAnnualReview(n *Note) (*AnnualReview, error) {
+	var item AnnualReview
+	if err := decodeFrontmatter(n, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// decodeFrontmatter decodes the note's frontmatter into out. Dates and other
+// non-string scalars are flattened to strings first so they fit string fields.
+func decodeFrontmatter(n *Note, out interface{}) error {
+	fm, _ := n.Frontmatter.(map[string]interface{})
+	flat := make(map[string]interface{}, len(fm))
+	for k, v := range fm {
+		if k == "tags" {
+			flat[k] = tagList(v)
+			continue
+		}
+		flat[k] = stringValue(v)
+	}
+	data, err := yaml.Marshal(flat)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+func tagList(v interface{}) []string {
+	var tags []string
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			if t := stringValue(item); t != "" {
+				tags = append(tags, t)
+			}
+		}
+	case string:
+		if t := strings.TrimSpace(val); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,265 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRecurrence is returned for a recur field that cannot be parsed
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+
+// Recurrence frequencies
+const (
+	FreqDay   = "day"
+	FreqWeek  = "week"
+	FreqMonth = "month"
+	FreqYear  = "year"
+)
+
+// Recurrence is the repeat rule of a next action, from its recur field
+type Recurrence struct {
+	Freq     string
+	Interval int
+	Weekdays []time.Weekday // week: the days it falls on, none for every Interval weeks
+	MonthDay int            // month: the day of the month, 0 to keep the due date's
+	WhenDone bool           // count from the completion day instead of the due date
+	Count    int            // instances left including this one, 0 for no limit
+	Until    time.Time      // last possible day, zero for no limit
+}
+
+var (
+	everyRe    = regexp.MustCompile(`^every\s+(?:(\d+|other)\s+)?(day|week|month|year)s?(?:\s+on\s+(.+))?$`)
+	rruleCount = regexp.MustCompile(`(?i)\bCOUNT=\d+`)
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"su": time.Sunday, "sun": time.Sunday, "sunday": time.Sunday,
+	"mo": time.Monday, "mon": time.Monday, "monday": time.Monday,
+	"tu": time.Tuesday, "tue": time.Tuesday, "tuesday": time.Tuesday,
+	"we": time.Wednesday, "wed": time.Wednesday, "wednesday": time.Wednesday,
+	"th": time.Thursday, "thu": time.Thursday, "thursday": time.Thursday,
+	"fr": time.Friday, "fri": time.Friday, "friday": time.Friday,
+	"sa": time.Saturday, "sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseRecurrence reads a recur field: "daily", "weekly", "every 2 weeks",
+// "every other month", "every monday, thursday", "every weekday",
+// "every week on friday", optionally followed by "when done", or an RRULE
+// such as "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO".
+func ParseRecurrence(s string) (*Recurrence, error) {
+	rule := strings.ToLower(strings.TrimSpace(s))
+	if strings.Contains(rule, "freq=") {
+		return parseRRule(rule)
+	}
+	r := &Recurrence{Interval: 1}
+	if rest, ok := strings.CutSuffix(rule, " when done"); ok {
+		r.WhenDone = true
+		rule = strings.TrimSpace(rest)
+	}
+	switch rule {
+	case "daily", "every day":
+		r.Freq = FreqDay
+		return r, nil
+	case "weekly":
+		r.Freq = FreqWeek
+		return r, nil
+	case "monthly":
+		r.Freq = FreqMonth
+		return r, nil
+	case "yearly", "annually":
+		r.Freq = FreqYear
+		return r, nil
+	case "every weekday":
+		r.Freq = FreqWeek
+		r.Weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+		return r, nil
+	}
+	if m := everyRe.FindStringSubmatch(rule); m != nil {
+		r.Freq = m[2]
+		switch m[1] {
+		case "":
+		case "other":
+			r.Interval = 2
+		default:
+			r.Interval, _ = strconv.Atoi(m[1])
+		}
+		if r.Interval < 1 {
+			return nil, fmt.Errorf("%w: %q repeats every 0 %ss", ErrInvalidRecurrence, s, r.Freq)
+		}
+		if m[3] != "" {
+			if r.Freq != FreqWeek {
+				return nil, fmt.Errorf("%w: %q: only weekly rules take days", ErrInvalidRecurrence, s)
+			}
+			days, err := parseWeekdays(m[3])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: %v", ErrInvalidRecurrence, s, err)
+			}
+			r.Weekdays = days
+		}
+		return r, nil
+	}
+	if days, err := parseWeekdays(strings.TrimPrefix(rule, "every ")); strings.HasPrefix(rule, "every ") && err == nil {
+		r.Freq, r.Weekdays = FreqWeek, days
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidRecurrence, s)
+}
+
+// parseWeekdays reads a list such as "monday, thu and fr"
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, name := range strings.FieldsFunc(strings.ReplaceAll(s, " and ", ","), func(r rune) bool { return r == ',' || r == ' ' }) {
+		d, ok := weekdayNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", name)
+		}
+		days = append(days, d)
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("no days given")
+	}
+	return days, nil
+}
+
+// parseRRule reads the subset of RFC 5545 rules that make sense for tasks:
+// FREQ, INTERVAL, BYDAY without ordinals, a single BYMONTHDAY, COUNT and UNTIL
+func parseRRule(s string) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(s, "rrule:"), ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			if part == "" {
+				continue
+			}
+			return nil, fmt.Errorf("%w: RRULE part %q", ErrInvalidRecurrence, part)
+		}
+		var err error
+		switch key {
+		case "freq":
+			r.Freq = map[string]string{"daily": FreqDay, "weekly": FreqWeek, "monthly": FreqMonth, "yearly": FreqYear}[value]
+			if r.Freq == "" {
+				err = fmt.Errorf("unsupported FREQ %s", strings.ToUpper(value))
+			}
+		case "interval":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("INTERVAL must be positive")
+			}
+		case "byday":
+			r.Weekdays, err = parseWeekdays(strings.ReplaceAll(value, ",", " "))
+		case "bymonthday":
+			r.MonthDay, err = strconv.Atoi(value)
+			if err == nil && (r.MonthDay < 1 || r.MonthDay > 31) {
+				err = fmt.Errorf("BYMONTHDAY must be between 1 and 31")
+			}
+		case "count":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("COUNT must be positive")
+			}
+		case "until":
+			r.Until, err = time.Parse("20060102", value[:min(len(value), 8)])
+		case "wkst":
+		default:
+			err = fmt.Errorf("unsupported RRULE part %s", strings.ToUpper(key))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+		}
+	}
+	switch {
+	case r.Freq == "":
+		return nil, fmt.Errorf("%w: RRULE without FREQ", ErrInvalidRecurrence)
+	case len(r.Weekdays) > 0 && r.Freq != FreqWeek:
+		return nil, fmt.Errorf("%w: BYDAY is only supported with FREQ=WEEKLY", ErrInvalidRecurrence)
+	case r.MonthDay > 0 && r.Freq != FreqMonth:
+		return nil, fmt.Errorf("%w: BYMONTHDAY is only supported with FREQ=MONTHLY", ErrInvalidRecurrence)
+	}
+	return r, nil
+}
+
+// Next returns the due date of the instance after one due on due (zero if it
+// had none) and completed on done. The result is always after done, so an
+// overdue daily task comes back tomorrow rather than yesterday. It returns
+// false when the rule has run out.
+func (r *Recurrence) Next(due, done time.Time) (time.Time, bool) {
+	if r.Count == 1 {
+		return time.Time{}, false
+	}
+	done = truncateDay(done)
+	base := done
+	if !due.IsZero() && !r.WhenDone {
+		base = truncateDay(due)
+	}
+	next := r.step(base)
+	for !next.After(done) {
+		next = r.step(next)
+	}
+	if !r.Until.IsZero() && next.After(r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// step returns the first occurrence after day
+func (r *Recurrence) step(day time.Time) time.Time {
+	switch r.Freq {
+	case FreqDay:
+		return day.AddDate(0, 0, r.Interval)
+	case FreqWeek:
+		if len(r.Weekdays) == 0 {
+			return day.AddDate(0, 0, 7*r.Interval)
+		}
+		// Walk forward to the next listed day, skipping to the next active
+		// week when the current one has none left
+		week := day.AddDate(0, 0, -int((day.Weekday()+6)%7))
+		for d := day.AddDate(0, 0, 1); ; d = d.AddDate(0, 0, 1) {
+			if weeks := int((d.Sub(week)+12*time.Hour)/(24*time.Hour)) / 7; weeks%r.Interval != 0 {
+				continue
+			}
+			for _, wd := range r.Weekdays {
+				if d.Weekday() == wd {
+					return d
+				}
+			}
+		}
+	case FreqMonth:
+		next := addMonths(day, r.Interval)
+		if r.MonthDay == 0 {
+			return next
+		}
+		last := addMonths(next.AddDate(0, 0, 1-next.Day()), 1).AddDate(0, 0, -1).Day()
+		return next.AddDate(0, 0, min(r.MonthDay, last)-next.Day())
+	default:
+		return addMonths(day, 12*r.Interval)
+	}
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// followingRule returns the recur field of the next instance of one due on
+// due: an RRULE COUNT goes down by one, and a monthly rule due after the 28th
+// gets a BYMONTHDAY so a short month does not pull later instances forward.
+// Anything else stays as written.
+func followingRule(rule string, r *Recurrence, due time.Time) string {
+	if r.Count > 0 {
+		rule = rruleCount.ReplaceAllString(rule, "COUNT="+strconv.Itoa(r.Count-1))
+	}
+	if r.Freq != FreqMonth || r.MonthDay != 0 || r.WhenDone || due.IsZero() || due.Day() <= 28 {
+		return rule
+	}
+	anchor := ";BYMONTHDAY=" + strconv.Itoa(due.Day())
+	if strings.Contains(strings.ToLower(rule), "freq=") {
+		return strings.TrimRight(rule, "; ") + anchor
+	}
+	if r.Interval > 1 {
+		anchor = ";INTERVAL=" + strconv.Itoa(r.Interval) + anchor
+	}
+	return "FREQ=MONTHLY" + anchor
+}
@@ -0,0 +1,358 @@
+package vault
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// FieldKind is the kind of value a frontmatter field holds
+type FieldKind string
+
+const (
+	FieldText    FieldKind = "text"
+	FieldEnum    FieldKind = "enum"
+	FieldDate    FieldKind = "date"    // 2006-01-02
+	FieldLink    FieldKind = "link"    // [[Note]]
+	FieldWeek    FieldKind = "week"    // 2024-W47
+	FieldQuarter FieldKind = "quarter" // 2024-Q4
+	FieldYear    FieldKind = "year"    // 2024
+	FieldTags    FieldKind = "tags"
+)
+
+// Allowed values for enum fields, as listed in the templates.
+var (
+	InboxTypes          = []string{"task", "idea", "reference", "project"}
+	NextActionStatuses  = []string{"next", "waiting", "scheduled", "delegated", "done"}
+	ProjectStatuses     = []string{"planning", "active", "on-hold", "completed", "cancelled"}
+	Contexts            = []string{"@calls", "@computer", "@errands", "@home", "@office", "@calendar"}
+	Priorities          = []string{"low", "medium", "high", "urgent"}
+	Energies            = []string{"low", "medium", "high"}
+	ReferenceCategories = []string{"process", "contact", "document", "knowledge"}
+)
+
+// FieldSpec describes one frontmatter field of a note type
+type FieldSpec struct {
+	Name     string
+	Kind     FieldKind
+	Required bool
+	Values   []string // allowed values for FieldEnum
+}
+
+// Schema describes the frontmatter of one GTD note type
+type Schema struct {
+	Name     string // e.g. "next-action"
+	Type     string // value of the type field that identifies the note, if any
+	Template string // template used to create the note
+	Folder   string // vault folder the notes live in
+	Fields   []FieldSpec
+
+	parse func(*Note) (interface{}, error)
+}
+
+// ValidationIssue describes a frontmatter value that does not match its schema
+type ValidationIssue struct {
+	Field   string `json:"field"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Field, i.Message)
+}
+
+var (
+	schemaMu sync.RWMutex
+	schemas  []*Schema
+)
+
+func init() {
+	created := FieldSpec{Name: "created", Kind: FieldDate}
+	priority := FieldSpec{Name: "priority", Kind: FieldEnum, Values: Priorities}
+	tags := FieldSpec{Name: "tags", Kind: FieldTags}
+	typeField := func(t string) FieldSpec {
+		return FieldSpec{Name: "type", Kind: FieldEnum, Required: true, Values: []string{t}}
+	}
+
+	for _, s := range []*Schema{
+		{
+			Name: "inbox", Template: "Inbox Item Template", Folder: "1. Inbox",
+			Fields: []FieldSpec{created,
+				{Name: "status", Kind: FieldEnum, Required: true, Values: []string{"inbox"}},
+				{Name: "type", Kind: FieldEnum, Values: InboxTypes},
+				priority, tags},
+			parse: func(n *Note) (interface{}, error) { return ParseInboxItem(n) },
+		},
+		{
+			Name: "next-action", Template: "Next Action Template", Folder: "2. Next Actions",
+			Fields: []FieldSpec{created,
+				{Name: "status", Kind: FieldEnum, Required: true, Values: NextActionStatuses},
+				{Name: "context", Kind: FieldEnum, Values: Contexts},
+				{Name: "project", Kind: FieldLink},
+				priority,
+				{Name: "due_date", Kind: FieldDate},
+				{Name: "completed", Kind: FieldDate},
+				{Name: "energy", Kind: FieldEnum, Values: Energies},
+				{Name: "time_estimate", Kind: FieldText},
+				{Name: "recur", Kind: FieldText},
+				tags},
+			parse: func(n *Note) (interface{}, error) { return ParseNextAction(n) },
+		},
+		{
+			Name: "waiting-for", Template: "Waiting For Template", Folder: "2. Next Actions/@waiting",
+			Fields: []FieldSpec{created,
+				{Name: "status", Kind: FieldEnum, Required: true, Values: []string{"waiting", "done"}},
+				{Name: "waiting_for", Kind: FieldText, Required: true},
+				{Name: "email", Kind: FieldText},
+				{Name: "date_requested", Kind: FieldDate},
+				{Name: "follow_up_date", Kind: FieldDate},
+				{Name: "completed", Kind: FieldDate},
+				{Name: "project", Kind: FieldLink},
+				tags},
+			parse: func(n *Note) (interface{}, error) { return ParseWaitingFor(n) },
+		},
+		{
+			Name: "project", Type: "project", Template: "Project Template", Folder: "3. Projects",
+			Fields: []FieldSpec{created,
+				{Name: "status", Kind: FieldEnum, Required: true, Values: ProjectStatuses},
+				{Name: "type", Kind: FieldEnum, Values: []string{"project"}},
+				priority,
+				{Name: "due_date", Kind: FieldDate},
+				{Name: "review_date", Kind: FieldDate},
+				{Name: "closed", Kind: FieldDate},
+				tags},
+			parse: func(n *Note) (interface{}, error) { return ParseProject(n) },
+		},
+		{
+			Name: "someday-maybe", Type: "someday-maybe", Folder: "4. Someday Maybe",
+			Fields: []FieldSpec{created,
+				{Name: "type", Kind: FieldEnum, Values: []string{"someday-maybe"}},
+				{Name: "review_date", Kind: FieldDate},
+				tags},
+			parse: func(n *Note) (interface{}, error) { return ParseSomedayMaybe(n) },
+		},
+		{
+			Name: "reference", Type: "reference", Template: "Reference Template", Folder: "5. Reference",
+			Fields: []FieldSpec{created,
+				{Name: "type", Kind: FieldEnum, Values: []string{"reference"}},
+				{Name: "category", Kind: FieldEnum, Values: ReferenceCategories},
+				tags},
+			parse: func(n *Note) (interface{}, error) { return ParseReference(n) },
+		},
+		{
+			Name: "weekly-review", Type: "weekly-review", Template: "Weekly Review Template", Folder: "6. Weekly Reviews",
+			Fields: []FieldSpec{created, typeField("weekly-review"),
+				{Name: "week_of", Kind: FieldWeek, Required: true},
+				tags},
+			parse: func(n *Note) (interface{}, error) { return ParseWeeklyReview(n) },
+		},
+		{
+			Name: "daily-capture", Type: "daily-capture", Template: "Daily Capture Template",
+			Fields: []FieldSpec{created, typeField("daily-capture"), tags},
+			parse:  func(n *Note) (interface{}, error) { return ParseDailyCapture(n) },
+		},
+		{
+			Name: "quarterly-review", Type: "quarterly-review", Template: "Quarterly Review Template",
+			Fields: []FieldSpec{created, typeField("quarterly-review"),
+				{Name: "quarter", Kind: FieldQuarter, Required: true},
+				tags},
+			parse: func(n *Note) (interface{}, error) { return ParseQuarterlyReview(n) },
+		},
+		{
+			Name: "annual-review", Type: "annual-review", Template: "Annual Review Template",
+			Fields: []FieldSpec{created, typeField("annual-review"),
+				{Name: "year", Kind: FieldYear, Required: true},
+				tags},
+			parse: func(n *Note) (interface{}, error) { return ParseAnnualReview(n) },
+		},
+	} {
+		RegisterSchema(s)
+	}
+}
+
+// RegisterSchema adds a schema to the registry, replacing one with the same name.
+func RegisterSchema(s *Schema) {
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+	for i, existing := range schemas {
+		if existing.Name == s.Name {
+			schemas[i] = s
+			return
+		}
+	}
+	schemas = append(schemas, s)
+}
+
+// Schemas returns all registered schemas.
+func Schemas() []*Schema {
+	schemaMu.RLock()
+	defer schemaMu.RUnlock()
+	return append([]*Schema(nil), schemas...)
+}
+
+// LookupSchema returns the schema with the given name, or nil.
+func LookupSchema(name string) *Schema {
+	schemaMu.RLock()
+	defer schemaMu.RUnlock()
+	for _, s := range schemas {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// SchemaFor picks the schema for a note from its frontmatter and location. It
+// returns nil for notes without frontmatter or of an unknown kind.
+func SchemaFor(relPath string, fm map[string]interface{}) *Schema {
+	if len(fm) == 0 {
+		return nil
+	}
+	if InFolder(relPath, "1. Inbox") {
+		return LookupSchema("inbox")
+	}
+	typ := stringValue(fm["type"])
+	if typ != "" {
+		schemaMu.RLock()
+		for _, s := range schemas {
+			if s.Type == typ {
+				schemaMu.RUnlock()
+				return s
+			}
+		}
+		schemaMu.RUnlock()
+	}
+	if InFolder(relPath, "2. Next Actions/@waiting") || (stringValue(fm["status"]) == "waiting" && fm["waiting_for"] != nil) {
+		return LookupSchema("waiting-for")
+	}
+	schemaMu.RLock()
+	defer schemaMu.RUnlock()
+	for _, s := range schemas {
+		if s.Folder != "" && InFolder(relPath, s.Folder) {
+			return s
+		}
+	}
+	return nil
+}
+
+// ValidateNote validates frontmatter against the schema picked by SchemaFor.
+func ValidateNote(relPath string, fm map[string]interface{}) (*Schema, []ValidationIssue) {
+	s := SchemaFor(relPath, fm)
+	if s == nil {
+		return nil, nil
+	}
+	return s, s.Validate(fm)
+}
+
+// Validate checks the frontmatter fields against the schema.
+func (s *Schema) Validate(fm map[string]interface{}) []ValidationIssue {
+	var issues []ValidationIssue
+	for _, f := range s.Fields {
+		if msg, value := f.check(fm[f.Name]); msg != "" {
+			issues = append(issues, ValidationIssue{Field: f.Name, Value: value, Message: msg})
+		}
+	}
+	return issues
+}
+
+// Parse decodes the note's frontmatter into the schema's typed struct, e.g.
+// *NextAction for "next-action".
+func (s *Schema) Parse(n *Note) (interface{}, error) {
+	if s.parse == nil {
+		return nil, fmt.Errorf("schema %s has no parser", s.Name)
+	}
+	v, err := s.parse(n)
+	if err != nil {
+		// Keep the result a plain nil, not a nil pointer in an interface
+		return nil, err
+	}
+	return v, nil
+}
+
+// Validate checks the note against the schema for its path and frontmatter.
+func (n *IndexedNote) Validate() (*Schema, []ValidationIssue) {
+	return ValidateNote(n.Path, n.Frontmatter)
+}
+
+var (
+	weekRe    = regexp.MustCompile(`^\d{4}-W(0[1-9]|[1-4]\d|5[0-3])$`)
+	quarterRe = regexp.MustCompile(`^\d{4}-Q[1-4]$`)
+	yearRe    = regexp.MustCompile(`^\d{4}$`)
+	linkRe    = regexp.MustCompile(`^\[\[[^\[\]]+\]\]$`)
+)
+
+// check returns a message describing what is wrong with v, and v as a string.
+func (f FieldSpec) check(v interface{}) (string, string) {
+	switch val := v.(type) {
+	case nil:
+		if f.Required {
+			return "missing required field", ""
+		}
+		return "", ""
+	case map[string]interface{}:
+		// An unrendered {{placeholder}} parses as a flow mapping
+		return "template placeholder was not filled in", ""
+	case []interface{}:
+		if f.Kind == FieldTags {
+			return "", ""
+		}
+		if f.Kind == FieldLink && isNestedLink(val) {
+			break
+		}
+		s := stringValue(val)
+		if len(val) == 1 && strings.Contains(s, "/") {
+			return fmt.Sprintf("template placeholder [%s] was not filled in", s), s
+		}
+		return "expected a single value", s
+	}
+
+	s := stringValue(v)
+	if s == "" {
+		if f.Required {
+			return "missing required field", ""
+		}
+		return "", ""
+	}
+
+	switch f.Kind {
+	case FieldEnum:
+		for _, allowed := range f.Values {
+			if s == allowed {
+				return "", s
+			}
+		}
+		return fmt.Sprintf("invalid %s %q (want one of %s)", f.Name, s, strings.Join(f.Values, ", ")), s
+	case FieldDate:
+		if _, ok := parseDate(s); !ok {
+			return fmt.Sprintf("invalid date %q (want YYYY-MM-DD)", s), s
+		}
+	case FieldWeek:
+		if !weekRe.MatchString(s) {
+			return fmt.Sprintf("invalid week %q (want YYYY-Www)", s), s
+		}
+	case FieldQuarter:
+		if !quarterRe.MatchString(s) {
+			return fmt.Sprintf("invalid quarter %q (want YYYY-Qn)", s), s
+		}
+	case FieldYear:
+		if !yearRe.MatchString(s) {
+			return fmt.Sprintf("invalid year %q (want YYYY)", s), s
+		}
+	case FieldLink:
+		if !linkRe.MatchString(s) {
+			return fmt.Sprintf("invalid link %q (want [[Note]])", s), s
+		}
+	}
+	return "", s
+}
+
+// isNestedLink reports whether v is an unquoted [[Link]], which YAML reads as
+// a list inside a list.
+func isNestedLink(v []interface{}) bool {
+	if len(v) != 1 {
+		return false
+	}
+	inner, ok := v[0].([]interface{})
+	return ok && len(inner) == 1
+}
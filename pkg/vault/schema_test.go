@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaFor(t *testing.T) {
+	cases := []struct {
+		path string
+		fm   map[string]interface{}
+		want string
+	}{
+		{"1. Inbox/Idea.md", map[string]interface{}{"status": "inbox", "type": "project"}, "inbox"},
+		{"2. Next Actions/@calls/Call Bob.md", map[string]interface{}{"status": "next"}, "next-action"},
+		{"2. Next Actions/@waiting/Quote.md", map[string]interface{}{"status": "waiting"}, "waiting-for"},
+		{"Misc/Quote.md", map[string]interface{}{"status": "waiting", "waiting_for": "Bob"}, "waiting-for"},
+		{"3. Projects/Active/Website.md", map[string]interface{}{"status": "active"}, "project"},
+		{"Journal/2024-Q4.md", map[string]interface{}{"type": "quarterly-review"}, "quarterly-review"},
+		{"6. Weekly Reviews/2024-W47.md", map[string]interface{}{"created": "2024-11-20"}, "weekly-review"},
+		{"3. Projects/README.md", nil, ""},
+	}
+	for _, c := range cases {
+		s := SchemaFor(c.path, c.fm)
+		got := ""
+		if s != nil {
+			got = s.Name
+		}
+		if got != c.want {
+			t.Errorf("SchemaFor(%s) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestValidateNote(t *testing.T) {
+	note, err := ParseNote("2. Next Actions/@home/Fix sink.md", []byte(`---
+created: 2024-11-20
+status: [next/waiting/scheduled/delegated]
+context: "@garden"
+project: [[Home Repairs]]
+priority: asap
+due_date: next friday
+tags: []
+---
+# Fix sink
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm := note.Frontmatter.(map[string]interface{})
+
+	s, issues := ValidateNote(note.Path, fm)
+	if s == nil || s.Name != "next-action" {
+		t.Fatalf("unexpected schema %v", s)
+	}
+	got := map[string]string{}
+	for _, issue := range issues {
+		got[issue.Field] = issue.Message
+	}
+	for _, field := range []string{"status", "context", "priority", "due_date"} {
+		if got[field] == "" {
+			t.Errorf("expected an issue for %s, got %v", field, issues)
+		}
+	}
+	if !strings.Contains(got["status"], "placeholder") {
+		t.Errorf("status issue = %q", got["status"])
+	}
+	if _, ok := got["project"]; ok {
+		t.Errorf("unquoted wikilink should be valid: %s", got["project"])
+	}
+	if _, ok := got["created"]; ok {
+		t.Errorf("created should be valid: %s", got["created"])
+	}
+}
+
+func TestSchemaParse(t *testing.T) {
+	note, _ := ParseNote("Quote.md", []byte(`---
+created: 2024-11-20
+status: waiting
+waiting_for: Bob
+follow_up_date: 2024-11-27
+tags: [waiting-for]
+---
+`))
+	parsed, err := LookupSchema("waiting-for").Parse(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, ok := parsed.(*WaitingFor)
+	if !ok {
+		t.Fatalf("unexpected type %T", parsed)
+	}
+	if item.WaitingFor != "Bob" || item.FollowUpDate != "2024-11-27" || item.Created != "2024-11-20" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if len(item.Tags) != 1 || item.Tags[0] != "waiting-for" {
+		t.Errorf("unexpected tags %v", item.Tags)
+	}
+}
@@ -0,0 +1,341 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrSectionNotFound is returned when a document has no matching heading
+var ErrSectionNotFound = errors.New("section not found")
+
+// SectionPathSeparator separates nested headings in a section path, e.g.
+// "Project Review > Active Projects".
+const SectionPathSeparator = " > "
+
+// Section is a heading together with the lines up to the next heading of the
+// same or a higher level
+type Section struct {
+	Heading  Heading
+	Children []*Section
+
+	line   int // index of the heading line
+	ownEnd int // end of the section's own text, before the first child heading
+	end    int // end of the section including its children
+}
+
+// ChecklistItem is a Markdown task list item
+type ChecklistItem struct {
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+}
+
+// Document is the body of a Markdown note split into a heading tree. The
+// frontmatter is kept verbatim and never touched by section edits.
+type Document struct {
+	frontmatter string
+	lines       []string
+	sections    []*Section
+}
+
+var checklistRe = regexp.MustCompile(`^(\s*[-*+]\s+)\[([ xX])\]\s?(.*)$`)
+
+// ParseDocument parses note content, including any frontmatter.
+func ParseDocument(content string) *Document {
+	d := &Document{}
+	body := content
+	if _, _, b, ok := splitFrontmatter(content); ok {
+		body = b
+	}
+	d.frontmatter = content[:len(content)-len(body)]
+	d.lines = strings.Split(body, "\n")
+	d.parse()
+	return d
+}
+
+// EditSections reads the note at path, lets fn edit its sections and writes
+// it back if anything changed.
+func EditSections(path string, fn func(*Document) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	d := ParseDocument(string(data))
+	if err := fn(d); err != nil {
+		return err
+	}
+	out := d.String()
+	if out == string(data) {
+		return nil
+	}
+	return os.WriteFile(path, []byte(out), 0644)
+}
+
+// String renders the document back to Markdown.
+func (d *Document) String() string {
+	return d.frontmatter + strings.Join(d.lines, "\n")
+}
+
+// Sections returns the top-level sections of the heading tree.
+func (d *Document) Sections() []*Section {
+	return d.sections
+}
+
+// Section finds a section by heading text, case-insensitively. Nested
+// headings can be addressed with a path like "Project Review > Active Projects";
+// each component is searched at any depth below the previous one.
+func (d *Document) Section(heading string) *Section {
+	parts := strings.Split(heading, SectionPathSeparator)
+	candidates := d.sections
+	var found *Section
+	for _, part := range parts {
+		found = findSection(candidates, strings.TrimSpace(part))
+		if found == nil {
+			return nil
+		}
+		candidates = found.Children
+	}
+	return found
+}
+
+// findSection searches sections depth-first
+func findSection(sections []*Section, text string) *Section {
+	for _, s := range sections {
+		if strings.EqualFold(s.Heading.Text, text) {
+			return s
+		}
+		if found := findSection(s.Children, text); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Content returns the text below a heading, including its subsections.
+func (d *Document) Content(heading string) (string, bool) {
+	s := d.Section(heading)
+	if s == nil {
+		return "", false
+	}
+	return strings.Join(d.lines[s.line+1:s.end], "\n"), true
+}
+
+// Replace replaces everything below a heading, including its subsections,
+// keeping the blank lines that separate it from the next heading.
+func (d *Document) Replace(heading, body string) error {
+	s, err := d.find(heading)
+	if err != nil {
+		return err
+	}
+	start := s.line + 1
+	blanks := trailingBlankLines(d.lines[start:s.end])
+	repl := append(textLines(body), make([]string, blanks)...)
+	d.splice(start, s.end, repl)
+	return nil
+}
+
+// Append adds text at the end of a section's own content, before any
+// subsection headings.
+func (d *Document) Append(heading, text string) error {
+	s, err := d.find(heading)
+	if err != nil {
+		return err
+	}
+	at := s.ownEnd - trailingBlankLines(d.lines[s.line+1:s.ownEnd])
+	d.splice(at, at, textLines(text))
+	return nil
+}
+
+// Insert adds text at the top of a section, after any blank lines that
+// follow the heading.
+func (d *Document) Insert(heading, text string) error {
+	s, err := d.find(heading)
+	if err != nil {
+		return err
+	}
+	at := s.line + 1
+	for at < s.ownEnd && strings.TrimSpace(d.lines[at]) == "" {
+		at++
+	}
+	if at == s.ownEnd {
+		at = s.line + 1
+	}
+	d.splice(at, at, textLines(text))
+	return nil
+}
+
+// InsertAfter adds text below the first line of a section's own content that
+// reads after, e.g. the lead-in of a list. It inserts like Insert when no
+// line matches.
+func (d *Document) InsertAfter(heading, after, text string) error {
+	s, err := d.find(heading)
+	if err != nil {
+		return err
+	}
+	for i := s.line + 1; i < s.ownEnd; i++ {
+		if strings.TrimSpace(d.lines[i]) == after {
+			d.splice(i+1, i+1, textLines(text))
+			return nil
+		}
+	}
+	return d.Insert(heading, text)
+}
+
+// ChecklistItems returns the task list items in a section's own content.
+func (d *Document) ChecklistItems(heading string) ([]ChecklistItem, error) {
+	s, err := d.find(heading)
+	if err != nil {
+		return nil, err
+	}
+	var items []ChecklistItem
+	for _, line := range d.lines[s.line+1 : s.ownEnd] {
+		if m := checklistRe.FindStringSubmatch(line); m != nil {
+			items = append(items, ChecklistItem{Text: m[3], Checked: m[2] != " "})
+		}
+	}
+	return items, nil
+}
+
+// AddChecklistItem adds an unchecked "- [ ] text" item after the last task
+// list item of a section, or at the end of its content when it has none.
+func (d *Document) AddChecklistItem(heading, text string) error {
+	s, err := d.find(heading)
+	if err != nil {
+		return err
+	}
+	prefix, last := "", -1
+	for i := s.line + 1; i < s.ownEnd; i++ {
+		if m := checklistRe.FindStringSubmatch(d.lines[i]); m != nil {
+			if last < 0 {
+				prefix = m[1]
+			}
+			last = i
+		}
+	}
+	if last < 0 {
+		return d.Append(heading, "- [ ] "+text)
+	}
+	// Match the bullet of the first item so the new one is not nested
+	d.splice(last+1, last+1, []string{prefix + "[ ] " + text})
+	return nil
+}
+
+// TickChecklistItem checks the first unchecked task list item whose text
+// matches and returns its text. With keepOpen an unchecked copy is added right
+// below it, for tasks that come back.
+func (d *Document) TickChecklistItem(match func(text string) bool, keepOpen bool) (string, bool) {
+	for i, line := range d.lines {
+		m := checklistRe.FindStringSubmatch(line)
+		if m == nil || m[2] != " " || !match(m[3]) {
+			continue
+		}
+		ticked := m[1] + "[x] " + m[3]
+		if keepOpen {
+			d.splice(i, i+1, []string{ticked, line})
+		} else {
+			d.splice(i, i+1, []string{ticked})
+		}
+		return m[3], true
+	}
+	return "", false
+}
+
+// AppendSection adds a new section with the given heading level at the end
+// of the document.
+func (d *Document) AppendSection(level int, title, body string) {
+	end := len(d.lines) - trailingBlankLines(d.lines)
+	lines := []string{strings.Repeat("#", level) + " " + title}
+	if end > 0 {
+		lines = append([]string{""}, lines...)
+	}
+	lines = append(lines, textLines(body)...)
+	d.splice(end, len(d.lines), append(lines, ""))
+}
+
+func (d *Document) find(heading string) (*Section, error) {
+	if s := d.Section(heading); s != nil {
+		return s, nil
+	}
+	return nil, fmt.Errorf("%q: %w", heading, ErrSectionNotFound)
+}
+
+// splice replaces lines[start:end] and rebuilds the heading tree
+func (d *Document) splice(start, end int, repl []string) {
+	lines := make([]string, 0, len(d.lines)-(end-start)+len(repl))
+	lines = append(lines, d.lines[:start]...)
+	lines = append(lines, repl...)
+	lines = append(lines, d.lines[end:]...)
+	d.lines = lines
+	d.parse()
+}
+
+// parse builds the heading tree from the document lines, ignoring headings
+// inside fenced code blocks
+func (d *Document) parse() {
+	d.sections = nil
+	var stack []*Section
+	closeTo := func(level, at int) {
+		for len(stack) > 0 && stack[len(stack)-1].Heading.Level >= level {
+			stack[len(stack)-1].end = at
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	inFence := false
+	for i, line := range d.lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		m := headingRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		s := &Section{Heading: Heading{Level: len(m[1]), Text: m[2]}, line: i, ownEnd: -1}
+		closeTo(s.Heading.Level, i)
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			if parent.ownEnd < 0 {
+				parent.ownEnd = i
+			}
+			parent.Children = append(parent.Children, s)
+		} else {
+			d.sections = append(d.sections, s)
+		}
+		stack = append(stack, s)
+	}
+	closeTo(0, len(d.lines))
+	d.fixOwnEnd(d.sections)
+}
+
+func (d *Document) fixOwnEnd(sections []*Section) {
+	for _, s := range sections {
+		if s.ownEnd < 0 {
+			s.ownEnd = s.end
+		}
+		d.fixOwnEnd(s.Children)
+	}
+}
+
+// textLines splits text into lines, dropping a single trailing newline
+func textLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func trailingBlankLines(lines []string) int {
+	n := 0
+	for i := len(lines) - 1; i >= 0 && strings.TrimSpace(lines[i]) == ""; i-- {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,160 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sectionNote = `---
+type: weekly-review
+---
+
+# Weekly Review
+
+## Project Review
+### Active Projects
+Review each project for:
+- [ ] Clear next action identified
+- [x] Any stalled projects need attention
+
+### Someday/Maybe Review
+- [ ] Any items ready to activate?
+
+## Reflections
+What worked well?
+` + "```\n# not a heading\n```\n"
+
+func TestParseDocumentTree(t *testing.T) {
+	d := ParseDocument(sectionNote)
+	if d.String() != sectionNote {
+		t.Fatalf("round trip changed the note:\n%s", d.String())
+	}
+	top := d.Sections()
+	if len(top) != 1 || top[0].Heading.Text != "Weekly Review" {
+		t.Fatalf("top-level sections = %+v", top)
+	}
+	if n := len(top[0].Children); n != 2 {
+		t.Fatalf("expected 2 level-2 sections, got %d", n)
+	}
+	if s := d.Section("project review > someday/maybe review"); s == nil || s.Heading.Level != 3 {
+		t.Errorf("nested lookup failed: %+v", s)
+	}
+	if d.Section("not a heading") != nil {
+		t.Error("heading inside a code fence was parsed")
+	}
+
+	content, ok := d.Content("Active Projects")
+	if !ok || content != "Review each project for:\n- [ ] Clear next action identified\n- [x] Any stalled projects need attention\n" {
+		t.Errorf("Content = %q", content)
+	}
+	items, err := d.ChecklistItems("Active Projects")
+	if err != nil || len(items) != 2 || items[0].Checked || !items[1].Checked {
+		t.Errorf("ChecklistItems = %+v, %v", items, err)
+	}
+}
+
+func TestDocumentEdits(t *testing.T) {
+	d := ParseDocument(sectionNote)
+	if err := d.AddChecklistItem("Active Projects", "[[Website]]"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Insert("Reflections", "Shipped the release."); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Append("Someday/Maybe Review", "- [ ] Any items to remove?"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Replace("Project Review", "Nothing active."); err == nil {
+		content, _ := d.Content("Project Review")
+		if content != "Nothing active.\n" {
+			t.Errorf("Replace left %q", content)
+		}
+	} else {
+		t.Fatal(err)
+	}
+	d.AppendSection(2, "AI Insights", "Focus on one project.")
+
+	want := `---
+type: weekly-review
+---
+
+# Weekly Review
+
+## Project Review
+Nothing active.
+
+## Reflections
+Shipped the release.
+What worked well?
+` + "```\n# not a heading\n```\n" + `
+## AI Insights
+Focus on one project.
+`
+	if d.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", d.String(), want)
+	}
+
+	if err := d.Append("Missing", "x"); !errors.Is(err, ErrSectionNotFound) {
+		t.Errorf("expected ErrSectionNotFound, got %v", err)
+	}
+}
+
+func TestAddChecklistItemKeepsListOrder(t *testing.T) {
+	d := ParseDocument("## Tasks\nIntro\n\n- [ ] one\n  - [ ] nested\n\nTrailing text\n\n## Next\n")
+	if err := d.AddChecklistItem("Tasks", "two"); err != nil {
+		t.Fatal(err)
+	}
+	want := "## Tasks\nIntro\n\n- [ ] one\n  - [ ] nested\n- [ ] two\n\nTrailing text\n\n## Next\n"
+	if d.String() != want {
+		t.Errorf("got %q", d.String())
+	}
+
+	d = ParseDocument("## Empty\n\n## Next\n")
+	if err := d.AddChecklistItem("Empty", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if d.String() != "## Empty\n- [ ] first\n\n## Next\n" {
+		t.Errorf("got %q", d.String())
+	}
+}
+
+func TestInsertAfter(t *testing.T) {
+	d := ParseDocument(sectionNote)
+	if err := d.InsertAfter("Active Projects", "Review each project for:", "- [ ] [[Website]]"); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := d.Content("Active Projects")
+	if content != "Review each project for:\n- [ ] [[Website]]\n- [ ] Clear next action identified\n- [x] Any stalled projects need attention\n" {
+		t.Errorf("Content = %q", content)
+	}
+
+	// Without the line it inserts at the top of the section
+	if err := d.InsertAfter("Reflections", "Missing line", "Shipped the release."); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := d.Content("Reflections"); !strings.HasPrefix(content, "Shipped the release.\nWhat worked well?\n") {
+		t.Errorf("Content = %q", content)
+	}
+}
+
+func TestEditSections(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "note.md", "# Note\n\n## Description\nBrief description of the item\n\n## Notes\n")
+	path := filepath.Join(root, "note.md")
+	err := EditSections(path, func(d *Document) error {
+		return d.Replace("Description", "Call the plumber")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "# Note\n\n## Description\nCall the plumber\n\n## Notes\n" {
+		t.Errorf("content = %q", data)
+	}
+}
@@ -1,18 +1,31 @@
 package vault
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// ErrInvalidTemplateName is returned for a template name that would leave the
+// template directory
+var ErrInvalidTemplateName = errors.New("invalid template name")
+
 // TemplateEngine handles loading and rendering of Obsidian templates
 type TemplateEngine struct {
 	TemplateDir string
+	Clock       func() time.Time // time source for date placeholders; defaults to time.Now
 }
 
+// TemplateData holds the named variables available to a template
+type TemplateData map[string]interface{}
+
 // NewTemplateEngine creates a new TemplateEngine
 func NewTemplateEngine(templateDir string) *TemplateEngine {
 	return &TemplateEngine{
@@ -20,8 +33,20 @@ func NewTemplateEngine(templateDir string) *TemplateEngine {
 	}
 }
 
-// LoadTemplate reads a template file from the template directory
+// Now returns the current time according to the engine's clock
+func (e *TemplateEngine) Now() time.Time {
+	if e.Clock != nil {
+		return e.Clock()
+	}
+	return time.Now()
+}
+
+// LoadTemplate reads a template file from the template directory. The name
+// may not contain a path separator or "..".
 func (e *TemplateEngine) LoadTemplate(templateName string) (string, error) {
+	if templateName == "" || strings.ContainsAny(templateName, `/\`) || strings.Contains(templateName, "..") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTemplateName, templateName)
+	}
 	// Ensure extension
 	if !strings.HasSuffix(templateName, ".md") {
 		templateName += ".md"
@@ -35,57 +60,502 @@ func (e *TemplateEngine) LoadTemplate(templateName string) (string, error) {
 	return string(content), nil
 }
 
-// Render replaces placeholders in the template content
-// Supported placeholders:
-// {{title}} - Replaced with the provided title
-// {{date:FORMAT}} - Replaced with current date formatted according to FORMAT (e.g. YYYY-MM-DD)
+// RenderTemplate loads a template by name and renders it with data
+func (e *TemplateEngine) RenderTemplate(templateName string, data TemplateData) (string, error) {
+	tmpl, err := e.LoadTemplate(templateName)
+	if err != nil {
+		return "", err
+	}
+	return e.RenderWith(tmpl, data)
+}
+
+// Render replaces placeholders in the template content, using title as the
+// {{title}} variable. Content with malformed blocks is returned unchanged.
 func (e *TemplateEngine) Render(content string, title string) string {
-	// Replace {{title}}
-	content = strings.ReplaceAll(content, "{{title}}", title)
+	rendered, err := e.RenderWith(content, TemplateData{"title": title})
+	if err != nil {
+		log.Printf("Render template %q: %v", title, err)
+		return content
+	}
+	return rendered
+}
+
+// RenderWith renders template content with the given variables.
+//
+// Supported syntax:
+//
+//	{{title}}, {{name}}, {{a.b}}  - variables; time values accept {{name:FORMAT}}
+//	{{date}}, {{date:FORMAT}}     - current date, FORMAT is a Moment.js format (default YYYY-MM-DD)
+//	{{time}}, {{time:FORMAT}}     - current time (default HH:mm)
+//	{{date+7d:FORMAT}}            - offsets in y, Q, M, w, d, h, m or s
+//	{{yesterday}}, {{tomorrow}}   - relative dates
+//	{{monday:FORMAT}}             - a weekday of the current week
+//	{{#if x}}...{{else}}...{{/if}}, {{#unless x}}...{{/unless}}
+//	{{#each items}}{{this}} {{@index}} {{field}}{{/each}}
+//	<% tp.file.title %>, <% tp.date.now("FORMAT", 7) %>, tp.date.tomorrow,
+//	tp.date.yesterday and tp.date.weekday - a subset of Templater commands
+//
+// Unknown placeholders are left untouched so Obsidian can still expand them.
+func (e *TemplateEngine) RenderWith(content string, data TemplateData) (string, error) {
+	nodes, err := parseTemplate(content)
+	if err != nil {
+		return "", err
+	}
+	r := &templateRenderer{now: e.Now(), scopes: []templateScope{{value: map[string]interface{}(data)}}}
+	var b strings.Builder
+	r.render(&b, nodes)
+	return b.String(), nil
+}
+
+// --- Parsing ---
+
+var templateTagRe = regexp.MustCompile(`(?s)\{\{(.*?)\}\}`)
+
+type templateNode struct {
+	text string // literal text, or the raw tag of a placeholder
+
+	expr     string // placeholder expression; empty for text
+	block    string // "if", "unless" or "each" for blocks
+	body     []templateNode
+	elseBody []templateNode
+}
+
+type openBlock struct {
+	node     *templateNode
+	inElse   bool
+	children []templateNode
+}
+
+func parseTemplate(content string) ([]templateNode, error) {
+	stack := []*openBlock{{node: &templateNode{}}}
+	appendNode := func(n templateNode) {
+		top := stack[len(stack)-1]
+		top.children = append(top.children, n)
+	}
 
-	// Replace {{date:FORMAT}}
-	// Regex to find {{date:FORMAT}}
-	re := regexp.MustCompile(`\{\{date:(.*?)\}\}`)
+	last := 0
+	for _, m := range templateTagRe.FindAllStringSubmatchIndex(content, -1) {
+		start, end := m[0], m[1]
+		expr := strings.TrimSpace(content[m[2]:m[3]])
+		isBlock := strings.HasPrefix(expr, "#") || strings.HasPrefix(expr, "/") || expr == "else"
 
-	content = re.ReplaceAllStringFunc(content, func(match string) string {
-		// Extract format
-		parts := re.FindStringSubmatch(match)
-		if len(parts) < 2 {
-			return match
+		text := content[last:start]
+		if isBlock {
+			// Block tags alone on a line take the whole line with them
+			lineStart := strings.LastIndexByte(text, '\n') + 1
+			lineEnd := strings.IndexByte(content[end:], '\n')
+			if lineEnd < 0 {
+				lineEnd = len(content) - end
+			}
+			if strings.TrimSpace(text[lineStart:]) == "" && strings.TrimSpace(content[end:end+lineEnd]) == "" {
+				text = text[:lineStart]
+				end += lineEnd
+				if end < len(content) {
+					end++
+				}
+			}
 		}
-		format := parts[1]
+		if text != "" {
+			appendNode(templateNode{text: text})
+		}
+		last = end
 
-		// Convert Go time format
-		// This is a simplified conversion, might need more robust mapping
-		// Obsidian uses Moment.js format, Go uses reference time
-		goFormat := convertMomentToGoFormat(format)
+		switch {
+		case strings.HasPrefix(expr, "#"):
+			kind, arg, _ := strings.Cut(expr[1:], " ")
+			if kind != "if" && kind != "unless" && kind != "each" {
+				return nil, fmt.Errorf("unknown block {{%s}}", expr)
+			}
+			stack = append(stack, &openBlock{node: &templateNode{block: kind, expr: strings.TrimSpace(arg)}})
+		case expr == "else":
+			top := stack[len(stack)-1]
+			if len(stack) == 1 || top.inElse {
+				return nil, fmt.Errorf("unexpected {{else}}")
+			}
+			top.node.body, top.children, top.inElse = top.children, nil, true
+		case strings.HasPrefix(expr, "/"):
+			top := stack[len(stack)-1]
+			if len(stack) == 1 || top.node.block != strings.TrimSpace(expr[1:]) {
+				return nil, fmt.Errorf("unexpected {{%s}}", expr)
+			}
+			if top.inElse {
+				top.node.elseBody = top.children
+			} else {
+				top.node.body = top.children
+			}
+			stack = stack[:len(stack)-1]
+			appendNode(*top.node)
+		default:
+			appendNode(templateNode{text: content[start:end], expr: expr})
+		}
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("unclosed {{#%s %s}}", stack[len(stack)-1].node.block, stack[len(stack)-1].node.expr)
+	}
+	if last < len(content) {
+		appendNode(templateNode{text: content[last:]})
+	}
+	return stack[0].children, nil
+}
 
-		return time.Now().Format(goFormat)
-	})
+// --- Rendering ---
 
-	return content
+type templateScope struct {
+	value  interface{}
+	locals map[string]interface{} // @index, @key, @first, @last
 }
 
-// convertMomentToGoFormat converts simple Moment.js format strings to Go time format
-func convertMomentToGoFormat(format string) string {
-	format = strings.ReplaceAll(format, "YYYY", "2006")
-	format = strings.ReplaceAll(format, "MM", "01")
-	format = strings.ReplaceAll(format, "DD", "02")
-	format = strings.ReplaceAll(format, "HH", "15")
-	format = strings.ReplaceAll(format, "mm", "04")
-	format = strings.ReplaceAll(format, "ss", "05")
-	// Handle [W]WW for week number, Go doesn't support ISO week directly in Format easily without external lib or custom logic
-	// For now, let's just handle standard date parts.
-	// If we need week number, we might need a custom placeholder logic.
-	if strings.Contains(format, "W") {
-		// Fallback for week number if needed, or just leave it for now/implement later
-		// For this MVP, let's assume standard dates.
-		// Actually, let's handle the specific case of YYYY-[W]WW which was in the template
-		if format == "2006-[W]WW" {
-			y, w := time.Now().ISOWeek()
-			return fmt.Sprintf("%d-W%02d", y, w)
+type templateRenderer struct {
+	now    time.Time
+	scopes []templateScope
+}
+
+func (r *templateRenderer) render(b *strings.Builder, nodes []templateNode) {
+	for _, n := range nodes {
+		switch {
+		case n.block == "if" || n.block == "unless":
+			v, _ := r.lookup(n.expr)
+			if truthy(v) == (n.block == "if") {
+				r.render(b, n.body)
+			} else {
+				r.render(b, n.elseBody)
+			}
+		case n.block == "each":
+			v, _ := r.lookup(n.expr)
+			if !r.each(b, v, n.body) {
+				r.render(b, n.elseBody)
+			}
+		case n.expr != "":
+			if s, ok := r.placeholder(n.expr); ok {
+				b.WriteString(s)
+			} else {
+				b.WriteString(n.text)
+			}
+		default:
+			// Templater commands are expanded in the template text only, so
+			// variable values that look like commands stay as they are.
+			b.WriteString(r.templater(n.text))
 		}
 	}
+}
 
-	return format
+// each renders body once per element of a slice or map and reports whether
+// there was anything to iterate over.
+func (r *templateRenderer) each(b *strings.Builder, v interface{}, body []templateNode) bool {
+	rv := reflect.ValueOf(v)
+	type item struct {
+		key   interface{}
+		value interface{}
+	}
+	var items []item
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, item{i, rv.Index(i).Interface()})
+		}
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			items = append(items, item{k.Interface(), rv.MapIndex(k).Interface()})
+		}
+	}
+	for i, it := range items {
+		r.scopes = append(r.scopes, templateScope{value: it.value, locals: map[string]interface{}{
+			"@index": i,
+			"@key":   it.key,
+			"@first": i == 0,
+			"@last":  i == len(items)-1,
+		}})
+		r.render(b, body)
+		r.scopes = r.scopes[:len(r.scopes)-1]
+	}
+	return len(items) > 0
+}
+
+var (
+	dateTagRe    = regexp.MustCompile(`(?i)^(date|time)\s*(?:([+-]\d+)([yqmwdhs]))?\s*(?::(.+))?$`)
+	weekdayTagRe = regexp.MustCompile(`(?i)^(sunday|monday|tuesday|wednesday|thursday|friday|saturday)\s*(?::(.+))?$`)
+	varTagRe     = regexp.MustCompile(`^(@?[\w-]+(?:\.[\w-]+)*)\s*(?::(.+))?$`)
+)
+
+// placeholder evaluates a {{...}} expression. Variables take precedence over
+// the built-in date placeholders.
+func (r *templateRenderer) placeholder(expr string) (string, bool) {
+	if m := varTagRe.FindStringSubmatch(expr); m != nil {
+		if v, ok := r.lookup(m[1]); ok {
+			return formatTemplateValue(v, m[2]), true
+		}
+	}
+	if m := dateTagRe.FindStringSubmatch(expr); m != nil {
+		format := strings.TrimSpace(m[4])
+		if format == "" {
+			format = "YYYY-MM-DD"
+			if strings.EqualFold(m[1], "time") {
+				format = "HH:mm"
+			}
+		}
+		t := r.now
+		if m[2] != "" {
+			n, _ := strconv.Atoi(m[2])
+			t = addMomentOffset(t, n, m[3])
+		}
+		return FormatMoment(t, format), true
+	}
+	switch strings.ToLower(expr) {
+	case "yesterday":
+		return FormatMoment(r.now.AddDate(0, 0, -1), "YYYY-MM-DD"), true
+	case "tomorrow":
+		return FormatMoment(r.now.AddDate(0, 0, 1), "YYYY-MM-DD"), true
+	}
+	if m := weekdayTagRe.FindStringSubmatch(expr); m != nil {
+		format := strings.TrimSpace(m[2])
+		if format == "" {
+			format = "YYYY-MM-DD"
+		}
+		return FormatMoment(weekdayOf(r.now, weekdayIndex(m[1])), format), true
+	}
+	return "", false
+}
+
+// lookup resolves a dotted variable path, searching the innermost scope first
+func (r *templateRenderer) lookup(path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		scope := r.scopes[i]
+		var v interface{}
+		var ok bool
+		switch {
+		case parts[0] == "this":
+			v, ok = scope.value, true
+		case strings.HasPrefix(parts[0], "@"):
+			v, ok = scope.locals[parts[0]]
+		default:
+			v, ok = field(scope.value, parts[0])
+		}
+		if !ok {
+			continue
+		}
+		for _, p := range parts[1:] {
+			if v, ok = field(v, p); !ok {
+				return nil, false
+			}
+		}
+		return v, true
+	}
+	return nil, false
+}
+
+// field returns a map entry or struct field by name. Struct fields also
+// match their json tag, case-insensitively.
+func field(v interface{}, name string) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		val := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !val.IsValid() {
+			return nil, false
+		}
+		return val.Interface(), true
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if strings.EqualFold(f.Name, name) || (tag != "" && strings.EqualFold(tag, name)) {
+				return rv.Field(i).Interface(), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func truthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if t, ok := v.(time.Time); ok {
+		return !t.IsZero()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return rv.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !rv.IsNil()
+	}
+	return !rv.IsZero()
+}
+
+// formatTemplateValue renders a variable. Times use the Moment format when
+// given, slices are joined with commas.
+func formatTemplateValue(v interface{}, format string) string {
+	format = strings.TrimSpace(format)
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		if format == "" {
+			format = "YYYY-MM-DD"
+		}
+		return FormatMoment(val, format)
+	case *time.Time:
+		if val == nil {
+			return ""
+		}
+		return formatTemplateValue(*val, format)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			parts[i] = formatTemplateValue(rv.Index(i).Interface(), format)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+// addMomentOffset adds n units to t like Moment's add(). Month arithmetic
+// clamps to the end of the month instead of overflowing.
+func addMomentOffset(t time.Time, n int, unit string) time.Time {
+	switch unit {
+	case "M":
+		return addMonths(t, n)
+	case "m":
+		return t.Add(time.Duration(n) * time.Minute)
+	}
+	switch strings.ToLower(unit) {
+	case "y":
+		return addMonths(t, 12*n)
+	case "q":
+		return addMonths(t, 3*n)
+	case "w":
+		return t.AddDate(0, 0, 7*n)
+	case "d":
+		return t.AddDate(0, 0, n)
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour)
+	case "s":
+		return t.Add(time.Duration(n) * time.Second)
+	}
+	return t
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).AddDate(0, n, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// weekdayOf returns the given weekday (0 = Sunday) of the week containing t,
+// with weeks starting on Sunday as in Moment's default locale.
+func weekdayOf(t time.Time, weekday int) time.Time {
+	return t.AddDate(0, 0, weekday-int(t.Weekday()))
+}
+
+func weekdayIndex(name string) int {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return int(d)
+		}
+	}
+	return 0
+}
+
+// --- Templater ---
+
+var (
+	templaterTagRe  = regexp.MustCompile(`<%[-_]?\s*(.*?)\s*[-_]?%>`)
+	templaterCallRe = regexp.MustCompile(`^(tp\.[\w.]+)\s*(?:\((.*)\))?$`)
+)
+
+// templater expands the supported Templater commands and leaves the rest as-is
+func (r *templateRenderer) templater(content string) string {
+	if !strings.Contains(content, "<%") {
+		return content
+	}
+	return templaterTagRe.ReplaceAllStringFunc(content, func(tag string) string {
+		expr := templaterTagRe.FindStringSubmatch(tag)[1]
+		m := templaterCallRe.FindStringSubmatch(expr)
+		if m == nil {
+			return tag
+		}
+		args := splitTemplaterArgs(m[2])
+		arg := func(i int, def string) string {
+			if i < len(args) && args[i] != "" {
+				return args[i]
+			}
+			return def
+		}
+		switch m[1] {
+		case "tp.file.title":
+			if v, ok := field(r.scopes[0].value, "title"); ok {
+				return formatTemplateValue(v, "")
+			}
+		case "tp.date.now":
+			t := r.now
+			if n, err := strconv.Atoi(arg(1, "0")); err == nil {
+				t = t.AddDate(0, 0, n)
+			}
+			return FormatMoment(t, arg(0, "YYYY-MM-DD"))
+		case "tp.date.tomorrow":
+			return FormatMoment(r.now.AddDate(0, 0, 1), arg(0, "YYYY-MM-DD"))
+		case "tp.date.yesterday":
+			return FormatMoment(r.now.AddDate(0, 0, -1), arg(0, "YYYY-MM-DD"))
+		case "tp.date.weekday":
+			weekday, err := strconv.Atoi(arg(1, "0"))
+			if err != nil {
+				return tag
+			}
+			return FormatMoment(weekdayOf(r.now, weekday), arg(0, "YYYY-MM-DD"))
+		}
+		return tag
+	})
+}
+
+// splitTemplaterArgs splits a JavaScript argument list of string and number
+// literals, removing the quotes.
+func splitTemplaterArgs(s string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteByte(c)
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == ',':
+			args = append(args, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if rest := strings.TrimSpace(cur.String()); rest != "" || len(args) > 0 {
+		args = append(args, rest)
+	}
+	return args
 }
@@ -0,0 +1,141 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixedEngine(t time.Time) *TemplateEngine {
+	e := NewTemplateEngine("")
+	e.Clock = func() time.Time { return t }
+	return e
+}
+
+func TestFormatMoment(t *testing.T) {
+	ts := time.Date(2024, time.March, 2, 14, 5, 9, 0, time.UTC) // Saturday
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"YYYY-MM-DD", "2024-03-02"},
+		{"ddd, MMMM Do", "Sat, March 2nd"},
+		{"dddd MMM D YY", "Saturday Mar 2 24"},
+		{"YYYY-[W]WW", "2024-W09"},
+		{"YYYY-[Q]Q", "2024-Q1"},
+		{"h:mm A", "2:05 PM"},
+		{"HH:mm:ss", "14:05:09"},
+		{"DDDD [day]", "062 day"},
+		{"[Week] w, gggg", "Week 9, 2024"},
+		{"E e", "6 6"},
+	}
+	for _, tt := range tests {
+		if got := FormatMoment(ts, tt.format); got != tt.want {
+			t.Errorf("FormatMoment(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMomentWeekYear(t *testing.T) {
+	// 2021-01-01 belongs to ISO week 53 of 2020 but to locale week 1 of 2021
+	ts := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if got := FormatMoment(ts, "GGGG-[W]WW"); got != "2020-W53" {
+		t.Errorf("ISO week = %q", got)
+	}
+	if got := FormatMoment(ts, "gggg-ww"); got != "2021-01" {
+		t.Errorf("locale week = %q", got)
+	}
+}
+
+func TestRenderDatePlaceholders(t *testing.T) {
+	e := fixedEngine(time.Date(2024, time.January, 31, 9, 30, 0, 0, time.UTC)) // Wednesday
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{{date}}", "2024-01-31"},
+		{"{{time}}", "09:30"},
+		{"{{date+7d:YYYY-MM-DD}}", "2024-02-07"},
+		{"{{date-1w}}", "2024-01-24"},
+		{"{{date+1M:YYYY-MM-DD}}", "2024-02-29"},
+		{"{{time+45m:HH:mm}}", "10:15"},
+		{"{{ date : dddd }}", "Wednesday"},
+		{"{{yesterday}} {{tomorrow}}", "2024-01-30 2024-02-01"},
+		{"{{monday:MM-DD}}", "01-29"},
+		{`<% tp.date.now("YYYY-MM-DD", 1) %>`, "2024-02-01"},
+		{`<% tp.date.weekday("ddd D", 0) %>`, "Sun 28"},
+		{"<% tp.file.title %>", "Note"},
+		{"<% tp.user.custom() %> {{unknown}}", "<% tp.user.custom() %> {{unknown}}"},
+		{"{{body}}", "<% tp.date.now() %>"},
+	}
+	for _, tt := range tests {
+		got, err := e.RenderWith(tt.tmpl, TemplateData{"title": "Note", "body": "<% tp.date.now() %>"})
+		if err != nil {
+			t.Fatalf("RenderWith(%q): %v", tt.tmpl, err)
+		}
+		if got != tt.want {
+			t.Errorf("RenderWith(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestRenderVariablesAndBlocks(t *testing.T) {
+	e := fixedEngine(time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC))
+	tmpl := `# {{title}}
+{{#if project}}
+Project: [[{{project.name}}]] due {{project.due:MMM D}}
+{{else}}
+No project
+{{/if}}
+{{#each actions}}
+- [ ] {{@index}}. {{text}} {{context}}
+{{else}}
+Nothing to do
+{{/each}}
+{{#unless tags}}untagged{{/unless}}`
+
+	data := TemplateData{
+		"title": "Plan",
+		"project": map[string]interface{}{
+			"name": "Website",
+			"due":  time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC),
+		},
+		"actions": []struct {
+			Text    string `json:"text"`
+			Context string
+		}{
+			{"Draft copy", "@computer"},
+			{"Call Anna", "@phone"},
+		},
+	}
+	got, err := e.RenderWith(tmpl, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Plan
+Project: [[Website]] due Feb 15
+- [ ] 0. Draft copy @computer
+- [ ] 1. Call Anna @phone
+untagged`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, _ = e.RenderWith(tmpl, TemplateData{"title": "Empty", "tags": []string{"x"}})
+	if !strings.Contains(got, "No project\nNothing to do\n") || strings.Contains(got, "untagged") {
+		t.Errorf("else branches not rendered:\n%s", got)
+	}
+}
+
+func TestRenderMalformedBlocks(t *testing.T) {
+	e := NewTemplateEngine("")
+	for _, tmpl := range []string{"{{#if x}}open", "{{/each}}", "{{#if x}}{{/each}}", "{{#with x}}{{/with}}"} {
+		if _, err := e.RenderWith(tmpl, nil); err == nil {
+			t.Errorf("RenderWith(%q) succeeded", tmpl)
+		}
+	}
+	// Render keeps the content when it cannot be parsed
+	if got := e.Render("{{#if x}}{{title}}", "T"); got != "{{#if x}}{{title}}" {
+		t.Errorf("Render = %q", got)
+	}
+}
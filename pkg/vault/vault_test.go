@@ -93,3 +93,36 @@ func TestReadWriteNote(t *testing.T) {
 		t.Errorf("Content mismatch. Got: %s", readNote.Content)
 	}
 }
+
+func TestCreateInboxNoteKeepsExistingItem(t *testing.T) {
+	root := t.TempDir()
+	tmplDir := filepath.Join(root, "templates")
+	if err := os.MkdirAll(tmplDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmplDir, "Inbox Item Template.md"), []byte("---\nstatus: inbox\n---\n# {{title}}\n\n## Description\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewTemplateEngine(tmplDir)
+
+	first, err := CreateInboxNote(root, engine, "Call Bob", "about the fence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := CreateInboxNote(root, engine, "Call Bob", "about the roof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != filepath.Join(InboxFolder, "Call Bob.md") || second != filepath.Join(InboxFolder, "Call Bob 2.md") {
+		t.Fatalf("paths = %q, %q", first, second)
+	}
+	for rel, want := range map[string]string{first: "about the fence", second: "about the roof"} {
+		data, err := ioutil.ReadFile(filepath.Join(root, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, want %q", rel, data, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package vault
+
+import (
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WaitingFolder holds the waiting-for notes
+const WaitingFolder = NextActionsFolder + "/@waiting"
+
+// WaitingItem is something delegated to or expected from someone else
+type WaitingItem struct {
+	Path          string `json:"path"`
+	Title         string `json:"title"`
+	WaitingFor    string `json:"waiting_for"`
+	Email         string `json:"email,omitempty"`
+	Project       string `json:"project,omitempty"`
+	DateRequested string `json:"date_requested,omitempty"`
+	FollowUpDate  string `json:"follow_up_date,omitempty"`
+	DaysWaiting   int    `json:"days_waiting"` // since date_requested, or created
+	DaysOverdue   int    `json:"days_overdue"` // since follow_up_date
+	Overdue       bool   `json:"overdue"`      // the follow-up date has come
+}
+
+// WaitingFilter selects waiting-for items. Empty fields match everything.
+type WaitingFilter struct {
+	Person  string // part of waiting_for, any case
+	Project string
+	Overdue bool // only items whose follow-up is due
+}
+
+// IsWaiting reports whether a note is an open waiting-for item
+func IsWaiting(n *IndexedNote) bool {
+	s := SchemaFor(n.Path, n.Frontmatter)
+	return s != nil && s.Name == "waiting-for" && n.Status() != "done" && !InFolder(n.Path, ActionArchiveFolder)
+}
+
+// NewWaitingItem reads an indexed waiting-for note as of now. The email is
+// the email field, or the address in waiting_for such as
+// "Bob <bob@example.com>"; an invalid address is dropped.
+func NewWaitingItem(n IndexedNote, now time.Time) WaitingItem {
+	w := WaitingItem{
+		Path:          n.Path,
+		Title:         n.Title,
+		WaitingFor:    n.String("waiting_for"),
+		Email:         parseEmail(n.String("email")),
+		Project:       n.Project(),
+		DateRequested: n.String("date_requested"),
+		FollowUpDate:  n.String("follow_up_date"),
+	}
+	if w.Email == "" && strings.Contains(w.WaitingFor, "@") {
+		w.Email = parseEmail(w.WaitingFor)
+	}
+	today := truncateDay(now)
+	requested, ok := parseDate(w.DateRequested)
+	if !ok {
+		requested, ok = parseDate(n.String("created"))
+	}
+	if ok {
+		w.DaysWaiting = max(daysBetween(requested, today), 0)
+	}
+	if followUp, ok := parseDate(w.FollowUpDate); ok && !followUp.After(today) {
+		w.Overdue = true
+		w.DaysOverdue = daysBetween(followUp, today)
+	}
+	return w
+}
+
+// ListWaiting returns the open waiting-for items matching f as of now. Items
+// with a follow-up date come first, earliest first, then the rest by how
+// long they have been waiting.
+func ListWaiting(x *Index, f WaitingFilter, now time.Time) []WaitingItem {
+	var out []WaitingItem
+	for _, n := range x.All() {
+		if !IsWaiting(&n) {
+			continue
+		}
+		if f.Project != "" && !strings.EqualFold(n.Project(), StripLink(f.Project)) {
+			continue
+		}
+		w := NewWaitingItem(n, now)
+		if f.Person != "" && !strings.Contains(strings.ToLower(w.WaitingFor), strings.ToLower(f.Person)) {
+			continue
+		}
+		if f.Overdue && !w.Overdue {
+			continue
+		}
+		out = append(out, w)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if (a.FollowUpDate != "") != (b.FollowUpDate != "") {
+			return a.FollowUpDate != ""
+		}
+		if a.FollowUpDate != b.FollowUpDate {
+			return a.FollowUpDate < b.FollowUpDate
+		}
+		if a.DaysWaiting != b.DaysWaiting {
+			return a.DaysWaiting > b.DaysWaiting
+		}
+		return a.Title < b.Title
+	})
+	return out
+}
+
+// parseEmail returns the address in s, or "" when s is not a valid address
+func parseEmail(s string) string {
+	if s == "" {
+		return ""
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return ""
+	}
+	return addr.Address
+}
+
+// daysBetween counts the calendar days from a to b
+func daysBetween(a, b time.Time) int {
+	a = time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	b = time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
@@ -0,0 +1,76 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListWaiting(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "2. Next Actions/@waiting/Quote.md", "---\nstatus: waiting\nwaiting_for: Bob Builder <bob@example.com>\ndate_requested: 2026-05-01\nfollow_up_date: 2026-05-08\nproject: \"[[Garden]]\"\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@waiting/Invoice.md", "---\nstatus: waiting\nwaiting_for: Accounting\nemail: billing@example.com\ndate_requested: 2026-05-05\nfollow_up_date: 2026-05-10\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@waiting/Contract.md", "---\nstatus: waiting\nwaiting_for: Legal\ncreated: 2026-04-20\nfollow_up_date: 2026-06-01\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@waiting/Keys.md", "---\nstatus: waiting\nwaiting_for: Alice\ndate_requested: 2026-04-01\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@waiting/Forged.md", "---\nstatus: waiting\nwaiting_for: Mallory\nemail: \"mallory@example.com\\r\\nBcc: all@example.com\"\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@waiting/Returned.md", "---\nstatus: done\nwaiting_for: Bob\nfollow_up_date: 2026-05-01\n---\n")
+	writeTestFile(t, root, "2. Next Actions/@calls/Call Bob.md", "---\nstatus: next\n---\n")
+	idx := NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 5, 10, 15, 0, 0, 0, time.UTC)
+	all := ListWaiting(idx, WaitingFilter{}, now)
+	var titles []string
+	for _, w := range all {
+		titles = append(titles, w.Title)
+	}
+	if len(all) != 5 || all[0].Title != "Quote" || all[1].Title != "Invoice" || all[2].Title != "Contract" || all[3].Title != "Keys" || all[4].Title != "Forged" {
+		t.Fatalf("unexpected order: %v", titles)
+	}
+	quote := all[0]
+	if quote.Email != "bob@example.com" || quote.Project != "Garden" || quote.DaysWaiting != 9 || !quote.Overdue || quote.DaysOverdue != 2 {
+		t.Errorf("quote = %+v", quote)
+	}
+	if invoice := all[1]; invoice.Email != "billing@example.com" || !invoice.Overdue || invoice.DaysOverdue != 0 {
+		t.Errorf("a follow-up due today is overdue: %+v", invoice)
+	}
+	if contract := all[2]; contract.Overdue || contract.DaysWaiting != 20 {
+		t.Errorf("contract = %+v", contract)
+	}
+	if forged := all[4]; forged.Email != "" {
+		t.Errorf("an invalid email field must be dropped: %q", forged.Email)
+	}
+
+	if overdue := ListWaiting(idx, WaitingFilter{Overdue: true}, now); len(overdue) != 2 {
+		t.Errorf("expected 2 overdue items, got %+v", overdue)
+	}
+	if bob := ListWaiting(idx, WaitingFilter{Person: "bob"}, now); len(bob) != 1 || bob[0].Title != "Quote" {
+		t.Errorf("person filter = %+v", bob)
+	}
+	if garden := ListWaiting(idx, WaitingFilter{Project: "[[Garden]]"}, now); len(garden) != 1 {
+		t.Errorf("project filter = %+v", garden)
+	}
+}
+
+func TestCompletedWaitingForPassesLint(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "2. Next Actions/@waiting/Quote.md", "---\nstatus: waiting\nwaiting_for: Bob\nfollow_up_date: 2026-05-08\n---\n# Quote\n")
+	idx := NewIndex(root, nil)
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCompleter(idx, nil)
+	c.now = func() time.Time { return time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC) }
+	if _, err := c.Complete("2. Next Actions/@waiting/Quote.md", false); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	n, _ := idx.Get("2. Next Actions/@waiting/Quote.md")
+	if IsWaiting(&n) || n.String("completed") != "2026-05-10" {
+		t.Errorf("completed item = %+v", n.Frontmatter)
+	}
+	if report := Lint(idx, LintOptions{Now: c.now()}); len(report.Issues) != 0 {
+		t.Errorf("unexpected lint issues: %+v", report.Issues)
+	}
+}
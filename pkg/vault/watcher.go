@@ -0,0 +1,259 @@
+package vault
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChangeKind describes what happened to a note on disk
+type ChangeKind string
+
+const (
+	ChangeCreated  ChangeKind = "created"
+	ChangeModified ChangeKind = "modified"
+	ChangeRenamed  ChangeKind = "renamed"
+	ChangeDeleted  ChangeKind = "deleted"
+)
+
+// ChangeEvent is a debounced change to a note. Paths are relative to the vault root.
+type ChangeEvent struct {
+	Kind    ChangeKind
+	Path    string
+	OldPath string // set for ChangeRenamed
+}
+
+// watchBackend delivers raw change events from the platform file notification API.
+// Events the backend holds back, like the first half of a rename, are given
+// up on the next flush through expire; run calls wake to make sure that flush
+// happens.
+type watchBackend interface {
+	run(emit func(ChangeEvent), wake func()) error // blocks until close is called
+	expire(emit func(ChangeEvent))
+	close() error
+}
+
+// Watcher publishes debounced change events for markdown notes in the vault.
+// Subscribers are called in the order they subscribed, one batch at a time,
+// so the index can be subscribed first and be fresh for everyone after it.
+type Watcher struct {
+	vaultPath string
+	debounce  time.Duration
+	maxWait   time.Duration
+
+	mu          sync.Mutex
+	subscribers []func([]ChangeEvent)
+	pending     map[string]*ChangeEvent
+	order       []string
+	firstAt     time.Time
+	timer       *time.Timer
+
+	backend watchBackend
+	runDone chan struct{}
+
+	sendMu  sync.Mutex
+	closed  bool
+	batches chan []ChangeEvent
+	wg      sync.WaitGroup
+}
+
+// NewWatcher creates a watcher that waits for debounce of quiet time before
+// publishing a batch of changes.
+func NewWatcher(vaultPath string, debounce time.Duration) *Watcher {
+	if debounce <= 0 {
+		debounce = time.Second
+	}
+	return &Watcher{
+		vaultPath: vaultPath,
+		debounce:  debounce,
+		maxWait:   10 * debounce,
+		pending:   make(map[string]*ChangeEvent),
+		batches:   make(chan []ChangeEvent, 64),
+	}
+}
+
+// Subscribe registers fn to receive every batch of changes.
+func (w *Watcher) Subscribe(fn func([]ChangeEvent)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Start begins watching the vault.
+func (w *Watcher) Start() error {
+	backend, err := newWatchBackend(w.vaultPath)
+	if err != nil {
+		return err
+	}
+	w.backend = backend
+	w.runDone = make(chan struct{})
+
+	go func() {
+		defer close(w.runDone)
+		if err := backend.run(w.add, w.wake); err != nil {
+			log.Printf("Vault watcher stopped: %v", err)
+		}
+	}()
+	w.wg.Add(1)
+	go w.dispatch()
+	return nil
+}
+
+// Stop stops watching and waits for pending batches to be delivered.
+func (w *Watcher) Stop() {
+	if w.backend == nil {
+		return
+	}
+	w.backend.close()
+	<-w.runDone
+
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	w.flush()
+
+	w.sendMu.Lock()
+	w.closed = true
+	close(w.batches)
+	w.sendMu.Unlock()
+	w.wg.Wait()
+}
+
+func (w *Watcher) dispatch() {
+	defer w.wg.Done()
+	for batch := range w.batches {
+		w.mu.Lock()
+		subs := append([]func([]ChangeEvent){}, w.subscribers...)
+		w.mu.Unlock()
+		for _, fn := range subs {
+			fn(batch)
+		}
+	}
+}
+
+// add merges a raw event into the pending batch and (re)arms the debounce timer.
+func (w *Watcher) add(e ChangeEvent) {
+	if !isNotePath(e.Path) && !(e.Kind == ChangeRenamed && isNotePath(e.OldPath)) {
+		return
+	}
+	if e.Kind == ChangeRenamed && !isNotePath(e.OldPath) {
+		// A temp file renamed over a note is a save of that note.
+		e = ChangeEvent{Kind: ChangeModified, Path: e.Path}
+	} else if e.Kind == ChangeRenamed && !isNotePath(e.Path) {
+		e = ChangeEvent{Kind: ChangeDeleted, Path: e.OldPath}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.merge(e)
+	w.arm()
+}
+
+// wake arms the debounce timer without adding an event.
+func (w *Watcher) wake() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.arm()
+}
+
+func (w *Watcher) arm() {
+	if w.timer == nil {
+		w.firstAt = time.Now()
+		w.timer = time.AfterFunc(w.debounce, w.flush)
+	} else if time.Since(w.firstAt) < w.maxWait {
+		w.timer.Reset(w.debounce)
+	}
+}
+
+func (w *Watcher) merge(e ChangeEvent) {
+	prev := w.pending[e.Path]
+	switch e.Kind {
+	case ChangeCreated:
+		if prev != nil && prev.Kind == ChangeDeleted {
+			e.Kind = ChangeModified
+		}
+	case ChangeModified:
+		if prev != nil && prev.Kind != ChangeDeleted {
+			return
+		}
+	case ChangeDeleted:
+		if prev != nil && prev.Kind == ChangeCreated {
+			w.drop(e.Path)
+			return
+		}
+		if prev != nil && prev.Kind == ChangeRenamed {
+			w.drop(e.Path)
+			e.Path = prev.OldPath
+		}
+	case ChangeRenamed:
+		if old := w.pending[e.OldPath]; old != nil {
+			w.drop(e.OldPath)
+			if old.Kind == ChangeCreated {
+				e = ChangeEvent{Kind: ChangeCreated, Path: e.Path}
+			} else if old.Kind == ChangeRenamed {
+				e.OldPath = old.OldPath
+			}
+		}
+	}
+
+	if _, exists := w.pending[e.Path]; !exists {
+		w.order = append(w.order, e.Path)
+	}
+	w.pending[e.Path] = &e
+}
+
+func (w *Watcher) drop(path string) {
+	delete(w.pending, path)
+	for i, p := range w.order {
+		if p == path {
+			w.order = append(w.order[:i], w.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (w *Watcher) flush() {
+	if w.backend != nil {
+		w.backend.expire(w.add)
+	}
+
+	w.mu.Lock()
+	if w.timer != nil {
+		// Expired events re-armed the timer; they go out with this batch.
+		w.timer.Stop()
+	}
+	w.timer = nil
+	if len(w.order) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	batch := make([]ChangeEvent, 0, len(w.order))
+	for _, p := range w.order {
+		batch = append(batch, *w.pending[p])
+	}
+	w.pending = make(map[string]*ChangeEvent)
+	w.order = nil
+	w.mu.Unlock()
+
+	w.sendMu.Lock()
+	defer w.sendMu.Unlock()
+	if !w.closed {
+		w.batches <- batch
+	}
+}
+
+func isNotePath(relPath string) bool {
+	if relPath == "" || !strings.HasSuffix(relPath, ".md") {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
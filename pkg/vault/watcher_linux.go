@@ -0,0 +1,306 @@
+//go:build linux
+
+package vault
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotifyBackend watches every directory of the vault with one inotify instance.
+type inotifyBackend struct {
+	root string
+	fd   int
+	file *os.File
+
+	mu        sync.Mutex
+	dirs      map[int]string         // watch descriptor -> vault-relative dir ("" is the root)
+	known     map[string]bool        // vault-relative paths of files seen so far
+	moves     map[uint32]pendingMove // MOVED_FROMs waiting for their MOVED_TO, by cookie
+	moveOrder []uint32
+}
+
+type pendingMove struct {
+	path  string
+	isDir bool
+}
+
+func newWatchBackend(vaultPath string) (watchBackend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+	b := &inotifyBackend{
+		root:  vaultPath,
+		fd:    fd,
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		dirs:  make(map[int]string),
+		known: make(map[string]bool),
+		moves: make(map[uint32]pendingMove),
+	}
+	if err := b.addTree("", nil); err != nil {
+		b.file.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *inotifyBackend) close() error {
+	return b.file.Close()
+}
+
+// expire reports the files moved out of the vault. The two halves of a rename
+// can arrive in different reads, so a MOVED_FROM is only given up when the
+// debounce flush finds it still unpaired.
+func (b *inotifyBackend) expire(emit func(ChangeEvent)) {
+	b.mu.Lock()
+	var gone []pendingMove
+	for _, cookie := range b.moveOrder {
+		if from, ok := b.moves[cookie]; ok {
+			gone = append(gone, from)
+		}
+	}
+	b.moves = make(map[uint32]pendingMove)
+	b.moveOrder = nil
+	b.mu.Unlock()
+
+	for _, from := range gone {
+		b.removed(from, emit)
+	}
+}
+
+func (b *inotifyBackend) run(emit func(ChangeEvent), wake func()) error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("inotify read: %w", err)
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[offset:])))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			cookie := binary.NativeEndian.Uint32(buf[offset+8:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+nameLen]), "\x00")
+			offset = nameStart + nameLen
+
+			if mask&syscall.IN_Q_OVERFLOW != 0 {
+				// Subscribers that mirror the vault elsewhere, like the Drive
+				// backup and the Calendar push, also run periodic passes to
+				// catch the changes lost here.
+				log.Printf("Vault watcher: inotify queue overflow, some changes were missed")
+				continue
+			}
+			if mask&syscall.IN_IGNORED != 0 {
+				b.forgetWatch(wd)
+				continue
+			}
+
+			b.mu.Lock()
+			dir, ok := b.dirs[wd]
+			b.mu.Unlock()
+			if !ok || name == "" {
+				continue
+			}
+			rel := filepath.Join(dir, name)
+			isDir := mask&syscall.IN_ISDIR != 0
+			if isDir && strings.HasPrefix(name, ".") {
+				continue
+			}
+
+			switch {
+			case mask&syscall.IN_MOVED_FROM != 0:
+				b.mu.Lock()
+				b.moves[cookie] = pendingMove{path: rel, isDir: isDir}
+				b.moveOrder = append(b.moveOrder, cookie)
+				b.mu.Unlock()
+			case mask&syscall.IN_MOVED_TO != 0:
+				b.mu.Lock()
+				from, paired := b.moves[cookie]
+				delete(b.moves, cookie)
+				b.mu.Unlock()
+				if paired {
+					b.renamed(from, rel, emit)
+				} else {
+					b.created(rel, isDir, emit)
+				}
+			case mask&syscall.IN_CREATE != 0:
+				b.created(rel, isDir, emit)
+			case mask&syscall.IN_DELETE != 0:
+				if !isDir {
+					b.setKnown(rel, false)
+					emit(ChangeEvent{Kind: ChangeDeleted, Path: rel})
+				}
+			case mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0:
+				if !isDir {
+					b.setKnown(rel, true)
+					emit(ChangeEvent{Kind: ChangeModified, Path: rel})
+				}
+			}
+		}
+
+		// Anything still waiting for its MOVED_TO is expired on the next flush.
+		b.mu.Lock()
+		waiting := len(b.moves) > 0
+		b.mu.Unlock()
+		if waiting {
+			wake()
+		}
+	}
+}
+
+// addTree adds watches for dir and all its subdirectories. Notes found are
+// reported through emit when it is non-nil.
+func (b *inotifyBackend) addTree(dir string, emit func(ChangeEvent)) error {
+	return filepath.Walk(filepath.Join(b.root, dir), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, path)
+		if err != nil {
+			return nil
+		}
+		if rel == "." {
+			rel = ""
+		}
+		if info.IsDir() {
+			if rel != "" && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			wd, err := syscall.InotifyAddWatch(b.fd, path, inotifyMask)
+			if err != nil {
+				if rel == "" {
+					return fmt.Errorf("watch %s: %w", path, err)
+				}
+				log.Printf("Vault watcher: watch %s: %v", rel, err)
+				return nil
+			}
+			b.mu.Lock()
+			b.dirs[wd] = rel
+			b.mu.Unlock()
+			return nil
+		}
+		b.setKnown(rel, true)
+		if emit != nil {
+			emit(ChangeEvent{Kind: ChangeCreated, Path: rel})
+		}
+		return nil
+	})
+}
+
+func (b *inotifyBackend) created(rel string, isDir bool, emit func(ChangeEvent)) {
+	if isDir {
+		if err := b.addTree(rel, emit); err != nil {
+			log.Printf("Vault watcher: %v", err)
+		}
+		return
+	}
+	kind := ChangeCreated
+	if b.isKnown(rel) {
+		kind = ChangeModified
+	}
+	b.setKnown(rel, true)
+	emit(ChangeEvent{Kind: kind, Path: rel})
+}
+
+func (b *inotifyBackend) renamed(from pendingMove, to string, emit func(ChangeEvent)) {
+	if !from.isDir {
+		b.setKnown(from.path, false)
+		b.setKnown(to, true)
+		emit(ChangeEvent{Kind: ChangeRenamed, Path: to, OldPath: from.path})
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for wd, dir := range b.dirs {
+		if newDir, ok := rebase(dir, from.path, to); ok {
+			b.dirs[wd] = newDir
+		}
+	}
+	var moved []string
+	for p := range b.known {
+		if _, ok := rebase(p, from.path, to); ok {
+			moved = append(moved, p)
+		}
+	}
+	for _, p := range moved {
+		newPath, _ := rebase(p, from.path, to)
+		delete(b.known, p)
+		b.known[newPath] = true
+		emit(ChangeEvent{Kind: ChangeRenamed, Path: newPath, OldPath: p})
+	}
+}
+
+func (b *inotifyBackend) removed(from pendingMove, emit func(ChangeEvent)) {
+	if !from.isDir {
+		b.setKnown(from.path, false)
+		emit(ChangeEvent{Kind: ChangeDeleted, Path: from.path})
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for wd, dir := range b.dirs {
+		if _, ok := rebase(dir, from.path, from.path); ok {
+			syscall.InotifyRmWatch(b.fd, uint32(wd))
+			delete(b.dirs, wd)
+		}
+	}
+	for p := range b.known {
+		if _, ok := rebase(p, from.path, from.path); ok {
+			delete(b.known, p)
+			emit(ChangeEvent{Kind: ChangeDeleted, Path: p})
+		}
+	}
+}
+
+func (b *inotifyBackend) forgetWatch(wd int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.dirs, wd)
+}
+
+func (b *inotifyBackend) isKnown(rel string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.known[rel]
+}
+
+func (b *inotifyBackend) setKnown(rel string, known bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if known {
+		b.known[rel] = true
+	} else {
+		delete(b.known, rel)
+	}
+}
+
+// rebase moves p from under oldDir to under newDir. It reports false when p
+// is not oldDir or inside it.
+func rebase(p, oldDir, newDir string) (string, bool) {
+	if p == oldDir {
+		return newDir, true
+	}
+	prefix := oldDir + string(filepath.Separator)
+	if strings.HasPrefix(p, prefix) {
+		return filepath.Join(newDir, strings.TrimPrefix(p, prefix)), true
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+//go:build !linux
+
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const pollInterval = 2 * time.Second
+
+// pollBackend detects changes by comparing directory snapshots. It is used on
+// platforms without inotify and cannot tell renames from delete+create.
+type pollBackend struct {
+	root   string
+	stopCh chan struct{}
+	last   map[string]fileStamp
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func newWatchBackend(vaultPath string) (watchBackend, error) {
+	if _, err := os.Stat(vaultPath); err != nil {
+		return nil, err
+	}
+	b := &pollBackend{root: vaultPath, stopCh: make(chan struct{})}
+	b.last = b.snapshot()
+	return b, nil
+}
+
+func (b *pollBackend) close() error {
+	close(b.stopCh)
+	return nil
+}
+
+func (b *pollBackend) expire(emit func(ChangeEvent)) {}
+
+func (b *pollBackend) run(emit func(ChangeEvent), wake func()) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			current := b.snapshot()
+			for p, stamp := range current {
+				prev, ok := b.last[p]
+				if !ok {
+					emit(ChangeEvent{Kind: ChangeCreated, Path: p})
+				} else if prev != stamp {
+					emit(ChangeEvent{Kind: ChangeModified, Path: p})
+				}
+			}
+			for p := range b.last {
+				if _, ok := current[p]; !ok {
+					emit(ChangeEvent{Kind: ChangeDeleted, Path: p})
+				}
+			}
+			b.last = current
+		case <-b.stopCh:
+			return nil
+		}
+	}
+}
+
+func (b *pollBackend) snapshot() map[string]fileStamp {
+	out := make(map[string]fileStamp)
+	filepath.Walk(b.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != b.root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(info.Name(), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, path)
+		if err == nil {
+			out[rel] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+	return out
+}
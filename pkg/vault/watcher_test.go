@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherMergesBursts(t *testing.T) {
+	w := NewWatcher(t.TempDir(), time.Hour)
+	w.merge(ChangeEvent{Kind: ChangeCreated, Path: "a.md"})
+	w.merge(ChangeEvent{Kind: ChangeModified, Path: "a.md"})
+	w.merge(ChangeEvent{Kind: ChangeModified, Path: "b.md"})
+	w.merge(ChangeEvent{Kind: ChangeDeleted, Path: "b.md"})
+	w.merge(ChangeEvent{Kind: ChangeCreated, Path: "tmp.md"})
+	w.merge(ChangeEvent{Kind: ChangeDeleted, Path: "tmp.md"})
+	w.merge(ChangeEvent{Kind: ChangeRenamed, Path: "c.md", OldPath: "a.md"})
+
+	if len(w.order) != 2 {
+		t.Fatalf("expected 2 pending events, got %v", w.order)
+	}
+	if e := w.pending["b.md"]; e == nil || e.Kind != ChangeDeleted {
+		t.Errorf("b.md = %+v, want deleted", e)
+	}
+	if e := w.pending["c.md"]; e == nil || e.Kind != ChangeCreated {
+		t.Errorf("c.md = %+v, want created (a.md was new)", e)
+	}
+}
+
+func TestWatcherPublishesChanges(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "1. Inbox"), 0755)
+
+	w := NewWatcher(root, 50*time.Millisecond)
+	batches := make(chan []ChangeEvent, 10)
+	w.Subscribe(func(events []ChangeEvent) { batches <- events })
+	if err := w.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer w.Stop()
+
+	next := func() ChangeEvent {
+		t.Helper()
+		select {
+		case b := <-batches:
+			if len(b) != 1 {
+				t.Fatalf("expected 1 event, got %+v", b)
+			}
+			return b[0]
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for change event")
+		}
+		return ChangeEvent{}
+	}
+
+	notePath := filepath.Join(root, "1. Inbox", "Idea.md")
+	os.WriteFile(notePath, []byte("# Idea"), 0644)
+	if e := next(); e.Kind != ChangeCreated || e.Path != filepath.Join("1. Inbox", "Idea.md") {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	renamed := filepath.Join(root, "1. Inbox", "Better Idea.md")
+	os.Rename(notePath, renamed)
+	if e := next(); e.Kind != ChangeRenamed || e.Path != filepath.Join("1. Inbox", "Better Idea.md") || e.OldPath != filepath.Join("1. Inbox", "Idea.md") {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	os.Remove(renamed)
+	if e := next(); e.Kind != ChangeDeleted || e.Path != filepath.Join("1. Inbox", "Better Idea.md") {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+func TestWatcherNoteMovedOutOfVault(t *testing.T) {
+	root := t.TempDir()
+	notePath := filepath.Join(root, "Idea.md")
+	os.WriteFile(notePath, []byte("# Idea"), 0644)
+
+	w := NewWatcher(root, 50*time.Millisecond)
+	batches := make(chan []ChangeEvent, 10)
+	w.Subscribe(func(events []ChangeEvent) { batches <- events })
+	if err := w.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer w.Stop()
+
+	os.Rename(notePath, filepath.Join(t.TempDir(), "Idea.md"))
+	select {
+	case b := <-batches:
+		if len(b) != 1 || b[0].Kind != ChangeDeleted || b[0].Path != "Idea.md" {
+			t.Errorf("unexpected batch %+v", b)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change event")
+	}
+}
@@ -5,22 +5,21 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
-// WriteNote writes a note to the specified path
+// WriteNote writes a note to the specified path. Notes read with ReadNote keep
+// their original frontmatter formatting; only changed fields are rewritten.
 func WriteNote(note *Note) error {
-	// Marshal Frontmatter
-	fmData, err := yaml.Marshal(note.Frontmatter)
+	content, err := renderNote(note)
 	if err != nil {
-		return fmt.Errorf("failed to marshal frontmatter: %w", err)
+		return err
 	}
 
-	// Construct file content
-	content := fmt.Sprintf("---\n%s---\n%s", string(fmData), note.Content)
-
 	// Ensure directory exists
 	dir := filepath.Dir(note.Path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -28,48 +27,107 @@ func WriteNote(note *Note) error {
 	}
 
 	// Write file
-	if err := ioutil.WriteFile(note.Path, []byte(content), 0644); err != nil {
+	if err := ioutil.WriteFile(note.Path, content, 0644); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+func renderNote(note *Note) ([]byte, error) {
+	if fm, ok := note.Frontmatter.(map[string]interface{}); ok && note.source != nil {
+		if content, err := renderEdited(note, fm); err == nil {
+			return content, nil
+		}
+	}
+
+	// Marshal Frontmatter
+	fmData, err := yaml.Marshal(note.Frontmatter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal frontmatter: %w", err)
+	}
+
+	// Construct file content
+	return []byte(fmt.Sprintf("---\n%s---\n%s", string(fmData), note.Content)), nil
+}
+
+// renderEdited applies the note's changes on top of the file it was read from.
+func renderEdited(note *Note, fm map[string]interface{}) ([]byte, error) {
+	original, err := ParseNote(note.Path, note.source)
+	if err != nil {
+		return nil, err
+	}
+	origFM, _ := original.Frontmatter.(map[string]interface{})
+
+	editor, err := ParseFrontmatter(note.source)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyFrontmatter(editor, origFM, fm); err != nil {
+		return nil, err
+	}
+	if note.Content != original.Content {
+		editor.SetBody(note.Content)
+	}
+	return editor.Bytes()
+}
+
 // CreateInboxItem creates a new inbox item from a template
 func CreateInboxItem(vaultPath string, templateEngine *TemplateEngine, title string, content string) error {
+	_, err := CreateInboxNote(vaultPath, templateEngine, title, content)
+	return err
+}
+
+// CreateInboxNote creates a new inbox item from a template and returns its
+// path relative to the vault.
+func CreateInboxNote(vaultPath string, templateEngine *TemplateEngine, title string, content string) (string, error) {
 	// Load Template
 	tmpl, err := templateEngine.LoadTemplate("Inbox Item Template")
 	if err != nil {
-		return fmt.Errorf("failed to load template: %w", err)
+		return "", fmt.Errorf("failed to load template: %w", err)
 	}
 
 	// Render Template
 	rendered := templateEngine.Render(tmpl, title)
 
-	// Parse rendered content to separate frontmatter (if we need to modify it programmatically)
-	// For now, let's just append the user content if the template has a specific place,
-	// or just assume the template is the starting point.
-	// The template engine returns the full file content (FM + Body).
-
-	// If we want to inject specific content into the "Description" or "Notes" section,
-	// we might need a more sophisticated parser or just simple string replacement.
-	// For MVP, let's assume the "Description" in the template is a placeholder or we just append.
-
-	// Let's replace "Brief description of the item" with actual content if provided
 	if content != "" {
-		rendered = strings.Replace(rendered, "Brief description of the item", content, 1)
+		doc := ParseDocument(rendered)
+		if err := doc.Replace("Description", content); err != nil {
+			doc.AppendSection(2, "Description", content)
+		}
+		rendered = doc.String()
 	}
 
 	// Generate Filename (sanitize title)
-	filename := SanitizeFilename(title) + ".md"
-	path := filepath.Join(vaultPath, "1. Inbox", filename)
+	rel := filepath.Join(InboxFolder, SanitizeFilename(title)+".md")
 
 	// Write to file
 	// We can just write the rendered string directly since it already has FM.
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return err
+	if err := os.MkdirAll(filepath.Join(vaultPath, InboxFolder), 0755); err != nil {
+		return "", err
+	}
+	return createExclusive(vaultPath, rel, []byte(rendered))
+}
+
+// createExclusive writes data to a new note at rel, or at rel with a number
+// added when a note exists there already, and returns the path it used
+func createExclusive(vaultPath, rel string, data []byte) (string, error) {
+	base := strings.TrimSuffix(rel, ".md")
+	for i := 2; ; i++ {
+		f, err := os.OpenFile(filepath.Join(vaultPath, rel), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			rel = base + " " + strconv.Itoa(i) + ".md"
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return "", err
+		}
+		return rel, f.Close()
 	}
-	return ioutil.WriteFile(path, []byte(rendered), 0644)
 }
 
 // SanitizeFilename removes characters invalid in filenames.
@@ -81,3 +139,14 @@ func SanitizeFilename(name string) string {
 	}
 	return name
 }
+
+// CompleteNextAction marks the next action at relPath done on the given day
+func CompleteNextAction(vaultPath, relPath string, day time.Time) error {
+	return EditFrontmatter(filepath.Join(vaultPath, relPath), func(e *FrontmatterEditor) error {
+		if err := e.Set("status", "done"); err != nil {
+			return err
+		}
+		// Keep the date unquoted, as Obsidian writes it
+		return e.Set("completed", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: day.Format("2006-01-02")})
+	})
+}